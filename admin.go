@@ -0,0 +1,659 @@
+package LingEcho
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultAdminPageSize = 20
+	adminTemplate        = "admin/console.html"
+	adminCSRFCookie      = "lingecho_admin_csrf"
+	adminCSRFField       = "_csrf"
+	adminVersionField    = "_version"
+	adminFilterPrefix    = "f."
+)
+
+var (
+	ErrAdminNotFound      = errors.New("not found")
+	ErrAdminNotAllowed    = errors.New("method not allowed")
+	ErrAdminInvalidCSRF   = errors.New("invalid csrf token, reload the page and retry")
+	ErrAdminInvalidNumber = errors.New("invalid number")
+	ErrAdminInvalidTime   = errors.New("invalid time")
+)
+
+// Admin serves a server-rendered console of the WebObjects for the staff,
+// without a frontend. Each object gets the pages by its AllowMethods:
+//   - QUERY: the list, paginated, filtered by Filterables (LIKE for the
+//     strings, = for the others), ordered by Orderables and searched by
+//     Searchables
+//   - CREATE, EDIT: the forms of the Editables
+//   - DELETE: the confirmation of the delete
+//
+// The pages go through GetDB, the Before* hooks and the validation as the
+// routes do, the writes are recorded and invalidate the cache. The console
+// has no access control of its own besides AuthRequired, mount it on a
+// group restricted to the staff. The forms are protected from CSRF by a
+// double-submit cookie.
+type Admin struct {
+	Title    string // "Admin" by default
+	PageSize int    // DefaultAdminPageSize by default
+	// Authenticated report whether the request is made by a signed-in user,
+	// the objects with AuthRequired are rejected without one. Without it,
+	// they can't be accessed at all.
+	Authenticated func(c *gin.Context) bool
+
+	base      string
+	objects   []*adminObject
+	templates *CombineTemplates
+}
+
+type adminObject struct {
+	obj     *WebObject
+	doc     WebObjectDoc
+	columns []DocField // the scalar fields listed
+	inputs  []DocField // the fields of the forms
+}
+
+// adminPage is the data of the console template, rendered by Page.
+type adminPage struct {
+	Title   string
+	Base    string
+	Page    string // "index", "list", "form", "delete" or "error"
+	Objects []adminLink
+	CSRF    string
+
+	Name   string
+	Desc   string
+	Error  string
+	Status int
+
+	// list
+	Columns    []string
+	Rows       []adminRow
+	Filters    []adminInput
+	Orders     []string
+	Order      string
+	Descending bool
+	Keyword    string
+	Searchable bool
+	Total      int
+	From, To   int
+	PrevURL    string
+	NextURL    string
+	CreateURL  string
+
+	// form and delete
+	Action  string
+	IsNew   bool
+	Version string
+	Fields  []adminInput
+	Record  []adminCell
+}
+
+type adminLink struct {
+	Name, Desc, URL string
+}
+
+type adminRow struct {
+	Cells     []string
+	EditURL   string
+	DeleteURL string
+}
+
+type adminCell struct {
+	Name, Value string
+}
+
+type adminInput struct {
+	Name     string
+	Label    string
+	Type     string // the type of the input: "text", "number", "checkbox" or "select"
+	Value    string
+	Options  []string // of the select
+	Required bool
+	Error    string
+}
+
+// NewAdmin build the console of objs, the objects are built if not yet registered.
+func NewAdmin(objs []WebObject) (*Admin, error) {
+	a := &Admin{
+		templates: NewCombineTemplates(NewCombineEmbedFS(HintAssetsRoot("templates"), EmbedFS{"templates", EmbedTemplates})),
+	}
+	names := map[string]bool{}
+	for idx := range objs {
+		obj := &objs[idx]
+		if obj.modelElem == nil {
+			if err := obj.Build(); err != nil {
+				return nil, err
+			}
+		}
+		if names[obj.Name] {
+			return nil, fmt.Errorf("%s: duplicate object name", obj.Name)
+		}
+		names[obj.Name] = true
+		a.objects = append(a.objects, newAdminObject(obj))
+	}
+	return a, nil
+}
+
+func newAdminObject(obj *WebObject) *adminObject {
+	o := &adminObject{obj: obj, doc: GetWebObjectDocDefine("", *obj)}
+	skipped := map[string]bool{}
+	for _, name := range []string{obj.TenantField, obj.VersionField} {
+		if name != "" {
+			skipped[name] = true
+		}
+	}
+	for _, f := range o.doc.Fields {
+		if f.IsArray || f.Type == TYPE_OBJECT || f.Type == TYPE_MAP || f.Type == "" {
+			continue
+		}
+		o.columns = append(o.columns, f)
+		if f.IsPrimary || skipped[f.FieldName] || !slices.Contains(o.doc.Editables, f.Name) {
+			continue
+		}
+		o.inputs = append(o.inputs, f)
+	}
+	return o
+}
+
+// RegisterHandler serve the console at r.
+func (a *Admin) RegisterHandler(r *gin.RouterGroup) {
+	a.base = strings.TrimSuffix(r.BasePath(), "/")
+	r.GET("/", a.handleIndex)
+	r.GET("/:object", a.handleList)
+	r.GET("/:object/new", a.handleForm)
+	r.POST("/:object/new", a.handleForm)
+	r.GET("/:object/edit", a.handleForm)
+	r.POST("/:object/edit", a.handleForm)
+	r.GET("/:object/delete", a.handleDelete)
+	r.POST("/:object/delete", a.handleDelete)
+}
+
+func (a *Admin) handleIndex(c *gin.Context) {
+	a.render(c, http.StatusOK, &adminPage{Page: "index"})
+}
+
+func (a *Admin) handleList(c *gin.Context) {
+	o := a.object(c, QUERY)
+	if o == nil {
+		return
+	}
+	obj := o.obj
+	page := &adminPage{Page: "list", Name: obj.Name, Desc: obj.Desc}
+
+	prepare := func(db *gorm.DB, c *gin.Context) (*gorm.DB, *QueryForm, error) {
+		return db, a.queryForm(o, c, page), nil
+	}
+	db, form, err := obj.prepareQueryForm(c, prepare)
+	if err != nil {
+		a.fail(c, http.StatusBadRequest, err)
+		return
+	}
+	r, err := obj.queryObjects(db, c, form)
+	if err != nil {
+		a.fail(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	allowMethods := obj.getAllowMethods()
+	for _, f := range o.columns {
+		page.Columns = append(page.Columns, f.Name)
+	}
+	for _, item := range r.Items {
+		values := adminValues(item)
+		row := adminRow{}
+		for _, f := range o.columns {
+			row.Cells = append(row.Cells, adminFormat(values[f.Name]))
+		}
+		if key := o.keyQuery(values); key != "" {
+			if allowMethods&EDIT != 0 && len(o.inputs) > 0 && len(obj.Editables) > 0 {
+				row.EditURL = a.url(obj.Name, "edit") + "?" + key
+			}
+			if allowMethods&DELETE != 0 {
+				row.DeleteURL = a.url(obj.Name, "delete") + "?" + key
+			}
+		}
+		page.Rows = append(page.Rows, row)
+	}
+
+	page.Total = r.TotalCount
+	if len(r.Items) > 0 {
+		page.From, page.To = form.Pos+1, form.Pos+len(r.Items)
+	}
+	if form.Pos > 0 {
+		page.PrevURL = a.pageURL(c, obj.Name, max(form.Pos-form.Limit, 0))
+	}
+	if page.To < page.Total {
+		page.NextURL = a.pageURL(c, obj.Name, page.To)
+	}
+	if allowMethods&CREATE != 0 && len(o.inputs) > 0 {
+		page.CreateURL = a.url(obj.Name, "new")
+	}
+	a.render(c, http.StatusOK, page)
+}
+
+// queryForm build the QueryForm of the list from the query string, and fill
+// the inputs of page. prepareQueryForm strips it to the whitelists.
+func (a *Admin) queryForm(o *adminObject, c *gin.Context, page *adminPage) *QueryForm {
+	form := &QueryForm{Limit: a.pageSize()}
+	form.Pos, _ = strconv.Atoi(c.Query("pos"))
+	if form.Pos < 0 {
+		form.Pos = 0
+	}
+
+	for _, f := range o.fields(o.doc.Filters) {
+		input := adminInput{Name: adminFilterPrefix + f.Name, Label: f.Name, Value: c.Query(adminFilterPrefix + f.Name)}
+		filter := Filter{Name: f.Name, Op: FilterOpEqual, Value: input.Value}
+		switch f.Type {
+		case TYPE_STRING:
+			input.Type = "text"
+			filter.Op = FilterOpLike
+		case TYPE_BOOLEAN:
+			input.Type = "select"
+			input.Options = []string{"true", "false"}
+			filter.Value = input.Value == "true"
+		case TYPE_INT, TYPE_FLOAT:
+			input.Type = "number"
+		case TYPE_DATE:
+			input.Type = "text"
+			input.Label = f.Name + " ≥"
+			filter.Op = FilterOpGreaterOrEqual
+		default:
+			continue
+		}
+		page.Filters = append(page.Filters, input)
+		if input.Value != "" {
+			form.Filters = append(form.Filters, filter)
+		}
+	}
+
+	for _, f := range o.fields(o.doc.Orders) {
+		page.Orders = append(page.Orders, f.Name)
+	}
+	if order := c.Query("order"); order != "" && slices.Contains(page.Orders, order) {
+		page.Order, page.Descending = order, c.Query("desc") == "1"
+		op := OrderOpAsc
+		if page.Descending {
+			op = OrderOpDesc
+		}
+		form.Orders = []Order{{Name: order, Op: op}}
+	}
+
+	page.Searchable = len(o.doc.Searches) > 0 || (o.obj.SearchIndex != nil && o.obj.SearchIndex.Keyword)
+	if page.Searchable {
+		page.Keyword = strings.TrimSpace(c.Query("keyword"))
+		form.Keyword = page.Keyword
+	}
+	return form
+}
+
+// handleForm serve the form creating a record on "new", and the form editing
+// the record of the keys in the query string on "edit".
+func (a *Admin) handleForm(c *gin.Context) {
+	isNew := strings.HasSuffix(c.FullPath(), "/new")
+	method := EDIT
+	if isNew {
+		method = CREATE
+	}
+	o := a.object(c, method)
+	if o == nil {
+		return
+	}
+	obj := o.obj
+	if len(o.inputs) == 0 || (!isNew && len(obj.Editables) == 0) {
+		a.fail(c, http.StatusMethodNotAllowed, ErrAdminNotAllowed)
+		return
+	}
+	page := &adminPage{Page: "form", Name: obj.Name, Desc: obj.Desc, IsNew: isNew, Action: c.Request.URL.RequestURI()}
+
+	var keys []string
+	var values map[string]any
+	if !isNew {
+		var err error
+		if keys, err = o.keys(c); err != nil {
+			a.fail(c, http.StatusBadRequest, err)
+			return
+		}
+		val, err := o.load(c, keys)
+		if err != nil {
+			a.failLoad(c, err)
+			return
+		}
+		values = adminValues(val)
+		page.Version = obj.getETag(val)
+	}
+
+	if c.Request.Method == http.MethodPost {
+		inputVals, fieldErrs := o.parseForm(c)
+		var err error
+		if len(fieldErrs) == 0 {
+			if isNew {
+				err = o.create(c, inputVals)
+			} else {
+				err = obj.editObject(obj.getDB(c, false), c, keys, inputVals, c.PostForm(adminVersionField))
+			}
+		}
+		if len(fieldErrs) == 0 && err == nil {
+			obj.afterCommit(c)
+			c.Redirect(http.StatusSeeOther, a.url(obj.Name))
+			return
+		}
+
+		// render the form again with the values posted
+		status := http.StatusUnprocessableEntity
+		for _, e := range fieldErrors(err) {
+			fieldErrs[e.Field] = e.Message
+		}
+		switch {
+		case errors.Is(err, ErrPreconditionFailed), errors.Is(err, ErrPreconditionRequired):
+			status = http.StatusConflict
+			page.Error = err.Error()
+		case err != nil && fieldErrors(err) == nil:
+			status = http.StatusBadRequest
+			page.Error = err.Error()
+		}
+		page.Fields = o.formInputs(c.PostForm, fieldErrs)
+		page.Version = c.PostForm(adminVersionField)
+		a.render(c, status, page)
+		return
+	}
+
+	page.Fields = o.formInputs(func(name string) string {
+		return adminFormat(values[name])
+	}, nil)
+	a.render(c, http.StatusOK, page)
+}
+
+func (a *Admin) handleDelete(c *gin.Context) {
+	o := a.object(c, DELETE)
+	if o == nil {
+		return
+	}
+	obj := o.obj
+	keys, err := o.keys(c)
+	if err != nil {
+		a.fail(c, http.StatusBadRequest, err)
+		return
+	}
+
+	val, err := o.load(c, keys)
+	if err != nil {
+		a.failLoad(c, err)
+		return
+	}
+
+	if c.Request.Method == http.MethodPost {
+		if err := obj.deleteObject(obj.getDB(c, false), c, keys); err != nil {
+			a.fail(c, http.StatusBadRequest, err)
+			return
+		}
+		obj.afterCommit(c)
+		c.Redirect(http.StatusSeeOther, a.url(obj.Name))
+		return
+	}
+
+	page := &adminPage{Page: "delete", Name: obj.Name, Desc: obj.Desc, Action: c.Request.URL.RequestURI()}
+	values := adminValues(val)
+	for _, f := range o.columns {
+		page.Record = append(page.Record, adminCell{Name: f.Name, Value: adminFormat(values[f.Name])})
+	}
+	a.render(c, http.StatusOK, page)
+}
+
+// object return the object of the request, or render the error and return
+// nil if it's missing, not allowed, or the request is not authorized.
+func (a *Admin) object(c *gin.Context, method int) *adminObject {
+	var o *adminObject
+	for _, v := range a.objects {
+		if v.obj.Name == c.Param("object") {
+			o = v
+			break
+		}
+	}
+	if o == nil {
+		a.fail(c, http.StatusNotFound, ErrAdminNotFound)
+		return nil
+	}
+	if o.obj.getAllowMethods()&method == 0 {
+		a.fail(c, http.StatusMethodNotAllowed, ErrAdminNotAllowed)
+		return nil
+	}
+	if o.obj.AuthRequired && (a.Authenticated == nil || !a.Authenticated(c)) {
+		a.fail(c, http.StatusUnauthorized, ErrAuthRequired)
+		return nil
+	}
+	if o.obj.TenantField != "" {
+		if _, err := o.obj.tenantValue(c); err != nil {
+			a.fail(c, http.StatusBadRequest, err)
+			return nil
+		}
+	}
+	if c.Request.Method == http.MethodPost && !a.checkCSRF(c) {
+		a.fail(c, http.StatusForbidden, ErrAdminInvalidCSRF)
+		return nil
+	}
+	return o
+}
+
+// csrfToken return the token of the double-submit cookie, set a new one if missing.
+func (a *Admin) csrfToken(c *gin.Context) string {
+	if token, err := c.Cookie(adminCSRFCookie); err == nil && token != "" {
+		return token
+	}
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	token := hex.EncodeToString(buf)
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(adminCSRFCookie, token, 0, a.base+"/", "", c.Request.TLS != nil, true)
+	return token
+}
+
+func (a *Admin) checkCSRF(c *gin.Context) bool {
+	token, err := c.Cookie(adminCSRFCookie)
+	if err != nil || token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(c.PostForm(adminCSRFField))) == 1
+}
+
+func (a *Admin) render(c *gin.Context, status int, page *adminPage) {
+	page.Title = a.Title
+	if page.Title == "" {
+		page.Title = "Admin"
+	}
+	page.Base = a.base
+	page.CSRF = a.csrfToken(c)
+	for _, o := range a.objects {
+		if o.obj.getAllowMethods()&QUERY != 0 {
+			page.Objects = append(page.Objects, adminLink{Name: o.obj.Name, Desc: o.obj.Desc, URL: a.url(o.obj.Name)})
+		}
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Render(status, a.templates.Instance(adminTemplate, page))
+}
+
+func (a *Admin) fail(c *gin.Context, status int, err error) {
+	a.render(c, status, &adminPage{Page: "error", Status: status, Error: err.Error()})
+}
+
+// failLoad render the error of loading a record, 404 if not found.
+func (a *Admin) failLoad(c *gin.Context, err error) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		a.fail(c, http.StatusNotFound, ErrAdminNotFound)
+		return
+	}
+	a.fail(c, http.StatusBadRequest, err)
+}
+
+func (a *Admin) url(parts ...string) string {
+	return a.base + "/" + strings.Join(parts, "/")
+}
+
+// pageURL return the list URL of the request at pos.
+func (a *Admin) pageURL(c *gin.Context, name string, pos int) string {
+	query := c.Request.URL.Query()
+	query.Set("pos", strconv.Itoa(pos))
+	return a.url(name) + "?" + query.Encode()
+}
+
+func (a *Admin) pageSize() int {
+	if a.PageSize > 0 {
+		return min(a.PageSize, DefaultQueryLimit)
+	}
+	return DefaultAdminPageSize
+}
+
+// fields return the doc fields of the struct field names, in order.
+func (o *adminObject) fields(names []string) []DocField {
+	var result []DocField
+	for _, name := range names {
+		for _, f := range o.columns {
+			if f.FieldName == name {
+				result = append(result, f)
+			}
+		}
+	}
+	return result
+}
+
+// keys return the primary values of the query string.
+func (o *adminObject) keys(c *gin.Context) ([]string, error) {
+	var result []string
+	for _, field := range o.obj.uniqueKeys {
+		v := c.Query(field.JSONName)
+		if v == "" {
+			return nil, fmt.Errorf("invalid primary: %s", field.JSONName)
+		}
+		result = append(result, v)
+	}
+	return result, nil
+}
+
+// keyQuery return the query string of the primary values of the record values.
+func (o *adminObject) keyQuery(values map[string]any) string {
+	query := url.Values{}
+	for _, field := range o.obj.uniqueKeys {
+		v := adminFormat(values[field.JSONName])
+		if v == "" {
+			return ""
+		}
+		query.Set(field.JSONName, v)
+	}
+	return query.Encode()
+}
+
+func (o *adminObject) load(c *gin.Context, keys []string) (any, error) {
+	val := reflect.New(o.obj.modelElem).Interface()
+	if err := o.obj.buildPrimaryCondition(o.obj.getDB(c, false), keys).Take(val).Error; err != nil {
+		return nil, err
+	}
+	return val, nil
+}
+
+func (o *adminObject) create(c *gin.Context, inputVals map[string]any) error {
+	data, err := json.Marshal(inputVals)
+	if err != nil {
+		return err
+	}
+	val := reflect.New(o.obj.modelElem).Interface()
+	if err := json.Unmarshal(data, val); err != nil {
+		return err
+	}
+	return o.obj.createObject(o.obj.getDB(c, true), c, val)
+}
+
+// parseForm convert the posted inputs to the JSON values of the fields, the
+// empty numbers and times are left out. It returns the errors by the fields.
+func (o *adminObject) parseForm(c *gin.Context) (map[string]any, map[string]string) {
+	values, errs := map[string]any{}, map[string]string{}
+	for _, f := range o.inputs {
+		s := c.PostForm(f.Name)
+		switch f.Type {
+		case TYPE_BOOLEAN:
+			values[f.Name] = s == "true"
+		case TYPE_INT, TYPE_FLOAT:
+			if s = strings.TrimSpace(s); s == "" {
+				continue
+			}
+			n, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				errs[f.Name] = ErrAdminInvalidNumber.Error()
+				continue
+			}
+			values[f.Name] = n
+		case TYPE_DATE:
+			if s = strings.TrimSpace(s); s == "" {
+				continue
+			}
+			t, ok := castTime(s).(time.Time)
+			if !ok {
+				errs[f.Name] = ErrAdminInvalidTime.Error()
+				continue
+			}
+			values[f.Name] = t
+		default:
+			if s == "" && f.CanNull {
+				continue
+			}
+			values[f.Name] = s
+		}
+	}
+	return values, errs
+}
+
+// formInputs return the inputs of the form, with the values of value.
+func (o *adminObject) formInputs(value func(name string) string, errs map[string]string) []adminInput {
+	var inputs []adminInput
+	for _, f := range o.inputs {
+		input := adminInput{Name: f.Name, Label: f.Name, Type: "text", Value: value(f.Name), Required: f.Required, Error: errs[f.Name]}
+		switch f.Type {
+		case TYPE_BOOLEAN:
+			input.Type = "checkbox"
+		case TYPE_INT, TYPE_FLOAT:
+			input.Type = "number"
+		}
+		inputs = append(inputs, input)
+	}
+	return inputs
+}
+
+// adminValues return the JSON values of the record.
+func adminValues(val any) map[string]any {
+	values := map[string]any{}
+	if data, err := json.Marshal(val); err == nil {
+		json.Unmarshal(data, &values)
+	}
+	return values
+}
+
+// adminFormat format the JSON value for the pages, the numbers without exponent.
+func adminFormat(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
@@ -0,0 +1,146 @@
+package LingEcho
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/code-100-precent/LingFramework/pkg/constants"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newTestAdmin(t *testing.T, objs ...WebObject) (*gin.Engine, *gorm.DB) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{})
+	require.NoError(t, err)
+	for _, obj := range objs {
+		require.NoError(t, db.AutoMigrate(obj.Model))
+	}
+
+	admin, err := NewAdmin(objs)
+	require.NoError(t, err)
+	admin.PageSize = 2
+	admin.Authenticated = func(c *gin.Context) bool {
+		return c.GetHeader("X-User") != ""
+	}
+	r := gin.New()
+	g := r.Group("/admin", func(c *gin.Context) {
+		c.Set(constants.DbField, db)
+		c.Next()
+	})
+	admin.RegisterHandler(g)
+	return r, db
+}
+
+func doAdminRequest(r http.Handler, method, path string, form url.Values, user string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if form.Has(adminCSRFField) {
+			req.AddCookie(&http.Cookie{Name: adminCSRFCookie, Value: form.Get(adminCSRFField)})
+		}
+	}
+	if user != "" {
+		req.Header.Set("X-User", user)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestAdmin(t *testing.T) {
+	r, db := newTestAdmin(t, WebObject{
+		Model:        testProfile{},
+		Desc:         "The profiles",
+		Editables:    []string{"Name", "Email", "Age"},
+		Filterables:  []string{"Name", "Age"},
+		Orderables:   []string{"Age"},
+		AllowMethods: GET | CREATE | EDIT | DELETE | QUERY,
+	}, WebObject{
+		Model:        testItem{},
+		AuthRequired: true,
+		AllowMethods: GET | QUERY,
+	})
+	for i, name := range []string{"alice", "bob", "carol"} {
+		require.NoError(t, db.Create(&testProfile{Name: name, Age: 20 + i}).Error)
+	}
+
+	w := doAdminRequest(r, http.MethodGet, "/admin/", nil, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `href="/admin/testprofile"`)
+	assert.Contains(t, w.Body.String(), "The profiles")
+
+	// the list is paginated, filtered and ordered by the whitelists
+	w = doAdminRequest(r, http.MethodGet, "/admin/testprofile?order=age&desc=1", nil, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	assert.Contains(t, body, "1 - 2 of 3")
+	assert.Less(t, strings.Index(body, "carol"), strings.Index(body, "bob"))
+	assert.NotContains(t, body, "alice")
+	assert.Contains(t, body, `href="/admin/testprofile?desc=1&amp;order=age&amp;pos=2"`)
+	assert.Contains(t, body, `href="/admin/testprofile/edit?id=3"`)
+	assert.Contains(t, body, `href="/admin/testprofile/new"`)
+
+	w = doAdminRequest(r, http.MethodGet, "/admin/testprofile?f.name=li&f.email=x", nil, "")
+	body = w.Body.String()
+	assert.Contains(t, body, "alice")
+	assert.NotContains(t, body, "carol")
+
+	// the create rejects the invalid values with the messages
+	w = doAdminRequest(r, http.MethodPost, "/admin/testprofile/new", url.Values{"name": {"toolongname"}, "age": {"x"}}, "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	form := url.Values{adminCSRFField: {"token"}, "name": {"toolongname"}, "age": {"x"}}
+	w = doAdminRequest(r, http.MethodPost, "/admin/testprofile/new", form, "")
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), ErrAdminInvalidNumber.Error())
+	form.Set("age", "30")
+	w = doAdminRequest(r, http.MethodPost, "/admin/testprofile/new", form, "")
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), "validation failed for field &#39;name&#39; with rule &#39;maxlen&#39;")
+	assert.Contains(t, w.Body.String(), `value="toolongname"`)
+
+	form.Set("name", "dave")
+	w = doAdminRequest(r, http.MethodPost, "/admin/testprofile/new", form, "")
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	assert.Equal(t, "/admin/testprofile", w.Header().Get("Location"))
+	var profile testProfile
+	require.NoError(t, db.First(&profile, 4).Error)
+	assert.Equal(t, testProfile{ID: 4, Name: "dave", Age: 30}, profile)
+
+	// the edit form carries the values of the record
+	w = doAdminRequest(r, http.MethodGet, "/admin/testprofile/edit?id=4", nil, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `value="dave"`)
+	w = doAdminRequest(r, http.MethodPost, "/admin/testprofile/edit?id=4", url.Values{adminCSRFField: {"token"}, "name": {"erin"}, "email": {"erin@example.org"}, "age": {"31"}}, "")
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	require.NoError(t, db.First(&profile, 4).Error)
+	assert.Equal(t, testProfile{ID: 4, Name: "erin", Email: "erin@example.org", Age: 31}, profile)
+	w = doAdminRequest(r, http.MethodGet, "/admin/testprofile/edit?id=100", nil, "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// the delete is confirmed
+	w = doAdminRequest(r, http.MethodGet, "/admin/testprofile/delete?id=4", nil, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "erin@example.org")
+	w = doAdminRequest(r, http.MethodPost, "/admin/testprofile/delete?id=4", url.Values{adminCSRFField: {"token"}}, "")
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	assert.ErrorIs(t, db.First(&profile, 4).Error, gorm.ErrRecordNotFound)
+
+	// the AuthRequired objects need a signed-in user, and the methods are limited
+	w = doAdminRequest(r, http.MethodGet, "/admin/testitem", nil, "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = doAdminRequest(r, http.MethodGet, "/admin/testitem", nil, "alice")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "/admin/testitem/new")
+	w = doAdminRequest(r, http.MethodGet, "/admin/testitem/new", nil, "alice")
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	w = doAdminRequest(r, http.MethodGet, "/admin/missing", nil, "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -5,7 +5,9 @@ import (
 	"net/http"
 	"path/filepath"
 	"reflect"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/code-100-precent/LingFramework/pkg/constants"
 	docsPkg "github.com/code-100-precent/LingFramework/pkg/docs"
@@ -39,6 +41,7 @@ type DocField struct {
 	CanNull   bool       `json:"canNull,omitempty"`
 	IsArray   bool       `json:"isArray,omitempty"`
 	IsPrimary bool       `json:"isPrimary,omitempty"`
+	Rules     string     `json:"rules,omitempty"` // validation rules, such as "required,maxlen:64"
 	Fields    []DocField `json:"fields,omitempty"`
 }
 
@@ -52,7 +55,13 @@ type WebObjectDoc struct {
 	Filters      []string   `json:"filters,omitempty"`
 	Orders       []string   `json:"orders,omitempty"`
 	Searches     []string   `json:"searches,omitempty"`
+	Groups       []string   `json:"groups,omitempty"`
+	Aggregates   []string   `json:"aggregates,omitempty"`
+	Version      string     `json:"version,omitempty"`    // field of If-Match edits
+	CacheTTL     int        `json:"cacheTtl,omitempty"`   // seconds the GET and QUERY responses are cached
+	Idempotent   bool       `json:"idempotent,omitempty"` // CREATE and BATCH honour the Idempotency-Key header
 	Editables    []string   `json:"editables,omitempty"`
+	Expands      []DocField `json:"expands,omitempty"` // shapes of the Expandables
 	Views        []UriDoc   `json:"views,omitempty"`
 }
 
@@ -104,6 +113,13 @@ func RegisterHandlerWithAutoGen(prefix string, r *gin.Engine, uriDocs []UriDoc,
 		for _, doc := range uriDocsMap {
 			uriDocs = append(uriDocs, doc)
 		}
+		// 按路径排序，保证生成的文档稳定
+		sort.Slice(uriDocs, func(i, j int) bool {
+			if uriDocs[i].Path != uriDocs[j].Path {
+				return uriDocs[i].Path < uriDocs[j].Path
+			}
+			return uriDocs[i].Method < uriDocs[j].Method
+		})
 	}
 
 	r.GET(prefix+".json", func(ctx *gin.Context) {
@@ -118,28 +134,20 @@ func RegisterHandlerWithAutoGen(prefix string, r *gin.Engine, uriDocs []UriDoc,
 
 	// OpenAPI 导出
 	r.GET(prefix+"/openapi.json", func(ctx *gin.Context) {
-		scheme := "http"
-		if ctx.Request.TLS != nil {
-			scheme = "https"
-		}
-		baseURL := scheme + "://" + ctx.Request.Host
-		openapiGen := docsPkg.NewOpenAPIGenerator(baseURL, "1.0.0", "LingFramework API")
-		// 转换类型
-		docsUriDocs := make([]docsPkg.UriDoc, len(uriDocs))
-		for i, doc := range uriDocs {
-			docsUriDocs[i] = convertToDocsUriDoc(doc)
-		}
-		docsObjDocs := make([]docsPkg.WebObjectDoc, len(objDocs))
-		for i, doc := range objDocs {
-			docsObjDocs[i] = convertToDocsWebObjectDoc(doc)
+		data, err := buildOpenAPISpec(ctx, uriDocs, objDocs).ToJSON()
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
 		}
-		spec := openapiGen.Generate(docsUriDocs, docsObjDocs)
-		jsonData, err := spec.ToJSON()
+		ctx.Data(http.StatusOK, "application/json", data)
+	})
+	r.GET(prefix+"/openapi.yaml", func(ctx *gin.Context) {
+		data, err := buildOpenAPISpec(ctx, uriDocs, objDocs).ToYAML()
 		if err != nil {
 			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
-		ctx.Data(http.StatusOK, "application/json", jsonData)
+		ctx.Data(http.StatusOK, "application/yaml", data)
 	})
 
 	r.GET(prefix, func(ctx *gin.Context) {
@@ -147,6 +155,30 @@ func RegisterHandlerWithAutoGen(prefix string, r *gin.Engine, uriDocs []UriDoc,
 	})
 }
 
+// buildOpenAPISpec 生成 OpenAPI 3.1 文档，服务地址取自当前请求
+func buildOpenAPISpec(ctx *gin.Context, uriDocs []UriDoc, objDocs []WebObjectDoc) *docsPkg.Spec {
+	scheme := "http"
+	if ctx.Request.TLS != nil || ctx.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	baseURL := scheme + "://" + ctx.Request.Host
+	openapiGen := docsPkg.NewOpenAPIGenerator(baseURL, "1.0.0", "LingFramework API")
+	return openapiGen.Generate(ConvertDocs(uriDocs, objDocs))
+}
+
+// ConvertDocs 转换为 docs 包的文档，用于生成 OpenAPI 和客户端 SDK
+func ConvertDocs(uriDocs []UriDoc, objDocs []WebObjectDoc) ([]docsPkg.UriDoc, []docsPkg.WebObjectDoc) {
+	docsUriDocs := make([]docsPkg.UriDoc, len(uriDocs))
+	for i, doc := range uriDocs {
+		docsUriDocs[i] = convertToDocsUriDoc(doc)
+	}
+	docsObjDocs := make([]docsPkg.WebObjectDoc, len(objDocs))
+	for i, doc := range objDocs {
+		docsObjDocs[i] = convertToDocsWebObjectDoc(doc)
+	}
+	return docsUriDocs, docsObjDocs
+}
+
 func GetDocDefine(obj any) *DocField {
 	if obj == nil {
 		return nil
@@ -165,11 +197,10 @@ func GetWebObjectDocDefine(prefix string, obj WebObject) WebObjectDoc {
 		Filters:      obj.Filterables,
 		Orders:       obj.Orderables,
 		Searches:     obj.Searchables,
+		Groups:       obj.Groupables,
+		Aggregates:   obj.Aggregatables,
 	}
-	allowMethods := obj.AllowMethods
-	if obj.AllowMethods == 0 {
-		allowMethods = GET | CREATE | EDIT | DELETE | QUERY
-	}
+	allowMethods := obj.getAllowMethods()
 
 	if allowMethods&GET != 0 {
 		doc.AllowMethods = append(doc.AllowMethods, "GET")
@@ -186,8 +217,29 @@ func GetWebObjectDocDefine(prefix string, obj WebObject) WebObjectDoc {
 	if allowMethods&QUERY != 0 {
 		doc.AllowMethods = append(doc.AllowMethods, "QUERY")
 	}
+	if allowMethods&BATCH != 0 {
+		doc.AllowMethods = append(doc.AllowMethods, "BATCH")
+	}
+	if allowMethods&EXPORT != 0 {
+		doc.AllowMethods = append(doc.AllowMethods, "EXPORT")
+	}
+	if allowMethods&IMPORT != 0 {
+		doc.AllowMethods = append(doc.AllowMethods, "IMPORT")
+	}
+	if allowMethods&AGGREGATE != 0 {
+		doc.AllowMethods = append(doc.AllowMethods, "AGGREGATE")
+	}
+	if allowMethods&HISTORY != 0 {
+		doc.AllowMethods = append(doc.AllowMethods, "HISTORY")
+	}
 
 	doc.Fields = GetDocDefine(obj.Model).Fields
+	for i, f := range doc.Fields {
+		if rules, ok := obj.Rules[f.FieldName]; ok {
+			doc.Fields[i].Rules = mergeRules(f.Rules, rules)
+			doc.Fields[i].Required = f.Required || hasRule(rules, "required")
+		}
+	}
 	allFields := []string{}
 	for _, f := range doc.Fields {
 		allFields = append(allFields, f.Name)
@@ -205,6 +257,23 @@ func GetWebObjectDocDefine(prefix string, obj WebObject) WebObjectDoc {
 		}
 	}
 
+	doc.Expands = getExpandDocs(&obj)
+
+	if obj.Cache != nil {
+		doc.CacheTTL = int(obj.cacheTTL() / time.Second)
+	}
+	doc.Idempotent = obj.Idempotency != nil
+
+	if obj.VersionField != "" {
+		rt := reflect.TypeOf(obj.Model)
+		if rt.Kind() == reflect.Ptr {
+			rt = rt.Elem()
+		}
+		if f, ok := rt.FieldByName(obj.VersionField); ok {
+			doc.Version = jsonFieldName(f.Tag, f.Name)
+		}
+	}
+
 	for _, v := range obj.Views {
 		doc.Views = append(doc.Views, UriDoc{
 			Path:   filepath.Join(doc.Path, v.Path),
@@ -281,6 +350,11 @@ func parseDocField(rt reflect.Type, name string, stacks []string) (val DocField)
 			fieldRT.Required = true
 		}
 
+		if rules := f.Tag.Get("validate"); rules != "" {
+			fieldRT.Rules = rules
+			fieldRT.Required = fieldRT.Required || hasRule(rules, "required")
+		}
+
 		if strings.Contains(jsonTag, "omitempty") {
 			fieldRT.CanNull = true
 		}
@@ -376,6 +450,7 @@ func convertDocsDocField(field *docsPkg.DocField) *DocField {
 		CanNull:   field.CanNull,
 		IsArray:   field.IsArray,
 		IsPrimary: field.IsPrimary,
+		Rules:     field.Rules,
 	}
 	if len(field.Fields) > 0 {
 		result.Fields = make([]DocField, len(field.Fields))
@@ -401,6 +476,7 @@ func convertToDocsDocField(field *DocField) *docsPkg.DocField {
 		CanNull:   field.CanNull,
 		IsArray:   field.IsArray,
 		IsPrimary: field.IsPrimary,
+		Rules:     field.Rules,
 	}
 	if len(field.Fields) > 0 {
 		result.Fields = make([]docsPkg.DocField, len(field.Fields))
@@ -422,6 +498,11 @@ func convertToDocsWebObjectDoc(doc WebObjectDoc) docsPkg.WebObjectDoc {
 		Filters:      doc.Filters,
 		Orders:       doc.Orders,
 		Searches:     doc.Searches,
+		Groups:       doc.Groups,
+		Aggregates:   doc.Aggregates,
+		Version:      doc.Version,
+		CacheTTL:     doc.CacheTTL,
+		Idempotent:   doc.Idempotent,
 		Editables:    doc.Editables,
 	}
 	if len(doc.Fields) > 0 {
@@ -430,6 +511,12 @@ func convertToDocsWebObjectDoc(doc WebObjectDoc) docsPkg.WebObjectDoc {
 			result.Fields[i] = *convertToDocsDocField(&f)
 		}
 	}
+	if len(doc.Expands) > 0 {
+		result.Expands = make([]docsPkg.DocField, len(doc.Expands))
+		for i, f := range doc.Expands {
+			result.Expands[i] = *convertToDocsDocField(&f)
+		}
+	}
 	if len(doc.Views) > 0 {
 		result.Views = make([]docsPkg.UriDoc, len(doc.Views))
 		for i, v := range doc.Views {
@@ -0,0 +1,48 @@
+package LingEcho
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterHandlerOpenAPI(t *testing.T) {
+	obj := &WebObject{
+		Model:        testVersionedItem{},
+		Filterables:  []string{"Name"},
+		VersionField: "Version",
+	}
+	r, db := newTestObject(t, obj)
+	r.GET("/api/ping", func(c *gin.Context) {})
+	RegisterHandler("/docs", r, nil, []WebObjectDoc{GetWebObjectDocDefine("/api", *obj)}, db)
+
+	req := httptest.NewRequest(http.MethodGet, "/docs/openapi.json", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var spec map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &spec))
+	assert.Equal(t, "3.1.0", spec["openapi"])
+	paths := spec["paths"].(map[string]any)
+	assert.Contains(t, paths, "/api/testversioneditem/{id}")
+	assert.Contains(t, paths, "/api/ping")
+	// the WebObject routes are not collected twice
+	assert.NotContains(t, paths, "/api/testversioneditem/{ID}")
+	assert.Len(t, paths, 3)
+
+	edit := paths["/api/testversioneditem/{id}"].(map[string]any)["patch"].(map[string]any)
+	assert.Contains(t, edit["responses"], "412")
+
+	req = httptest.NewRequest(http.MethodGet, "/docs/openapi.yaml", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, strings.HasPrefix(w.Body.String(), "components:"))
+}
@@ -7,11 +7,13 @@ import (
 	"os"
 	"strings"
 
+	LingEcho "github.com/code-100-precent/LingFramework"
 	"github.com/code-100-precent/LingFramework/pkg/config"
 	"github.com/code-100-precent/LingFramework/pkg/logger"
 	"github.com/code-100-precent/LingFramework/pkg/middleware"
 	"github.com/code-100-precent/LingFramework/pkg/notification"
 	"github.com/code-100-precent/LingFramework/pkg/utils"
+	"github.com/code-100-precent/LingFramework/pkg/webhook"
 	"go.uber.org/zap"
 
 	"gorm.io/gorm"
@@ -133,9 +135,10 @@ func RunMigrations(db *gorm.DB) error {
 	if db == nil {
 		return errors.New("db is nil")
 	}
-	return utils.MakeMigrates(db, []any{
+	return utils.MakeMigrates(db, append([]any{
 		&utils.Config{},
 		&notification.InternalNotification{},
 		&middleware.OperationLog{},
-	})
+		&LingEcho.ObjectHistory{},
+	}, webhook.Models()...))
 }
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/code-100-precent/LingFramework/cmd/bootstrap"
+	"github.com/code-100-precent/LingFramework/internal/handlers"
+	"github.com/code-100-precent/LingFramework/pkg/config"
+	"github.com/code-100-precent/LingFramework/pkg/logger"
+)
+
+// reindex adds the existing rows of the WebObjects with a SearchIndex to the search index:
+//
+//	go run ./cmd/reindex -object user
+func main() {
+	mode := flag.String("mode", "", "running environment (development, test, production)")
+	object := flag.String("object", "", "name of the object to reindex, all by default")
+	batch := flag.Int("batch", 0, "rows indexed at a time")
+	flag.Parse()
+
+	if *mode != "" {
+		os.Setenv("APP_ENV", *mode)
+	}
+	if err := config.Load(); err != nil {
+		log.Fatalf("config load failed: %v", err)
+	}
+	if err := logger.Init(&config.GlobalConfig.Log, config.GlobalConfig.Mode); err != nil {
+		log.Fatalf("logger init failed: %v", err)
+	}
+
+	db, err := bootstrap.SetupDatabase(os.Stdout, &bootstrap.Options{})
+	if err != nil {
+		log.Fatalf("database setup failed: %v", err)
+	}
+
+	h := handlers.NewHandlers(db)
+	engine := h.GetSearchHandler().GetEngine()
+	if engine == nil {
+		log.Fatalf("search engine is not enabled")
+	}
+	defer engine.Close()
+
+	found := false
+	objs := h.GetObjs()
+	for i := range objs {
+		obj := &objs[i]
+		if err := obj.Build(); err != nil {
+			log.Fatalf("build %s failed: %v", obj.Name, err)
+		}
+		if obj.SearchIndex == nil || (*object != "" && obj.Name != *object) {
+			continue
+		}
+		found = true
+		n, err := obj.Reindex(context.Background(), db, *batch)
+		if err != nil {
+			log.Fatalf("reindex %s failed: %v", obj.Name, err)
+		}
+		log.Printf("reindexed %s: %d rows", obj.Name, n)
+	}
+	if !found {
+		log.Printf("no object to reindex")
+	}
+}
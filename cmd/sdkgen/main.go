@@ -0,0 +1,79 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	LingEcho "github.com/code-100-precent/LingFramework"
+	"github.com/code-100-precent/LingFramework/internal/handlers"
+	"github.com/code-100-precent/LingFramework/pkg/config"
+	"github.com/code-100-precent/LingFramework/pkg/docs"
+	"github.com/code-100-precent/LingFramework/pkg/utils"
+)
+
+// sdkgen generates the typed clients from the WebObjects and the UriDocs of the app:
+//
+//	go run ./cmd/sdkgen -out ./sdk -lang ts,go
+//
+// writes ./sdk/client.ts and ./sdk/client/client.go
+func main() {
+	out := flag.String("out", "./sdk", "output directory")
+	langs := flag.String("lang", "ts,go", "languages to generate, comma separated (ts, go)")
+	pkgName := flag.String("package", "client", "package name of the Go client")
+	mode := flag.String("mode", "", "running environment (development, test, production)")
+	flag.Parse()
+
+	if *mode != "" {
+		os.Setenv("APP_ENV", *mode)
+	}
+	if err := config.Load(); err != nil {
+		log.Fatalf("config load failed: %v", err)
+	}
+
+	// The docs don't depend on the data, an empty memory database is enough
+	db, err := utils.InitDatabase(io.Discard, "sqlite", "file::memory:?cache=shared")
+	if err != nil {
+		log.Fatalf("init database failed: %v", err)
+	}
+	if err := utils.MakeMigrates(db, []any{&utils.Config{}}); err != nil {
+		log.Fatalf("migrate failed: %v", err)
+	}
+
+	h := handlers.NewHandlers(db)
+	var objDocs []LingEcho.WebObjectDoc
+	for _, obj := range h.GetObjs() {
+		objDocs = append(objDocs, LingEcho.GetWebObjectDocDefine(config.GlobalConfig.APIPrefix, obj))
+	}
+	uriDocs, webObjectDocs := LingEcho.ConvertDocs(h.GetDocs(), objDocs)
+
+	gen := docs.NewSDKGenerator()
+	gen.PackageName = *pkgName
+	for _, lang := range strings.Split(*langs, ",") {
+		switch strings.TrimSpace(lang) {
+		case "ts", "typescript":
+			writeFile(filepath.Join(*out, "client.ts"), gen.GenerateTypeScript(uriDocs, webObjectDocs))
+		case "go":
+			code, err := gen.GenerateGo(uriDocs, webObjectDocs)
+			if err != nil {
+				log.Fatalf("generate go client failed: %v", err)
+			}
+			writeFile(filepath.Join(*out, *pkgName, "client.go"), code)
+		default:
+			log.Fatalf("unknown language: %s", lang)
+		}
+	}
+}
+
+func writeFile(name string, data []byte) {
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		log.Fatalf("create directory failed: %v", err)
+	}
+	if err := os.WriteFile(name, data, 0644); err != nil {
+		log.Fatalf("write %s failed: %v", name, err)
+	}
+	log.Printf("generated %s", name)
+}
@@ -1,12 +1,15 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"flag"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"sync"
+	"syscall"
 	"time"
 
 	LingEcho "github.com/code-100-precent/LingFramework"
@@ -41,9 +44,9 @@ func NewLingEchoApp(db *gorm.DB) *LingEchoApp {
 	}
 }
 
-func (app *LingEchoApp) RegisterRoutes(r *gin.Engine) {
+func (app *LingEchoApp) RegisterRoutes(ctx context.Context, r *gin.Engine) {
 	// Register system routes (with /api prefix)
-	app.handlers.Register(r)
+	app.handlers.Register(ctx, r)
 }
 
 func main() {
@@ -190,8 +193,10 @@ func main() {
 	}
 	r.StaticFS(apiPrefix+"/static", http.FS(staticAssets))
 
-	// 18. Register Routes
-	app.RegisterRoutes(r)
+	// 18. Register Routes, the background work stops with the server
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	app.RegisterRoutes(ctx, r)
 
 	// 18.6. Register Metrics Monitor Routes
 	// Get API prefix from config (default: /api)
@@ -209,6 +214,14 @@ func main() {
 		IdleTimeout:    120 * time.Second,
 		MaxHeaderBytes: 1 << 20, // 1MB
 	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			logger.Warn("HTTP server shutdown failed", zap.Error(err))
+		}
+	}()
 
 	// Check if SSL is enabled
 	if config.GlobalConfig.SSLEnabled {
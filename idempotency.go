@@ -0,0 +1,254 @@
+package LingEcho
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/code-100-precent/LingFramework/pkg/cache"
+	"github.com/code-100-precent/LingFramework/pkg/utils/response"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	DefaultIdempotencyTTL         = 24 * time.Hour
+	DefaultIdempotencyMaxBodySize = 10 << 20
+)
+
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotentReplayedHeader  = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+	idempotencyKeyPrefix      = "lingecho:idempotency:"
+	idempotencyLockExpiration = time.Minute // a crashed request holds the key no longer
+)
+
+var (
+	ErrInvalidIdempotencyKey    = errors.New("invalid Idempotency-Key")
+	ErrIdempotencyKeyReused     = errors.New("Idempotency-Key reused with a different request")
+	ErrIdempotencyKeyInProgress = errors.New("request with the Idempotency-Key in progress")
+)
+
+// Idempotency stores the first response of the mutating requests carrying
+// an Idempotency-Key header, the retries with the same key get the stored
+// response, with the Idempotent-Replayed header, instead of running again.
+// A key reused with another method, path or body is rejected with 422, and
+// a retry while the first request is running is rejected with 409.
+// Only the successful responses are stored, the 2xx without a response
+// envelope or with its code 200, the failures can be retried.
+type Idempotency struct {
+	Cache       cache.Cache
+	TTL         time.Duration // DefaultIdempotencyTTL by default
+	MaxBodySize int64         // of the requests, DefaultIdempotencyMaxBodySize by default
+	// Vary return the owner of the keys besides the tenant of the request,
+	// such as the user, so the clients can't replay the responses of others
+	// or probe their keys. Without it the keys are shared by the tenant, it's
+	// required on the authenticated routes, and by the AuthRequired objects.
+	Vary func(c *gin.Context) string
+}
+
+// idempotentResponse is a stored response.
+type idempotentResponse struct {
+	Fingerprint string      `json:"fingerprint"` // hash of the method, path and body
+	Status      int         `json:"status"`
+	Header      http.Header `json:"header,omitempty"`
+	Body        []byte      `json:"body,omitempty"`
+}
+
+// recordingWriter keeps a copy of the body written to the client.
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// IdempotencyMiddleware honours the Idempotency-Key header of the POST, PUT,
+// PATCH and DELETE requests, the others and the requests without the header
+// pass through. It can be used globally, or per WebObject with Idempotency.
+func IdempotencyMiddleware(idem *Idempotency) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		switch c.Request.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		default:
+			key = ""
+		}
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			response.AbortWithStatusJSON(c, http.StatusBadRequest, ErrInvalidIdempotencyKey)
+			return
+		}
+
+		var body []byte
+		if c.Request.Body != nil {
+			var err error
+			if body, err = io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, idem.maxBodySize())); err != nil {
+				status := http.StatusBadRequest
+				if _, ok := err.(*http.MaxBytesError); ok {
+					status = http.StatusRequestEntityTooLarge
+				}
+				response.AbortWithStatusJSON(c, status, err)
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		fingerprint := hashParts(c.Request.Method, c.Request.URL.RequestURI(), string(body))
+
+		ctx := c.Request.Context()
+		storeKey := idempotencyKeyPrefix + idem.owner(c, key)
+		if idem.replay(c, storeKey, fingerprint) {
+			return
+		}
+
+		// only the first of the concurrent requests runs
+		lockKey := storeKey + ":lock"
+		if ok, err := idem.Cache.SetNX(ctx, lockKey, 1, idempotencyLockExpiration); err != nil || !ok {
+			response.AbortWithStatusJSON(c, http.StatusConflict, ErrIdempotencyKeyInProgress)
+			return
+		}
+		defer idem.Cache.Delete(context.WithoutCancel(ctx), lockKey)
+		// the first request may have stored its response and released the
+		// lock since the load
+		if idem.replay(c, storeKey, fingerprint) {
+			return
+		}
+
+		w := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+
+		if !succeeded(w.Status(), w.body.Bytes()) {
+			return
+		}
+		idem.store(context.WithoutCancel(ctx), storeKey, &idempotentResponse{
+			Fingerprint: fingerprint,
+			Status:      w.Status(),
+			Header:      w.Header().Clone(),
+			Body:        w.body.Bytes(),
+		})
+	}
+}
+
+// owner return the hash of key scoped to the tenant and Vary of the request.
+func (idem *Idempotency) owner(c *gin.Context, key string) string {
+	vary := ""
+	if idem.Vary != nil {
+		vary = idem.Vary(c)
+	}
+	return hashParts(CurrentTenant(c), vary, key)
+}
+
+func (idem *Idempotency) maxBodySize() int64 {
+	if idem.MaxBodySize > 0 {
+		return idem.MaxBodySize
+	}
+	return DefaultIdempotencyMaxBodySize
+}
+
+func (idem *Idempotency) ttl() time.Duration {
+	if idem.TTL > 0 {
+		return idem.TTL
+	}
+	return DefaultIdempotencyTTL
+}
+
+// replay write the stored response of key, or reject the request when the
+// key was used with another fingerprint. It return false without a stored
+// response.
+func (idem *Idempotency) replay(c *gin.Context, key, fingerprint string) bool {
+	stored, ok := idem.load(c.Request.Context(), key)
+	if !ok {
+		return false
+	}
+	if stored.Fingerprint != fingerprint {
+		response.AbortWithStatusJSON(c, http.StatusUnprocessableEntity, ErrIdempotencyKeyReused)
+		return true
+	}
+	stored.replay(c)
+	return true
+}
+
+// succeeded tells if the response is stored: a 2xx, and with the code 200
+// when it's a response envelope, as response.Fail answers the failures
+// with 200 and the code 500.
+func succeeded(status int, body []byte) bool {
+	if status < http.StatusOK || status >= http.StatusMultipleChoices {
+		return false
+	}
+	var envelope struct {
+		Code *int `json:"code"`
+	}
+	if json.Unmarshal(body, &envelope) == nil && envelope.Code != nil {
+		return *envelope.Code == http.StatusOK
+	}
+	return true
+}
+
+// load return the stored response of key.
+func (idem *Idempotency) load(ctx context.Context, key string) (*idempotentResponse, bool) {
+	v, ok := idem.Cache.Get(ctx, key)
+	if !ok {
+		return nil, false
+	}
+	s, ok := v.(string)
+	if !ok {
+		return nil, false
+	}
+	var stored idempotentResponse
+	if err := json.Unmarshal([]byte(s), &stored); err != nil {
+		return nil, false
+	}
+	return &stored, true
+}
+
+// store keep the response of key, it's the best effort as the query cache.
+func (idem *Idempotency) store(ctx context.Context, key string, stored *idempotentResponse) {
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return
+	}
+	idem.Cache.Set(ctx, key, string(data), idem.ttl())
+}
+
+// replay write the stored response again.
+func (stored *idempotentResponse) replay(c *gin.Context) {
+	header := c.Writer.Header()
+	for k, v := range stored.Header {
+		header[k] = v
+	}
+	header.Set(IdempotentReplayedHeader, "true")
+	c.Status(stored.Status)
+	c.Writer.Write(stored.Body)
+	c.Abort()
+}
+
+// hashParts return the hex sha256 of the parts separated by newlines.
+func hashParts(parts ...string) string {
+	h := sha256.New()
+	for i, part := range parts {
+		if i > 0 {
+			h.Write([]byte("\n"))
+		}
+		h.Write([]byte(part))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,219 @@
+package LingEcho
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/code-100-precent/LingFramework/pkg/cache"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestWebObjectIdempotency(t *testing.T) {
+	r, db := newTestObject(t, &WebObject{
+		Model:        testItem{},
+		Editables:    []string{"Name", "Score"},
+		AllowMethods: GET | CREATE | EDIT | BATCH,
+		Idempotency:  &Idempotency{Cache: newTestCache()},
+	})
+	count := func() int64 {
+		var n int64
+		require.NoError(t, db.Model(&testItem{}).Count(&n).Error)
+		return n
+	}
+	create := func(key string, body any) *httptest.ResponseRecorder {
+		return doVersionRequest(r, http.MethodPut, "/api/testitem", body, map[string]string{IdempotencyKeyHeader: key})
+	}
+
+	w := create("k1", map[string]any{"name": "alice"})
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get(IdempotentReplayedHeader))
+	first := w.Body.String()
+
+	// the retry replays the first response
+	w = create("k1", map[string]any{"name": "alice"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "true", w.Header().Get(IdempotentReplayedHeader))
+	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, first, w.Body.String())
+	assert.Equal(t, int64(1), count())
+
+	w = create("k1", map[string]any{"name": "bob"})
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), ErrIdempotencyKeyReused.Error())
+
+	w = create("k2", map[string]any{"name": "bob"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = create("", map[string]any{"name": "bob"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = create(strings.Repeat("k", 256), map[string]any{"name": "bob"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, int64(3), count())
+
+	// the same key on another route is another request
+	batch := map[string]any{"creates": []map[string]any{{"name": "carol"}}}
+	w = doVersionRequest(r, http.MethodPost, "/api/testitem/batch", batch, map[string]string{IdempotencyKeyHeader: "k1"})
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	for i := 0; i < 2; i++ {
+		w = doVersionRequest(r, http.MethodPost, "/api/testitem/batch", batch, map[string]string{IdempotencyKeyHeader: "b1"})
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+	assert.Equal(t, int64(4), count())
+
+	// the edits are not covered by the WebObject
+	for i := 0; i < 2; i++ {
+		w = doVersionRequest(r, http.MethodPatch, "/api/testitem/1", map[string]any{"score": i}, map[string]string{IdempotencyKeyHeader: "e1"})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get(IdempotentReplayedHeader))
+	}
+
+	// the responses of the users must vary
+	obj := WebObject{Model: testItem{}, AuthRequired: true, Idempotency: &Idempotency{Cache: newTestCache()}}
+	assert.Error(t, obj.Build())
+	obj.Idempotency.Vary = func(c *gin.Context) string { return c.GetHeader("X-User") }
+	assert.NoError(t, obj.Build())
+}
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var mu sync.Mutex
+	calls := map[string]int{}
+	started, release := make(chan struct{}), make(chan struct{})
+
+	store := newTestCache()
+	racing := &racingCache{Cache: store}
+	r := gin.New()
+	r.Use(IdempotencyMiddleware(&Idempotency{
+		Cache:       racing,
+		MaxBodySize: 1024,
+		Vary:        func(c *gin.Context) string { return c.GetHeader("X-User") },
+	}))
+	r.POST("/orders", func(c *gin.Context) {
+		mu.Lock()
+		calls[c.GetHeader("X-User")]++
+		mu.Unlock()
+		if c.Query("slow") != "" {
+			close(started)
+			<-release
+		}
+		if c.Query("fail") != "" {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "down"})
+			return
+		}
+		c.Header("Location", "/orders/1")
+		c.JSON(http.StatusCreated, gin.H{"id": 1})
+	})
+	r.GET("/orders", func(c *gin.Context) {
+		calls["get"]++
+		c.Status(http.StatusOK)
+	})
+	post := func(path, user, key string) *httptest.ResponseRecorder {
+		return doVersionRequest(r, http.MethodPost, path, map[string]any{"item": "book"}, map[string]string{IdempotencyKeyHeader: key, "X-User": user})
+	}
+
+	for i := 0; i < 2; i++ {
+		w := post("/orders", "alice", "k1")
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, "/orders/1", w.Header().Get("Location"))
+		var body map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, float64(1), body["id"])
+	}
+	// the keys of the users are apart
+	post("/orders", "bob", "k1")
+	assert.Equal(t, map[string]int{"alice": 1, "bob": 1}, calls)
+
+	// the server errors can be retried
+	post("/orders?fail=1", "carol", "k2")
+	w := post("/orders?fail=1", "carol", "k2")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, 2, calls["carol"])
+
+	// the safe methods ignore the key
+	for i := 0; i < 2; i++ {
+		doVersionRequest(r, http.MethodGet, "/orders", nil, map[string]string{IdempotencyKeyHeader: "k3"})
+	}
+	assert.Equal(t, 2, calls["get"])
+
+	// the retry of a running request is rejected
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- post("/orders?slow=1", "dave", "k4") }()
+	<-started
+	w = post("/orders?slow=1", "dave", "k4")
+	assert.Equal(t, http.StatusConflict, w.Code)
+	// the lock of a crashed request expires
+	lockKey := idempotencyKeyPrefix + hashParts("", "dave", "k4") + ":lock"
+	_, ttl, ok := store.GetWithTTL(context.Background(), lockKey)
+	assert.True(t, ok)
+	assert.True(t, ttl > 0 && ttl <= idempotencyLockExpiration, "ttl %v", ttl)
+	close(release)
+	assert.Equal(t, http.StatusCreated, (<-done).Code)
+	w = post("/orders?slow=1", "dave", "k4")
+	assert.Equal(t, "true", w.Header().Get(IdempotentReplayedHeader))
+
+	// the first request completes between the load and the lock of the retry
+	racing.beforeSetNX = func() {
+		racing.beforeSetNX = nil
+		assert.Equal(t, http.StatusCreated, post("/orders", "erin", "k5").Code)
+	}
+	w = post("/orders", "erin", "k5")
+	assert.Equal(t, "true", w.Header().Get(IdempotentReplayedHeader))
+	assert.Equal(t, 1, calls["erin"])
+
+	w = doVersionRequest(r, http.MethodPost, "/orders", map[string]any{"item": strings.Repeat("b", 1024)}, map[string]string{IdempotencyKeyHeader: "k6"})
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
+
+// racingCache runs beforeSetNX before acquiring a lock
+type racingCache struct {
+	cache.Cache
+	beforeSetNX func()
+}
+
+func (r *racingCache) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	if r.beforeSetNX != nil {
+		r.beforeSetNX()
+	}
+	return r.Cache.SetNX(ctx, key, value, expiration)
+}
+
+func TestWebObjectIdempotencyFailure(t *testing.T) {
+	failures := 1
+	r, db := newTestObject(t, &WebObject{
+		Model:        testItem{},
+		Editables:    []string{"Name"},
+		AllowMethods: GET | CREATE,
+		Idempotency:  &Idempotency{Cache: newTestCache()},
+		BeforeCreate: func(db *gorm.DB, c *gin.Context, vptr any) error {
+			if failures > 0 {
+				failures--
+				return errors.New("database is locked")
+			}
+			return nil
+		},
+	})
+	create := func() map[string]any {
+		w := doVersionRequest(r, http.MethodPut, "/api/testitem", map[string]any{"name": "alice"}, map[string]string{IdempotencyKeyHeader: "k1"})
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get(IdempotentReplayedHeader))
+		var body map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return body
+	}
+
+	// the failure answered by response.Fail is not stored, the retry runs
+	assert.Equal(t, float64(500), create()["code"])
+	assert.Equal(t, float64(200), create()["code"])
+	var n int64
+	require.NoError(t, db.Model(&testItem{}).Count(&n).Error)
+	assert.Equal(t, int64(1), n)
+}
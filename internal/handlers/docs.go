@@ -9,6 +9,7 @@ import (
 	"github.com/code-100-precent/LingFramework/pkg/constants"
 	"github.com/code-100-precent/LingFramework/pkg/search"
 	"github.com/code-100-precent/LingFramework/pkg/utils"
+	"github.com/code-100-precent/LingFramework/pkg/websocket"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
@@ -24,6 +25,8 @@ func (h *Handlers) GetObjs() []LingEcho.WebObject {
 			Editables:   []string{"Email", "Phone", "FirstName", "LastName", "DisplayName", "Role", "Permissions", "Enabled"},
 			Searchables: []string{},
 			Orderables:  []string{"UpdatedAt"},
+			SearchIndex: h.searchIndex(true, "DisplayName", "FirstName", "LastName"),
+			ChangeFeed:  h.changeFeed(),
 			GetDB: func(c *gin.Context, isCreate bool) *gorm.DB {
 				if isCreate {
 					return h.db
@@ -37,6 +40,41 @@ func (h *Handlers) GetObjs() []LingEcho.WebObject {
 	}
 }
 
+// searchIndex returns the SearchIndex of the fields, nil if the search engine is not available
+func (h *Handlers) searchIndex(keyword bool, fields ...string) *LingEcho.SearchIndex {
+	if h.searchHandler == nil || h.searchHandler.GetEngine() == nil {
+		return nil
+	}
+	return &LingEcho.SearchIndex{
+		Indexer: search.NewObjectIndexer(h.searchHandler.GetEngine()),
+		Fields:  fields,
+		Keyword: keyword,
+	}
+}
+
+// changeFeed returns the ChangeFeed publishing to the websocket groups, nil without the hub
+func (h *Handlers) changeFeed() *LingEcho.ChangeFeed {
+	if h.wsHub == nil {
+		return nil
+	}
+	feed := &LingEcho.ChangeFeed{
+		Publisher: websocket.NewObjectPublisher(h.wsHub),
+		// the user of the subscription is loaded again, it may be disabled since
+		Authenticated: func(c *gin.Context) bool {
+			user := models.CurrentUser(c)
+			if user == nil {
+				return false
+			}
+			_, err := models.GetUserByUID(h.db, user.ID)
+			return err == nil
+		},
+	}
+	if config.GlobalConfig.TenantClaim != "" {
+		feed.Member = tenantMember(tenantFromClaim())
+	}
+	return feed
+}
+
 func (h *Handlers) GetDocs() []LingEcho.UriDoc {
 	// Define the API documentation
 	uriDocs := []LingEcho.UriDoc{
@@ -149,5 +187,38 @@ func (h *Handlers) GetDocs() []LingEcho.UriDoc {
 			},
 		}...)
 	}
+
+	uriDocs = append(uriDocs, LingEcho.UriDoc{
+		Group:        "WebSocket",
+		Path:         config.GlobalConfig.APIPrefix + websocket.RouteWebSocket,
+		Method:       http.MethodGet,
+		AuthRequired: true,
+		Desc:         "Upgrade to WebSocket. Send `{\"type\": \"join_group\", \"data\": \"objects.user\"}` to receive the `object_change` messages of the user object, the records are filtered as GET filters them",
+		Response:     LingEcho.GetDocDefine(LingEcho.ChangeMessage{}),
+	})
+
+	if config.GlobalConfig.GraphQLPrefix != "" {
+		uriDocs = append(uriDocs, LingEcho.UriDoc{
+			Group:  "GraphQL",
+			Path:   config.GlobalConfig.APIPrefix + config.GlobalConfig.GraphQLPrefix,
+			Method: http.MethodPost,
+			Desc:   "Query and mutate the objects above in one request, the schema is available by introspection. Queries are also accepted by GET",
+			Request: &LingEcho.DocField{
+				Type: LingEcho.TYPE_OBJECT,
+				Fields: []LingEcho.DocField{
+					{Name: "query", Type: LingEcho.TYPE_STRING, Required: true},
+					{Name: "operationName", Type: LingEcho.TYPE_STRING},
+					{Name: "variables", Type: LingEcho.TYPE_MAP},
+				},
+			},
+			Response: &LingEcho.DocField{
+				Type: LingEcho.TYPE_OBJECT,
+				Fields: []LingEcho.DocField{
+					{Name: "data", Type: LingEcho.TYPE_MAP},
+					{Name: "errors", Type: LingEcho.TYPE_OBJECT, IsArray: true},
+				},
+			},
+		})
+	}
 	return uriDocs
 }
@@ -1,16 +1,24 @@
 package handlers
 
 import (
+	"context"
 	"log"
+	"net/http"
 	"time"
 
 	LingEcho "github.com/code-100-precent/LingFramework"
+	"github.com/code-100-precent/LingFramework/internal/models"
+	"github.com/code-100-precent/LingFramework/pkg/auth"
 	"github.com/code-100-precent/LingFramework/pkg/config"
 	"github.com/code-100-precent/LingFramework/pkg/constants"
+	"github.com/code-100-precent/LingFramework/pkg/i18n"
 	"github.com/code-100-precent/LingFramework/pkg/logger"
 	"github.com/code-100-precent/LingFramework/pkg/middleware"
 	"github.com/code-100-precent/LingFramework/pkg/search"
 	"github.com/code-100-precent/LingFramework/pkg/utils"
+	"github.com/code-100-precent/LingFramework/pkg/utils/response"
+	"github.com/code-100-precent/LingFramework/pkg/validator"
+	"github.com/code-100-precent/LingFramework/pkg/webhook"
 	"github.com/code-100-precent/LingFramework/pkg/websocket"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -21,6 +29,7 @@ type Handlers struct {
 	db            *gorm.DB
 	wsHub         *websocket.Hub
 	searchHandler *search.SearchHandlers
+	webhooks      *webhook.Dispatcher
 }
 
 // GetSearchHandler gets the search handlers (for scheduled tasks)
@@ -88,10 +97,13 @@ func NewHandlers(db *gorm.DB) *Handlers {
 		db:            db,
 		wsHub:         wsHub,
 		searchHandler: searchHandler,
+		webhooks:      webhook.NewDispatcher(db, nil),
 	}
 }
 
-func (h *Handlers) Register(engine *gin.Engine) {
+// Register the routes on engine, the background work such as the webhook
+// deliveries runs until ctx is done.
+func (h *Handlers) Register(ctx context.Context, engine *gin.Engine) {
 
 	r := engine.Group(config.GlobalConfig.APIPrefix)
 
@@ -101,6 +113,22 @@ func (h *Handlers) Register(engine *gin.Engine) {
 	// Register Operation Log Middleware for authenticated routes
 	r.Use(middleware.OperationLogMiddleware())
 
+	// Localize the validation errors of the WebObjects to the locale of the request
+	translations := i18n.NewManager(&i18n.Config{
+		SupportedLocales: []i18n.Locale{"en", "zh-CN", "zh-TW"},
+		TranslationsPath: "pkg/i18n/translations",
+	})
+	objectMiddlewares := []gin.HandlerFunc{
+		i18n.Middleware(translations),
+		validator.Middleware(validator.NewValidator(translations)),
+	}
+
+	// Resolve the tenant for the WebObjects with TenantField
+	if resolvers := tenantResolvers(); len(resolvers) > 0 {
+		objectMiddlewares = append(objectMiddlewares, LingEcho.TenantMiddleware(resolvers...))
+	}
+	r.Use(objectMiddlewares...)
+
 	// Register routes regardless of whether search is enabled, check in handlers methods
 	// If handlers is nil, try to initialize
 	if h.searchHandler == nil {
@@ -153,8 +181,45 @@ func (h *Handlers) Register(engine *gin.Engine) {
 	} else {
 		logger.Warn("Search handlers is still nil after initialization, routes not registered")
 	}
+	LingEcho.HistoryActor = func(c *gin.Context) (uint, string) {
+		user := models.CurrentUser(c)
+		if user == nil {
+			return 0, ""
+		}
+		return user.ID, user.DisplayName
+	}
 	objs := h.GetObjs()
 	LingEcho.RegisterObjects(r, objs)
+
+	// The changes of the objects are published to the groups joined by join_group
+	wsHandler := websocket.NewHandler(h.wsHub)
+	r.GET(websocket.RouteWebSocket, func(c *gin.Context) {
+		if models.CurrentUser(c) == nil {
+			response.AbortWithStatus(c, http.StatusUnauthorized)
+			return
+		}
+		wsHandler.HandleWebSocket(c)
+	})
+
+	// GraphQL over the same objects, optional
+	if config.GlobalConfig.GraphQLPrefix != "" {
+		gql, err := LingEcho.NewGraphQL(objs)
+		if err != nil {
+			logger.Warn("Failed to build the GraphQL schema, endpoint not registered", zap.Error(err))
+		} else {
+			gql.Authenticated = func(c *gin.Context) bool {
+				return models.CurrentUser(c) != nil
+			}
+			gql.RegisterHandler(r, config.GlobalConfig.GraphQLPrefix)
+		}
+	}
+
+	// Webhooks, managed by the staff
+	LingEcho.AddObjectListenerFor(h.webhooks.Wants, h.webhooks.Enqueue)
+	h.webhooks.Start(ctx)
+	staff := r.Group("", staffRequired)
+	LingEcho.RegisterObjects(staff, h.webhooks.WebObjects())
+	h.webhooks.RegisterRoutes(staff)
 	if config.GlobalConfig.DocsPrefix != "" {
 		var objDocs []LingEcho.WebObjectDoc
 		for _, obj := range objs {
@@ -162,6 +227,77 @@ func (h *Handlers) Register(engine *gin.Engine) {
 		}
 		LingEcho.RegisterHandler(config.GlobalConfig.DocsPrefix, engine, h.GetDocs(), objDocs, h.db)
 	}
+
+	// Admin console over the same objects, for the staff
+	if config.GlobalConfig.AdminPrefix != "" {
+		admin, err := LingEcho.NewAdmin(objs)
+		if err != nil {
+			logger.Warn("Failed to build the admin console, not registered", zap.Error(err))
+		} else {
+			admin.Authenticated = func(c *gin.Context) bool {
+				return models.CurrentUser(c) != nil
+			}
+			adminGroup := engine.Group(config.GlobalConfig.AdminPrefix, middleware.InjectDB(h.db), staffRequired)
+			adminGroup.Use(objectMiddlewares...)
+			admin.RegisterHandler(adminGroup)
+		}
+	}
+}
+
+// tenantResolvers returns the tenant resolvers configured, in the order of
+// the header, the subdomain and the JWT claim. The header and the subdomain
+// are chosen by the client, they are rejected unless they match the claim.
+func tenantResolvers() []LingEcho.TenantResolver {
+	if config.GlobalConfig.TenantClaim == "" {
+		if config.GlobalConfig.TenantHeader != "" || config.GlobalConfig.TenantDomain != "" {
+			logger.Warn("TENANT_HEADER and TENANT_DOMAIN require TENANT_CLAIM, the tenants are not resolved")
+		}
+		return nil
+	}
+	fromClaim := tenantFromClaim()
+	member := tenantMember(fromClaim)
+
+	var resolvers []LingEcho.TenantResolver
+	if config.GlobalConfig.TenantHeader != "" {
+		resolvers = append(resolvers, LingEcho.TenantFromHeader(config.GlobalConfig.TenantHeader, member))
+	}
+	if config.GlobalConfig.TenantDomain != "" {
+		resolvers = append(resolvers, LingEcho.TenantFromSubdomain(config.GlobalConfig.TenantDomain, member))
+	}
+	return append(resolvers, fromClaim)
+}
+
+// tenantFromClaim resolves the tenant from the TENANT_CLAIM of the JWT
+func tenantFromClaim() LingEcho.TenantResolver {
+	secret := utils.GetEnv("JWT_SECRET_KEY")
+	if secret == "" {
+		secret = config.GlobalConfig.SessionSecret
+	}
+	jwtManager := auth.NewJWTManager(auth.DefaultJWTConfig(secret))
+	return jwtManager.TenantFromClaim(config.GlobalConfig.TenantClaim)
+}
+
+// tenantMember accepts the tenant claimed by the JWT of the request, which
+// must still be valid
+func tenantMember(fromClaim LingEcho.TenantResolver) LingEcho.TenantMemberFunc {
+	return func(c *gin.Context, tenant string) bool {
+		claimed, err := fromClaim(c)
+		return err == nil && claimed == tenant
+	}
+}
+
+// staffRequired aborts the request unless the current user is staff
+func staffRequired(c *gin.Context) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.AbortWithStatus(c, http.StatusUnauthorized)
+		return
+	}
+	if !user.IsStaff {
+		response.AbortWithStatus(c, http.StatusForbidden)
+		return
+	}
+	c.Next()
 }
 
 // registerAuthRoutes User Module
@@ -1,12 +1,12 @@
 package LingEcho
 
 import (
-	"database/sql"
 	"errors"
 	"fmt"
 	"net/http"
 	"path/filepath"
 	"reflect"
+	"slices"
 	"strings"
 	"time"
 
@@ -14,10 +14,14 @@ import (
 	"github.com/code-100-precent/LingFramework/pkg/utils/response"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
 )
 
 const (
 	DefaultQueryLimit = 102400 // 100k
+	DefaultBatchLimit = 1000
+	DefaultImportSize = 32 << 20 // 32MB
 )
 
 const (
@@ -32,6 +36,16 @@ const (
 	FilterOpLessOrEqual    = "<="
 	FilterOpLike           = "like"
 	FilterOpBetween        = "between"
+	FilterOpNotLike        = "not_like"
+	FilterOpStartsWith     = "starts_with"
+	FilterOpEndsWith       = "ends_with"
+	FilterOpIsNull         = "is_null"
+	FilterOpNotNull        = "not_null"
+
+	// groups of the child filters
+	FilterOpAnd = "and"
+	FilterOpOr  = "or"
+	FilterOpNot = "not"
 )
 
 const (
@@ -45,6 +59,12 @@ const (
 	EDIT   = 1 << 3
 	DELETE = 1 << 4
 	QUERY  = 1 << 5
+	BATCH  = 1 << 6
+	EXPORT = 1 << 7
+	IMPORT = 1 << 8
+
+	AGGREGATE = 1 << 9
+	HISTORY   = 1 << 10 // record the changes, list and revert them
 )
 
 type GetDB func(c *gin.Context, isCreate bool) *gorm.DB // designed for group
@@ -56,6 +76,8 @@ type (
 	BeforeUpdateFunc      func(db *gorm.DB, ctx *gin.Context, vptr any, vals map[string]any) error
 	BeforeRenderFunc      func(db *gorm.DB, ctx *gin.Context, vptr any) (any, error)
 	BeforeQueryRenderFunc func(db *gorm.DB, ctx *gin.Context, r *QueryResult) (any, error)
+
+	BeforeAggregateRenderFunc func(db *gorm.DB, ctx *gin.Context, r *AggregateResult) (any, error)
 )
 
 type QueryView struct {
@@ -73,15 +95,27 @@ type WebObjectPrimaryField struct {
 }
 
 type WebObject struct {
-	Model             any
-	Group             string
-	Name              string
-	Desc              string
-	AuthRequired      bool
-	Editables         []string
-	Filterables       []string
-	Orderables        []string
-	Searchables       []string
+	Model         any
+	Group         string
+	Name          string
+	Desc          string
+	AuthRequired  bool
+	Editables     []string
+	Filterables   []string
+	Orderables    []string
+	Searchables   []string
+	Expandables   []string // associations can be preloaded, such as "Author", "Comments.Author"
+	Groupables    []string // fields can be grouped by in aggregate
+	Aggregatables []string // numeric fields for sum/avg/min/max
+	VersionField  string   // integer version or time field (such as UpdatedAt) for If-Match edits
+	TenantField   string   // string or integer field holding the tenant, the rows are scoped to CurrentTenant
+	// Rules validate the creates and edits, such as {"Email": "required,email"},
+	// in addition to the `validate` tags of the fields. See validator.ValidateMap.
+	Rules             map[string]string
+	SearchIndex       *SearchIndex
+	Cache             *QueryCache
+	ChangeFeed        *ChangeFeed
+	Idempotency       *Idempotency // replay the create and batch responses of the retries with the same Idempotency-Key
 	GetDB             GetDB
 	PrepareQuery      PrepareQuery
 	BeforeCreate      BeforeCreateFunc
@@ -90,12 +124,19 @@ type WebObject struct {
 	BeforeRender      BeforeRenderFunc
 	BeforeQueryRender BeforeQueryRenderFunc
 
+	BeforeAggregateRender BeforeAggregateRenderFunc
+
 	Views        []QueryView
 	AllowMethods int
 
 	primaryKeys []WebObjectPrimaryField
 	uniqueKeys  []WebObjectPrimaryField
 	tableName   string
+	expandables map[string]string // JSON path => field path
+
+	versionIsTime bool
+
+	searchIndexFields []string // JSON names of SearchIndex.Fields
 
 	// Model type
 	modelElem reflect.Type
@@ -105,18 +146,29 @@ type WebObject struct {
 	// Map json tag to field kind. such as:
 	// UUID string `json:"id"` => {"id": string}
 	jsonToKinds map[string]reflect.Kind
+	// JSON names of the `gorm:"serializer:json"` fields
+	jsonColumns map[string]bool
+	// Map json tag to the validation rules of the field, from the `validate`
+	// tags and Rules. such as:
+	// Email string `json:"email" validate:"required,email"` => {"email": "required,email"}
+	rules map[string]string
 }
 
+// Filter is a condition on the field Name, or a group of child Filters
+// when Op is "and", "or" or "not", such as:
+// {"op": "or", "filters": [{"name": "status", "op": "=", "value": "a"}, ...]}
 type Filter struct {
-	isTimeType bool   `json:"-"`
-	Name       string `json:"name"`
-	Op         string `json:"op"`
-	Value      any    `json:"value"`
+	isTimeType bool     `json:"-"`
+	Name       string   `json:"name"`
+	Op         string   `json:"op"`
+	Value      any      `json:"value"`
+	Filters    []Filter `json:"filters,omitempty"`
 }
 
 type Order struct {
-	Name string `json:"name"`
-	Op   string `json:"op"`
+	field string `json:"-"`
+	Name  string `json:"name"`
+	Op    string `json:"op"`
 }
 
 type QueryForm struct {
@@ -128,6 +180,18 @@ type QueryForm struct {
 	ForeignMode  bool     `json:"foreign"` // for foreign key
 	ViewFields   []string `json:"-"`       // for view
 	searchFields []string `json:"-"`       // for keyword
+	searchKeys   []any    `json:"-"`       // for keyword matched by the SearchIndex
+
+	// Keyset pagination, pass the nextCursor/prevCursor of last result,
+	// or set CursorMode to fetch the first page.
+	Cursor     string `json:"cursor,omitempty"`
+	CursorMode bool   `json:"cursorMode,omitempty"`
+	SkipCount  bool   `json:"skipCount,omitempty"` // skip COUNT(*), total will be empty
+
+	// Associations to preload, limited to Expandables. The fields of an
+	// association can be selected, such as "author(id,name)".
+	Expand  []string        `json:"expand,omitempty"`
+	expands []expandPreload `json:"-"`
 }
 
 type QueryResult struct {
@@ -136,6 +200,8 @@ type QueryResult struct {
 	Limit      int    `json:"limit,omitempty"`
 	Keyword    string `json:"keyword,omitempty"`
 	Items      []any  `json:"items"`
+	NextCursor string `json:"nextCursor,omitempty"`
+	PrevCursor string `json:"prevCursor,omitempty"`
 }
 
 // GetQuery return the combined filter SQL statement.
@@ -190,9 +256,15 @@ func (obj *WebObject) RegisterObject(r *gin.RouterGroup) error {
 	}
 
 	p := obj.Name
-	allowMethods := obj.AllowMethods
-	if allowMethods == 0 {
-		allowMethods = GET | CREATE | EDIT | DELETE | QUERY
+	allowMethods := obj.getAllowMethods()
+	if obj.TenantField != "" {
+		r = r.Group("", obj.requireTenant)
+	}
+
+	// the routes creating records honour the Idempotency-Key
+	creates := r
+	if obj.Idempotency != nil {
+		creates = r.Group("", IdempotencyMiddleware(obj.Idempotency))
 	}
 
 	primaryKeyPath := obj.BuildPrimaryPath(p)
@@ -202,7 +274,7 @@ func (obj *WebObject) RegisterObject(r *gin.RouterGroup) error {
 		})
 	}
 	if allowMethods&CREATE != 0 {
-		r.PUT(p, func(c *gin.Context) {
+		creates.PUT(p, func(c *gin.Context) {
 			handleCreateObject(c, obj)
 		})
 	}
@@ -224,6 +296,39 @@ func (obj *WebObject) RegisterObject(r *gin.RouterGroup) error {
 		})
 	}
 
+	if allowMethods&BATCH != 0 {
+		creates.POST(filepath.Join(p, "batch"), func(c *gin.Context) {
+			handleBatchObject(c, obj)
+		})
+	}
+
+	if allowMethods&EXPORT != 0 {
+		r.POST(filepath.Join(p, "export"), func(c *gin.Context) {
+			handleExportObject(c, obj)
+		})
+	}
+
+	if allowMethods&IMPORT != 0 {
+		r.POST(filepath.Join(p, "import"), func(c *gin.Context) {
+			handleImportObject(c, obj)
+		})
+	}
+
+	if allowMethods&AGGREGATE != 0 {
+		r.POST(filepath.Join(p, "aggregate"), func(c *gin.Context) {
+			handleAggregateObject(c, obj)
+		})
+	}
+
+	if allowMethods&HISTORY != 0 {
+		r.GET(filepath.Join(primaryKeyPath, "history"), func(c *gin.Context) {
+			handleQueryHistory(c, obj)
+		})
+		r.POST(filepath.Join(primaryKeyPath, "history", ":historyId", "revert"), func(c *gin.Context) {
+			handleRevertHistory(c, obj)
+		})
+	}
+
 	for i := 0; i < len(obj.Views); i++ {
 		v := &obj.Views[i]
 		if v.Path == "" {
@@ -324,6 +429,14 @@ func RegisterObjects(r *gin.RouterGroup, objs []WebObject) {
 	}
 }
 
+// dbTable return the table name of the model, honoring its TableName method.
+func (obj *WebObject) dbTable(db *gorm.DB) string {
+	if t, ok := reflect.New(obj.modelElem).Interface().(schema.Tabler); ok {
+		return t.TableName()
+	}
+	return db.NamingStrategy.TableName(obj.tableName)
+}
+
 // Build fill the properties of obj.
 func (obj *WebObject) Build() error {
 	rt := reflect.TypeOf(obj.Model)
@@ -340,8 +453,42 @@ func (obj *WebObject) Build() error {
 
 	obj.jsonToFields = make(map[string]string)
 	obj.jsonToKinds = make(map[string]reflect.Kind)
+	obj.jsonColumns = make(map[string]bool)
+	obj.rules = make(map[string]string)
 	obj.parseFields(obj.modelElem)
 
+	if err := obj.buildRules(); err != nil {
+		return err
+	}
+
+	if err := obj.buildExpandables(); err != nil {
+		return err
+	}
+
+	if err := obj.buildVersionField(); err != nil {
+		return err
+	}
+
+	if err := obj.buildTenantField(); err != nil {
+		return err
+	}
+
+	if obj.Cache != nil && obj.Cache.Cache == nil {
+		return fmt.Errorf("%s query cache without cache", obj.Name)
+	}
+	if obj.Cache != nil && obj.Cache.Vary == nil && (obj.GetDB != nil || obj.BeforeRender != nil || obj.BeforeQueryRender != nil) {
+		// the responses may depend on the user, they would be shared
+		return fmt.Errorf("%s query cache without vary, required with GetDB or the render hooks", obj.Name)
+	}
+
+	if obj.Idempotency != nil && obj.Idempotency.Cache == nil {
+		return fmt.Errorf("%s idempotency without cache", obj.Name)
+	}
+	if obj.Idempotency != nil && obj.Idempotency.Vary == nil && obj.AuthRequired {
+		// the responses of a user would be replayed to the others
+		return fmt.Errorf("%s idempotency without vary, required with AuthRequired", obj.Name)
+	}
+
 	if obj.primaryKeys != nil {
 		obj.uniqueKeys = obj.primaryKeys
 	}
@@ -349,7 +496,7 @@ func (obj *WebObject) Build() error {
 	if len(obj.uniqueKeys) <= 0 && len(obj.primaryKeys) <= 0 {
 		return fmt.Errorf("%s not has primaryKey", obj.Name)
 	}
-	return nil
+	return obj.buildSearchIndex()
 }
 
 // parseFields parse the following properties according to struct tag:
@@ -396,6 +543,15 @@ func (obj *WebObject) parseFields(rt reflect.Type) {
 		if pkField.JSONName == "" {
 			pkField.JSONName = pkField.Name
 		}
+		if jsonTag != "-" {
+			// the fields out of the JSON are never in the input, nor validated
+			if rules := f.Tag.Get("validate"); rules != "" {
+				obj.rules[pkField.JSONName] = rules
+			}
+			if strings.Contains(gormTag, "serializer:json") {
+				obj.jsonColumns[pkField.JSONName] = true
+			}
+		}
 
 		if pkField.IsPrimary {
 			obj.primaryKeys = append(obj.primaryKeys, pkField)
@@ -420,10 +576,23 @@ func handleGetObject(c *gin.Context, obj *WebObject) {
 		response.Fail(c, err.Error(), nil)
 		return
 	}
-	db := GetDbConnection(c, obj.GetDB, false)
+
+	var cacheKey string
+	if c.GetHeader("If-None-Match") == "" {
+		var hit bool
+		if cacheKey, hit = obj.serveCache(c, "get", []any{keys, c.Request.URL.Query()}); hit {
+			return
+		}
+	}
+	db := obj.getDB(c, false)
+	expands, _, err := obj.resolveExpands(db, c.QueryArray("expand"))
+	if err != nil {
+		response.Fail(c, err.Error(), nil)
+		return
+	}
 	// the real name of the primaryKey column
 	val := reflect.New(obj.modelElem).Interface()
-	result := obj.buildPrimaryCondition(db, keys).Take(&val)
+	result := applyExpands(obj.buildPrimaryCondition(db, keys), expands).Take(val)
 	if result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 			response.Fail(c, "not found", nil)
@@ -433,6 +602,13 @@ func handleGetObject(c *gin.Context, obj *WebObject) {
 		return
 	}
 
+	etag := obj.getETag(val)
+	// not modified only once BeforeRender allows the record
+	notModified := false
+	if inm := c.GetHeader("If-None-Match"); etag != "" && inm != "" {
+		notModified = obj.matchVersion(val, inm)
+	}
+
 	if obj.BeforeRender != nil {
 		rr, err := obj.BeforeRender(db, c, val)
 		if err != nil {
@@ -450,6 +626,17 @@ func handleGetObject(c *gin.Context, obj *WebObject) {
 		}
 	}
 
+	if etag != "" {
+		c.Header("ETag", etag)
+		if notModified {
+			c.Status(http.StatusNotModified)
+			return
+		}
+	}
+
+	if cacheKey != "" {
+		obj.setCache(c, cacheKey, c.Writer.Header().Get("ETag"), val)
+	}
 	response.Success(c, "success", val)
 }
 
@@ -465,23 +652,42 @@ func handleCreateObject(c *gin.Context, obj *WebObject) {
 		}
 	}
 
-	db := GetDbConnection(c, obj.GetDB, true)
-	if obj.BeforeCreate != nil {
-		if err := obj.BeforeCreate(db, c, val); err != nil {
+	db := obj.getDB(c, true)
+	if err := obj.createObject(db, c, val); err != nil {
+		if !failValidation(c, err) {
 			response.Fail(c, err.Error(), nil)
-			return
 		}
-	}
-
-	result := db.Create(val)
-	if result.Error != nil {
-		response.Fail(c, result.Error.Error(), nil)
 		return
 	}
+	obj.afterCommit(c)
 
 	response.Success(c, "created successfully", val)
 }
 
+// createObject validate vptr, run BeforeCreate and insert vptr.
+func (obj *WebObject) createObject(db *gorm.DB, c *gin.Context, vptr any) error {
+	if err := obj.stampTenant(c, vptr); err != nil {
+		return err
+	}
+	if err := obj.validateCreate(c, vptr); err != nil {
+		return err
+	}
+	if obj.BeforeCreate != nil {
+		if err := obj.BeforeCreate(db, c, vptr); err != nil {
+			return err
+		}
+	}
+	if !obj.inTransaction(db) {
+		return db.Create(vptr).Error
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(vptr).Error; err != nil {
+			return err
+		}
+		return obj.afterWrite(tx, c, HistoryActionCreate, nil, vptr)
+	})
+}
+
 func handleEditObject(c *gin.Context, obj *WebObject) {
 	keys, err := obj.getPrimaryValues(c)
 	if err != nil {
@@ -489,14 +695,57 @@ func handleEditObject(c *gin.Context, obj *WebObject) {
 		return
 	}
 
-	var inputVals map[string]any
-	if err := c.BindJSON(&inputVals); err != nil {
-		response.Fail(c, err.Error(), nil)
-		return
+	db := obj.getDB(c, false)
+	ifMatch := c.GetHeader("If-Match")
+	switch contentType := c.ContentType(); contentType {
+	case ContentTypeJSONPatch, ContentTypeMergePatch:
+		body, err := c.GetRawData()
+		if err != nil {
+			response.Fail(c, err.Error(), nil)
+			return
+		}
+		// the record patched is locked until updated, the concurrent edits wait
+		err = db.Transaction(func(tx *gorm.DB) error {
+			inputVals, err := obj.patchInput(tx.Clauses(clause.Locking{Strength: "UPDATE"}), keys, contentType, body)
+			if err != nil {
+				return err
+			}
+			return obj.editObject(tx, c, keys, inputVals, ifMatch)
+		})
+		if err != nil {
+			if !failPatch(c, err) && !failVersion(c, err) && !failValidation(c, err) {
+				response.Fail(c, err.Error(), nil)
+			}
+			return
+		}
+	default:
+		var inputVals map[string]any
+		if err := c.BindJSON(&inputVals); err != nil {
+			response.Fail(c, err.Error(), nil)
+			return
+		}
+		if err := obj.editObject(db, c, keys, inputVals, ifMatch); err != nil {
+			if !failVersion(c, err) && !failValidation(c, err) {
+				response.Fail(c, err.Error(), nil)
+			}
+			return
+		}
 	}
+	obj.afterCommit(c)
 
-	db := GetDbConnection(c, obj.GetDB, false)
+	if obj.VersionField != "" {
+		val := reflect.New(obj.modelElem).Interface()
+		if err := obj.buildPrimaryCondition(db.Session(&gorm.Session{NewDB: true}), keys).Take(val).Error; err == nil {
+			c.Header("ETag", obj.getETag(val))
+		}
+	}
+	response.Success(c, "updated successfully", true)
+}
 
+// editObject strip inputVals to the Editables, validate them, run
+// BeforeUpdate and update the record identified by keys.
+// With VersionField, ifMatch must match the version of the record.
+func (obj *WebObject) editObject(db *gorm.DB, c *gin.Context, keys []string, inputVals map[string]any, ifMatch string) error {
 	var vals map[string]any = map[string]any{}
 
 	// can't edit primaryKey
@@ -511,12 +760,14 @@ func handleEditObject(c *gin.Context, obj *WebObject) {
 
 		fieldName, ok, err := obj.checkType(db, k, v)
 		if err != nil {
-			response.Fail(c, fmt.Sprintf("%s type not match", k), nil)
-			return
+			return fmt.Errorf("%s type not match", k)
 		}
 		if !ok { // ignore invalid field
 			continue
 		}
+		if obj.jsonColumns[k] {
+			v = jsonColumn{v}
+		}
 		vals[fieldName] = v
 	}
 
@@ -533,32 +784,73 @@ func handleEditObject(c *gin.Context, obj *WebObject) {
 		vals = map[string]any{}
 	}
 
+	if obj.VersionField != "" {
+		// the version is only changed by the update
+		delete(vals, db.NamingStrategy.ColumnName(obj.tableName, obj.VersionField))
+	}
+	if obj.TenantField != "" {
+		// rows never move across tenants
+		delete(vals, obj.tenantColumn(db))
+	}
+
 	if len(vals) == 0 {
-		response.Fail(c, "not changed", nil)
-		return
+		return errors.New("not changed")
+	}
+	if err := obj.validateEdit(c, inputVals); err != nil {
+		return err
 	}
 	db = obj.buildPrimaryCondition(db.Model(obj.Model), keys)
 
-	if obj.BeforeUpdate != nil {
-		val := reflect.New(obj.modelElem).Interface()
+	inTx := obj.inTransaction(db)
+	var val any
+	if obj.BeforeUpdate != nil || obj.VersionField != "" || inTx {
+		val = reflect.New(obj.modelElem).Interface()
 		tx := db.Session(&gorm.Session{})
 		if err := tx.First(val).Error; err != nil {
-			response.Fail(c, "not found", nil)
-			return
+			return errors.New("not found")
 		}
-		if err := obj.BeforeUpdate(db, c, val, inputVals); err != nil {
-			response.Fail(c, err.Error(), nil)
-			return
+
+		if obj.VersionField != "" {
+			if ifMatch == "" {
+				return ErrPreconditionRequired
+			}
+			if !obj.matchVersion(val, ifMatch) {
+				return ErrPreconditionFailed
+			}
+			cond, column, next := obj.versionCondition(db, val)
+			db = db.Where(cond)
+			vals[column] = next
 		}
-	}
 
-	result := db.Updates(vals)
-	if result.Error != nil {
-		response.Fail(c, result.Error.Error(), nil)
-		return
+		if obj.BeforeUpdate != nil {
+			if err := obj.BeforeUpdate(db, c, val, inputVals); err != nil {
+				return err
+			}
+		}
 	}
 
-	response.Success(c, "updated successfully", true)
+	update := func(tx *gorm.DB) error {
+		result := tx.Updates(vals)
+		if result.Error != nil {
+			return result.Error
+		}
+		if obj.VersionField != "" && result.RowsAffected == 0 {
+			// changed by others after loaded
+			return ErrPreconditionFailed
+		}
+		if !inTx {
+			return nil
+		}
+		after := reflect.New(obj.modelElem).Interface()
+		if err := obj.buildPrimaryCondition(tx.Session(&gorm.Session{NewDB: true}), keys).Take(after).Error; err != nil {
+			return err
+		}
+		return obj.afterWrite(tx, c, HistoryActionEdit, val, after)
+	}
+	if !inTx {
+		return update(db)
+	}
+	return db.Transaction(update)
 }
 
 func handleDeleteObject(c *gin.Context, obj *WebObject) {
@@ -568,7 +860,18 @@ func handleDeleteObject(c *gin.Context, obj *WebObject) {
 		return
 	}
 
-	db := GetDbConnection(c, obj.GetDB, false)
+	db := obj.getDB(c, false)
+	if err := obj.deleteObject(db, c, keys); err != nil {
+		response.Fail(c, err.Error(), nil)
+		return
+	}
+	obj.afterCommit(c)
+
+	response.Success(c, "deleted successfully", true)
+}
+
+// deleteObject load the record identified by keys, run BeforeDelete and delete it.
+func (obj *WebObject) deleteObject(db *gorm.DB, c *gin.Context, keys []string) error {
 	val := reflect.New(obj.modelElem).Interface()
 
 	r := obj.buildPrimaryCondition(db, keys).Session(&gorm.Session{}).First(val)
@@ -576,37 +879,80 @@ func handleDeleteObject(c *gin.Context, obj *WebObject) {
 	// for gorm delete hook, need to load rtcmedia first.
 	if r.Error != nil {
 		if errors.Is(r.Error, gorm.ErrRecordNotFound) {
-			response.Fail(c, "not found", nil)
-		} else {
-			response.Fail(c, r.Error.Error(), nil)
+			return errors.New("not found")
 		}
-		return
+		return r.Error
 	}
 
 	if obj.BeforeDelete != nil {
 		if err := obj.BeforeDelete(db, c, val); err != nil {
-			response.Fail(c, err.Error(), nil)
-			return
+			return err
 		}
 	}
 
-	r = db.Delete(val)
-	if r.Error != nil {
-		response.Fail(c, r.Error.Error(), nil)
+	if !obj.inTransaction(db) {
+		return db.Delete(val).Error
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(val).Error; err != nil {
+			return err
+		}
+		return obj.afterWrite(tx, c, HistoryActionDelete, val, nil)
+	})
+}
+
+func handleQueryObject(c *gin.Context, obj *WebObject, prepareQuery PrepareQuery) {
+	db, form, err := obj.prepareQueryForm(c, prepareQuery)
+	if err != nil {
+		response.Fail(c, err.Error(), nil)
 		return
 	}
 
-	response.Success(c, "deleted successfully", true)
+	cacheKey, hit := obj.serveCache(c, "query", []any{form, form.ViewFields})
+	if hit {
+		return
+	}
+
+	r, err := obj.queryObjects(db, c, form)
+	if err != nil {
+		response.Fail(c, err.Error(), nil)
+		return
+	}
+
+	if obj.BeforeQueryRender != nil {
+		result, err := obj.BeforeQueryRender(db, c, &r)
+		if err != nil {
+			response.Fail(c, err.Error(), nil)
+			return
+		}
+
+		if c.Writer.Written() || c.Writer.Status() != http.StatusOK {
+			// if body has written, return
+			return
+		}
+
+		if result != nil {
+			if cacheKey != "" {
+				obj.setCache(c, cacheKey, "", result)
+			}
+			response.Success(c, "success", result)
+			return
+		}
+	}
+	if cacheKey != "" {
+		obj.setCache(c, cacheKey, "", r)
+	}
+	response.Success(c, "success", r)
 }
 
-func handleQueryObject(c *gin.Context, obj *WebObject, prepareQuery PrepareQuery) {
+// prepareQueryForm run prepareQuery and strip the QueryForm to the whitelists.
+func (obj *WebObject) prepareQueryForm(c *gin.Context, prepareQuery PrepareQuery) (*gorm.DB, *QueryForm, error) {
 	if prepareQuery == nil {
 		prepareQuery = DefaultPrepareQuery
 	}
-	db, form, err := prepareQuery(GetDbConnection(c, obj.GetDB, false), c)
+	db, form, err := prepareQuery(obj.getDB(c, false), c)
 	if err != nil {
-		response.Fail(c, err.Error(), nil)
-		return
+		return nil, nil, err
 	}
 
 	namer := db.NamingStrategy
@@ -618,29 +964,11 @@ func handleQueryObject(c *gin.Context, obj *WebObject, prepareQuery PrepareQuery
 	}
 
 	if len(filterFields) > 0 {
-		var stripFilters []Filter
-		for i := 0; i < len(form.Filters); i++ {
-			filter := form.Filters[i]
-			// Struct must has this field.
-			field, ok := obj.jsonToFields[filter.Name]
-			if !ok {
-				continue
-			}
-			if _, ok := filterFields[field]; !ok {
-				continue
-			}
-
-			if f, ok := obj.modelElem.FieldByName(field); ok {
-				var typeName string = f.Type.Name()
-				if f.Type.Kind() == reflect.Ptr {
-					typeName = f.Type.Elem().Name()
-				}
-				filter.isTimeType = typeName == "Time" || typeName == "NullTime" || typeName == "DeletedAt"
-			}
-			filter.Name = namer.ColumnName(obj.tableName, field)
-			stripFilters = append(stripFilters, filter)
+		nodes := 0
+		form.Filters, err = obj.stripFilters(form.Filters, filterFields, namer, 0, &nodes)
+		if err != nil {
+			return nil, nil, err
 		}
-		form.Filters = stripFilters
 	} else {
 		form.Filters = []Filter{}
 	}
@@ -660,7 +988,8 @@ func handleQueryObject(c *gin.Context, obj *WebObject, prepareQuery PrepareQuery
 			if _, ok := orderFields[field]; !ok {
 				continue
 			}
-			order.Name = namer.ColumnName(obj.tableName, order.Name)
+			order.field = field
+			order.Name = namer.ColumnName(obj.tableName, field)
 			stripOrders = append(stripOrders, order)
 		}
 		form.Orders = stripOrders
@@ -669,9 +998,16 @@ func handleQueryObject(c *gin.Context, obj *WebObject, prepareQuery PrepareQuery
 	}
 
 	if form.Keyword != "" {
-		form.searchFields = []string{}
-		for _, v := range obj.Searchables {
-			form.searchFields = append(form.searchFields, namer.ColumnName(obj.tableName, v))
+		if obj.SearchIndex != nil && obj.SearchIndex.Keyword {
+			form.searchKeys, err = obj.searchKeyword(c, form.Keyword)
+			if err != nil {
+				return nil, nil, err
+			}
+		} else {
+			form.searchFields = []string{}
+			for _, v := range obj.Searchables {
+				form.searchFields = append(form.searchFields, namer.ColumnName(obj.tableName, v))
+			}
 		}
 	}
 
@@ -683,30 +1019,35 @@ func handleQueryObject(c *gin.Context, obj *WebObject, prepareQuery PrepareQuery
 		form.ViewFields = stripViewFields
 	}
 
-	r, err := obj.queryObjects(db, c, form)
+	if len(form.Expand) == 0 {
+		form.Expand = c.QueryArray("expand")
+	}
+	expands, columns, err := obj.resolveExpands(db, form.Expand)
 	if err != nil {
-		response.Fail(c, err.Error(), nil)
-		return
+		return nil, nil, err
 	}
-
-	if obj.BeforeQueryRender != nil {
-		result, err := obj.BeforeQueryRender(db, c, &r)
-		if err != nil {
-			response.Fail(c, err.Error(), nil)
-			return
-		}
-
-		if c.Writer.Written() || c.Writer.Status() != http.StatusOK {
-			// if body has written, return
-			return
+	form.expands = expands
+	if len(form.ViewFields) > 0 {
+		form.ViewFields = appendColumns(form.ViewFields, columns...)
+		if obj.VersionField != "" {
+			// items carry the version for the later edits
+			form.ViewFields = appendColumns(form.ViewFields, namer.ColumnName(obj.tableName, obj.VersionField))
 		}
+	}
+	return db, form, nil
+}
 
-		if result != nil {
-			response.Success(c, "success", result)
-			return
-		}
+// isTimeField return true if the struct field is a time type.
+func (obj *WebObject) isTimeField(field string) bool {
+	f, ok := obj.modelElem.FieldByName(field)
+	if !ok {
+		return false
 	}
-	response.Success(c, "success", r)
+	var typeName string = f.Type.Name()
+	if f.Type.Kind() == reflect.Ptr {
+		typeName = f.Type.Elem().Name()
+	}
+	return typeName == "Time" || typeName == "NullTime" || typeName == "DeletedAt"
 }
 
 func castTime(value any) any {
@@ -722,75 +1063,52 @@ func castTime(value any) any {
 }
 
 func (obj *WebObject) queryObjects(db *gorm.DB, ctx *gin.Context, form *QueryForm) (r QueryResult, err error) {
-	tblName := db.NamingStrategy.TableName(obj.tableName)
-
-	for _, v := range form.Filters {
-		if q := v.GetQuery(); q != "" {
-			if v.Op == FilterOpLike {
-				if kws, ok := v.Value.([]any); ok {
-					qs := []string{}
-					for _, kw := range kws {
-						k := fmt.Sprintf("\"%%%s%%\"", strings.ReplaceAll(kw.(string), "\"", "\\\""))
-						q := fmt.Sprintf("`%s`.`%s` LIKE %s", tblName, v.Name, k)
-						qs = append(qs, q)
-					}
-					db = db.Where(strings.Join(qs, " OR "))
-				} else {
-					db = db.Where(fmt.Sprintf("`%s`.%s", tblName, q), fmt.Sprintf("%%%s%%", v.Value))
-				}
-			} else if v.Op == FilterOpBetween {
-				vt := reflect.ValueOf(v.Value)
-				if vt.Kind() != reflect.Slice && vt.Len() != 2 {
-					return r, fmt.Errorf("invalid between value, must be slice with 2 elements")
-				}
-
-				leftValue := vt.Index(0).Interface()
-				rightValue := vt.Index(1).Interface()
-				if v.isTimeType {
-					leftValue = castTime(leftValue)
-					rightValue = castTime(rightValue)
-				}
-				db = db.Where(fmt.Sprintf("`%s`.%s", tblName, q), leftValue, rightValue)
-			} else {
-				if v.isTimeType {
-					v.Value = castTime(v.Value)
-				}
-				db = db.Where(fmt.Sprintf("`%s`.%s", tblName, q), v.Value)
-			}
-		}
+	cursorMode := form.CursorMode || form.Cursor != ""
+	db, err = obj.applyQueryForm(db, form, !cursorMode)
+	if err != nil {
+		return r, err
 	}
 
-	for _, v := range form.Orders {
-		if q := v.GetQuery(); q != "" {
-			db = db.Order(fmt.Sprintf("%s.%s", tblName, q))
+	var keyset []keysetColumn
+	if cursorMode {
+		if keyset, err = obj.keysetColumns(db, form); err != nil {
+			return r, err
 		}
-	}
-
-	if form.Keyword != "" && len(form.searchFields) > 0 {
-		var query []string
-		for _, v := range form.searchFields {
-			query = append(query, fmt.Sprintf("`%s`.`%s` LIKE @keyword", tblName, v))
+		if len(form.ViewFields) > 0 {
+			for _, col := range keyset {
+				if !slices.Contains(form.ViewFields, col.column) {
+					form.ViewFields = append(form.ViewFields, col.column)
+				}
+			}
 		}
-		searchKey := strings.Join(query, " OR ")
-		db = db.Where(searchKey, sql.Named("keyword", "%"+form.Keyword+"%"))
 	}
 
 	if len(form.ViewFields) > 0 {
 		db = db.Select(form.ViewFields)
 	}
 
-	r.Pos = form.Pos
 	r.Limit = form.Limit
 	r.Keyword = form.Keyword
+	if !cursorMode {
+		r.Pos = form.Pos
+	}
 
-	var c int64
-	if err := db.Model(obj.Model).Count(&c).Error; err != nil {
-		return r, err
+	if !form.SkipCount {
+		var c int64
+		if err := db.Model(obj.Model).Count(&c).Error; err != nil {
+			return r, err
+		}
+		if c <= 0 {
+			return r, nil
+		}
+		r.TotalCount = int(c)
 	}
-	if c <= 0 {
-		return r, nil
+
+	db = applyExpands(db, form.expands)
+
+	if cursorMode {
+		return obj.queryObjectsByCursor(db, ctx, form, keyset, r)
 	}
-	r.TotalCount = int(c)
 
 	vals := reflect.New(reflect.SliceOf(obj.modelElem))
 	result := db.Offset(form.Pos).Limit(form.Limit).Find(vals.Interface())
@@ -798,23 +1116,71 @@ func (obj *WebObject) queryObjects(db *gorm.DB, ctx *gin.Context, form *QueryFor
 		return r, result.Error
 	}
 
-	r.Items = make([]any, 0, vals.Elem().Len())
-	for i := 0; i < vals.Elem().Len(); i++ {
-		modelObj := vals.Elem().Index(i).Addr().Interface()
+	r.Items, err = obj.renderQueryItems(db, ctx, vals.Elem())
+	if err != nil {
+		return r, err
+	}
+	r.Pos += int(len(r.Items))
+	return r, nil
+}
+
+// applyQueryForm apply the filters, keyword and (if withOrders) orders of form to db.
+func (obj *WebObject) applyQueryForm(db *gorm.DB, form *QueryForm, withOrders bool) (*gorm.DB, error) {
+	tblName := obj.dbTable(db)
+
+	db, err := applyFilters(db, tblName, form.Filters)
+	if err != nil {
+		return nil, err
+	}
+
+	if withOrders {
+		for _, v := range form.Orders {
+			db = db.Order(clause.OrderByColumn{
+				Column: clause.Column{Table: tblName, Name: v.Name},
+				Desc:   v.Op == OrderOpDesc,
+			})
+		}
+	}
+
+	if form.Keyword != "" && len(form.searchFields) > 0 {
+		var query []string
+		var args []any
+		for _, v := range form.searchFields {
+			query = append(query, "? LIKE ? ESCAPE '"+likeEscape+"'")
+			args = append(args, clause.Column{Table: tblName, Name: v}, "%"+escapeLike(form.Keyword)+"%")
+		}
+		db = db.Where("("+strings.Join(query, " OR ")+")", args...)
+	}
+
+	if form.searchKeys != nil {
+		if len(form.searchKeys) == 0 {
+			db = db.Where("1 = 0")
+		} else {
+			column := db.NamingStrategy.ColumnName(obj.tableName, obj.uniqueKeys[0].Name)
+			db = db.Where("? IN ?", clause.Column{Table: tblName, Name: column}, form.searchKeys)
+		}
+	}
+	return db, nil
+}
+
+// renderQueryItems run BeforeRender on each model of the slice value.
+func (obj *WebObject) renderQueryItems(db *gorm.DB, ctx *gin.Context, vals reflect.Value) ([]any, error) {
+	items := make([]any, 0, vals.Len())
+	for i := 0; i < vals.Len(); i++ {
+		modelObj := vals.Index(i).Addr().Interface()
 		if obj.BeforeRender != nil {
 			rr, err := obj.BeforeRender(db, ctx, modelObj)
 			if err != nil {
-				return r, err
+				return nil, err
 			}
 			if rr != nil {
 				// if BeforeRender return not nil, then use it as result
 				modelObj = rr
 			}
 		}
-		r.Items = append(r.Items, modelObj)
+		items = append(items, modelObj)
 	}
-	r.Pos += int(len(r.Items))
-	return r, nil
+	return items, nil
 }
 
 // DefaultPrepareQuery return default QueryForm.
@@ -0,0 +1,318 @@
+package LingEcho
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/code-100-precent/LingFramework/pkg/utils/response"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	AggregateOpCount = "count"
+	AggregateOpSum   = "sum"
+	AggregateOpAvg   = "avg"
+	AggregateOpMin   = "min"
+	AggregateOpMax   = "max"
+)
+
+const (
+	BucketHour  = "hour"
+	BucketDay   = "day"
+	BucketWeek  = "week" // the monday of the week
+	BucketMonth = "month"
+	BucketYear  = "year"
+)
+
+var aliasPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,63}$`)
+
+// AggregateGroup is a group-by field, time fields can be bucketed.
+type AggregateGroup struct {
+	Name   string `json:"name"`
+	Bucket string `json:"bucket,omitempty"`
+	As     string `json:"as,omitempty"`
+}
+
+// AggregateMetric is an aggregate function, count without Name counts the rows.
+type AggregateMetric struct {
+	Op   string `json:"op"`
+	Name string `json:"name,omitempty"`
+	As   string `json:"as,omitempty"`
+}
+
+// AggregateForm is the request body of the aggregate route, the filters and
+// keyword of QueryForm are read from the same body.
+type AggregateForm struct {
+	GroupBy []AggregateGroup  `json:"groupBy,omitempty"`
+	Metrics []AggregateMetric `json:"metrics"`
+	Sort    []Order           `json:"sort,omitempty"` // by the result columns
+	Limit   int               `json:"limit,omitempty"`
+}
+
+// AggregateResult is a table, the group-by columns first then the metrics.
+type AggregateResult struct {
+	Columns []string `json:"columns"`
+	Rows    [][]any  `json:"rows"`
+}
+
+func handleAggregateObject(c *gin.Context, obj *WebObject) {
+	// the body is read twice, as AggregateForm and as QueryForm
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		response.Fail(c, err.Error(), nil)
+		return
+	}
+	var form AggregateForm
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &form); err != nil {
+			response.Fail(c, err.Error(), nil)
+			return
+		}
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	db, queryForm, err := obj.prepareQueryForm(c, obj.PrepareQuery)
+	if err != nil {
+		response.Fail(c, err.Error(), nil)
+		return
+	}
+	db, err = obj.applyQueryForm(db, queryForm, false)
+	if err != nil {
+		response.Fail(c, err.Error(), nil)
+		return
+	}
+
+	r, err := obj.aggregateObjects(db, &form)
+	if err != nil {
+		response.Fail(c, err.Error(), nil)
+		return
+	}
+
+	if obj.BeforeAggregateRender != nil {
+		result, err := obj.BeforeAggregateRender(db, c, r)
+		if err != nil {
+			response.Fail(c, err.Error(), nil)
+			return
+		}
+
+		if c.Writer.Written() || c.Writer.Status() != http.StatusOK {
+			// if body has written, return
+			return
+		}
+
+		if result != nil {
+			response.Success(c, "success", result)
+			return
+		}
+	}
+	response.Success(c, "success", r)
+}
+
+// aggregateObjects run the GROUP BY query, the groups are referenced by
+// position so that the same SQL works on sqlite, mysql and postgres.
+func (obj *WebObject) aggregateObjects(db *gorm.DB, form *AggregateForm) (*AggregateResult, error) {
+	if len(form.Metrics) == 0 {
+		return nil, fmt.Errorf("metrics required")
+	}
+
+	tblName := obj.dbTable(db)
+	r := &AggregateResult{}
+	var selects []string
+	var args []any
+
+	addColumn := func(alias string) error {
+		if !aliasPattern.MatchString(alias) {
+			return fmt.Errorf("invalid alias: %s", alias)
+		}
+		for _, v := range r.Columns {
+			if v == alias {
+				return fmt.Errorf("duplicate column: %s", alias)
+			}
+		}
+		r.Columns = append(r.Columns, alias)
+		return nil
+	}
+
+	for _, g := range form.GroupBy {
+		field, ok := obj.jsonToFields[g.Name]
+		if !ok || !slices.Contains(obj.Groupables, field) {
+			return nil, fmt.Errorf("group by %s not allowed", g.Name)
+		}
+		col := clause.Column{Table: tblName, Name: db.NamingStrategy.ColumnName(obj.tableName, field)}
+
+		expr := "?"
+		if g.Bucket != "" {
+			if !obj.isTimeField(field) {
+				return nil, fmt.Errorf("bucket on %s not allowed, not a time field", g.Name)
+			}
+			var err error
+			if expr, err = bucketExpr(db.Dialector.Name(), g.Bucket); err != nil {
+				return nil, err
+			}
+		}
+
+		alias := g.As
+		if alias == "" {
+			alias = g.Name
+		}
+		if err := addColumn(alias); err != nil {
+			return nil, err
+		}
+		selects = append(selects, expr+" AS ?")
+		args = append(args, repeatArg(col, strings.Count(expr, "?"))...)
+		args = append(args, clause.Column{Name: alias})
+	}
+
+	for _, m := range form.Metrics {
+		op := strings.ToLower(m.Op)
+		switch op {
+		case AggregateOpCount, AggregateOpSum, AggregateOpAvg, AggregateOpMin, AggregateOpMax:
+		default:
+			return nil, fmt.Errorf("invalid aggregate op: %s", m.Op)
+		}
+
+		var expr string
+		var exprArgs []any
+		if m.Name == "" {
+			if op != AggregateOpCount {
+				return nil, fmt.Errorf("%s requires a field", op)
+			}
+			expr = "COUNT(*)"
+		} else {
+			field, ok := obj.jsonToFields[m.Name]
+			if !ok || !slices.Contains(obj.Aggregatables, field) {
+				return nil, fmt.Errorf("%s of %s not allowed", op, m.Name)
+			}
+			expr = strings.ToUpper(op) + "(?)"
+			exprArgs = []any{clause.Column{Table: tblName, Name: db.NamingStrategy.ColumnName(obj.tableName, field)}}
+		}
+
+		alias := m.As
+		if alias == "" {
+			alias = op
+			if m.Name != "" {
+				alias = op + "_" + m.Name
+			}
+		}
+		if err := addColumn(alias); err != nil {
+			return nil, err
+		}
+		selects = append(selects, expr+" AS ?")
+		args = append(append(args, exprArgs...), clause.Column{Name: alias})
+	}
+
+	db = db.Model(obj.Model).Select(strings.Join(selects, ", "), args...)
+	if len(form.GroupBy) > 0 {
+		var positions []clause.Column
+		for i := range form.GroupBy {
+			positions = append(positions, clause.Column{Name: strconv.Itoa(i + 1), Raw: true})
+		}
+		db = db.Clauses(clause.GroupBy{Columns: positions})
+	}
+
+	if len(form.Sort) > 0 {
+		for _, v := range form.Sort {
+			pos := -1
+			for i, col := range r.Columns {
+				if col == v.Name {
+					pos = i + 1
+				}
+			}
+			if pos < 0 {
+				return nil, fmt.Errorf("invalid sort column: %s", v.Name)
+			}
+			if v.Op == OrderOpDesc {
+				db = db.Order(strconv.Itoa(pos) + " DESC")
+			} else {
+				db = db.Order(strconv.Itoa(pos) + " ASC")
+			}
+		}
+	} else {
+		for i := range form.GroupBy {
+			db = db.Order(strconv.Itoa(i + 1))
+		}
+	}
+
+	if form.Limit <= 0 || form.Limit > DefaultQueryLimit {
+		form.Limit = DefaultQueryLimit
+	}
+	rows, err := db.Limit(form.Limit).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	r.Rows = [][]any{}
+	for rows.Next() {
+		vals := make([]any, len(r.Columns))
+		ptrs := make([]any, len(vals))
+		for i := range vals {
+			ptrs[i] = &vals[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		for i, v := range vals {
+			if b, ok := v.([]byte); ok {
+				vals[i] = string(b)
+			}
+		}
+		r.Rows = append(r.Rows, vals)
+	}
+	return r, rows.Err()
+}
+
+// bucketExpr return the SQL truncating the time column ? to the bucket, as text.
+func bucketExpr(dialect, bucket string) (string, error) {
+	var formats map[string]string
+	switch dialect {
+	case "sqlite":
+		formats = map[string]string{
+			BucketHour:  "strftime('%Y-%m-%d %H:00', ?)",
+			BucketDay:   "strftime('%Y-%m-%d', ?)",
+			BucketWeek:  "date(?, '-6 days', 'weekday 1')",
+			BucketMonth: "strftime('%Y-%m', ?)",
+			BucketYear:  "strftime('%Y', ?)",
+		}
+	case "mysql":
+		formats = map[string]string{
+			BucketHour:  "DATE_FORMAT(?, '%Y-%m-%d %H:00')",
+			BucketDay:   "DATE_FORMAT(?, '%Y-%m-%d')",
+			BucketWeek:  "DATE_FORMAT(DATE_SUB(?, INTERVAL WEEKDAY(?) DAY), '%Y-%m-%d')",
+			BucketMonth: "DATE_FORMAT(?, '%Y-%m')",
+			BucketYear:  "DATE_FORMAT(?, '%Y')",
+		}
+	case "postgres":
+		formats = map[string]string{
+			BucketHour:  "to_char(?, 'YYYY-MM-DD HH24:00')",
+			BucketDay:   "to_char(?, 'YYYY-MM-DD')",
+			BucketWeek:  "to_char(date_trunc('week', ?), 'YYYY-MM-DD')",
+			BucketMonth: "to_char(?, 'YYYY-MM')",
+			BucketYear:  "to_char(?, 'YYYY')",
+		}
+	default:
+		return "", fmt.Errorf("bucket not supported on %s", dialect)
+	}
+	expr, ok := formats[bucket]
+	if !ok {
+		return "", fmt.Errorf("invalid bucket: %s", bucket)
+	}
+	return expr, nil
+}
+
+func repeatArg(arg any, n int) []any {
+	args := make([]any, n)
+	for i := range args {
+		args[i] = arg
+	}
+	return args
+}
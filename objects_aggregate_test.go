@@ -0,0 +1,94 @@
+package LingEcho
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestWebObjectAggregate(t *testing.T) {
+	r, db := newTestObject(t, &WebObject{
+		Model:         testItem{},
+		Filterables:   []string{"Status"},
+		Groupables:    []string{"Status", "CreatedAt"},
+		Aggregatables: []string{"Score"},
+		AllowMethods:  QUERY | AGGREGATE,
+		BeforeAggregateRender: func(db *gorm.DB, ctx *gin.Context, r *AggregateResult) (any, error) {
+			r.Columns = append(r.Columns, "rendered")
+			return nil, nil
+		},
+	})
+	base := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	items := []testItem{
+		{Name: "a", Status: "on", Score: 1, CreatedAt: base},
+		{Name: "b", Status: "on", Score: 3, CreatedAt: base.Add(time.Hour)},
+		{Name: "c", Status: "off", Score: 5, CreatedAt: base.AddDate(0, 0, 1)},
+		{Name: "d", Status: "on", Score: 7, CreatedAt: base.AddDate(0, 0, 8)},
+	}
+	require.NoError(t, db.Create(&items).Error)
+
+	res := doTestRequest(r, http.MethodPost, "/api/testitem/aggregate", map[string]any{
+		"groupBy": []map[string]any{{"name": "status"}},
+		"metrics": []map[string]any{{"op": "count"}, {"op": "sum", "name": "score"}, {"op": "max", "name": "score", "as": "top"}},
+		"sort":    []map[string]any{{"name": "count", "op": "desc"}},
+	})
+	require.Equal(t, float64(200), res["code"], res["msg"])
+	data := res["data"].(map[string]any)
+	assert.Equal(t, []any{"status", "count", "sum_score", "top", "rendered"}, data["columns"])
+	assert.Equal(t, []any{
+		[]any{"on", float64(3), float64(11), float64(7)},
+		[]any{"off", float64(1), float64(5), float64(5)},
+	}, data["rows"])
+
+	// the filters of QueryForm apply
+	res = doTestRequest(r, http.MethodPost, "/api/testitem/aggregate", map[string]any{
+		"filters": []map[string]any{{"name": "status", "op": "=", "value": "on"}},
+		"groupBy": []map[string]any{{"name": "createdAt", "bucket": "day", "as": "day"}},
+		"metrics": []map[string]any{{"op": "avg", "name": "score"}},
+	})
+	require.Equal(t, float64(200), res["code"], res["msg"])
+	assert.Equal(t, []any{
+		[]any{"2024-01-01", float64(2)},
+		[]any{"2024-01-09", float64(7)},
+	}, res["data"].(map[string]any)["rows"])
+
+	res = doTestRequest(r, http.MethodPost, "/api/testitem/aggregate", map[string]any{
+		"groupBy": []map[string]any{{"name": "createdAt", "bucket": "week", "as": "week"}},
+		"metrics": []map[string]any{{"op": "count"}},
+	})
+	assert.Equal(t, []any{
+		[]any{"2024-01-01", float64(3)},
+		[]any{"2024-01-08", float64(1)},
+	}, res["data"].(map[string]any)["rows"])
+
+	for _, form := range []map[string]any{
+		{"metrics": []map[string]any{}},
+		{"groupBy": []map[string]any{{"name": "name"}}, "metrics": []map[string]any{{"op": "count"}}},
+		{"metrics": []map[string]any{{"op": "sum", "name": "id"}}},
+		{"metrics": []map[string]any{{"op": "median", "name": "score"}}},
+		{"groupBy": []map[string]any{{"name": "status", "bucket": "day"}}, "metrics": []map[string]any{{"op": "count"}}},
+		{"metrics": []map[string]any{{"op": "count", "as": "x; DROP TABLE"}}},
+	} {
+		res = doTestRequest(r, http.MethodPost, "/api/testitem/aggregate", form)
+		assert.Equal(t, float64(500), res["code"], form)
+	}
+}
+
+func TestBucketExpr(t *testing.T) {
+	for _, dialect := range []string{"sqlite", "mysql", "postgres"} {
+		for _, bucket := range []string{BucketHour, BucketDay, BucketWeek, BucketMonth, BucketYear} {
+			expr, err := bucketExpr(dialect, bucket)
+			assert.NoError(t, err)
+			assert.Contains(t, expr, "?")
+		}
+	}
+	_, err := bucketExpr("sqlserver", BucketDay)
+	assert.Error(t, err)
+	_, err = bucketExpr("sqlite", "decade")
+	assert.Error(t, err)
+}
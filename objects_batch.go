@@ -0,0 +1,207 @@
+package LingEcho
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"github.com/code-100-precent/LingFramework/pkg/utils/response"
+	"github.com/code-100-precent/LingFramework/pkg/validator"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// BatchForm is the request body of the batch route.
+// Edits carry the primary fields and the fields to change, such as:
+// {"id": 1, "email": "bob@example.org"}
+// Deletes are objects keyed by the primary fields, or the bare primary
+// value when the object has a single primary key.
+type BatchForm struct {
+	Creates []json.RawMessage `json:"creates,omitempty"`
+	Edits   []map[string]any  `json:"edits,omitempty"`
+	Deletes []any             `json:"deletes,omitempty"`
+}
+
+type BatchItemResult struct {
+	Index  int                        `json:"index"`
+	OK     bool                       `json:"ok"`
+	Error  string                     `json:"error,omitempty"`
+	Errors validator.ValidationErrors `json:"errors,omitempty"` // the field errors of a failed validation
+	Data   any                        `json:"data,omitempty"`
+}
+
+type BatchResult struct {
+	Creates []BatchItemResult `json:"creates,omitempty"`
+	Edits   []BatchItemResult `json:"edits,omitempty"`
+	Deletes []BatchItemResult `json:"deletes,omitempty"`
+}
+
+// getAllowMethods return AllowMethods, or the default methods if not set.
+func (obj *WebObject) getAllowMethods() int {
+	if obj.AllowMethods == 0 {
+		return GET | CREATE | EDIT | DELETE | QUERY
+	}
+	return obj.AllowMethods
+}
+
+// getBatchPrimaryValues return the primary values of a batch item.
+func (obj *WebObject) getBatchPrimaryValues(item any) ([]string, error) {
+	vals, ok := item.(map[string]any)
+	if !ok {
+		if len(obj.uniqueKeys) != 1 {
+			return nil, errors.New("invalid primary")
+		}
+		vals = map[string]any{obj.uniqueKeys[0].JSONName: item}
+	}
+
+	var result []string
+	for _, field := range obj.uniqueKeys {
+		v := formatPrimaryValue(vals[field.JSONName])
+		if v == "" {
+			return nil, fmt.Errorf("invalid primary: %s", field.JSONName)
+		}
+		result = append(result, v)
+	}
+	return result, nil
+}
+
+// getBatchVersion return the version of a batch edit, used as If-Match.
+func (obj *WebObject) getBatchVersion(vals map[string]any) string {
+	if obj.VersionField == "" {
+		return ""
+	}
+	f, _ := obj.modelElem.FieldByName(obj.VersionField)
+	return formatPrimaryValue(vals[jsonFieldName(f.Tag, f.Name)])
+}
+
+// formatPrimaryValue convert JSON value to the string form used in the url path.
+func formatPrimaryValue(v any) string {
+	switch vv := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return vv
+	case float64:
+		return strconv.FormatFloat(vv, 'f', -1, 64)
+	case json.Number:
+		return vv.String()
+	case map[string]any, []any:
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+func handleBatchObject(c *gin.Context, obj *WebObject) {
+	var form BatchForm
+	if err := c.BindJSON(&form); err != nil {
+		response.Fail(c, err.Error(), nil)
+		return
+	}
+
+	total := len(form.Creates) + len(form.Edits) + len(form.Deletes)
+	if total == 0 {
+		response.Fail(c, "empty batch", nil)
+		return
+	}
+	if total > DefaultBatchLimit {
+		response.Fail(c, fmt.Sprintf("too many batch items, limit %d", DefaultBatchLimit), nil)
+		return
+	}
+
+	allowMethods := obj.getAllowMethods()
+	if len(form.Creates) > 0 && allowMethods&CREATE == 0 {
+		response.Fail(c, "create not allowed", nil)
+		return
+	}
+	if len(form.Edits) > 0 && allowMethods&EDIT == 0 {
+		response.Fail(c, "edit not allowed", nil)
+		return
+	}
+	if len(form.Deletes) > 0 && allowMethods&DELETE == 0 {
+		response.Fail(c, "delete not allowed", nil)
+		return
+	}
+
+	db := obj.getDB(c, false)
+	result, err := obj.batchObjects(db, c, &form)
+	if err != nil {
+		response.Fail(c, err.Error(), result)
+		return
+	}
+	obj.afterCommit(c)
+	response.Success(c, "success", result)
+}
+
+// batchObjects apply creates, edits and deletes in one transaction.
+// It stops at the first failed item and rolls back all the others, the items
+// applied before it are reported as rolled back.
+func (obj *WebObject) batchObjects(db *gorm.DB, c *gin.Context, form *BatchForm) (*BatchResult, error) {
+	r := &BatchResult{}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// new rows are written on the create connection of GetDB, in the transaction
+		createTx := joinTransaction(obj.getDB(c, true), tx)
+		for i, raw := range form.Creates {
+			val := reflect.New(obj.modelElem).Interface()
+			err := json.Unmarshal(raw, val)
+			if err == nil {
+				err = obj.createObject(createTx, c, val)
+			}
+			if err != nil {
+				r.Creates = append(r.Creates, BatchItemResult{Index: i, Error: err.Error(), Errors: fieldErrors(err)})
+				return fmt.Errorf("creates[%d]: %w", i, err)
+			}
+			r.Creates = append(r.Creates, BatchItemResult{Index: i, OK: true, Data: val})
+		}
+
+		for i, vals := range form.Edits {
+			keys, err := obj.getBatchPrimaryValues(vals)
+			if err == nil {
+				err = obj.editObject(tx, c, keys, vals, obj.getBatchVersion(vals))
+			}
+			if err != nil {
+				r.Edits = append(r.Edits, BatchItemResult{Index: i, Error: err.Error(), Errors: fieldErrors(err)})
+				return fmt.Errorf("edits[%d]: %w", i, err)
+			}
+			r.Edits = append(r.Edits, BatchItemResult{Index: i, OK: true})
+		}
+
+		for i, item := range form.Deletes {
+			keys, err := obj.getBatchPrimaryValues(item)
+			if err == nil {
+				err = obj.deleteObject(tx, c, keys)
+			}
+			if err != nil {
+				r.Deletes = append(r.Deletes, BatchItemResult{Index: i, Error: err.Error()})
+				return fmt.Errorf("deletes[%d]: %w", i, err)
+			}
+			r.Deletes = append(r.Deletes, BatchItemResult{Index: i, OK: true})
+		}
+		return nil
+	})
+	if err != nil {
+		r.rollback()
+		discardChanges(c)
+	}
+	return r, err
+}
+
+// rollback mark the items applied before the failed one as rolled back.
+func (r *BatchResult) rollback() {
+	for _, items := range [][]BatchItemResult{r.Creates, r.Edits, r.Deletes} {
+		for i := range items {
+			if items[i].OK {
+				items[i] = BatchItemResult{Index: items[i].Index, Error: "rolled back"}
+			}
+		}
+	}
+}
+
+// joinTransaction return db bound to the transaction tx opened on another connection.
+func joinTransaction(db, tx *gorm.DB) *gorm.DB {
+	// the statement is cloned along with the context, the one of db is kept
+	db = db.Session(&gorm.Session{Context: tx.Statement.Context})
+	db.Statement.ConnPool = tx.Statement.ConnPool
+	return db
+}
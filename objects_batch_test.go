@@ -0,0 +1,143 @@
+package LingEcho
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/code-100-precent/LingFramework/pkg/constants"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestWebObjectBatch(t *testing.T) {
+	var creates, updates, deletes int
+	r, db := newTestObject(t, &WebObject{
+		Model:        testItem{},
+		Editables:    []string{"Name", "Score"},
+		AllowMethods: CREATE | EDIT | DELETE | BATCH,
+		BeforeCreate: func(db *gorm.DB, ctx *gin.Context, vptr any) error {
+			creates++
+			return nil
+		},
+		BeforeUpdate: func(db *gorm.DB, ctx *gin.Context, vptr any, vals map[string]any) error {
+			updates++
+			return nil
+		},
+		BeforeDelete: func(db *gorm.DB, ctx *gin.Context, vptr any) error {
+			deletes++
+			return nil
+		},
+	})
+
+	res := doTestRequest(r, http.MethodPost, "/api/testitem/batch", map[string]any{
+		"creates": []any{
+			map[string]any{"name": "a"},
+			map[string]any{"name": "b"},
+			map[string]any{"name": "c"},
+		},
+	})
+	require.Equal(t, float64(200), res["code"], res["msg"])
+	data := res["data"].(map[string]any)
+	assert.Len(t, data["creates"], 3)
+
+	res = doTestRequest(r, http.MethodPost, "/api/testitem/batch", map[string]any{
+		"edits":   []any{map[string]any{"id": 1, "score": 10}},
+		"deletes": []any{2, map[string]any{"id": 3}},
+	})
+	require.Equal(t, float64(200), res["code"], res["msg"])
+	assert.Equal(t, 3, creates)
+	assert.Equal(t, 1, updates)
+	assert.Equal(t, 2, deletes)
+
+	var items []testItem
+	require.NoError(t, db.Find(&items).Error)
+	require.Len(t, items, 1)
+	assert.Equal(t, 10, items[0].Score)
+}
+
+func TestWebObjectBatchRollback(t *testing.T) {
+	r, db := newTestObject(t, &WebObject{
+		Model:        testItem{},
+		Editables:    []string{"Name"},
+		AllowMethods: CREATE | EDIT | BATCH,
+		BeforeCreate: func(db *gorm.DB, ctx *gin.Context, vptr any) error {
+			if vptr.(*testItem).Name == "" {
+				return errors.New("name required")
+			}
+			return nil
+		},
+	})
+
+	res := doTestRequest(r, http.MethodPost, "/api/testitem/batch", map[string]any{
+		"creates": []any{map[string]any{"name": "a"}, map[string]any{}},
+	})
+	assert.Equal(t, float64(500), res["code"])
+	assert.Equal(t, "creates[1]: name required", res["msg"])
+
+	var count int64
+	db.Model(&testItem{}).Count(&count)
+	assert.Equal(t, int64(0), count)
+
+	res = doTestRequest(r, http.MethodPost, "/api/testitem/batch", map[string]any{
+		"deletes": []any{1},
+	})
+	assert.Equal(t, "delete not allowed", res["msg"])
+}
+
+func TestWebObjectBatchNotAllowed(t *testing.T) {
+	r, _ := newTestObject(t, &WebObject{Model: testItem{}})
+
+	res := doTestRequest(r, http.MethodPost, "/api/testitem/batch", map[string]any{
+		"creates": []any{map[string]any{"name": "a"}},
+	})
+	assert.Nil(t, res)
+}
+
+func TestWebObjectBatchCreateDB(t *testing.T) {
+	var onCreateDB []bool
+	r, db := newTestObject(t, &WebObject{
+		Model:        testItem{},
+		Editables:    []string{"Name"},
+		AllowMethods: CREATE | EDIT | BATCH,
+		GetDB: func(c *gin.Context, isCreate bool) *gorm.DB {
+			db := c.MustGet(constants.DbField).(*gorm.DB)
+			if isCreate {
+				return db.Set("create", true)
+			}
+			return db.Where("name <> ?", "hidden")
+		},
+		BeforeCreate: func(db *gorm.DB, ctx *gin.Context, vptr any) error {
+			_, ok := db.Get("create")
+			onCreateDB = append(onCreateDB, ok)
+			if vptr.(*testItem).Name == "" {
+				return errors.New("name required")
+			}
+			return nil
+		},
+	})
+
+	res := doTestRequest(r, http.MethodPost, "/api/testitem/batch", map[string]any{
+		"creates": []any{map[string]any{"name": "a"}, map[string]any{"name": "hidden"}},
+	})
+	require.Equal(t, float64(200), res["code"], res["msg"])
+	assert.Equal(t, []bool{true, true}, onCreateDB)
+
+	// the creates applied are rolled back with the failed one
+	res = doTestRequest(r, http.MethodPost, "/api/testitem/batch", map[string]any{
+		"creates": []any{map[string]any{"name": "b"}, map[string]any{"name": "c"}, map[string]any{}},
+	})
+	assert.Equal(t, "creates[2]: name required", res["msg"])
+	creates := res["data"].(map[string]any)["creates"].([]any)
+	require.Len(t, creates, 3)
+	for i, item := range creates[:2] {
+		assert.Equal(t, map[string]any{"index": float64(i), "ok": false, "error": "rolled back"}, item)
+	}
+	assert.Equal(t, "name required", creates[2].(map[string]any)["error"])
+
+	var count int64
+	db.Model(&testItem{}).Count(&count)
+	assert.Equal(t, int64(2), count)
+}
@@ -0,0 +1,153 @@
+package LingEcho
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/code-100-precent/LingFramework/pkg/cache"
+	"github.com/code-100-precent/LingFramework/pkg/utils/response"
+	"github.com/gin-gonic/gin"
+)
+
+const DefaultCacheTTL = time.Minute
+
+const cacheKeyPrefix = "lingecho:object:"
+
+// QueryCache caches the responses of GET and QUERY, including the views.
+// The entries of an object share a generation, incremented after every
+// committed create, edit or delete, so the writes invalidate all of them at
+// once. The generation lives in Cache, it's shared by the nodes with the
+// redis backend.
+type QueryCache struct {
+	Cache cache.Cache
+	TTL   time.Duration // DefaultCacheTTL by default
+	// Vary return what else the responses differ by, besides the tenant of
+	// the objects with TenantField, such as the user if GetDB or the render
+	// hooks depend on it. It's required with GetDB, BeforeRender or
+	// BeforeQueryRender, return "" if they don't depend on the request.
+	Vary func(c *gin.Context) string
+}
+
+// cacheEntry is a cached response.
+type cacheEntry struct {
+	ETag string          `json:"etag,omitempty"`
+	Data json.RawMessage `json:"data"`
+}
+
+func (obj *WebObject) cacheTTL() time.Duration {
+	if obj.Cache.TTL > 0 {
+		return obj.Cache.TTL
+	}
+	return DefaultCacheTTL
+}
+
+// cachePrefix return the prefix of the keys of obj, for the tenant of the request
+// if obj has TenantField.
+func (obj *WebObject) cachePrefix(c *gin.Context) string {
+	name := obj.Name
+	if obj.TenantField != "" {
+		name = tenantKey(CurrentTenant(c), name)
+	}
+	return cacheKeyPrefix + name
+}
+
+// cacheGeneration return the current generation of the entries, a new one
+// is started if it's missing.
+func (obj *WebObject) cacheGeneration(c *gin.Context) (string, error) {
+	ctx := searchContext(c)
+	key := obj.cachePrefix(c) + ":gen"
+	if v, ok := obj.Cache.Cache.Get(ctx, key); ok {
+		return fmt.Sprint(v), nil
+	}
+	// started from the time, not to reuse the generations of the entries alive
+	gen, err := obj.Cache.Cache.Increment(ctx, key, time.Now().UnixNano())
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(gen, 10), nil
+}
+
+// invalidateCache start a new generation of the entries of obj, after the changes are committed.
+func (obj *WebObject) invalidateCache(c *gin.Context) {
+	if obj.Cache == nil {
+		return
+	}
+	obj.Cache.Cache.Increment(searchContext(c), obj.cachePrefix(c)+":gen", 1)
+}
+
+// cacheKey return the key of the response identified by parts, in the current generation.
+func (obj *WebObject) cacheKey(c *gin.Context, kind string, parts any) (string, error) {
+	data, err := json.Marshal(parts)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	h.Write([]byte(c.Request.Method + " " + c.FullPath() + "\n"))
+	h.Write(data)
+	if obj.Cache.Vary != nil {
+		h.Write([]byte("\n" + obj.Cache.Vary(c)))
+	}
+	gen, err := obj.cacheGeneration(c)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join([]string{obj.cachePrefix(c), gen, kind, hex.EncodeToString(h.Sum(nil))}, ":"), nil
+}
+
+// getCache return the cached response of key.
+func (obj *WebObject) getCache(c *gin.Context, key string) (*cacheEntry, bool) {
+	v, ok := obj.Cache.Cache.Get(searchContext(c), key)
+	if !ok {
+		return nil, false
+	}
+	s, ok := v.(string)
+	if !ok {
+		return nil, false
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal([]byte(s), &entry); err != nil {
+		return nil, false
+	}
+	return &entry, true
+}
+
+// setCache store the response data of key, it's the best effort.
+func (obj *WebObject) setCache(c *gin.Context, key, etag string, data any) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+	entry, err := json.Marshal(cacheEntry{ETag: etag, Data: raw})
+	if err != nil {
+		return
+	}
+	obj.Cache.Cache.Set(searchContext(c), key, string(entry), obj.cacheTTL())
+}
+
+// serveCache write the cached response of parts if there is one, otherwise
+// return the key to cache the response, "" if obj has no cache.
+func (obj *WebObject) serveCache(c *gin.Context, kind string, parts any) (string, bool) {
+	if obj.Cache == nil {
+		return "", false
+	}
+	key, err := obj.cacheKey(c, kind, parts)
+	if err != nil {
+		return "", false
+	}
+	entry, ok := obj.getCache(c, key)
+	if !ok {
+		c.Header("X-Cache", "MISS")
+		return key, false
+	}
+	if entry.ETag != "" {
+		c.Header("ETag", entry.ETag)
+	}
+	c.Header("X-Cache", "HIT")
+	response.Success(c, "success", entry.Data)
+	return key, true
+}
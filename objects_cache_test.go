@@ -0,0 +1,187 @@
+package LingEcho
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/code-100-precent/LingFramework/pkg/cache"
+	"github.com/code-100-precent/LingFramework/pkg/constants"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func newTestCache() cache.Cache {
+	return cache.NewLocalCache(cache.LocalConfig{MaxSize: 1000, DefaultExpiration: time.Minute, CleanupInterval: time.Minute})
+}
+
+func TestWebObjectCache(t *testing.T) {
+	var queries int
+	r, db := newTestObject(t, &WebObject{
+		Model:        testItem{},
+		Editables:    []string{"Name", "Score"},
+		Filterables:  []string{"Name"},
+		AllowMethods: GET | CREATE | EDIT | DELETE | QUERY | BATCH,
+		Cache: &QueryCache{
+			Cache: newTestCache(),
+			TTL:   time.Minute,
+			Vary:  func(c *gin.Context) string { return "" },
+		},
+		BeforeQueryRender: func(db *gorm.DB, ctx *gin.Context, r *QueryResult) (any, error) {
+			queries++
+			return nil, nil
+		},
+	})
+	require.NoError(t, db.Create(&testItem{Name: "alice", Score: 1}).Error)
+
+	res := doTestRequest(r, http.MethodPost, "/api/testitem", map[string]any{})
+	assert.Equal(t, float64(1), res["data"].(map[string]any)["total"])
+	// changed bypassing the WebObject, the cached result is served
+	require.NoError(t, db.Create(&testItem{Name: "bob", Score: 2}).Error)
+	res = doTestRequest(r, http.MethodPost, "/api/testitem", map[string]any{})
+	assert.Equal(t, float64(1), res["data"].(map[string]any)["total"])
+	assert.Equal(t, 1, queries)
+
+	// another form is another entry
+	res = doTestRequest(r, http.MethodPost, "/api/testitem", map[string]any{
+		"filters": []any{map[string]any{"name": "name", "op": "=", "value": "bob"}},
+	})
+	assert.Equal(t, float64(1), res["data"].(map[string]any)["total"])
+	assert.Equal(t, 2, queries)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/testitem/1", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, "MISS", w.Header().Get("X-Cache"))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, "HIT", w.Header().Get("X-Cache"))
+	assert.Contains(t, w.Body.String(), `"name":"alice"`)
+
+	// the writes invalidate all the entries
+	res = doTestRequest(r, http.MethodPatch, "/api/testitem/1", map[string]any{"name": "alice2"})
+	require.Equal(t, float64(200), res["code"], res["msg"])
+	res = doTestRequest(r, http.MethodGet, "/api/testitem/1", nil)
+	assert.Equal(t, "alice2", res["data"].(map[string]any)["name"])
+	res = doTestRequest(r, http.MethodPost, "/api/testitem", map[string]any{})
+	assert.Equal(t, float64(2), res["data"].(map[string]any)["total"])
+	assert.Equal(t, 3, queries)
+
+	doTestRequest(r, http.MethodPut, "/api/testitem", map[string]any{"name": "carol"})
+	res = doTestRequest(r, http.MethodPost, "/api/testitem", map[string]any{})
+	assert.Equal(t, float64(3), res["data"].(map[string]any)["total"])
+
+	doTestRequest(r, http.MethodPost, "/api/testitem/batch", map[string]any{"deletes": []any{3}})
+	res = doTestRequest(r, http.MethodPost, "/api/testitem", map[string]any{})
+	assert.Equal(t, float64(2), res["data"].(map[string]any)["total"])
+
+	doTestRequest(r, http.MethodDelete, "/api/testitem/2", nil)
+	res = doTestRequest(r, http.MethodPost, "/api/testitem", map[string]any{})
+	assert.Equal(t, float64(1), res["data"].(map[string]any)["total"])
+	assert.Equal(t, 6, queries)
+}
+
+func TestWebObjectCacheVary(t *testing.T) {
+	r, db := newTestObject(t, &WebObject{
+		Model: testItem{},
+		Cache: &QueryCache{
+			Cache: newTestCache(),
+			Vary:  func(c *gin.Context) string { return c.GetHeader("X-User") },
+		},
+		GetDB: func(c *gin.Context, isCreate bool) *gorm.DB {
+			db := c.MustGet(constants.DbField).(*gorm.DB)
+			if user := c.GetHeader("X-User"); user != "" {
+				return db.Where("name", user)
+			}
+			return db
+		},
+	})
+	require.NoError(t, db.Create(&testItem{Name: "alice"}).Error)
+	require.NoError(t, db.Create(&testItem{Name: "bob"}).Error)
+
+	total := func(user string) any {
+		req := httptest.NewRequest(http.MethodPost, "/api/testitem", nil)
+		req.Header.Set("X-User", user)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+	assert.Contains(t, total("alice"), `"total":1`)
+	assert.Contains(t, total(""), `"total":2`)
+	assert.Contains(t, total("alice"), `"total":1`)
+}
+
+// sharedCache is the distributed cache of the nodes, with in-process pub/sub
+type sharedCache struct {
+	cache.Cache
+	mu       sync.Mutex
+	handlers []func(string)
+}
+
+func (s *sharedCache) Publish(ctx context.Context, channel, message string) error {
+	s.mu.Lock()
+	handlers := append([]func(string){}, s.handlers...)
+	s.mu.Unlock()
+	for _, h := range handlers {
+		h(message)
+	}
+	return nil
+}
+
+func (s *sharedCache) Subscribe(ctx context.Context, channel string, handler func(message string)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers = append(s.handlers, handler)
+	return nil
+}
+
+func TestWebObjectCacheAcrossNodes(t *testing.T) {
+	shared := &sharedCache{Cache: newTestCache()}
+	node := func() (*gin.Engine, *gorm.DB) {
+		layered, err := cache.NewLayeredCacheWith(newTestCache(), shared, &cache.Options{LocalExpiration: time.Minute})
+		require.NoError(t, err)
+		return newTestObject(t, &WebObject{
+			Model:        testItem{},
+			Editables:    []string{"Name"},
+			AllowMethods: GET | EDIT,
+			Cache:        &QueryCache{Cache: layered, TTL: time.Minute},
+		})
+	}
+	node1, db := node()
+	node2, _ := node()
+	get := func(r *gin.Engine) string {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/testitem/1", nil))
+		return w.Body.String()
+	}
+
+	require.NoError(t, db.Create(&testItem{Name: "alice"}).Error)
+	assert.Contains(t, get(node1), `"name":"alice"`)
+	assert.Contains(t, get(node2), `"name":"alice"`)
+
+	// the write on node1 invalidates the entries served by node2
+	res := doTestRequest(node1, http.MethodPatch, "/api/testitem/1", map[string]any{"name": "bob"})
+	require.Equal(t, float64(200), res["code"], res["msg"])
+	assert.Contains(t, get(node2), `"name":"bob"`)
+}
+
+func TestWebObjectCacheDocs(t *testing.T) {
+	obj := WebObject{Model: testItem{}, Cache: &QueryCache{Cache: newTestCache(), TTL: 30 * time.Second}}
+	require.NoError(t, obj.Build())
+	doc := GetWebObjectDocDefine("/api", obj)
+	assert.Equal(t, 30, doc.CacheTTL)
+
+	obj = WebObject{Model: testItem{}, Cache: &QueryCache{}}
+	assert.Error(t, obj.Build())
+
+	// the responses scoped to the user must vary
+	obj = WebObject{Model: testItem{}, Cache: &QueryCache{Cache: newTestCache()}, GetDB: func(c *gin.Context, isCreate bool) *gorm.DB { return nil }}
+	assert.Error(t, obj.Build())
+	obj.Cache.Vary = func(c *gin.Context) string { return c.GetHeader("X-User") }
+	assert.NoError(t, obj.Build())
+}
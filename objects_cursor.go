@@ -0,0 +1,214 @@
+package LingEcho
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvalidCursor  = errors.New("invalid cursor")
+	ErrNullableCursor = errors.New("cursor can't order by a nullable field")
+)
+
+// keysetColumn is one column of the keyset, the Orders followed by the primary keys.
+type keysetColumn struct {
+	field  string
+	column string
+	desc   bool
+	isTime bool
+}
+
+// queryCursor is the decoded form of the opaque cursor.
+type queryCursor struct {
+	Values []any `json:"v"`
+	Prev   bool  `json:"p,omitempty"`
+}
+
+// keysetColumns refuse the nullable Orders: the comparisons of the keyset
+// are never true for NULL, the rows would be skipped after the first page.
+func (obj *WebObject) keysetColumns(db *gorm.DB, form *QueryForm) ([]keysetColumn, error) {
+	var cols []keysetColumn
+	for _, v := range form.Orders {
+		if obj.isNullableField(v.field) {
+			return nil, ErrNullableCursor
+		}
+		cols = append(cols, keysetColumn{
+			field:  v.field,
+			column: v.Name,
+			desc:   v.Op == OrderOpDesc,
+			isTime: obj.isTimeField(v.field),
+		})
+	}
+	for _, k := range obj.uniqueKeys {
+		col := db.NamingStrategy.ColumnName(obj.tableName, k.Name)
+		exists := false
+		for _, v := range cols {
+			if v.column == col {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			cols = append(cols, keysetColumn{field: k.Name, column: col})
+		}
+	}
+	return cols, nil
+}
+
+// isNullableField return true if the struct field can hold NULL, a pointer or a sql.Null* like type.
+func (obj *WebObject) isNullableField(field string) bool {
+	f, ok := obj.modelElem.FieldByName(field)
+	if !ok {
+		return false
+	}
+	if f.Type.Kind() == reflect.Ptr || f.Type.Kind() == reflect.Interface {
+		return true
+	}
+	if _, ok := reflect.New(f.Type).Interface().(driver.Valuer); ok {
+		// sql.NullString, gorm.DeletedAt...
+		_, hasValid := f.Type.FieldByName("Valid")
+		return f.Type.Kind() == reflect.Struct && hasValid
+	}
+	return false
+}
+
+func encodeCursor(cur queryCursor) string {
+	data, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(value string, keyset []keysetColumn) (*queryCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cur queryCursor
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&cur); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if len(cur.Values) != len(keyset) {
+		return nil, ErrInvalidCursor
+	}
+	for i, v := range cur.Values {
+		if n, ok := v.(json.Number); ok {
+			if iv, err := n.Int64(); err == nil {
+				cur.Values[i] = iv
+			} else if fv, err := n.Float64(); err == nil {
+				cur.Values[i] = fv
+			}
+		}
+		if keyset[i].isTime {
+			cur.Values[i] = castTime(cur.Values[i])
+		}
+	}
+	return &cur, nil
+}
+
+// cursorOf build the cursor pointing at the model value.
+func cursorOf(val reflect.Value, keyset []keysetColumn, prev bool) string {
+	cur := queryCursor{Prev: prev}
+	for _, col := range keyset {
+		f := val.FieldByName(col.field)
+		if f.Kind() == reflect.Ptr {
+			if f.IsNil() {
+				cur.Values = append(cur.Values, nil)
+				continue
+			}
+			f = f.Elem()
+		}
+		cur.Values = append(cur.Values, f.Interface())
+	}
+	return encodeCursor(cur)
+}
+
+// keysetCondition build the row comparison of the keyset, such as:
+// (a > ?) OR (a = ? AND id > ?)
+func keysetCondition(tblName string, keyset []keysetColumn, values []any, prev bool) (string, []any) {
+	var exprs []string
+	var args []any
+	for i, col := range keyset {
+		var parts []string
+		for j := 0; j < i; j++ {
+			parts = append(parts, "? = ?")
+			args = append(args, clause.Column{Table: tblName, Name: keyset[j].column}, values[j])
+		}
+		op := ">"
+		if col.desc != prev {
+			op = "<"
+		}
+		parts = append(parts, "? "+op+" ?")
+		args = append(args, clause.Column{Table: tblName, Name: col.column}, values[i])
+		exprs = append(exprs, "("+strings.Join(parts, " AND ")+")")
+	}
+	return "(" + strings.Join(exprs, " OR ") + ")", args
+}
+
+// queryObjectsByCursor fetch one page after (or before) form.Cursor, ordered by the keyset.
+func (obj *WebObject) queryObjectsByCursor(db *gorm.DB, ctx *gin.Context, form *QueryForm, keyset []keysetColumn, r QueryResult) (QueryResult, error) {
+	tblName := obj.dbTable(db)
+
+	var cur *queryCursor
+	if form.Cursor != "" {
+		var err error
+		if cur, err = decodeCursor(form.Cursor, keyset); err != nil {
+			return r, err
+		}
+		q, args := keysetCondition(tblName, keyset, cur.Values, cur.Prev)
+		db = db.Where(q, args...)
+	}
+	prev := cur != nil && cur.Prev
+
+	for _, col := range keyset {
+		db = db.Order(clause.OrderByColumn{
+			Column: clause.Column{Table: tblName, Name: col.column},
+			Desc:   col.desc != prev,
+		})
+	}
+
+	vals := reflect.New(reflect.SliceOf(obj.modelElem))
+	result := db.Limit(form.Limit + 1).Find(vals.Interface())
+	if result.Error != nil {
+		return r, result.Error
+	}
+
+	rows := vals.Elem()
+	hasMore := rows.Len() > form.Limit
+	if hasMore {
+		rows = rows.Slice(0, form.Limit)
+	}
+	if prev {
+		// fetched backwards, restore the requested order
+		swap := reflect.Swapper(rows.Interface())
+		for i, j := 0, rows.Len()-1; i < j; i, j = i+1, j-1 {
+			swap(i, j)
+		}
+	}
+
+	if rows.Len() > 0 {
+		first, last := rows.Index(0), rows.Index(rows.Len()-1)
+		if hasMore || prev {
+			r.NextCursor = cursorOf(last, keyset, false)
+		}
+		if (prev && hasMore) || (!prev && cur != nil) {
+			r.PrevCursor = cursorOf(first, keyset, true)
+		}
+	}
+
+	items, err := obj.renderQueryItems(db, ctx, rows)
+	if err != nil {
+		return r, err
+	}
+	r.Items = items
+	return r, nil
+}
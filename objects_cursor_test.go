@@ -0,0 +1,110 @@
+package LingEcho
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebObjectQueryCursor(t *testing.T) {
+	r, db := newTestObject(t, &WebObject{
+		Model:      testItem{},
+		Orderables: []string{"Score"},
+	})
+	for i := 0; i < 7; i++ {
+		require.NoError(t, db.Create(&testItem{Name: fmt.Sprintf("n%d", i), Score: i % 3}).Error)
+	}
+
+	names := func(data map[string]any) (result []string) {
+		for _, v := range data["items"].([]any) {
+			result = append(result, v.(map[string]any)["name"].(string))
+		}
+		return result
+	}
+
+	form := map[string]any{
+		"limit":      3,
+		"cursorMode": true,
+		"skipCount":  true,
+		"orders":     []any{map[string]any{"name": "score", "op": "desc"}},
+	}
+	res := doTestRequest(r, http.MethodPost, "/api/testitem", form)
+	require.Equal(t, float64(200), res["code"], res["msg"])
+	data := res["data"].(map[string]any)
+	assert.Equal(t, []string{"n2", "n5", "n1"}, names(data))
+	assert.Nil(t, data["total"])
+	assert.Nil(t, data["prevCursor"])
+	next := data["nextCursor"].(string)
+
+	form["cursor"] = next
+	res = doTestRequest(r, http.MethodPost, "/api/testitem", form)
+	data = res["data"].(map[string]any)
+	assert.Equal(t, []string{"n4", "n0", "n3"}, names(data))
+	prev := data["prevCursor"].(string)
+
+	form["cursor"] = data["nextCursor"]
+	res = doTestRequest(r, http.MethodPost, "/api/testitem", form)
+	data = res["data"].(map[string]any)
+	assert.Equal(t, []string{"n6"}, names(data))
+	assert.Nil(t, data["nextCursor"])
+
+	form["cursor"] = prev
+	res = doTestRequest(r, http.MethodPost, "/api/testitem", form)
+	data = res["data"].(map[string]any)
+	assert.Equal(t, []string{"n2", "n5", "n1"}, names(data))
+	assert.Nil(t, data["prevCursor"])
+	assert.Equal(t, next, data["nextCursor"])
+
+	form["cursor"] = "invalid"
+	res = doTestRequest(r, http.MethodPost, "/api/testitem", form)
+	assert.Equal(t, ErrInvalidCursor.Error(), res["msg"])
+}
+
+type testNullableItem struct {
+	ID       uint         `json:"id" gorm:"primaryKey"`
+	Name     string       `json:"name"`
+	Rank     *int         `json:"rank"`
+	Archived sql.NullTime `json:"archived"`
+}
+
+func TestWebObjectQueryCursorNullable(t *testing.T) {
+	r, _ := newTestObject(t, &WebObject{
+		Model:      testNullableItem{},
+		Orderables: []string{"Name", "Rank", "Archived"},
+	})
+	for _, name := range []string{"rank", "archived"} {
+		res := doTestRequest(r, http.MethodPost, "/api/testnullableitem", map[string]any{
+			"cursorMode": true,
+			"orders":     []any{map[string]any{"name": name}},
+		})
+		assert.Equal(t, ErrNullableCursor.Error(), res["msg"], name)
+	}
+
+	res := doTestRequest(r, http.MethodPost, "/api/testnullableitem", map[string]any{
+		"cursorMode": true,
+		"orders":     []any{map[string]any{"name": "name"}},
+	})
+	assert.Equal(t, float64(200), res["code"], res["msg"])
+}
+
+func TestWebObjectQueryOffset(t *testing.T) {
+	r, db := newTestObject(t, &WebObject{Model: testItem{}})
+	for i := 0; i < 5; i++ {
+		require.NoError(t, db.Create(&testItem{Name: fmt.Sprintf("n%d", i)}).Error)
+	}
+
+	res := doTestRequest(r, http.MethodPost, "/api/testitem", map[string]any{"pos": 1, "limit": 2})
+	data := res["data"].(map[string]any)
+	assert.Equal(t, float64(5), data["total"])
+	assert.Equal(t, float64(3), data["pos"])
+	assert.Len(t, data["items"], 2)
+
+	res = doTestRequest(r, http.MethodPost, "/api/testitem", map[string]any{"pos": 4, "limit": 2, "skipCount": true})
+	data = res["data"].(map[string]any)
+	assert.Nil(t, data["total"])
+	assert.Len(t, data["items"], 1)
+}
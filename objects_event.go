@@ -0,0 +1,108 @@
+package LingEcho
+
+import (
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ObjectEvent is a change of a record of a WebObject.
+type ObjectEvent struct {
+	Object    string // Name of the WebObject
+	Table     string
+	Action    string // HistoryActionCreate, HistoryActionEdit or HistoryActionDelete
+	RecordKey string // primary values joined as in the url path
+	Tenant    string // the tenant of the record, if the WebObject has TenantField
+	Before    any    // the model before the change, nil on create
+	After     any    // the model after the change, nil on delete
+}
+
+// ObjectEventFunc is called in the transaction of the change, the change is
+// rolled back if it returns an error.
+type ObjectEventFunc func(db *gorm.DB, c *gin.Context, e *ObjectEvent) error
+
+// ObjectListener is notified of the changes of the WebObjects it wants.
+type ObjectListener struct {
+	// Wants return true if the changes of the object, stored in table, are
+	// listened at the moment, nil wants them all. The writes of the objects
+	// wanted by no listener skip the transaction of the listeners.
+	Wants  func(object, table string) bool
+	Notify ObjectEventFunc
+}
+
+// ObjectListeners are notified of the creates, edits and deletes of the WebObjects,
+// they must be added before the routes are served.
+var ObjectListeners []ObjectListener
+
+// AddObjectListener add fn to ObjectListeners, notified of all the WebObjects.
+func AddObjectListener(fn ObjectEventFunc) {
+	ObjectListeners = append(ObjectListeners, ObjectListener{Notify: fn})
+}
+
+// AddObjectListenerFor add fn to ObjectListeners, notified of the WebObjects wanted.
+func AddObjectListenerFor(wants func(object, table string) bool, fn ObjectEventFunc) {
+	ObjectListeners = append(ObjectListeners, ObjectListener{Wants: wants, Notify: fn})
+}
+
+// listeners return the ObjectListeners wanting the changes of obj.
+func (obj *WebObject) listeners(db *gorm.DB) []ObjectEventFunc {
+	var fns []ObjectEventFunc
+	for _, l := range ObjectListeners {
+		if l.Wants == nil || l.Wants(obj.Name, obj.dbTable(db)) {
+			fns = append(fns, l.Notify)
+		}
+	}
+	return fns
+}
+
+// inTransaction return true if the writes must be done in a transaction,
+// along with the history, the search index, the listeners or the change feed.
+func (obj *WebObject) inTransaction(db *gorm.DB) bool {
+	return obj.hasHistory() || obj.SearchIndex != nil || obj.ChangeFeed != nil || len(obj.listeners(db)) > 0
+}
+
+// afterWrite runs in the transaction of the change: record the history,
+// notify ObjectListeners and stash the update of the search index and the
+// change for the ChangeFeed.
+func (obj *WebObject) afterWrite(tx *gorm.DB, c *gin.Context, action string, before, after any) error {
+	if obj.hasHistory() {
+		if err := obj.recordHistory(tx, c, action, before, after); err != nil {
+			return err
+		}
+	}
+
+	if action == HistoryActionDelete {
+		if err := obj.stashIndex(c, before, true); err != nil {
+			return err
+		}
+	} else if err := obj.stashIndex(c, after, false); err != nil {
+		return err
+	}
+
+	listeners := obj.listeners(tx)
+	if len(listeners) == 0 && obj.ChangeFeed == nil {
+		return nil
+	}
+	val := after
+	if val == nil {
+		val = before
+	}
+	vals, err := historyValues(val)
+	if err != nil {
+		return err
+	}
+	e := &ObjectEvent{
+		Object:    obj.Name,
+		Table:     obj.dbTable(tx),
+		Action:    action,
+		RecordKey: obj.historyRecordKey(vals),
+		Tenant:    obj.tenantOf(val),
+		Before:    before,
+		After:     after,
+	}
+	for _, fn := range listeners {
+		if err := fn(tx.Session(&gorm.Session{NewDB: true}), c, e); err != nil {
+			return err
+		}
+	}
+	return obj.stashChange(c, e, val)
+}
@@ -0,0 +1,267 @@
+package LingEcho
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// MaxExpandDepth is the max levels of an expandable path, such as "Comments.Author".
+const MaxExpandDepth = 3
+
+// expandPreload is one association to preload, resolved from the expand request.
+type expandPreload struct {
+	path    string   // field path for Preload, such as "Comments.Author"
+	columns []string // selected columns, empty for all
+}
+
+// buildExpandables check Expandables and index them by the JSON path.
+func (obj *WebObject) buildExpandables() error {
+	obj.expandables = make(map[string]string)
+	for _, path := range obj.Expandables {
+		segs := strings.Split(path, ".")
+		if len(segs) > MaxExpandDepth {
+			return fmt.Errorf("%s: expandable %s too deep, max %d", obj.Name, path, MaxExpandDepth)
+		}
+
+		rt := obj.modelElem
+		var jsonSegs []string
+		for _, seg := range segs {
+			f, ok := rt.FieldByName(seg)
+			if !ok {
+				return fmt.Errorf("%s: invalid expandable %s", obj.Name, path)
+			}
+			ft := f.Type
+			for ft.Kind() == reflect.Ptr || ft.Kind() == reflect.Slice || ft.Kind() == reflect.Array {
+				ft = ft.Elem()
+			}
+			if ft.Kind() != reflect.Struct {
+				return fmt.Errorf("%s: expandable %s is not an association", obj.Name, path)
+			}
+			jsonSegs = append(jsonSegs, jsonFieldName(f.Tag, f.Name))
+			rt = ft
+		}
+		obj.expandables[strings.Join(jsonSegs, ".")] = path
+	}
+	return nil
+}
+
+// jsonFieldName return the name of the field in JSON.
+func jsonFieldName(tag reflect.StructTag, name string) string {
+	if jsonTag := strings.TrimSpace(strings.Split(tag.Get("json"), ",")[0]); jsonTag != "" {
+		return jsonTag
+	}
+	return name
+}
+
+// splitExpand split the expand values at the commas outside parentheses,
+// "author(id,name),comments" => ["author(id,name)", "comments"]
+func splitExpand(values []string) []string {
+	var items []string
+	for _, v := range values {
+		depth, start := 0, 0
+		for i, ch := range v {
+			switch ch {
+			case '(':
+				depth++
+			case ')':
+				depth--
+			case ',':
+				if depth == 0 {
+					items = append(items, v[start:i])
+					start = i + 1
+				}
+			}
+		}
+		items = append(items, v[start:])
+	}
+
+	var result []string
+	for _, item := range items {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// parseExpandItem split the path and the selected fields, "author(id,name)" => "author", ["id", "name"]
+func parseExpandItem(item string) (string, []string) {
+	open := strings.IndexByte(item, '(')
+	if open < 0 || !strings.HasSuffix(item, ")") {
+		return item, nil
+	}
+	var fields []string
+	for _, f := range strings.Split(item[open+1:len(item)-1], ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			fields = append(fields, f)
+		}
+	}
+	return strings.TrimSpace(item[:open]), fields
+}
+
+// resolveExpands keep the whitelisted items of expand and resolve the selected columns.
+// It returns the columns of the model needed to load the associations,
+// they must be selected when ViewFields is set.
+func (obj *WebObject) resolveExpands(db *gorm.DB, expand []string) ([]expandPreload, []string, error) {
+	type resolved struct {
+		expandPreload
+		parent string
+		rel    *schema.Relationship
+		fields []string
+	}
+
+	var items []*resolved
+	for _, item := range splitExpand(expand) {
+		jsonPath, fields := parseExpandItem(item)
+		path, ok := obj.expandables[jsonPath]
+		if !ok {
+			continue
+		}
+		items = slices.DeleteFunc(items, func(v *resolved) bool { return v.path == path })
+		items = append(items, &resolved{expandPreload: expandPreload{path: path}, fields: fields})
+	}
+	if len(items) == 0 {
+		return nil, nil, nil
+	}
+
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(obj.Model); err != nil {
+		return nil, nil, err
+	}
+	for _, v := range items {
+		s := stmt.Schema
+		segs := strings.Split(v.path, ".")
+		for _, seg := range segs {
+			rel, ok := s.Relationships.Relations[seg]
+			if !ok {
+				return nil, nil, fmt.Errorf("%s is not an association", v.path)
+			}
+			v.rel = rel
+			s = rel.FieldSchema
+		}
+		v.parent = strings.Join(segs[:len(segs)-1], ".")
+	}
+
+	var modelColumns []string
+	for _, v := range items {
+		if v.parent == "" {
+			modelColumns = appendColumns(modelColumns, relationColumns(stmt.Schema, v.rel)...)
+		}
+		if len(v.fields) == 0 {
+			continue
+		}
+
+		s := v.rel.FieldSchema
+		for _, f := range s.PrimaryFields {
+			v.columns = appendColumns(v.columns, f.DBName)
+		}
+		for _, name := range v.fields {
+			for _, f := range s.Fields {
+				if f.DBName != "" && jsonFieldName(f.Tag, f.Name) == name {
+					v.columns = appendColumns(v.columns, f.DBName)
+				}
+			}
+		}
+		v.columns = appendColumns(v.columns, relationColumns(s, v.rel)...)
+		// the nested associations are loaded by the columns of this one
+		for _, child := range items {
+			if child.parent == v.path {
+				v.columns = appendColumns(v.columns, relationColumns(s, child.rel)...)
+			}
+		}
+	}
+
+	// parents are preloaded before the nested associations
+	slices.SortStableFunc(items, func(a, b *resolved) int {
+		return strings.Count(a.path, ".") - strings.Count(b.path, ".")
+	})
+	var expands []expandPreload
+	for _, v := range items {
+		expands = append(expands, v.expandPreload)
+	}
+	return expands, modelColumns, nil
+}
+
+// relationColumns return the columns of s referenced by the relationship.
+func relationColumns(s *schema.Schema, rel *schema.Relationship) []string {
+	var columns []string
+	for _, ref := range rel.References {
+		for _, f := range []*schema.Field{ref.PrimaryKey, ref.ForeignKey} {
+			if f != nil && f.Schema == s && f.DBName != "" {
+				columns = appendColumns(columns, f.DBName)
+			}
+		}
+	}
+	return columns
+}
+
+func appendColumns(columns []string, names ...string) []string {
+	for _, name := range names {
+		if !slices.Contains(columns, name) {
+			columns = append(columns, name)
+		}
+	}
+	return columns
+}
+
+// applyExpands preload the associations.
+func applyExpands(db *gorm.DB, expands []expandPreload) *gorm.DB {
+	for _, v := range expands {
+		if len(v.columns) == 0 {
+			db = db.Preload(v.path)
+			continue
+		}
+		columns := v.columns
+		db = db.Preload(v.path, func(tx *gorm.DB) *gorm.DB {
+			return tx.Select(columns)
+		})
+	}
+	return db
+}
+
+// getExpandDocs describe the shapes of Expandables.
+func getExpandDocs(obj *WebObject) []DocField {
+	var docs []DocField
+	for _, path := range obj.Expandables {
+		rt := reflect.TypeOf(obj.Model)
+		if rt.Kind() == reflect.Ptr {
+			rt = rt.Elem()
+		}
+
+		var ft reflect.Type
+		var jsonSegs []string
+		isArray := false
+		for _, seg := range strings.Split(path, ".") {
+			if rt.Kind() != reflect.Struct {
+				ft = nil
+				break
+			}
+			f, ok := rt.FieldByName(seg)
+			if !ok {
+				ft = nil
+				break
+			}
+			ft = f.Type
+			jsonSegs = append(jsonSegs, jsonFieldName(f.Tag, f.Name))
+			for rt = f.Type; rt.Kind() == reflect.Ptr || rt.Kind() == reflect.Slice || rt.Kind() == reflect.Array; rt = rt.Elem() {
+				if rt.Kind() != reflect.Ptr {
+					isArray = true
+				}
+			}
+		}
+		if ft == nil {
+			continue
+		}
+
+		doc := parseDocField(ft, strings.Join(jsonSegs, "."), nil)
+		doc.FieldName = path
+		doc.IsArray = isArray
+		docs = append(docs, doc)
+	}
+	return docs
+}
@@ -0,0 +1,87 @@
+package LingEcho
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testAuthor struct {
+	ID    uint   `json:"id" gorm:"primaryKey"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+type testComment struct {
+	ID       uint        `json:"id" gorm:"primaryKey"`
+	PostID   uint        `json:"postId"`
+	Body     string      `json:"body"`
+	AuthorID uint        `json:"authorId"`
+	Author   *testAuthor `json:"author,omitempty"`
+}
+
+type testPost struct {
+	ID       uint          `json:"id" gorm:"primaryKey"`
+	Title    string        `json:"title"`
+	AuthorID uint          `json:"authorId"`
+	Author   *testAuthor   `json:"author,omitempty"`
+	Comments []testComment `json:"comments,omitempty" gorm:"foreignKey:PostID"`
+}
+
+func TestWebObjectExpand(t *testing.T) {
+	obj := &WebObject{
+		Model:       testPost{},
+		Expandables: []string{"Author", "Comments", "Comments.Author"},
+	}
+	r, db := newTestObject(t, obj)
+	require.NoError(t, db.AutoMigrate(&testAuthor{}, &testComment{}))
+
+	alice := testAuthor{Name: "alice", Email: "alice@example.org"}
+	bob := testAuthor{Name: "bob", Email: "bob@example.org"}
+	require.NoError(t, db.Create(&alice).Error)
+	require.NoError(t, db.Create(&bob).Error)
+	post := testPost{Title: "hello", AuthorID: alice.ID}
+	require.NoError(t, db.Create(&post).Error)
+	require.NoError(t, db.Create(&testComment{PostID: post.ID, Body: "hi", AuthorID: bob.ID}).Error)
+
+	res := doTestRequest(r, http.MethodGet, "/api/testpost/1", nil)
+	data := res["data"].(map[string]any)
+	assert.Nil(t, data["author"])
+	assert.Nil(t, data["comments"])
+
+	res = doTestRequest(r, http.MethodGet, "/api/testpost/1?expand=author(name),comments(body),comments.author", nil)
+	data = res["data"].(map[string]any)
+	author := data["author"].(map[string]any)
+	assert.Equal(t, "alice", author["name"])
+	assert.Equal(t, "", author["email"])
+	comment := data["comments"].([]any)[0].(map[string]any)
+	assert.Equal(t, "hi", comment["body"])
+	assert.Equal(t, "bob@example.org", comment["author"].(map[string]any)["email"])
+
+	// not in Expandables
+	res = doTestRequest(r, http.MethodGet, "/api/testpost/1?expand=comments.author.posts", nil)
+	assert.Nil(t, res["data"].(map[string]any)["comments"])
+
+	res = doTestRequest(r, http.MethodPost, "/api/testpost", map[string]any{"expand": []string{"author(email)"}})
+	item := res["data"].(map[string]any)["items"].([]any)[0].(map[string]any)
+	assert.Equal(t, "alice@example.org", item["author"].(map[string]any)["email"])
+	assert.Equal(t, "", item["author"].(map[string]any)["name"])
+
+	doc := GetWebObjectDocDefine("/api", *obj)
+	require.Len(t, doc.Expands, 3)
+	assert.Equal(t, "comments.author", doc.Expands[2].Name)
+	assert.True(t, doc.Expands[2].IsArray)
+	assert.Equal(t, "email", doc.Expands[2].Fields[2].Name)
+
+	assert.Error(t, (&WebObject{Model: testPost{}, Expandables: []string{"Title"}}).Build())
+	assert.Error(t, (&WebObject{Model: testPost{}, Expandables: []string{"Editor"}}).Build())
+}
+
+func TestSplitExpand(t *testing.T) {
+	assert.Equal(t, []string{"author(id,name)", "comments"}, splitExpand([]string{"author(id,name), comments", ""}))
+	path, fields := parseExpandItem("author(id, name)")
+	assert.Equal(t, "author", path)
+	assert.Equal(t, []string{"id", "name"}, fields)
+}
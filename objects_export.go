@@ -0,0 +1,577 @@
+package LingEcho
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/code-100-precent/LingFramework/pkg/utils"
+	"github.com/code-100-precent/LingFramework/pkg/utils/response"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	ExportFormatCSV    = "csv"
+	ExportFormatXLSX   = "xlsx"
+	ExportFormatNDJSON = "ndjson"
+)
+
+// exportFlushRows is the number of rows written between two flushes.
+const exportFlushRows = 500
+
+// maxImportErrors stop the import after so many failed rows.
+const maxImportErrors = 100
+
+var exportContentTypes = map[string]string{
+	ExportFormatCSV:    "text/csv; charset=utf-8",
+	ExportFormatXLSX:   "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	ExportFormatNDJSON: "application/x-ndjson",
+}
+
+type ImportRowError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+type ImportResult struct {
+	Total   int              `json:"total"`
+	Created int              `json:"created"`
+	DryRun  bool             `json:"dryRun,omitempty"`
+	Errors  []ImportRowError `json:"errors,omitempty"`
+}
+
+// exportRowWriter write the records of export, one implementation per format.
+type exportRowWriter interface {
+	WriteHeader(columns []string) error
+	WriteRow(columns []string, record map[string]any) error
+	Close() error
+}
+
+func newExportRowWriter(format string, w io.Writer, sheetName string) (exportRowWriter, error) {
+	switch format {
+	case ExportFormatCSV:
+		return &csvRowWriter{w: csv.NewWriter(w)}, nil
+	case ExportFormatNDJSON:
+		return &ndjsonRowWriter{w: w}, nil
+	case ExportFormatXLSX:
+		xw, err := utils.NewXLSXWriter(w, sheetName)
+		if err != nil {
+			return nil, err
+		}
+		return &xlsxRowWriter{w: xw}, nil
+	}
+	return nil, fmt.Errorf("unsupported format: %s", format)
+}
+
+type csvRowWriter struct {
+	w *csv.Writer
+}
+
+func (cw *csvRowWriter) WriteHeader(columns []string) error {
+	return cw.w.Write(columns)
+}
+
+func (cw *csvRowWriter) WriteRow(columns []string, record map[string]any) error {
+	cells := make([]string, len(columns))
+	for i, col := range columns {
+		cells[i] = formatExportCell(record[col])
+	}
+	return cw.w.Write(cells)
+}
+
+func (cw *csvRowWriter) Close() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+type ndjsonRowWriter struct {
+	w io.Writer
+}
+
+func (nw *ndjsonRowWriter) WriteHeader(columns []string) error {
+	return nil
+}
+
+// WriteRow keep the keys in columns order, so the lines are stable.
+func (nw *ndjsonRowWriter) WriteRow(columns []string, record map[string]any) error {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, col := range columns {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, _ := json.Marshal(col)
+		val, err := json.Marshal(record[col])
+		if err != nil {
+			return err
+		}
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(val)
+	}
+	b.WriteString("}\n")
+	_, err := nw.w.Write(b.Bytes())
+	return err
+}
+
+func (nw *ndjsonRowWriter) Close() error {
+	return nil
+}
+
+type xlsxRowWriter struct {
+	w *utils.XLSXWriter
+}
+
+func (xw *xlsxRowWriter) WriteHeader(columns []string) error {
+	cells := make([]any, len(columns))
+	for i, col := range columns {
+		cells[i] = col
+	}
+	return xw.w.WriteRow(cells)
+}
+
+func (xw *xlsxRowWriter) WriteRow(columns []string, record map[string]any) error {
+	cells := make([]any, len(columns))
+	for i, col := range columns {
+		switch v := record[col].(type) {
+		case nil, bool, string:
+			cells[i] = v
+		case json.Number:
+			if iv, err := v.Int64(); err == nil {
+				cells[i] = iv
+			} else if fv, err := v.Float64(); err == nil {
+				cells[i] = fv
+			} else {
+				cells[i] = v.String()
+			}
+		default:
+			cells[i] = formatExportCell(v)
+		}
+	}
+	return xw.w.WriteRow(cells)
+}
+
+func (xw *xlsxRowWriter) Close() error {
+	return xw.w.Close()
+}
+
+// formatExportCell convert JSON value to the text of a cell,
+// objects and arrays are kept as JSON.
+func formatExportCell(v any) string {
+	switch vv := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return vv
+	case json.Number:
+		return vv.String()
+	case bool:
+		return strconv.FormatBool(vv)
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+// exportColumns return the JSON names of the exported columns,
+// limited to form.ViewFields if any.
+func (obj *WebObject) exportColumns(db *gorm.DB, form *QueryForm) []string {
+	var columns []string
+	for _, f := range GetDocDefine(obj.Model).Fields {
+		if len(form.ViewFields) > 0 {
+			col := db.NamingStrategy.ColumnName(obj.tableName, f.FieldName)
+			if !slices.Contains(form.ViewFields, col) {
+				continue
+			}
+		}
+		columns = append(columns, f.Name)
+	}
+	return columns
+}
+
+// exportRecord convert the rendered model to a map keyed by the JSON names.
+func exportRecord(val any) (map[string]any, error) {
+	data, err := json.Marshal(val)
+	if err != nil {
+		return nil, err
+	}
+	var record map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// handleExportObject stream all the rows matching the QueryForm,
+// Pos and Limit are ignored.
+func handleExportObject(c *gin.Context, obj *WebObject) {
+	format := strings.ToLower(c.DefaultQuery("format", ExportFormatCSV))
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		response.Fail(c, fmt.Sprintf("unsupported format: %s", format), nil)
+		return
+	}
+
+	db, form, err := obj.prepareQueryForm(c, obj.PrepareQuery)
+	if err != nil {
+		response.Fail(c, err.Error(), nil)
+		return
+	}
+	db, err = obj.applyQueryForm(db, form, true)
+	if err != nil {
+		response.Fail(c, err.Error(), nil)
+		return
+	}
+	columns := obj.exportColumns(db, form)
+	if len(form.ViewFields) > 0 {
+		db = db.Select(form.ViewFields)
+	}
+
+	rows, err := db.Model(obj.Model).Rows()
+	if err != nil {
+		response.Fail(c, err.Error(), nil)
+		return
+	}
+	defer rows.Close()
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, obj.Name, format))
+	c.Status(http.StatusOK)
+
+	w, err := newExportRowWriter(format, c.Writer, obj.Name)
+	if err == nil {
+		err = w.WriteHeader(columns)
+	}
+
+	// the headers have been sent, errors are only recorded on the context
+	n := 0
+	for err == nil && rows.Next() {
+		val := reflect.New(obj.modelElem).Interface()
+		if err = db.ScanRows(rows, val); err != nil {
+			break
+		}
+		var render any = val
+		if obj.BeforeRender != nil {
+			var rr any
+			if rr, err = obj.BeforeRender(db, c, val); err != nil {
+				break
+			}
+			if rr != nil {
+				render = rr
+			}
+		}
+		var record map[string]any
+		if record, err = exportRecord(render); err != nil {
+			break
+		}
+		if err = w.WriteRow(columns, record); err != nil {
+			break
+		}
+		if n++; n%exportFlushRows == 0 {
+			c.Writer.Flush()
+		}
+	}
+	if err == nil {
+		err = rows.Err()
+	}
+	if w != nil {
+		if cerr := w.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		c.Error(err)
+	}
+}
+
+// importRowReader return the rows of the import file, keyed by the header.
+type importRowReader interface {
+	Read() (map[string]any, error)
+}
+
+type tableRowReader struct {
+	read   func() ([]string, error)
+	header []string
+}
+
+func (tr *tableRowReader) Read() (map[string]any, error) {
+	if tr.header == nil {
+		header, err := tr.read()
+		if err != nil {
+			return nil, err
+		}
+		for i := range header {
+			header[i] = strings.TrimSpace(header[i])
+		}
+		tr.header = header
+	}
+	cells, err := tr.read()
+	if err != nil {
+		return nil, err
+	}
+	vals := make(map[string]any, len(cells))
+	for i, cell := range cells {
+		if i < len(tr.header) && tr.header[i] != "" && cell != "" {
+			vals[tr.header[i]] = cell
+		}
+	}
+	return vals, nil
+}
+
+type ndjsonRowReader struct {
+	scanner *bufio.Scanner
+}
+
+func (nr *ndjsonRowReader) Read() (map[string]any, error) {
+	if !nr.scanner.Scan() {
+		if err := nr.scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+	line := bytes.TrimSpace(nr.scanner.Bytes())
+	if len(line) == 0 {
+		return map[string]any{}, nil
+	}
+	var vals map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(line))
+	decoder.UseNumber()
+	if err := decoder.Decode(&vals); err != nil {
+		return nil, err
+	}
+	return vals, nil
+}
+
+// openImportReader read the uploaded "file" field, or the request body.
+// The format is taken from the query, or from the file extension.
+func openImportReader(c *gin.Context) (importRowReader, int, error) {
+	format := strings.ToLower(c.Query("format"))
+	var data []byte
+	if file, header, err := c.Request.FormFile("file"); err == nil {
+		defer file.Close()
+		if format == "" {
+			format = strings.TrimPrefix(strings.ToLower(filepath.Ext(header.Filename)), ".")
+		}
+		if data, err = io.ReadAll(io.LimitReader(file, DefaultImportSize+1)); err != nil {
+			return nil, 0, err
+		}
+	} else {
+		if data, err = io.ReadAll(io.LimitReader(c.Request.Body, DefaultImportSize+1)); err != nil {
+			return nil, 0, err
+		}
+	}
+	if len(data) > DefaultImportSize {
+		return nil, 0, fmt.Errorf("import file too large, limit %d bytes", DefaultImportSize)
+	}
+	if format == "" {
+		format = ExportFormatCSV
+	}
+
+	switch format {
+	case ExportFormatCSV:
+		r := csv.NewReader(bytes.NewReader(data))
+		r.FieldsPerRecord = -1
+		return &tableRowReader{read: r.Read}, 2, nil
+	case ExportFormatXLSX:
+		r, err := utils.NewXLSXReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, 0, err
+		}
+		return &tableRowReader{read: r.Read}, 2, nil
+	case ExportFormatNDJSON, "jsonl":
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(make([]byte, 64*1024), DefaultImportSize)
+		return &ndjsonRowReader{scanner: scanner}, 1, nil
+	}
+	return nil, 0, fmt.Errorf("unsupported format: %s", format)
+}
+
+// importEditables return the JSON names of Editables.
+func (obj *WebObject) importEditables() map[string]struct{} {
+	names := make(map[string]struct{})
+	for jsonName, field := range obj.jsonToFields {
+		if slices.Contains(obj.Editables, field) {
+			names[jsonName] = struct{}{}
+		}
+	}
+	return names
+}
+
+// convertImportValue convert the text of a cell to the JSON value of the field,
+// values of NDJSON are already typed and returned as is.
+func (obj *WebObject) convertImportValue(key string, value any) (any, error) {
+	s, ok := value.(string)
+	if !ok {
+		return value, nil
+	}
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+
+	switch obj.jsonToKinds[key] {
+	case reflect.String:
+		return s, nil
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bool %q", s)
+		}
+		return b, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if _, err := strconv.ParseFloat(s, 64); err != nil {
+			return nil, fmt.Errorf("invalid number %q", s)
+		}
+		return json.Number(s), nil
+	case reflect.Struct:
+		if obj.isTimeField(obj.jsonToFields[key]) {
+			if t := castTime(s); t != any(s) {
+				return t, nil
+			}
+			// date cells of xlsx are serial numbers
+			if serial, err := strconv.ParseFloat(s, 64); err == nil {
+				return utils.XLSXSerialToTime(serial), nil
+			}
+			return nil, fmt.Errorf("invalid time %q", s)
+		}
+		fallthrough
+	case reflect.Slice, reflect.Map, reflect.Array:
+		if !json.Valid([]byte(s)) {
+			return nil, fmt.Errorf("invalid json %q", s)
+		}
+		return json.RawMessage(s), nil
+	}
+	return s, nil
+}
+
+// parseImportRow build a new model from the editable values of the row.
+func (obj *WebObject) parseImportRow(vals map[string]any, editables map[string]struct{}) (any, error) {
+	input := make(map[string]any)
+	for k, v := range vals {
+		if _, ok := editables[k]; !ok {
+			continue
+		}
+		cv, err := obj.convertImportValue(k, v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", k, err)
+		}
+		if cv != nil {
+			input[k] = cv
+		}
+	}
+	if len(input) == 0 {
+		return nil, errors.New("empty row")
+	}
+
+	data, err := json.Marshal(input)
+	if err != nil {
+		return nil, err
+	}
+	val := reflect.New(obj.modelElem).Interface()
+	if err := json.Unmarshal(data, val); err != nil {
+		return nil, err
+	}
+	return val, nil
+}
+
+// handleImportObject create a row for each record of the file in one transaction,
+// the columns are matched to the JSON names of Editables.
+// Nothing is saved if any row fails, or if dryRun is set. Once all the rows
+// are created, each one is recorded in the history, the search index, the
+// ObjectListeners and the change feed as a create.
+func handleImportObject(c *gin.Context, obj *WebObject) {
+	editables := obj.importEditables()
+	if len(editables) == 0 {
+		response.Fail(c, "import not allowed, no editable fields", nil)
+		return
+	}
+
+	reader, firstRow, err := openImportReader(c)
+	if err != nil {
+		response.Fail(c, err.Error(), nil)
+		return
+	}
+
+	dryRun, _ := strconv.ParseBool(c.Query("dryRun"))
+	result := ImportResult{DryRun: dryRun}
+	errDiscard := errors.New("discard")
+
+	db := obj.getDB(c, true)
+	var created []any
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for row := firstRow; len(result.Errors) < maxImportErrors; row++ {
+			vals, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				result.Errors = append(result.Errors, ImportRowError{Row: row, Error: err.Error()})
+				break
+			}
+			if len(vals) == 0 {
+				continue
+			}
+			result.Total++
+
+			val, err := obj.parseImportRow(vals, editables)
+			if err == nil {
+				err = obj.stampTenant(c, val)
+			}
+			if err == nil {
+				err = obj.validateCreate(c, val)
+			}
+			if err == nil && obj.BeforeCreate != nil {
+				err = obj.BeforeCreate(tx, c, val)
+			}
+			if err != nil {
+				result.Errors = append(result.Errors, ImportRowError{Row: row, Error: err.Error()})
+				continue
+			}
+			if err := tx.Create(val).Error; err != nil {
+				// the transaction may be broken, stop here
+				result.Errors = append(result.Errors, ImportRowError{Row: row, Error: err.Error()})
+				break
+			}
+			result.Created++
+			created = append(created, val)
+		}
+		if len(result.Errors) > 0 || dryRun {
+			return errDiscard
+		}
+		// once all the rows are valid, as the creates of handleCreateObject
+		for _, val := range created {
+			if err := obj.afterWrite(tx, c, HistoryActionCreate, nil, val); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	if len(result.Errors) > 0 {
+		result.Created = 0
+		response.Fail(c, "import failed", result)
+		return
+	}
+	if err != nil && !errors.Is(err, errDiscard) {
+		response.Fail(c, err.Error(), result)
+		return
+	}
+	if !dryRun {
+		obj.afterCommit(c)
+	}
+	response.Success(c, "success", result)
+}
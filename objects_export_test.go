@@ -0,0 +1,153 @@
+package LingEcho
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/code-100-precent/LingFramework/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func newExportTestObject(t *testing.T) (*gin.Engine, func(method, path, contentType string, body []byte) *httptest.ResponseRecorder) {
+	r, db := newTestObject(t, &WebObject{
+		Model:        testItem{},
+		Editables:    []string{"Name", "Status", "Score", "CreatedAt"},
+		Filterables:  []string{"Status"},
+		Orderables:   []string{"Score"},
+		AllowMethods: QUERY | EXPORT | IMPORT,
+		PrepareQuery: func(db *gorm.DB, c *gin.Context) (*gorm.DB, *QueryForm, error) {
+			db, form, err := DefaultPrepareQuery(db, c)
+			if err == nil {
+				form.ViewFields = []string{"ID", "Name", "Score"}
+			}
+			return db, form, err
+		},
+	})
+	for i, name := range []string{"alice", "bob", "carol"} {
+		status := "on"
+		if name == "bob" {
+			status = "off"
+		}
+		require.NoError(t, db.Create(&testItem{Name: name, Status: status, Score: i + 1}).Error)
+	}
+	do := func(method, path, contentType string, body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	return r, do
+}
+
+func TestWebObjectExport(t *testing.T) {
+	_, do := newExportTestObject(t)
+	form, _ := json.Marshal(map[string]any{
+		"filters": []map[string]any{{"name": "status", "op": "=", "value": "on"}},
+		"orders":  []map[string]any{{"name": "score", "op": "desc"}},
+	})
+
+	w := do(http.MethodPost, "/api/testitem/export?format=csv", "application/json", form)
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "testitem.csv")
+	records, err := csv.NewReader(w.Body).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"id", "name", "score"}, {"3", "carol", "3"}, {"1", "alice", "1"}}, records)
+
+	w = do(http.MethodPost, "/api/testitem/export?format=ndjson", "application/json", form)
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	assert.Equal(t, []string{`{"id":3,"name":"carol","score":3}`, `{"id":1,"name":"alice","score":1}`}, lines)
+
+	w = do(http.MethodPost, "/api/testitem/export?format=xlsx", "application/json", form)
+	xr, err := utils.NewXLSXReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	require.NoError(t, err)
+	defer xr.Close()
+	header, err := xr.Read()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"id", "name", "score"}, header)
+	row, err := xr.Read()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"3", "carol", "3"}, row)
+
+	w = do(http.MethodPost, "/api/testitem/export?format=pdf", "application/json", nil)
+	assert.Contains(t, w.Body.String(), "unsupported format")
+}
+
+func TestWebObjectImport(t *testing.T) {
+	r, do := newExportTestObject(t)
+
+	body := "name,score,createdAt,id\ndave,4,2024-01-02,100\neve,5,,\n"
+	w := do(http.MethodPost, "/api/testitem/import?format=csv", "text/csv", []byte(body))
+	var res map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Equal(t, float64(200), res["code"])
+	assert.Equal(t, float64(2), res["data"].(map[string]any)["created"])
+
+	// id is not editable, dave is a new row
+	query := doTestRequest(r, http.MethodPost, "/api/testitem", map[string]any{})
+	assert.Equal(t, float64(5), query["data"].(map[string]any)["total"])
+
+	// bad rows roll back the whole file
+	body = "name,score\nfrank,6\ngrace,abc\n"
+	w = do(http.MethodPost, "/api/testitem/import?format=csv", "text/csv", []byte(body))
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Equal(t, "import failed", res["msg"])
+	data := res["data"].(map[string]any)
+	assert.Equal(t, float64(0), data["created"])
+	errs := data["errors"].([]any)
+	require.Len(t, errs, 1)
+	assert.Equal(t, float64(3), errs[0].(map[string]any)["row"])
+
+	query = doTestRequest(r, http.MethodPost, "/api/testitem", map[string]any{})
+	assert.Equal(t, float64(5), query["data"].(map[string]any)["total"])
+
+	// multipart upload, format from the file extension
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	fw, err := mw.CreateFormFile("file", "items.ndjson")
+	require.NoError(t, err)
+	fw.Write([]byte(`{"name":"heidi","score":7}` + "\n\n" + `{"name":"ivan","status":"off"}` + "\n"))
+	mw.Close()
+	w = do(http.MethodPost, "/api/testitem/import", mw.FormDataContentType(), buf.Bytes())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Equal(t, float64(200), res["code"])
+	assert.Equal(t, float64(2), res["data"].(map[string]any)["created"])
+
+	// dry run validates without saving
+	w = do(http.MethodPost, "/api/testitem/import?format=csv&dryRun=true", "text/csv", []byte("name\njudy\n"))
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Equal(t, float64(200), res["code"])
+	query = doTestRequest(r, http.MethodPost, "/api/testitem", map[string]any{})
+	assert.Equal(t, float64(7), query["data"].(map[string]any)["total"])
+}
+
+func TestWebObjectImportHistory(t *testing.T) {
+	r, db := newTestObject(t, &WebObject{
+		Model:        testAccount{},
+		Editables:    []string{"Name", "Email"},
+		AllowMethods: IMPORT | HISTORY,
+	})
+	require.NoError(t, db.AutoMigrate(&ObjectHistory{}))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/testaccount/import?format=csv", strings.NewReader("name,email\nbob,bob@example.org\n"))
+	req.Header.Set("Content-Type", "text/csv")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var history []ObjectHistory
+	require.NoError(t, db.Find(&history).Error)
+	require.Len(t, history, 1)
+	assert.Equal(t, HistoryActionCreate, history[0].Action)
+	assert.Equal(t, "1", history[0].RecordKey)
+	assert.Equal(t, "bob", history[0].Changes["name"].After)
+}
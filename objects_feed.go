@@ -0,0 +1,283 @@
+package LingEcho
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/code-100-precent/LingFramework/pkg/constants"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
+)
+
+// ChangeMessageType is the type of the ChangeMessage.
+const ChangeMessageType = "object_change"
+
+// ChangeFeed publishes the creates, edits and deletes of a WebObject to the
+// subscribers of Group once they are committed, such as a websocket.Hub group.
+// Each subscriber only receives the changes of the records it can GET: the
+// edited record must be in the GetDB scope of its request, and the request
+// must pass Authenticated when the object is AuthRequired. The deletes are
+// the exception, the record is gone and can't be matched against GetDB: they
+// are sent to the subscribers of the tenant, and only carry the key.
+//
+// The request of a subscriber is the one it subscribed with, its user and
+// tenant are not resolved again: Authenticated and Member are checked for
+// the changes published, they should look up the current status of the user
+// and its membership of the tenant rather than trust the request.
+//
+// The changes are published in the background, after the response: the
+// changes committed meanwhile are checked together, with one query per
+// object and subscriber, the records deleted by then are skipped. Beyond
+// ChangeQueueSize requests waiting, the changes are dropped without a log,
+// DroppedChanges counts them.
+type ChangeFeed struct {
+	Publisher ChangePublisher
+	Group     string // "objects.{Name}" by default
+	// Authenticated reports whether the subscriber is authenticated, as the
+	// routes of the AuthRequired objects check it.
+	Authenticated func(c *gin.Context) bool
+	// Member reports whether the user of the subscriber still belongs to
+	// the tenant of its request, with TenantField. All pass if it's nil.
+	Member TenantMemberFunc
+}
+
+// ChangePublisher delivers msg to the subscribers of group whose request
+// passes allow, the request is the one they subscribed with. The delivery is
+// best effort, as the cache invalidation.
+type ChangePublisher interface {
+	PublishChange(group string, msg *ChangeMessage, allow func(c *gin.Context) bool)
+}
+
+// ChangeMessage is shaped like websocket.Message, such as:
+// {"type": "object_change", "group": "objects.user", "data": {"object": "user", "action": "edit", "key": "1", "record": {...}}}
+type ChangeMessage struct {
+	Type      string     `json:"type"`
+	Data      ChangeData `json:"data"`
+	Timestamp int64      `json:"timestamp"`
+	Group     string     `json:"group,omitempty"`
+}
+
+type ChangeData struct {
+	Object string `json:"object"`
+	Action string `json:"action"` // HistoryActionCreate, HistoryActionEdit or HistoryActionDelete
+	Key    string `json:"key"`    // primary values joined as in the url path
+	// the stored model after create and edit, deletes only carry the key as
+	// the record can't be checked against GetDB anymore.
+	Record any `json:"record,omitempty"`
+}
+
+// ChangeQueueSize is the number of the committed requests whose changes
+// wait to be published, it's read when the first changes are published.
+var ChangeQueueSize = 1024
+
+// maxChangeBatch is the number of changes checked together.
+const maxChangeBatch = 256
+
+var (
+	changeQueue   chan []pendingChange
+	changeOnce    sync.Once
+	changePending sync.WaitGroup // the changes queued, for the tests
+	changeDropped atomic.Int64
+)
+
+// DroppedChanges return the number of the changes dropped as the queue of
+// the changes to publish was full.
+func DroppedChanges() int64 {
+	return changeDropped.Load()
+}
+
+// pendingChange is a change waiting for the commit of the request.
+type pendingChange struct {
+	obj    *WebObject
+	msg    *ChangeMessage
+	keys   []string
+	tenant string
+}
+
+// ChangeGroup return the group the changes of obj are published to.
+func (obj *WebObject) ChangeGroup() string {
+	if obj.ChangeFeed != nil && obj.ChangeFeed.Group != "" {
+		return obj.ChangeFeed.Group
+	}
+	return "objects." + obj.Name
+}
+
+// stashChange keep the change in the request until afterCommit publishes it.
+func (obj *WebObject) stashChange(c *gin.Context, e *ObjectEvent, val any) error {
+	if obj.ChangeFeed == nil || obj.ChangeFeed.Publisher == nil || c == nil {
+		return nil
+	}
+	keys, err := obj.primaryValues(val)
+	if err != nil {
+		return err
+	}
+	msg := &ChangeMessage{
+		Type:  ChangeMessageType,
+		Group: obj.ChangeGroup(),
+		Data:  ChangeData{Object: obj.Name, Action: e.Action, Key: e.RecordKey, Record: e.After},
+	}
+	pending, _ := c.Get(constants.ChangesField)
+	changes, _ := pending.([]pendingChange)
+	c.Set(constants.ChangesField, append(changes, pendingChange{obj: obj, msg: msg, keys: keys, tenant: e.Tenant}))
+	return nil
+}
+
+// discardChanges drop the changes and the index updates of the request,
+// they are rolled back.
+func discardChanges(c *gin.Context) {
+	for _, key := range []string{constants.ChangesField, constants.SearchField} {
+		if _, ok := c.Get(key); ok {
+			c.Set(key, nil)
+		}
+	}
+}
+
+// afterCommit runs after the changes of the request are committed: drop the
+// cached responses of obj, update the search index and queue the changes to publish.
+func (obj *WebObject) afterCommit(c *gin.Context) {
+	obj.invalidateCache(c)
+	applyIndex(c)
+
+	pending, _ := c.Get(constants.ChangesField)
+	changes, _ := pending.([]pendingChange)
+	if len(changes) == 0 {
+		return
+	}
+	c.Set(constants.ChangesField, nil)
+	now := time.Now().Unix()
+	for _, change := range changes {
+		change.msg.Timestamp = now
+	}
+
+	changeOnce.Do(func() {
+		changeQueue = make(chan []pendingChange, ChangeQueueSize)
+		go runChangeFeed()
+	})
+	changePending.Add(1)
+	select {
+	case changeQueue <- changes:
+	default:
+		changeDropped.Add(int64(len(changes)))
+		changePending.Done()
+	}
+}
+
+// runChangeFeed publishes the changes queued, with the ones queued meanwhile.
+func runChangeFeed() {
+	for changes := range changeQueue {
+		queued := 1
+	drain:
+		for len(changes) < maxChangeBatch {
+			select {
+			case more := <-changeQueue:
+				changes = append(changes, more...)
+				queued++
+			default:
+				break drain
+			}
+		}
+		publishChanges(changes)
+		changePending.Add(-queued)
+	}
+}
+
+// changeScope is the keys of the changes of an object visible to a subscriber.
+type changeScope struct {
+	obj *WebObject
+	sub *gin.Context
+}
+
+// changeAccess is the access of a subscriber to the changes of a tenant.
+type changeAccess struct {
+	changeScope
+	tenant string
+}
+
+// publishChanges publishes the changes in order, the records edited of each
+// object are checked against the scope of a subscriber at once.
+func publishChanges(changes []pendingChange) {
+	edited := map[*WebObject][][]string{}
+	for _, change := range changes {
+		if change.msg.Data.Action != HistoryActionDelete {
+			edited[change.obj] = append(edited[change.obj], change.keys)
+		}
+	}
+	visible := map[changeScope]map[string]bool{}
+	allowed := map[changeAccess]bool{}
+	for _, change := range changes {
+		allow := func(sub *gin.Context) bool {
+			access := changeAccess{changeScope{obj: change.obj, sub: sub}, change.tenant}
+			ok, checked := allowed[access]
+			if !checked {
+				ok = change.obj.allowChange(sub, change)
+				allowed[access] = ok
+			}
+			if !ok {
+				return false
+			}
+			if change.msg.Data.Action == HistoryActionDelete {
+				return true
+			}
+			scope := changeScope{obj: change.obj, sub: sub}
+			keys, ok := visible[scope]
+			if !ok {
+				keys = change.obj.visibleKeys(sub, edited[change.obj])
+				visible[scope] = keys
+			}
+			return keys[strings.Join(change.keys, "/")]
+		}
+		change.obj.ChangeFeed.Publisher.PublishChange(change.msg.Group, change.msg, allow)
+	}
+}
+
+// allowChange reports whether the subscriber of the request sub can see the
+// changes of the tenant of change, it's checked once per subscriber and
+// tenant for the changes published together.
+func (obj *WebObject) allowChange(sub *gin.Context, change pendingChange) bool {
+	if obj.AuthRequired && (obj.ChangeFeed.Authenticated == nil || !obj.ChangeFeed.Authenticated(sub)) {
+		return false
+	}
+	if obj.TenantField != "" {
+		v, err := obj.tenantValue(sub)
+		if err != nil || fmt.Sprint(v) != change.tenant {
+			return false
+		}
+		if obj.ChangeFeed.Member != nil && !obj.ChangeFeed.Member(sub, change.tenant) {
+			return false
+		}
+	}
+	return true
+}
+
+// visibleKeys return the primary values, joined as in the url path, of the
+// records of keys in the GetDB scope of the subscriber of the request sub.
+func (obj *WebObject) visibleKeys(sub *gin.Context, keys [][]string) map[string]bool {
+	db := obj.getDB(sub, false)
+	var conds []clause.Expression
+	for _, k := range keys {
+		var eqs []clause.Expression
+		for i, field := range obj.uniqueKeys {
+			eqs = append(eqs, clause.Eq{
+				Column: clause.Column{Table: obj.dbTable(db), Name: db.NamingStrategy.ColumnName(obj.tableName, field.Name)},
+				Value:  k[i],
+			})
+		}
+		conds = append(conds, clause.And(eqs...))
+	}
+
+	result := map[string]bool{}
+	vals := reflect.New(reflect.SliceOf(obj.modelElem))
+	if err := db.Model(obj.Model).Where(clause.Or(conds...)).Find(vals.Interface()).Error; err != nil {
+		return result
+	}
+	for i := 0; i < vals.Elem().Len(); i++ {
+		if k, err := obj.primaryValues(vals.Elem().Index(i).Addr().Interface()); err == nil {
+			result[strings.Join(k, "/")] = true
+		}
+	}
+	return result
+}
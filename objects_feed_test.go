@@ -0,0 +1,202 @@
+package LingEcho
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/code-100-precent/LingFramework/pkg/constants"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// testPublisher delivers the changes to the subscribers named by their requests
+type testPublisher struct {
+	subscribers map[string]*gin.Context
+	received    map[string][]*ChangeMessage
+}
+
+func (p *testPublisher) PublishChange(group string, msg *ChangeMessage, allow func(c *gin.Context) bool) {
+	for name, c := range p.subscribers {
+		if allow(c) {
+			p.received[name] = append(p.received[name], msg)
+		}
+	}
+}
+
+func (p *testPublisher) subscribe(db *gorm.DB, name, tenant string, user bool) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Set(constants.DbField, db)
+	c.Set(constants.TenantField, tenant)
+	if user {
+		c.Set(constants.UserField, name)
+	}
+	p.subscribers[name] = c
+}
+
+func TestWebObjectChangeFeed(t *testing.T) {
+	pub := &testPublisher{subscribers: map[string]*gin.Context{}, received: map[string][]*ChangeMessage{}}
+	obj := &WebObject{
+		Model:        testNote{},
+		Editables:    []string{"Title"},
+		TenantField:  "TenantID",
+		AuthRequired: true,
+		AllowMethods: GET | CREATE | EDIT | DELETE | BATCH,
+		GetDB: func(c *gin.Context, isCreate bool) *gorm.DB {
+			db := c.MustGet(constants.DbField).(*gorm.DB)
+			if isCreate {
+				return db
+			}
+			return db.Where("title <> ?", "hidden")
+		},
+		ChangeFeed: &ChangeFeed{
+			Publisher: pub,
+			Authenticated: func(c *gin.Context) bool {
+				_, ok := c.Get(constants.UserField)
+				return ok
+			},
+		},
+	}
+	r, db := newTestTenantObject(t, obj)
+	assert.Equal(t, "objects.testnote", obj.ChangeGroup())
+	pub.subscribe(db, "alice", "a", true)
+	pub.subscribe(db, "bob", "b", true)
+	pub.subscribe(db, "anonymous", "a", false)
+
+	res := doTenantRequest(r, "a", http.MethodPut, "/api/testnote", map[string]any{"title": "first"})
+	require.Equal(t, float64(200), res["code"], res["msg"])
+	res = doTenantRequest(r, "a", http.MethodPatch, "/api/testnote/1", map[string]any{"title": "second"})
+	require.Equal(t, float64(200), res["code"], res["msg"])
+	changePending.Wait()
+
+	// only the authenticated subscriber of the tenant
+	assert.Len(t, pub.received["bob"], 0)
+	assert.Len(t, pub.received["anonymous"], 0)
+	require.Len(t, pub.received["alice"], 2)
+	msg := pub.received["alice"][1]
+	assert.Equal(t, ChangeMessageType, msg.Type)
+	assert.Equal(t, "objects.testnote", msg.Group)
+	assert.NotZero(t, msg.Timestamp)
+	assert.Equal(t, HistoryActionEdit, msg.Data.Action)
+	assert.Equal(t, "1", msg.Data.Key)
+	assert.Equal(t, "second", msg.Data.Record.(*testNote).Title)
+
+	// out of the GetDB scope of the subscriber after the edit
+	res = doTenantRequest(r, "a", http.MethodPatch, "/api/testnote/1", map[string]any{"title": "hidden"})
+	require.Equal(t, float64(200), res["code"], res["msg"])
+	changePending.Wait()
+	assert.Len(t, pub.received["alice"], 2)
+
+	res = doTenantRequest(r, "a", http.MethodPut, "/api/testnote", map[string]any{"title": "other"})
+	require.Equal(t, float64(200), res["code"], res["msg"])
+	changePending.Wait()
+	res = doTenantRequest(r, "a", http.MethodDelete, "/api/testnote/2", nil)
+	require.Equal(t, float64(200), res["code"], res["msg"])
+	changePending.Wait()
+	require.Len(t, pub.received["alice"], 4)
+	msg = pub.received["alice"][3]
+	assert.Equal(t, HistoryActionDelete, msg.Data.Action)
+	assert.Equal(t, "2", msg.Data.Key)
+	assert.Nil(t, msg.Data.Record)
+
+	// the rolled back batches publish nothing
+	res = doTenantRequest(r, "a", http.MethodPost, "/api/testnote/batch", map[string]any{
+		"creates": []map[string]any{{"title": "third"}},
+		"deletes": []any{100},
+	})
+	assert.Equal(t, float64(500), res["code"])
+	changePending.Wait()
+	assert.Len(t, pub.received["alice"], 4)
+	res = doTenantRequest(r, "a", http.MethodPut, "/api/testnote", map[string]any{"title": "fourth"})
+	require.Equal(t, float64(200), res["code"], res["msg"])
+	changePending.Wait()
+	require.Len(t, pub.received["alice"], 5)
+	assert.Equal(t, "fourth", pub.received["alice"][4].Data.Record.(*testNote).Title)
+}
+
+func TestWebObjectChangeFeedBatch(t *testing.T) {
+	pub := &testPublisher{subscribers: map[string]*gin.Context{}, received: map[string][]*ChangeMessage{}}
+	var scopes int
+	obj := &WebObject{
+		Model:        testNote{},
+		Editables:    []string{"Title"},
+		TenantField:  "TenantID",
+		AllowMethods: CREATE | BATCH,
+		GetDB: func(c *gin.Context, isCreate bool) *gorm.DB {
+			db := c.MustGet(constants.DbField).(*gorm.DB)
+			if _, ok := c.Get(constants.UserField); ok {
+				scopes++
+				return db.Where("title <> ?", "hidden")
+			}
+			return db
+		},
+		ChangeFeed: &ChangeFeed{Publisher: pub},
+	}
+	r, db := newTestTenantObject(t, obj)
+	pub.subscribe(db, "alice", "a", true)
+
+	res := doTenantRequest(r, "a", http.MethodPost, "/api/testnote/batch", map[string]any{
+		"creates": []map[string]any{{"title": "first"}, {"title": "hidden"}, {"title": "second"}},
+	})
+	require.Equal(t, float64(200), res["code"], res["msg"])
+	changePending.Wait()
+
+	// one query for the changes of the request
+	assert.Equal(t, 1, scopes)
+	require.Len(t, pub.received["alice"], 2)
+	assert.Equal(t, "first", pub.received["alice"][0].Data.Record.(*testNote).Title)
+	assert.Equal(t, "second", pub.received["alice"][1].Data.Record.(*testNote).Title)
+}
+
+func TestWebObjectChangeFeedMember(t *testing.T) {
+	pub := &testPublisher{subscribers: map[string]*gin.Context{}, received: map[string][]*ChangeMessage{}}
+	var mu sync.Mutex
+	enabled := map[string]bool{"alice": true, "bob": true}
+	members := map[string]bool{"alice": true, "bob": true}
+	current := func(m map[string]bool, c *gin.Context) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return m[c.GetString(constants.UserField)]
+	}
+	obj := &WebObject{
+		Model:        testNote{},
+		Editables:    []string{"Title"},
+		TenantField:  "TenantID",
+		AuthRequired: true,
+		AllowMethods: CREATE,
+		ChangeFeed: &ChangeFeed{
+			Publisher: pub,
+			Authenticated: func(c *gin.Context) bool {
+				return current(enabled, c)
+			},
+			Member: func(c *gin.Context, tenant string) bool {
+				return current(members, c)
+			},
+		},
+	}
+	r, db := newTestTenantObject(t, obj)
+	pub.subscribe(db, "alice", "a", true)
+	pub.subscribe(db, "bob", "a", true)
+	pub.subscribe(db, "carol", "a", true)
+
+	res := doTenantRequest(r, "a", http.MethodPut, "/api/testnote", map[string]any{"title": "first"})
+	require.Equal(t, float64(200), res["code"], res["msg"])
+	changePending.Wait()
+	assert.Len(t, pub.received["alice"], 1)
+	assert.Len(t, pub.received["bob"], 1)
+	assert.Len(t, pub.received["carol"], 0)
+
+	// checked again after the subscription
+	mu.Lock()
+	enabled["alice"] = false
+	delete(members, "bob")
+	mu.Unlock()
+	res = doTenantRequest(r, "a", http.MethodPut, "/api/testnote", map[string]any{"title": "second"})
+	require.Equal(t, float64(200), res["code"], res["msg"])
+	changePending.Wait()
+	assert.Len(t, pub.received["alice"], 1)
+	assert.Len(t, pub.received["bob"], 1)
+}
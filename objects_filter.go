@@ -0,0 +1,236 @@
+package LingEcho
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	MaxFilterDepth = 8   // nesting levels of filter groups
+	MaxFilterNodes = 200 // filters and groups in one QueryForm
+)
+
+// likeEscape is the ESCAPE character of LIKE patterns, a backslash
+// would need different quoting on mysql and postgres.
+const likeEscape = "!"
+
+var ErrFilterTooComplex = errors.New("filter too complex")
+
+// isGroup return true if the filter combines the child Filters.
+func (f *Filter) isGroup() bool {
+	switch f.Op {
+	case FilterOpAnd, FilterOpOr, FilterOpNot:
+		return true
+	}
+	return false
+}
+
+// stripFilters keep the filters on filterFields, resolve the column names
+// and drop the groups left empty.
+func (obj *WebObject) stripFilters(filters []Filter, filterFields map[string]struct{}, namer schema.Namer, depth int, nodes *int) ([]Filter, error) {
+	if depth > MaxFilterDepth {
+		return nil, ErrFilterTooComplex
+	}
+
+	var result []Filter
+	for _, filter := range filters {
+		if *nodes++; *nodes > MaxFilterNodes {
+			return nil, ErrFilterTooComplex
+		}
+
+		if filter.isGroup() {
+			children, err := obj.stripFilters(filter.Filters, filterFields, namer, depth+1, nodes)
+			if err != nil {
+				return nil, err
+			}
+			if len(children) == 0 {
+				continue
+			}
+			filter.Name = ""
+			filter.Filters = children
+			result = append(result, filter)
+			continue
+		}
+
+		// Struct must has this field.
+		field, ok := obj.jsonToFields[filter.Name]
+		if !ok {
+			continue
+		}
+		if _, ok := filterFields[field]; !ok {
+			continue
+		}
+
+		filter.isTimeType = obj.isTimeField(field)
+		filter.Name = namer.ColumnName(obj.tableName, field)
+		filter.Filters = nil
+		result = append(result, filter)
+	}
+	return result, nil
+}
+
+// buildFilters join the conditions of filters with sep, such as:
+// (`users`.`status` = ? OR `users`.`status` = ?)
+func buildFilters(tblName string, filters []Filter, sep string) (string, []any, error) {
+	var exprs []string
+	var args []any
+	for i := range filters {
+		q, vars, err := filters[i].buildCondition(tblName)
+		if err != nil {
+			return "", nil, err
+		}
+		if q == "" {
+			continue
+		}
+		exprs = append(exprs, q)
+		args = append(args, vars...)
+	}
+	switch len(exprs) {
+	case 0:
+		return "", nil, nil
+	case 1:
+		return exprs[0], args, nil
+	}
+	return "(" + strings.Join(exprs, sep) + ")", args, nil
+}
+
+// buildCondition return the SQL condition of the filter with bound parameters,
+// the column is passed as clause.Column so that it's quoted by the dialector.
+// Unknown ops return an empty condition and are ignored.
+func (f *Filter) buildCondition(tblName string) (string, []any, error) {
+	switch f.Op {
+	case FilterOpAnd:
+		return buildFilters(tblName, f.Filters, " AND ")
+	case FilterOpOr:
+		return buildFilters(tblName, f.Filters, " OR ")
+	case FilterOpNot:
+		q, args, err := buildFilters(tblName, f.Filters, " AND ")
+		if err != nil || q == "" {
+			return "", nil, err
+		}
+		return "NOT (" + q + ")", args, nil
+	}
+
+	col := clause.Column{Table: tblName, Name: f.Name}
+	value := f.Value
+	if f.isTimeType {
+		value = castTimeValues(value)
+	}
+
+	switch f.Op {
+	case FilterOpIsNull:
+		return "? IS NULL", []any{col}, nil
+	case FilterOpNotNull:
+		return "? IS NOT NULL", []any{col}, nil
+	case FilterOpEqual, FilterOpNotEqual:
+		if value == nil {
+			if f.Op == FilterOpEqual {
+				return "? IS NULL", []any{col}, nil
+			}
+			return "? IS NOT NULL", []any{col}, nil
+		}
+		return "? " + f.Op + " ?", []any{col, value}, nil
+	case FilterOpGreater, FilterOpGreaterOrEqual, FilterOpLess, FilterOpLessOrEqual:
+		return "? " + f.Op + " ?", []any{col, value}, nil
+	case FilterOpIsNot:
+		return "? IS NOT ?", []any{col, value}, nil
+	case FilterOpIn, FilterOpNotIn:
+		vals := filterValues(value)
+		if len(vals) == 0 {
+			// IN () is not valid SQL
+			if f.Op == FilterOpIn {
+				return "1 = 0", nil, nil
+			}
+			return "1 = 1", nil, nil
+		}
+		if f.Op == FilterOpIn {
+			return "? IN ?", []any{col, vals}, nil
+		}
+		return "? NOT IN ?", []any{col, vals}, nil
+	case FilterOpBetween:
+		vals := filterValues(value)
+		if len(vals) != 2 {
+			return "", nil, errors.New("invalid between value, must be slice with 2 elements")
+		}
+		return "? BETWEEN ? AND ?", []any{col, vals[0], vals[1]}, nil
+	case FilterOpLike, FilterOpNotLike:
+		// like with many values matches any of them
+		var exprs []string
+		var args []any
+		for _, v := range filterValues(value) {
+			exprs = append(exprs, "? LIKE ? ESCAPE '"+likeEscape+"'")
+			args = append(args, col, "%"+escapeLike(fmt.Sprint(v))+"%")
+		}
+		if len(exprs) == 0 {
+			return "", nil, nil
+		}
+		q := strings.Join(exprs, " OR ")
+		if len(exprs) > 1 {
+			q = "(" + q + ")"
+		}
+		if f.Op == FilterOpNotLike {
+			return "NOT (" + strings.Join(exprs, " OR ") + ")", args, nil
+		}
+		return q, args, nil
+	case FilterOpStartsWith:
+		return "? LIKE ? ESCAPE '" + likeEscape + "'", []any{col, escapeLike(fmt.Sprint(value)) + "%"}, nil
+	case FilterOpEndsWith:
+		return "? LIKE ? ESCAPE '" + likeEscape + "'", []any{col, "%" + escapeLike(fmt.Sprint(value))}, nil
+	}
+	return "", nil, nil
+}
+
+// applyFilters add the filter tree to db, the top level filters are joined by AND.
+func applyFilters(db *gorm.DB, tblName string, filters []Filter) (*gorm.DB, error) {
+	for i := range filters {
+		q, args, err := filters[i].buildCondition(tblName)
+		if err != nil {
+			return nil, err
+		}
+		if q != "" {
+			db = db.Where(q, args...)
+		}
+	}
+	return db, nil
+}
+
+// escapeLike escape the wildcards of LIKE, so the value matches literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(likeEscape, likeEscape+likeEscape, "%", likeEscape+"%", "_", likeEscape+"_").Replace(s)
+}
+
+// filterValues return the elements of a slice value, or the value itself.
+func filterValues(value any) []any {
+	if value == nil {
+		return nil
+	}
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return []any{value}
+	}
+	vals := make([]any, rv.Len())
+	for i := range vals {
+		vals[i] = rv.Index(i).Interface()
+	}
+	return vals
+}
+
+func castTimeValues(value any) any {
+	if _, ok := value.(string); ok {
+		return castTime(value)
+	}
+	if vals, ok := value.([]any); ok {
+		result := make([]any, len(vals))
+		for i, v := range vals {
+			result[i] = castTime(v)
+		}
+		return result
+	}
+	return value
+}
@@ -0,0 +1,110 @@
+package LingEcho
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestWebObjectQueryFilterTree(t *testing.T) {
+	r, db := newTestObject(t, &WebObject{
+		Model:       testItem{},
+		Filterables: []string{"Name", "Status", "Score", "CreatedAt"},
+		Orderables:  []string{"Score"},
+	})
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	items := []testItem{
+		{Name: "alice", Status: "a", Score: 1, CreatedAt: base},
+		{Name: "bob", Status: "b", Score: 2, CreatedAt: base.AddDate(0, 0, 1)},
+		{Name: "carol", Status: "c", Score: 3, CreatedAt: base.AddDate(0, 0, 2)},
+		{Name: "100%_off", Status: "a", Score: 4, CreatedAt: base.AddDate(0, 0, 3)},
+	}
+	require.NoError(t, db.Create(&items).Error)
+
+	query := func(filters ...map[string]any) []string {
+		res := doTestRequest(r, http.MethodPost, "/api/testitem", map[string]any{
+			"filters": filters,
+			"orders":  []map[string]any{{"name": "score", "op": "asc"}},
+		})
+		require.Equal(t, float64(200), res["code"], res["msg"])
+		var names []string
+		items, _ := res["data"].(map[string]any)["items"].([]any)
+		for _, v := range items {
+			names = append(names, v.(map[string]any)["name"].(string))
+		}
+		return names
+	}
+
+	// (status = a OR status = b) AND createdAt BETWEEN X, Y
+	assert.Equal(t, []string{"alice", "bob"}, query(
+		map[string]any{"op": "or", "filters": []map[string]any{
+			{"name": "status", "op": "=", "value": "a"},
+			{"name": "status", "op": "=", "value": "b"},
+		}},
+		map[string]any{"name": "createdAt", "op": "between", "value": []string{"2024-01-01", "2024-01-02T12:00:00Z"}},
+	))
+	assert.Equal(t, []string{"bob", "carol"}, query(
+		map[string]any{"op": "not", "filters": []map[string]any{
+			{"name": "status", "op": "=", "value": "a"},
+		}},
+	))
+	assert.Equal(t, []string{"bob"}, query(map[string]any{"name": "status", "op": "not_in", "value": []string{"a", "c"}}))
+	assert.Equal(t, []string(nil), query(map[string]any{"name": "status", "op": "in", "value": []string{}}))
+	assert.Equal(t, []string{"carol"}, query(map[string]any{"name": "name", "op": "starts_with", "value": "car"}))
+	assert.Equal(t, []string{"alice"}, query(map[string]any{"name": "name", "op": "ends_with", "value": "ice"}))
+	assert.Equal(t, []string{"alice", "carol"}, query(map[string]any{"name": "name", "op": "like", "value": []string{"lic", "aro"}}))
+	assert.Equal(t, []string{"bob", "100%_off"}, query(map[string]any{"name": "name", "op": "not_like", "value": []string{"a"}}))
+	assert.Equal(t, []string(nil), query(map[string]any{"name": "status", "op": "is_null"}))
+	assert.Len(t, query(map[string]any{"name": "status", "op": "not_null"}), 4)
+
+	// wildcards match literally
+	assert.Equal(t, []string{"100%_off"}, query(map[string]any{"name": "name", "op": "like", "value": "%_"}))
+
+	// fields not in Filterables are dropped, so are the empty groups
+	assert.Len(t, query(map[string]any{"op": "and", "filters": []map[string]any{
+		{"name": "id", "op": "=", "value": 1},
+	}}), 4)
+
+	deep := map[string]any{"name": "status", "op": "=", "value": "a"}
+	for i := 0; i <= MaxFilterDepth; i++ {
+		deep = map[string]any{"op": "and", "filters": []map[string]any{deep}}
+	}
+	res := doTestRequest(r, http.MethodPost, "/api/testitem", map[string]any{"filters": []map[string]any{deep}})
+	assert.Equal(t, ErrFilterTooComplex.Error(), res["msg"])
+}
+
+func TestFilterBuildConditionDialects(t *testing.T) {
+	filters := []Filter{
+		{Op: FilterOpOr, Filters: []Filter{
+			{Name: "status", Op: FilterOpEqual, Value: "a"},
+			{Name: "status", Op: FilterOpIsNull},
+		}},
+		{Name: "name", Op: FilterOpStartsWith, Value: "a_"},
+	}
+
+	dialectors := map[string]gorm.Dialector{
+		"SELECT * FROM `items` WHERE ((`items`.`status` = 'a' OR `items`.`status` IS NULL)) AND `items`.`name` LIKE 'a!_%' ESCAPE '!'": mysql.New(mysql.Config{
+			DSN:                       "user:pass@tcp(127.0.0.1:3306)/test",
+			SkipInitializeWithVersion: true,
+		}),
+		`SELECT * FROM "items" WHERE (("items"."status" = 'a' OR "items"."status" IS NULL)) AND "items"."name" LIKE 'a!_%' ESCAPE '!'`: postgres.New(postgres.Config{
+			DSN: "host=127.0.0.1 user=test dbname=test",
+		}),
+	}
+	for expected, dialector := range dialectors {
+		db, err := gorm.Open(dialector, &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+		require.NoError(t, err)
+		sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			tx, err := applyFilters(tx.Table("items"), "items", filters)
+			require.NoError(t, err)
+			return tx.Find(&[]map[string]any{})
+		})
+		assert.Equal(t, expected, sql)
+	}
+}
@@ -0,0 +1,590 @@
+package LingEcho
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/code-100-precent/LingFramework/pkg/graphql"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var ErrAuthRequired = errors.New("authorization required")
+
+// orderDirection is shared by the order inputs of all objects.
+var orderDirection = &graphql.Type{
+	Kind: graphql.KindEnum,
+	Name: "OrderDirection",
+	EnumValues: []*graphql.EnumValue{
+		{Name: "ASC", Value: OrderOpAsc},
+		{Name: "DESC", Value: OrderOpDesc},
+	},
+}
+
+// GraphQL serves the WebObjects over a single endpoint, for the clients
+// fetching several objects in one round trip. Each object gets the fields
+// by its AllowMethods:
+//   - GET: {name}(keys): the object, with the Expandables selected preloaded
+//   - QUERY: {name}List(filters, orders, keyword, pos, limit), filtered by
+//     Filterables and ordered by Orderables
+//   - CREATE, EDIT, DELETE: the mutations create{Type}(input), edit{Type}(keys, input)
+//     and delete{Type}(keys), the inputs are limited to Editables
+//
+// The fields go through GetDB, PrepareQuery and the Before* hooks as the
+// routes do, the writes are recorded and invalidate the cache. The fields
+// are named by the JSON names, those not valid in GraphQL are left out.
+// The depth and the number of the fields selected by a request are limited
+// by the MaxDepth and MaxFields of Schema().
+type GraphQL struct {
+	// Authenticated report whether the request is made by a signed-in user,
+	// the objects with AuthRequired are rejected without one. Without it,
+	// they can't be accessed at all.
+	Authenticated func(c *gin.Context) bool
+
+	schema *graphql.Schema
+	types  map[string]bool // the names taken
+}
+
+// NewGraphQL build the schema of objs, the objects are built if not yet registered.
+func NewGraphQL(objs []WebObject) (*GraphQL, error) {
+	g := &GraphQL{types: map[string]bool{"Query": true, "Mutation": true, orderDirection.Name: true}}
+	query := &graphql.Type{Kind: graphql.KindObject, Name: "Query"}
+	mutation := &graphql.Type{Kind: graphql.KindObject, Name: "Mutation"}
+	for idx := range objs {
+		obj := &objs[idx]
+		if obj.modelElem == nil {
+			if err := obj.Build(); err != nil {
+				return nil, err
+			}
+		}
+		if err := g.addObject(obj, query, mutation); err != nil {
+			return nil, err
+		}
+	}
+	if len(mutation.Fields) == 0 {
+		mutation = nil
+	}
+
+	var err error
+	if g.schema, err = graphql.NewSchema(query, mutation); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Schema return the schema built.
+func (g *GraphQL) Schema() *graphql.Schema {
+	return g.schema
+}
+
+// RegisterHandler serve the endpoint at path of r, by GET and POST.
+func (g *GraphQL) RegisterHandler(r *gin.RouterGroup, path string) {
+	h := graphql.Handler(g.schema)
+	r.GET(path, h)
+	r.POST(path, h)
+}
+
+// typeName return name if it's not taken yet, otherwise with a number suffix.
+func (g *GraphQL) typeName(name string) string {
+	result := name
+	for i := 2; g.types[result]; i++ {
+		result = name + strconv.Itoa(i)
+	}
+	g.types[result] = true
+	return result
+}
+
+// graphqlPascal convert the name to PascalCase, "webhook_deliveries" => "WebhookDeliveries".
+func graphqlPascal(name string) string {
+	var sb strings.Builder
+	upper := true
+	for _, r := range name {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		sb.WriteRune(r)
+	}
+	result := sb.String()
+	if result == "" || unicode.IsDigit(rune(result[0])) {
+		result = "T" + result
+	}
+	return result
+}
+
+func (g *GraphQL) addObject(obj *WebObject, query, mutation *graphql.Type) error {
+	if !graphql.IsValidName(obj.Name) {
+		return fmt.Errorf("%s: invalid GraphQL name", obj.Name)
+	}
+	allowMethods := obj.getAllowMethods()
+	docFields := GetDocDefine(obj.Model).Fields
+	typ := g.typeName(graphqlPascal(obj.modelElem.Name()))
+	objType := g.objectType(typ, obj.Desc, docFields)
+
+	var keyArgs []*graphql.InputValue
+	for _, k := range obj.uniqueKeys {
+		if !graphql.IsValidName(k.JSONName) {
+			return fmt.Errorf("%s: invalid GraphQL name of the key %s", obj.Name, k.JSONName)
+		}
+		keyArgs = append(keyArgs, &graphql.InputValue{Name: k.JSONName, Type: graphql.NewNonNull(graphqlKeyType(k.Kind))})
+	}
+
+	if allowMethods&GET != 0 {
+		query.Fields = append(query.Fields, &graphql.FieldDef{
+			Name:        obj.Name,
+			Description: fmt.Sprintf("Get the %s by the primary keys, null if not found", typ),
+			Args:        keyArgs,
+			Type:        objType,
+			Resolve:     g.resolveGet(obj),
+		})
+	}
+	if allowMethods&QUERY != 0 {
+		query.Fields = append(query.Fields, &graphql.FieldDef{
+			Name:        obj.Name + "List",
+			Description: fmt.Sprintf("Query the %s objects", typ),
+			Args:        g.queryArgs(obj, typ, docFields),
+			Type:        g.listType(typ, objType),
+			Resolve:     g.resolveQuery(obj),
+		})
+	}
+
+	var editables []DocField
+	for _, f := range docFields {
+		for _, name := range obj.Editables {
+			if f.FieldName == name && graphql.IsValidName(f.Name) {
+				editables = append(editables, f)
+			}
+		}
+	}
+	if allowMethods&CREATE != 0 && len(editables) > 0 {
+		input := g.inputType(g.typeName(typ+"CreateInput"), editables)
+		mutation.Fields = append(mutation.Fields, &graphql.FieldDef{
+			Name:        "create" + typ,
+			Description: fmt.Sprintf("Create a %s", typ),
+			Args:        []*graphql.InputValue{{Name: "input", Type: graphql.NewNonNull(input)}},
+			Type:        objType,
+			Resolve:     g.resolveCreate(obj),
+		})
+	}
+	if allowMethods&EDIT != 0 && len(editables) > 0 {
+		input := g.inputType(g.typeName(typ+"EditInput"), editables)
+		args := append([]*graphql.InputValue{}, keyArgs...)
+		args = append(args, &graphql.InputValue{Name: "input", Type: graphql.NewNonNull(input)})
+		if obj.VersionField != "" {
+			args = append(args, &graphql.InputValue{
+				Name:        "ifMatch",
+				Description: "The ETag of the version edited, as the If-Match header",
+				Type:        graphql.NewNonNull(graphql.String),
+			})
+		}
+		mutation.Fields = append(mutation.Fields, &graphql.FieldDef{
+			Name:        "edit" + typ,
+			Description: fmt.Sprintf("Edit the %s, return the object updated", typ),
+			Args:        args,
+			Type:        objType,
+			Resolve:     g.resolveEdit(obj),
+		})
+	}
+	if allowMethods&DELETE != 0 {
+		mutation.Fields = append(mutation.Fields, &graphql.FieldDef{
+			Name:        "delete" + typ,
+			Description: fmt.Sprintf("Delete the %s", typ),
+			Args:        keyArgs,
+			Type:        graphql.NewNonNull(graphql.Boolean),
+			Resolve:     g.resolveDelete(obj),
+		})
+	}
+	return nil
+}
+
+// objectType return the output type of the fields, the nested objects are named after their parents.
+func (g *GraphQL) objectType(name, desc string, fields []DocField) *graphql.Type {
+	t := &graphql.Type{Kind: graphql.KindObject, Name: name, Description: desc}
+	for _, f := range fields {
+		if !graphql.IsValidName(f.Name) {
+			continue
+		}
+		var ft *graphql.Type
+		if f.Type == TYPE_OBJECT && len(f.Fields) > 0 {
+			ft = g.objectType(g.typeName(name+graphqlPascal(f.Name)), "", f.Fields)
+			if f.IsArray {
+				ft = graphql.NewList(ft)
+			}
+		} else {
+			ft = graphqlScalarType(f)
+		}
+		if f.IsPrimary {
+			ft = graphql.NewNonNull(ft)
+		}
+		t.Fields = append(t.Fields, &graphql.FieldDef{Name: f.Name, Description: f.Desc, Type: ft})
+	}
+	if len(t.Fields) == 0 {
+		t.Fields = append(t.Fields, &graphql.FieldDef{Name: "_", Type: graphql.JSON})
+	}
+	return t
+}
+
+// inputType return the input type of the fields, the nested objects are taken as JSON.
+func (g *GraphQL) inputType(name string, fields []DocField) *graphql.Type {
+	t := &graphql.Type{Kind: graphql.KindInputObject, Name: name}
+	for _, f := range fields {
+		t.InputFields = append(t.InputFields, &graphql.InputValue{Name: f.Name, Description: f.Desc, Type: graphqlScalarType(f)})
+	}
+	return t
+}
+
+// graphqlScalarType return the scalar type of the field, JSON for the others.
+func graphqlScalarType(f DocField) *graphql.Type {
+	var t *graphql.Type
+	switch f.Type {
+	case TYPE_STRING, TYPE_DATE:
+		t = graphql.String
+	case TYPE_BOOLEAN, "bool":
+		t = graphql.Boolean
+	case TYPE_INT, "int8", "int16", "int32", "int64", "uint", "uint16", "uint32", "uint64":
+		t = graphql.Int
+	case TYPE_FLOAT, "float32", "float64":
+		t = graphql.Float
+	case "uint8":
+		if f.IsArray { // []byte in base64
+			return graphql.String
+		}
+		t = graphql.Int
+	default:
+		return graphql.JSON
+	}
+	if f.IsArray {
+		return graphql.NewList(t)
+	}
+	return t
+}
+
+func graphqlKeyType(kind reflect.Kind) *graphql.Type {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return graphql.Int
+	}
+	return graphql.String
+}
+
+// fieldEnum return the enum of the JSON names of fields, nil if there is none.
+func (g *GraphQL) fieldEnum(name string, docFields []DocField, fields []string) *graphql.Type {
+	t := &graphql.Type{Kind: graphql.KindEnum, Name: name}
+	for _, field := range fields {
+		for _, f := range docFields {
+			if f.FieldName == field && graphql.IsValidName(f.Name) {
+				t.EnumValues = append(t.EnumValues, &graphql.EnumValue{Name: f.Name, Description: f.Desc, Value: f.Name})
+			}
+		}
+	}
+	if len(t.EnumValues) == 0 {
+		return nil
+	}
+	return t
+}
+
+func (g *GraphQL) queryArgs(obj *WebObject, typ string, docFields []DocField) []*graphql.InputValue {
+	var args []*graphql.InputValue
+	if fields := g.fieldEnum(g.typeName(typ+"FilterField"), docFields, obj.Filterables); fields != nil {
+		filter := &graphql.Type{Kind: graphql.KindInputObject, Name: g.typeName(typ + "Filter")}
+		filter.Description = "A condition on the field name, or a group of the child filters if op is and, or, not"
+		filter.InputFields = []*graphql.InputValue{
+			{Name: "name", Type: fields},
+			{Name: "op", Type: graphql.String, DefaultValue: FilterOpEqual},
+			{Name: "value", Type: graphql.JSON},
+			{Name: "filters", Type: graphql.NewList(graphql.NewNonNull(filter))},
+		}
+		args = append(args, &graphql.InputValue{Name: "filters", Type: graphql.NewList(graphql.NewNonNull(filter))})
+	}
+	if fields := g.fieldEnum(g.typeName(typ+"OrderField"), docFields, obj.Orderables); fields != nil {
+		order := &graphql.Type{Kind: graphql.KindInputObject, Name: g.typeName(typ + "Order")}
+		order.InputFields = []*graphql.InputValue{
+			{Name: "name", Type: graphql.NewNonNull(fields)},
+			{Name: "op", Type: orderDirection, DefaultValue: OrderOpAsc},
+		}
+		args = append(args, &graphql.InputValue{Name: "orders", Type: graphql.NewList(graphql.NewNonNull(order))})
+	}
+	if len(obj.Searchables) > 0 || (obj.SearchIndex != nil && obj.SearchIndex.Keyword) {
+		args = append(args, &graphql.InputValue{Name: "keyword", Type: graphql.String})
+	}
+	return append(args,
+		&graphql.InputValue{Name: "pos", Type: graphql.Int, DefaultValue: int64(0)},
+		&graphql.InputValue{Name: "limit", Type: graphql.Int, DefaultValue: int64(DefaultQueryLimit)},
+	)
+}
+
+func (g *GraphQL) listType(typ string, objType *graphql.Type) *graphql.Type {
+	return &graphql.Type{
+		Kind: graphql.KindObject,
+		Name: g.typeName(typ + "List"),
+		Fields: []*graphql.FieldDef{
+			{Name: "total", Type: graphql.Int},
+			{Name: "pos", Type: graphql.Int},
+			{Name: "limit", Type: graphql.Int},
+			{Name: "keyword", Type: graphql.String},
+			{Name: "items", Type: graphql.NewList(objType)},
+		},
+	}
+}
+
+// context return the request of the field, checked as the routes of obj check it.
+func (g *GraphQL) context(p graphql.ResolveParams, obj *WebObject) (*gin.Context, error) {
+	c, ok := p.Context.(*gin.Context)
+	if !ok {
+		return nil, errors.New("not a gin request")
+	}
+	if obj.AuthRequired && (g.Authenticated == nil || !g.Authenticated(c)) {
+		return nil, ErrAuthRequired
+	}
+	if obj.TenantField != "" {
+		if _, err := obj.tenantValue(c); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// graphqlKeys return the primary values of the arguments.
+func (obj *WebObject) graphqlKeys(args map[string]any) []string {
+	var keys []string
+	for _, k := range obj.uniqueKeys {
+		keys = append(keys, fmt.Sprint(args[k.JSONName]))
+	}
+	return keys
+}
+
+// graphqlExpand return the Expandables selected, as the expand of QUERY.
+func (obj *WebObject) graphqlExpand(selected []*graphql.SelectedField, prefix string) []string {
+	var result []string
+	for _, f := range selected {
+		path := f.Name
+		if prefix != "" {
+			path = prefix + "." + f.Name
+		}
+		if _, ok := obj.expandables[path]; ok {
+			result = append(result, path)
+		}
+		if len(f.Selected) > 0 {
+			result = append(result, obj.graphqlExpand(f.Selected, path)...)
+		}
+	}
+	return result
+}
+
+// graphqlValue convert v to the JSON values, as it's rendered by the routes.
+func graphqlValue(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var result any
+	if err := dec.Decode(&result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// graphqlInput convert the input to the values decoded from a JSON body.
+func graphqlInput(input any) (map[string]any, error) {
+	data, err := json.Marshal(input)
+	if err != nil {
+		return nil, err
+	}
+	var vals map[string]any
+	if err := json.Unmarshal(data, &vals); err != nil {
+		return nil, err
+	}
+	return vals, nil
+}
+
+// loadGraphQL load the object of keys with the associations of expand, and run BeforeRender.
+func (obj *WebObject) loadGraphQL(c *gin.Context, keys []string, expand []string) (any, error) {
+	db := obj.getDB(c, false)
+	expands, _, err := obj.resolveExpands(db, expand)
+	if err != nil {
+		return nil, err
+	}
+	val := reflect.New(obj.modelElem).Interface()
+	if err := applyExpands(obj.buildPrimaryCondition(db, keys), expands).Take(val).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if obj.BeforeRender != nil {
+		rr, err := obj.BeforeRender(db, c, val)
+		if err != nil {
+			return nil, err
+		}
+		if rr != nil {
+			return graphqlValue(rr)
+		}
+	}
+	return graphqlValue(val)
+}
+
+func (g *GraphQL) resolveGet(obj *WebObject) graphql.ResolveFunc {
+	return func(p graphql.ResolveParams) (any, error) {
+		c, err := g.context(p, obj)
+		if err != nil {
+			return nil, err
+		}
+		return obj.loadGraphQL(c, obj.graphqlKeys(p.Args), obj.graphqlExpand(p.Info.Selected, ""))
+	}
+}
+
+// prepareGraphQLForm run prepareQueryForm with form as the request body,
+// PrepareQuery reads it as the body of QUERY.
+func (obj *WebObject) prepareGraphQLForm(c *gin.Context, form *QueryForm) (*gorm.DB, *QueryForm, error) {
+	body, err := json.Marshal(form)
+	if err != nil {
+		return nil, nil, err
+	}
+	req := c.Request
+	defer func() { c.Request = req }()
+	c.Request = req.Clone(req.Context())
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	c.Request.ContentLength = int64(len(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	return obj.prepareQueryForm(c, obj.PrepareQuery)
+}
+
+func (g *GraphQL) resolveQuery(obj *WebObject) graphql.ResolveFunc {
+	return func(p graphql.ResolveParams) (any, error) {
+		c, err := g.context(p, obj)
+		if err != nil {
+			return nil, err
+		}
+
+		form := &QueryForm{}
+		if v, ok := p.Args["pos"].(int64); ok {
+			form.Pos = int(v)
+		}
+		if v, ok := p.Args["limit"].(int64); ok {
+			form.Limit = int(v)
+		}
+		form.Keyword, _ = p.Args["keyword"].(string)
+		for _, name := range []string{"filters", "orders"} {
+			if p.Args[name] == nil {
+				continue
+			}
+			data, err := json.Marshal(p.Args[name])
+			if err != nil {
+				return nil, err
+			}
+			target := any(&form.Filters)
+			if name == "orders" {
+				target = &form.Orders
+			}
+			if err := json.Unmarshal(data, target); err != nil {
+				return nil, err
+			}
+		}
+		for _, f := range p.Info.Selected {
+			if f.Name == "items" {
+				form.Expand = obj.graphqlExpand(f.Selected, "")
+			}
+		}
+
+		db, form, err := obj.prepareGraphQLForm(c, form)
+		if err != nil {
+			return nil, err
+		}
+		r, err := obj.queryObjects(db, c, form)
+		if err != nil {
+			return nil, err
+		}
+		if obj.BeforeQueryRender != nil {
+			result, err := obj.BeforeQueryRender(db, c, &r)
+			if err != nil {
+				return nil, err
+			}
+			if result != nil {
+				return graphqlValue(result)
+			}
+		}
+		v, err := graphqlValue(r)
+		if m, ok := v.(map[string]any); ok {
+			if _, ok := m["total"]; !ok {
+				m["total"] = 0
+			}
+		}
+		return v, err
+	}
+}
+
+func (g *GraphQL) resolveCreate(obj *WebObject) graphql.ResolveFunc {
+	return func(p graphql.ResolveParams) (any, error) {
+		c, err := g.context(p, obj)
+		if err != nil {
+			return nil, err
+		}
+		data, err := json.Marshal(p.Args["input"])
+		if err != nil {
+			return nil, err
+		}
+		val := reflect.New(obj.modelElem).Interface()
+		if err := json.Unmarshal(data, val); err != nil {
+			return nil, err
+		}
+		if err := obj.createObject(obj.getDB(c, true), c, val); err != nil {
+			// the other mutations of the request go on
+			discardChanges(c)
+			return nil, err
+		}
+		obj.afterCommit(c)
+		return graphqlValue(val)
+	}
+}
+
+func (g *GraphQL) resolveEdit(obj *WebObject) graphql.ResolveFunc {
+	return func(p graphql.ResolveParams) (any, error) {
+		c, err := g.context(p, obj)
+		if err != nil {
+			return nil, err
+		}
+		vals, err := graphqlInput(p.Args["input"])
+		if err != nil {
+			return nil, err
+		}
+		ifMatch, _ := p.Args["ifMatch"].(string)
+		keys := obj.graphqlKeys(p.Args)
+		if err := obj.editObject(obj.getDB(c, false), c, keys, vals, ifMatch); err != nil {
+			// the other mutations of the request go on
+			discardChanges(c)
+			return nil, err
+		}
+		obj.afterCommit(c)
+		return obj.loadGraphQL(c, keys, obj.graphqlExpand(p.Info.Selected, ""))
+	}
+}
+
+func (g *GraphQL) resolveDelete(obj *WebObject) graphql.ResolveFunc {
+	return func(p graphql.ResolveParams) (any, error) {
+		c, err := g.context(p, obj)
+		if err != nil {
+			return nil, err
+		}
+		if err := obj.deleteObject(obj.getDB(c, false), c, obj.graphqlKeys(p.Args)); err != nil {
+			// the other mutations of the request go on
+			discardChanges(c)
+			return nil, err
+		}
+		obj.afterCommit(c)
+		return true, nil
+	}
+}
@@ -0,0 +1,160 @@
+package LingEcho
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/code-100-precent/LingFramework/pkg/constants"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type testTodo struct {
+	ID    uint   `json:"id" gorm:"primaryKey"`
+	Title string `json:"title" comment:"What to do"`
+	Done  bool   `json:"done"`
+}
+
+func newTestGraphQL(t *testing.T, objs []WebObject) (*gin.Engine, *gorm.DB) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{})
+	require.NoError(t, err)
+	for _, obj := range objs {
+		require.NoError(t, db.AutoMigrate(obj.Model))
+	}
+
+	r := gin.New()
+	g := r.Group("/api")
+	g.Use(func(c *gin.Context) {
+		c.Set(constants.DbField, db)
+		c.Next()
+	})
+	RegisterObjects(g, objs)
+	gql, err := NewGraphQL(objs)
+	require.NoError(t, err)
+	gql.Authenticated = func(c *gin.Context) bool { return c.Query("user") != "" }
+	gql.RegisterHandler(g, "/graphql")
+	return r, db
+}
+
+func doGraphQL(r *gin.Engine, path, query string, variables map[string]any) (map[string]any, []any) {
+	res := doTestRequest(r, http.MethodPost, path, map[string]any{"query": query, "variables": variables})
+	data, _ := res["data"].(map[string]any)
+	errs, _ := res["errors"].([]any)
+	return data, errs
+}
+
+func TestGraphQL(t *testing.T) {
+	var created int
+	r, db := newTestGraphQL(t, []WebObject{
+		{
+			Model:       testPost{},
+			Editables:   []string{"Title", "AuthorID"},
+			Filterables: []string{"Title"},
+			Orderables:  []string{"ID"},
+			Expandables: []string{"Author", "Comments", "Comments.Author"},
+			BeforeCreate: func(db *gorm.DB, ctx *gin.Context, vptr any) error {
+				created++
+				return nil
+			},
+		},
+		{
+			Model:        testAuthor{},
+			AuthRequired: true,
+			Editables:    []string{"Name"},
+		},
+	})
+	require.NoError(t, db.AutoMigrate(&testComment{}))
+	alice := testAuthor{Name: "alice", Email: "alice@example.org"}
+	require.NoError(t, db.Create(&alice).Error)
+
+	data, errs := doGraphQL(r, "/api/graphql", `mutation {
+		a: createTestPost(input: {title: "hello", authorId: 1}) { id title }
+		b: createTestPost(input: {title: "world", authorId: 1}) { id }
+	}`, nil)
+	require.Empty(t, errs)
+	assert.Equal(t, map[string]any{"id": float64(1), "title": "hello"}, data["a"])
+	assert.Equal(t, 2, created)
+	require.NoError(t, db.Create(&testComment{PostID: 1, Body: "hi", AuthorID: alice.ID}).Error)
+
+	// the post, its associations and the list in one request
+	data, errs = doGraphQL(r, "/api/graphql", `query ($title: String) {
+		testpost(id: 1) { title author { name } comments { body author { email } } }
+		testpostList(filters: [{name: title, value: $title}], orders: [{name: id, op: DESC}]) {
+			total
+			items { id title author { name } }
+		}
+	}`, map[string]any{"title": "world"})
+	require.Empty(t, errs)
+	post := data["testpost"].(map[string]any)
+	assert.Equal(t, "alice", post["author"].(map[string]any)["name"])
+	assert.Equal(t, "alice@example.org", post["comments"].([]any)[0].(map[string]any)["author"].(map[string]any)["email"])
+	list := data["testpostList"].(map[string]any)
+	assert.Equal(t, float64(1), list["total"])
+	assert.Equal(t, map[string]any{"id": float64(2), "title": "world", "author": map[string]any{"name": "alice"}}, list["items"].([]any)[0])
+
+	data, errs = doGraphQL(r, "/api/graphql", `{ testpostList(orders: [{name: id, op: DESC}]) { items { id } } }`, nil)
+	require.Empty(t, errs)
+	assert.Equal(t, float64(2), data["testpostList"].(map[string]any)["items"].([]any)[0].(map[string]any)["id"])
+
+	// not in Filterables
+	_, errs = doGraphQL(r, "/api/graphql", `{ testpostList(filters: [{name: authorId, value: 1}]) { total } }`, nil)
+	require.Len(t, errs, 1)
+
+	// AuthRequired
+	data, errs = doGraphQL(r, "/api/graphql", `{ testauthor(id: 1) { name } }`, nil)
+	require.Len(t, errs, 1)
+	assert.Equal(t, ErrAuthRequired.Error(), errs[0].(map[string]any)["message"])
+	assert.Nil(t, data["testauthor"])
+	data, errs = doGraphQL(r, "/api/graphql?user=1", `{ testauthor(id: 1) { name } }`, nil)
+	require.Empty(t, errs)
+	assert.Equal(t, "alice", data["testauthor"].(map[string]any)["name"])
+
+	// limited to Editables
+	_, errs = doGraphQL(r, "/api/graphql", `mutation { editTestPost(id: 1, input: {id: 5}) { id } }`, nil)
+	require.Len(t, errs, 1)
+	data, errs = doGraphQL(r, "/api/graphql", `mutation ($input: TestPostEditInput!) { editTestPost(id: 1, input: $input) { title } }`,
+		map[string]any{"input": map[string]any{"title": "changed"}})
+	require.Empty(t, errs)
+	assert.Equal(t, "changed", data["editTestPost"].(map[string]any)["title"])
+
+	data, errs = doGraphQL(r, "/api/graphql", `mutation { deleteTestPost(id: 2) }`, nil)
+	require.Empty(t, errs)
+	assert.Equal(t, true, data["deleteTestPost"])
+	data, errs = doGraphQL(r, "/api/graphql", `{ testpost(id: 2) { id } }`, nil)
+	require.Empty(t, errs)
+	assert.Nil(t, data["testpost"])
+	_, errs = doGraphQL(r, "/api/graphql", `mutation { deleteTestPost(id: 2) }`, nil)
+	assert.Equal(t, "not found", errs[0].(map[string]any)["message"])
+}
+
+func TestGraphQLIntrospection(t *testing.T) {
+	r, _ := newTestGraphQL(t, []WebObject{{
+		Model:        testTodo{},
+		Desc:         "Todo items",
+		Editables:    []string{"Title"},
+		Filterables:  []string{"Title", "Done"},
+		AllowMethods: GET | QUERY | CREATE,
+	}})
+
+	data, errs := doGraphQL(r, "/api/graphql", `{
+		todo: __type(name: "TestTodo") { description fields { name description } }
+		filter: __type(name: "TestTodoFilterField") { enumValues { name } }
+		input: __type(name: "TestTodoCreateInput") { inputFields { name } }
+		__schema { mutationType { fields { name } } }
+	}`, nil)
+	require.Empty(t, errs)
+	todo := data["todo"].(map[string]any)
+	assert.Equal(t, "Todo items", todo["description"])
+	assert.Equal(t, map[string]any{"name": "title", "description": "What to do"}, todo["fields"].([]any)[1])
+	assert.Len(t, data["filter"].(map[string]any)["enumValues"], 2)
+	assert.Equal(t, []any{map[string]any{"name": "title"}}, data["input"].(map[string]any)["inputFields"])
+	assert.Equal(t, []any{map[string]any{"name": "createTestTodo"}}, data["__schema"].(map[string]any)["mutationType"].(map[string]any)["fields"])
+
+	_, err := NewGraphQL([]WebObject{{Model: testTodo{}, Name: "to-do"}})
+	assert.Error(t, err)
+}
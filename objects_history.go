@@ -0,0 +1,259 @@
+package LingEcho
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/code-100-precent/LingFramework/pkg/utils/response"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const DefaultHistoryLimit = 50
+
+const (
+	HistoryActionCreate = "create"
+	HistoryActionEdit   = "edit"
+	HistoryActionDelete = "delete"
+)
+
+// HistoryActorFunc return the id and name of the user making the change.
+type HistoryActorFunc func(c *gin.Context) (uint, string)
+
+// HistoryActor is called for every history record, the actor is empty if it's nil.
+var HistoryActor HistoryActorFunc
+
+// HistoryChange is the value of a field before and after the change.
+type HistoryChange struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// ObjectHistory is a change of a record of the WebObjects with HISTORY.
+// Fields are compared by the JSON form of the model, so `json:"-"` fields are never stored.
+type ObjectHistory struct {
+	ID        uint                     `json:"id" gorm:"primaryKey"`
+	Object    string                   `json:"object" gorm:"size:128;index:idx_object_history_record"` // table name
+	RecordKey string                   `json:"recordKey" gorm:"size:128;index:idx_object_history_record"`
+	Action    string                   `json:"action" gorm:"size:16"`
+	Changes   map[string]HistoryChange `json:"changes" gorm:"serializer:json"`
+	ActorID   uint                     `json:"actorId,omitempty"`
+	ActorName string                   `json:"actorName,omitempty" gorm:"size:128"`
+	Tenant    string                   `json:"tenant,omitempty" gorm:"size:64;index"`
+	CreatedAt time.Time                `json:"createdAt"`
+}
+
+func (ObjectHistory) TableName() string {
+	return "object_histories"
+}
+
+func (obj *WebObject) hasHistory() bool {
+	return obj.getAllowMethods()&HISTORY != 0
+}
+
+// historyValues return the JSON form of the model as a map.
+func historyValues(val any) (map[string]any, error) {
+	if val == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(val)
+	if err != nil {
+		return nil, err
+	}
+	var vals map[string]any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&vals); err != nil {
+		return nil, err
+	}
+	return vals, nil
+}
+
+// historyRecordKey return the primary values joined as in the url path.
+func (obj *WebObject) historyRecordKey(vals map[string]any) string {
+	var keys []string
+	for _, field := range obj.uniqueKeys {
+		keys = append(keys, formatPrimaryValue(vals[field.JSONName]))
+	}
+	return strings.Join(keys, "/")
+}
+
+// recordHistory store the diff between before and after, either of them is nil
+// when the record is created or deleted. Nothing is stored if no field changed.
+func (obj *WebObject) recordHistory(db *gorm.DB, c *gin.Context, action string, before, after any) error {
+	beforeVals, err := historyValues(before)
+	if err != nil {
+		return err
+	}
+	afterVals, err := historyValues(after)
+	if err != nil {
+		return err
+	}
+
+	changes := map[string]HistoryChange{}
+	for k, v := range beforeVals {
+		if !reflect.DeepEqual(v, afterVals[k]) {
+			changes[k] = HistoryChange{Before: v, After: afterVals[k]}
+		}
+	}
+	for k, v := range afterVals {
+		if _, ok := beforeVals[k]; !ok && v != nil {
+			changes[k] = HistoryChange{After: v}
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	vals := afterVals
+	if vals == nil {
+		vals = beforeVals
+	}
+	h := ObjectHistory{
+		Object:    obj.dbTable(db),
+		RecordKey: obj.historyRecordKey(vals),
+		Action:    action,
+		Changes:   changes,
+		Tenant:    obj.tenantOf(after),
+	}
+	if after == nil {
+		h.Tenant = obj.tenantOf(before)
+	}
+	if HistoryActor != nil && c != nil {
+		h.ActorID, h.ActorName = HistoryActor(c)
+	}
+	return db.Session(&gorm.Session{NewDB: true}).Create(&h).Error
+}
+
+func handleQueryHistory(c *gin.Context, obj *WebObject) {
+	keys, err := obj.getPrimaryValues(c)
+	if err != nil {
+		response.Fail(c, err.Error(), nil)
+		return
+	}
+
+	pos, _ := strconv.Atoi(c.Query("pos"))
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if pos < 0 {
+		pos = 0
+	}
+	if limit <= 0 || limit > DefaultQueryLimit {
+		limit = DefaultHistoryLimit
+	}
+
+	db := obj.getDB(c, false).Session(&gorm.Session{NewDB: true})
+	tx := db.Model(&ObjectHistory{}).
+		Where("object", obj.dbTable(db)).
+		Where("record_key", strings.Join(keys, "/"))
+	if obj.TenantField != "" {
+		tx = tx.Where("tenant", CurrentTenant(c))
+	}
+
+	r := QueryResult{Pos: pos, Limit: limit, Items: []any{}}
+	var total int64
+	if err := tx.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		response.Fail(c, err.Error(), nil)
+		return
+	}
+	r.TotalCount = int(total)
+
+	var items []ObjectHistory
+	if err := tx.Order("id DESC").Offset(pos).Limit(limit).Find(&items).Error; err != nil {
+		response.Fail(c, err.Error(), nil)
+		return
+	}
+	for _, v := range items {
+		r.Items = append(r.Items, v)
+	}
+	response.Success(c, "success", r)
+}
+
+func handleRevertHistory(c *gin.Context, obj *WebObject) {
+	keys, err := obj.getPrimaryValues(c)
+	if err != nil {
+		response.Fail(c, err.Error(), nil)
+		return
+	}
+	historyID, err := strconv.ParseUint(c.Param("historyId"), 10, 64)
+	if err != nil {
+		response.Fail(c, "invalid history id", nil)
+		return
+	}
+
+	if err := obj.revertObject(c, keys, uint(historyID)); err != nil {
+		if !failVersion(c, err) && !failValidation(c, err) {
+			response.Fail(c, err.Error(), nil)
+		}
+		return
+	}
+	obj.afterCommit(c)
+	response.Success(c, "reverted successfully", true)
+}
+
+// revertObject restore the record to the state right after the history historyID.
+// The fields changed later are set back to their value before the first later change,
+// an existing record is restored through editObject, so only Editables are restored,
+// a deleted record is created again.
+func (obj *WebObject) revertObject(c *gin.Context, keys []string, historyID uint) error {
+	db := obj.getDB(c, false)
+	histories := db.Session(&gorm.Session{NewDB: true}).Model(&ObjectHistory{}).
+		Where("object", obj.dbTable(db)).
+		Where("record_key", strings.Join(keys, "/"))
+	if obj.TenantField != "" {
+		histories = histories.Where("tenant", CurrentTenant(c))
+	}
+
+	var target ObjectHistory
+	if err := histories.Session(&gorm.Session{}).Where("id", historyID).Take(&target).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("history not found")
+		}
+		return err
+	}
+	if target.Action == HistoryActionDelete {
+		return errors.New("can't revert to a deleted version")
+	}
+
+	var laters []ObjectHistory
+	if err := histories.Session(&gorm.Session{}).Where("id > ?", historyID).Order("id").Find(&laters).Error; err != nil {
+		return err
+	}
+	vals := map[string]any{}
+	for _, h := range laters {
+		for k, v := range h.Changes {
+			if _, ok := vals[k]; !ok {
+				vals[k] = v.Before
+			}
+		}
+	}
+	if len(vals) == 0 {
+		return errors.New("not changed")
+	}
+
+	val := reflect.New(obj.modelElem).Interface()
+	err := obj.buildPrimaryCondition(db, keys).Session(&gorm.Session{}).Take(val).Error
+	if err == nil {
+		return obj.editObject(db, c, keys, vals, c.GetHeader("If-Match"))
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	// deleted later, the delete has the values of all fields
+	if laters[len(laters)-1].Action != HistoryActionDelete {
+		return errors.New("not found")
+	}
+	data, err := json.Marshal(vals)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, val); err != nil {
+		return err
+	}
+	return obj.createObject(obj.getDB(c, true), c, val)
+}
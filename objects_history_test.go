@@ -0,0 +1,125 @@
+package LingEcho
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type testAccount struct {
+	ID       uint   `json:"id" gorm:"primaryKey"`
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Password string `json:"-"`
+}
+
+func TestWebObjectHistory(t *testing.T) {
+	r, db := newTestObject(t, &WebObject{
+		Model:        testAccount{},
+		Editables:    []string{"Name", "Email", "Password"},
+		AllowMethods: GET | CREATE | EDIT | DELETE | HISTORY,
+		BeforeCreate: func(db *gorm.DB, ctx *gin.Context, vptr any) error {
+			vptr.(*testAccount).Password = "secret"
+			return nil
+		},
+	})
+	require.NoError(t, db.AutoMigrate(&ObjectHistory{}))
+
+	HistoryActor = func(c *gin.Context) (uint, string) { return 7, "alice" }
+	defer func() { HistoryActor = nil }()
+
+	res := doTestRequest(r, http.MethodPut, "/api/testaccount", map[string]any{"name": "bob", "email": "bob@example.org"})
+	require.Equal(t, float64(200), res["code"], res["msg"])
+	doTestRequest(r, http.MethodPatch, "/api/testaccount/1", map[string]any{"name": "bob2"})
+	doTestRequest(r, http.MethodPatch, "/api/testaccount/1", map[string]any{"email": "bob2@example.org"})
+	// nothing changed, no history
+	doTestRequest(r, http.MethodPatch, "/api/testaccount/1", map[string]any{"email": "bob2@example.org"})
+
+	res = doTestRequest(r, http.MethodGet, "/api/testaccount/1/history", nil)
+	data := res["data"].(map[string]any)
+	assert.Equal(t, float64(3), data["total"])
+	items := data["items"].([]any)
+	first := items[2].(map[string]any)
+	assert.Equal(t, HistoryActionCreate, first["action"])
+	assert.Equal(t, "alice", first["actorName"])
+	assert.Equal(t, float64(7), first["actorId"])
+	assert.NotContains(t, first["changes"], "password")
+	last := items[0].(map[string]any)
+	assert.Equal(t, HistoryActionEdit, last["action"])
+	assert.Equal(t, map[string]any{
+		"email": map[string]any{"before": "bob@example.org", "after": "bob2@example.org"},
+	}, last["changes"])
+
+	// revert to the create
+	res = doTestRequest(r, http.MethodPost, fmt.Sprintf("/api/testaccount/1/history/%v/revert", first["id"]), nil)
+	require.Equal(t, float64(200), res["code"], res["msg"])
+	var account testAccount
+	require.NoError(t, db.First(&account, 1).Error)
+	assert.Equal(t, "bob", account.Name)
+	assert.Equal(t, "bob@example.org", account.Email)
+
+	res = doTestRequest(r, http.MethodPost, fmt.Sprintf("/api/testaccount/1/history/%v/revert", items[1].(map[string]any)["id"]), nil)
+	require.Equal(t, float64(200), res["code"], res["msg"])
+	require.NoError(t, db.First(&account, 1).Error)
+	assert.Equal(t, "bob2", account.Name)
+	assert.Equal(t, "bob@example.org", account.Email)
+
+	// a deleted record is created again
+	doTestRequest(r, http.MethodDelete, "/api/testaccount/1", nil)
+	res = doTestRequest(r, http.MethodGet, "/api/testaccount/1/history?limit=1", nil)
+	data = res["data"].(map[string]any)
+	deleted := data["items"].([]any)[0].(map[string]any)
+	assert.Equal(t, HistoryActionDelete, deleted["action"])
+	assert.Len(t, data["items"], 1)
+
+	res = doTestRequest(r, http.MethodPost, fmt.Sprintf("/api/testaccount/1/history/%v/revert", deleted["id"]), nil)
+	assert.Equal(t, float64(500), res["code"])
+	res = doTestRequest(r, http.MethodPost, fmt.Sprintf("/api/testaccount/1/history/%v/revert", first["id"]), nil)
+	require.Equal(t, float64(200), res["code"], res["msg"])
+	require.NoError(t, db.First(&account, 1).Error)
+	assert.Equal(t, "bob", account.Name)
+	assert.Equal(t, "secret", account.Password)
+
+	res = doTestRequest(r, http.MethodPost, "/api/testaccount/1/history/100/revert", nil)
+	assert.Equal(t, "history not found", res["msg"])
+}
+
+type testLegacyAccount struct {
+	ID   uint   `json:"id" gorm:"primaryKey"`
+	Name string `json:"name"`
+}
+
+func (testLegacyAccount) TableName() string {
+	return "legacy_accounts"
+}
+
+func TestWebObjectHistoryTableName(t *testing.T) {
+	r, db := newTestObject(t, &WebObject{
+		Model:        testLegacyAccount{},
+		Editables:    []string{"Name"},
+		AllowMethods: GET | CREATE | EDIT | HISTORY,
+	})
+	require.NoError(t, db.AutoMigrate(&ObjectHistory{}))
+
+	doTestRequest(r, http.MethodPut, "/api/testlegacyaccount", map[string]any{"name": "bob"})
+	doTestRequest(r, http.MethodPatch, "/api/testlegacyaccount/1", map[string]any{"name": "bob2"})
+
+	var history ObjectHistory
+	require.NoError(t, db.First(&history).Error)
+	assert.Equal(t, "legacy_accounts", history.Object)
+
+	res := doTestRequest(r, http.MethodGet, "/api/testlegacyaccount/1/history", nil)
+	require.Equal(t, float64(200), res["code"], res["msg"])
+	assert.Equal(t, float64(2), res["data"].(map[string]any)["total"])
+
+	res = doTestRequest(r, http.MethodPost, fmt.Sprintf("/api/testlegacyaccount/1/history/%d/revert", history.ID), nil)
+	require.Equal(t, float64(200), res["code"], res["msg"])
+	var account testLegacyAccount
+	require.NoError(t, db.First(&account, 1).Error)
+	assert.Equal(t, "bob", account.Name)
+}
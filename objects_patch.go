@@ -0,0 +1,389 @@
+package LingEcho
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/code-100-precent/LingFramework/pkg/utils/response"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// The Content-Type of the edits applied as patches to the stored record,
+// the other edits are the flat map of the fields.
+const (
+	ContentTypeJSONPatch  = "application/json-patch+json"  // RFC 6902
+	ContentTypeMergePatch = "application/merge-patch+json" // RFC 7396
+)
+
+// ErrPatchTestFailed is returned when a "test" operation of a JSON Patch
+// doesn't match the record, nothing is changed.
+var ErrPatchTestFailed = errors.New("patch test failed")
+
+// JSONPatchOperation is an operation of a RFC 6902 JSON Patch, such as:
+// {"op": "add", "path": "/tags/-", "value": "new"}
+type JSONPatchOperation struct {
+	Op    string          `json:"op"` // add, remove, replace, move, copy or test
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"` // source of move and copy
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// patchInput loads the record identified by keys, applies the patch body to
+// its JSON form and returns the changed fields by their JSON names, as the
+// flat edit body. Changing a field out of the Editables is an error.
+// It runs in the transaction of the update, with db locking the record, so
+// the "test" operations hold until the record is updated.
+func (obj *WebObject) patchInput(db *gorm.DB, keys []string, contentType string, body []byte) (map[string]any, error) {
+	val := reflect.New(obj.modelElem).Interface()
+	if err := obj.buildPrimaryCondition(db, keys).Take(val).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("not found")
+		}
+		return nil, err
+	}
+	data, err := json.Marshal(val)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	var result any
+	switch contentType {
+	case ContentTypeJSONPatch:
+		var ops []JSONPatchOperation
+		if err := json.Unmarshal(body, &ops); err != nil {
+			return nil, fmt.Errorf("invalid JSON Patch: %w", err)
+		}
+		// the patch works on a copy, the original is compared below
+		if result, err = applyJSONPatch(deepCopyJSON(doc), ops); err != nil {
+			return nil, err
+		}
+	case ContentTypeMergePatch:
+		var patch any
+		if err := json.Unmarshal(body, &patch); err != nil {
+			return nil, fmt.Errorf("invalid Merge Patch: %w", err)
+		}
+		result = applyMergePatch(deepCopyJSON(doc), patch)
+	default:
+		return nil, fmt.Errorf("unsupported patch %s", contentType)
+	}
+
+	patched, ok := result.(map[string]any)
+	if !ok {
+		return nil, errors.New("the patched record must be an object")
+	}
+	for k := range doc {
+		if _, ok := patched[k]; !ok {
+			return nil, fmt.Errorf("can't remove %s", k)
+		}
+	}
+	vals := map[string]any{}
+	for k, v := range patched {
+		if old, ok := doc[k]; ok && reflect.DeepEqual(old, v) {
+			continue
+		}
+		if !obj.isEditable(k) {
+			return nil, fmt.Errorf("%s is not editable", k)
+		}
+		vals[k] = v
+	}
+	return vals, nil
+}
+
+// isEditable reports whether the field of the JSON name key is in the Editables.
+func (obj *WebObject) isEditable(key string) bool {
+	fieldName, ok := obj.jsonToFields[key]
+	return ok && slices.Contains(obj.Editables, fieldName)
+}
+
+// failPatch writes the status of the patch errors, returns false for the others.
+func failPatch(c *gin.Context, err error) bool {
+	if !errors.Is(err, ErrPatchTestFailed) {
+		return false
+	}
+	response.Result(c, http.StatusConflict, http.StatusConflict, err.Error(), nil)
+	return true
+}
+
+// applyJSONPatch applies the operations in order to doc, any failed
+// operation fails the whole patch.
+func applyJSONPatch(doc any, ops []JSONPatchOperation) (any, error) {
+	for i, op := range ops {
+		var err error
+		if doc, err = applyJSONPatchOperation(doc, op); err != nil {
+			return nil, fmt.Errorf("patch operation %d: %w", i, err)
+		}
+	}
+	return doc, nil
+}
+
+func applyJSONPatchOperation(doc any, op JSONPatchOperation) (any, error) {
+	path, err := parseJSONPointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var value any
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return nil, fmt.Errorf("%s requires value", op.Op)
+		}
+		if err := json.Unmarshal(op.Value, &value); err != nil {
+			return nil, err
+		}
+	case "move", "copy":
+		from, err := parseJSONPointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		if value, err = getJSONPointer(doc, from); err != nil {
+			return nil, err
+		}
+		if op.Op == "copy" {
+			value = deepCopyJSON(value)
+			break
+		}
+		if len(from) < len(path) && slices.Equal(from, path[:len(from)]) {
+			return nil, errors.New("can't move a value into itself")
+		}
+		if doc, err = removeJSONPointer(doc, from); err != nil {
+			return nil, err
+		}
+	case "remove":
+	default:
+		return nil, fmt.Errorf("unknown op %q", op.Op)
+	}
+
+	switch op.Op {
+	case "add", "move", "copy":
+		return addJSONPointer(doc, path, value)
+	case "remove":
+		return removeJSONPointer(doc, path)
+	case "replace":
+		if _, err := getJSONPointer(doc, path); err != nil {
+			return nil, err
+		}
+		if len(path) == 0 {
+			return value, nil
+		}
+		return updateJSONPointer(doc, path, func(container any, token string) (any, error) {
+			if m, ok := container.(map[string]any); ok {
+				m[token] = value
+				return m, nil
+			}
+			arr := container.([]any)
+			i, _ := jsonArrayIndex(token, len(arr), false)
+			arr[i] = value
+			return arr, nil
+		})
+	default: // test
+		current, err := getJSONPointer(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(current, value) {
+			return nil, fmt.Errorf("%w: %s", ErrPatchTestFailed, op.Path)
+		}
+		return doc, nil
+	}
+}
+
+// parseJSONPointer splits the RFC 6901 pointer into the unescaped tokens,
+// "" is the whole document.
+func parseJSONPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid pointer %q", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+	return tokens, nil
+}
+
+// jsonArrayIndex parses the array index of token, "-" is the end of the
+// array when appending.
+func jsonArrayIndex(token string, size int, appending bool) (int, error) {
+	if appending && token == "-" {
+		return size, nil
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || token[0] < '0' || token[0] > '9' || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	limit := size
+	if appending {
+		limit++
+	}
+	if i >= limit {
+		return 0, fmt.Errorf("array index %d out of range", i)
+	}
+	return i, nil
+}
+
+func getJSONPointer(doc any, path []string) (any, error) {
+	for _, token := range path {
+		switch v := doc.(type) {
+		case map[string]any:
+			child, ok := v[token]
+			if !ok {
+				return nil, fmt.Errorf("%s not found", token)
+			}
+			doc = child
+		case []any:
+			i, err := jsonArrayIndex(token, len(v), false)
+			if err != nil {
+				return nil, err
+			}
+			doc = v[i]
+		default:
+			return nil, fmt.Errorf("%s not found", token)
+		}
+	}
+	return doc, nil
+}
+
+// updateJSONPointer calls fn with the container of the last token of path and
+// replaces the container with the result, as appending changes the slices.
+func updateJSONPointer(doc any, path []string, fn func(container any, token string) (any, error)) (any, error) {
+	if len(path) == 1 {
+		switch doc.(type) {
+		case map[string]any, []any:
+			return fn(doc, path[0])
+		}
+		return nil, fmt.Errorf("%s not found", path[0])
+	}
+	switch v := doc.(type) {
+	case map[string]any:
+		child, ok := v[path[0]]
+		if !ok {
+			return nil, fmt.Errorf("%s not found", path[0])
+		}
+		child, err := updateJSONPointer(child, path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		v[path[0]] = child
+		return v, nil
+	case []any:
+		i, err := jsonArrayIndex(path[0], len(v), false)
+		if err != nil {
+			return nil, err
+		}
+		child, err := updateJSONPointer(v[i], path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		v[i] = child
+		return v, nil
+	}
+	return nil, fmt.Errorf("%s not found", path[0])
+}
+
+func addJSONPointer(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return updateJSONPointer(doc, path, func(container any, token string) (any, error) {
+		if m, ok := container.(map[string]any); ok {
+			m[token] = value
+			return m, nil
+		}
+		arr := container.([]any)
+		i, err := jsonArrayIndex(token, len(arr), true)
+		if err != nil {
+			return nil, err
+		}
+		return slices.Insert(arr, i, value), nil
+	})
+}
+
+func removeJSONPointer(doc any, path []string) (any, error) {
+	if len(path) == 0 {
+		return nil, errors.New("can't remove the whole document")
+	}
+	return updateJSONPointer(doc, path, func(container any, token string) (any, error) {
+		if m, ok := container.(map[string]any); ok {
+			if _, ok := m[token]; !ok {
+				return nil, fmt.Errorf("%s not found", token)
+			}
+			delete(m, token)
+			return m, nil
+		}
+		arr := container.([]any)
+		i, err := jsonArrayIndex(token, len(arr), false)
+		if err != nil {
+			return nil, err
+		}
+		return slices.Delete(arr, i, i+1), nil
+	})
+}
+
+// applyMergePatch merges patch into target: the objects are merged
+// recursively, null removes the key and the other values replace the target.
+func applyMergePatch(target, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]any)
+	if !ok {
+		t = map[string]any{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+		} else {
+			t[k] = applyMergePatch(t[k], v)
+		}
+	}
+	return t
+}
+
+// deepCopyJSON copies the objects and arrays of the decoded JSON value.
+func deepCopyJSON(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		for k, child := range v {
+			m[k] = deepCopyJSON(child)
+		}
+		return m
+	case []any:
+		arr := make([]any, len(v))
+		for i, child := range v {
+			arr[i] = deepCopyJSON(child)
+		}
+		return arr
+	}
+	return v
+}
+
+// jsonColumn stores a value of a `gorm:"serializer:json"` field, which is
+// not serialized by the updates of map.
+type jsonColumn struct {
+	value any
+}
+
+func (v jsonColumn) Value() (driver.Value, error) {
+	data, err := json.Marshal(v.value)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,150 @@
+package LingEcho
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type testDocument struct {
+	ID    uint           `json:"id" gorm:"primaryKey"`
+	Title string         `json:"title"`
+	Owner string         `json:"owner"`
+	Tags  []string       `json:"tags" gorm:"serializer:json"`
+	Meta  map[string]any `json:"meta" gorm:"serializer:json"`
+}
+
+func TestWebObjectPatch(t *testing.T) {
+	var updates []map[string]any
+	var inTransaction []bool
+	r, db := newTestObject(t, &WebObject{
+		Model:     testDocument{},
+		Editables: []string{"Title", "Tags", "Meta"},
+		BeforeUpdate: func(db *gorm.DB, ctx *gin.Context, vptr any, vals map[string]any) error {
+			updates = append(updates, vals)
+			_, ok := db.Statement.ConnPool.(gorm.TxCommitter)
+			inTransaction = append(inTransaction, ok)
+			return nil
+		},
+	})
+	require.NoError(t, db.Create(&testDocument{Title: "a", Owner: "alice", Tags: []string{"x"}, Meta: map[string]any{"k": "v", "n": 1}}).Error)
+
+	patch := func(contentType string, body any) map[string]any {
+		w := doVersionRequest(r, http.MethodPatch, "/api/testdocument/1", body, map[string]string{"Content-Type": contentType})
+		var result map[string]any
+		json.Unmarshal(w.Body.Bytes(), &result)
+		result["status"] = w.Code
+		return result
+	}
+	load := func() testDocument {
+		var doc testDocument
+		require.NoError(t, db.First(&doc, 1).Error)
+		return doc
+	}
+
+	res := patch(ContentTypeJSONPatch, []map[string]any{
+		{"op": "test", "path": "/title", "value": "a"},
+		{"op": "add", "path": "/tags/-", "value": "y"},
+		{"op": "remove", "path": "/meta/k"},
+		{"op": "copy", "from": "/title", "path": "/meta/title"},
+	})
+	assert.Equal(t, float64(200), res["code"], res["msg"])
+	doc := load()
+	assert.Equal(t, []string{"x", "y"}, doc.Tags)
+	assert.Equal(t, map[string]any{"n": float64(1), "title": "a"}, doc.Meta)
+	// only the changed fields reach BeforeUpdate
+	require.Len(t, updates, 1)
+	assert.Equal(t, map[string]any{"tags": []any{"x", "y"}, "meta": map[string]any{"n": float64(1), "title": "a"}}, updates[0])
+	// loaded, patched and updated in one transaction
+	assert.Equal(t, []bool{true}, inTransaction)
+
+	res = patch(ContentTypeMergePatch, map[string]any{"title": "b", "meta": map[string]any{"n": nil, "m": map[string]any{"z": true}}})
+	assert.Equal(t, float64(200), res["code"], res["msg"])
+	doc = load()
+	assert.Equal(t, "b", doc.Title)
+	assert.Equal(t, map[string]any{"title": "a", "m": map[string]any{"z": true}}, doc.Meta)
+
+	// failed test, nothing changed
+	res = patch(ContentTypeJSONPatch, []map[string]any{
+		{"op": "replace", "path": "/title", "value": "c"},
+		{"op": "test", "path": "/tags/0", "value": "y"},
+	})
+	assert.Equal(t, http.StatusConflict, res["status"])
+	assert.Equal(t, "b", load().Title)
+
+	for _, tc := range []struct {
+		contentType string
+		body        any
+		msg         string
+	}{
+		{ContentTypeJSONPatch, []map[string]any{{"op": "replace", "path": "/owner", "value": "bob"}}, "owner is not editable"},
+		{ContentTypeMergePatch, map[string]any{"id": 2}, "id is not editable"},
+		{ContentTypeMergePatch, map[string]any{"title": nil}, "can't remove title"},
+		{ContentTypeJSONPatch, []map[string]any{{"op": "remove", "path": "/tags/5"}}, "patch operation 0: array index 5 out of range"},
+		{ContentTypeJSONPatch, []map[string]any{{"op": "move", "from": "/meta", "path": "/meta/m/x"}}, "patch operation 0: can't move a value into itself"},
+		{ContentTypeJSONPatch, []map[string]any{{"op": "add", "path": "/tags/-"}}, "patch operation 0: add requires value"},
+		{ContentTypeJSONPatch, map[string]any{"title": "c"}, "invalid JSON Patch: json: cannot unmarshal object into Go value of type []LingEcho.JSONPatchOperation"},
+		{ContentTypeMergePatch, map[string]any{"title": "b"}, "not changed"},
+	} {
+		res := patch(tc.contentType, tc.body)
+		assert.Equal(t, float64(500), res["code"], tc.msg)
+		assert.Equal(t, tc.msg, res["msg"])
+	}
+	assert.Equal(t, "alice", load().Owner)
+
+	// the flat edits store the JSON columns too
+	res = doTestRequest(r, http.MethodPatch, "/api/testdocument/1", map[string]any{"tags": []string{"z"}})
+	assert.Equal(t, float64(200), res["code"], res["msg"])
+	assert.Equal(t, []string{"z"}, load().Tags)
+}
+
+func TestApplyJSONPatch(t *testing.T) {
+	doc := map[string]any{"a": map[string]any{"b/c": []any{1.0, 2.0}, "~": "t"}, "d": "x"}
+	var ops []JSONPatchOperation
+	require.NoError(t, json.Unmarshal([]byte(`[
+		{"op": "add", "path": "/a/b~1c/0", "value": 0},
+		{"op": "move", "from": "/a/~0", "path": "/e"},
+		{"op": "replace", "path": "/d", "value": null},
+		{"op": "test", "path": "/a/b~1c", "value": [0, 1, 2]}
+	]`), &ops))
+	result, err := applyJSONPatch(doc, ops)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"a": map[string]any{"b/c": []any{0.0, 1.0, 2.0}}, "d": nil, "e": "t"}, result)
+
+	for _, patch := range []string{
+		`[{"op": "remove", "path": ""}]`,
+		`[{"op": "replace", "path": "/missing", "value": 1}]`,
+		`[{"op": "add", "path": "/a/b~1c/01", "value": 1}]`,
+		`[{"op": "add", "path": "a", "value": 1}]`,
+		`[{"op": "unknown", "path": "/a"}]`,
+	} {
+		require.NoError(t, json.Unmarshal([]byte(patch), &ops))
+		_, err := applyJSONPatch(doc, ops)
+		assert.Error(t, err, patch)
+	}
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	// examples of RFC 7396
+	for _, tc := range [][3]string{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+	} {
+		var target, patch any
+		require.NoError(t, json.Unmarshal([]byte(tc[0]), &target))
+		require.NoError(t, json.Unmarshal([]byte(tc[1]), &patch))
+		data, err := json.Marshal(applyMergePatch(target, patch))
+		require.NoError(t, err)
+		assert.JSONEq(t, tc[2], string(data), tc[1])
+	}
+}
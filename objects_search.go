@@ -0,0 +1,268 @@
+package LingEcho
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"github.com/code-100-precent/LingFramework/pkg/constants"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultSearchLimit  = 1000 // max hits of the keyword
+	DefaultReindexBatch = 500
+)
+
+// SearchDoc is a record of a WebObject in the full-text index.
+type SearchDoc struct {
+	ID     string         // primary values joined as in the url path
+	Fields map[string]any // keyed by the JSON names
+}
+
+// SearchIndexer keeps the full-text index of the WebObjects, docType separates
+// the objects sharing the index, and the tenants of the objects with TenantField.
+// search.NewObjectIndexer adapts a search.Engine.
+type SearchIndexer interface {
+	Index(ctx context.Context, docType string, docs []SearchDoc) error
+	Delete(ctx context.Context, docType string, ids []string) error
+	// Search return the ids of the docs matching keyword in fields, the best first.
+	Search(ctx context.Context, docType, keyword string, fields []string, limit int) ([]string, error)
+}
+
+// SearchIndex declares the fields of the WebObject copied to the full-text index.
+// The index is updated once create, edit and delete are committed, a failed
+// index update doesn't roll back the change, it's fixed by the next change or Reindex.
+type SearchIndex struct {
+	Indexer SearchIndexer
+	Type    string   // document type, the Name of the object by default
+	Fields  []string // struct fields, Searchables by default
+	// Keyword makes the QUERY keyword match the index instead of LIKE over Searchables,
+	// the object must have a single primary key.
+	Keyword bool
+	Limit   int // max hits of the keyword, DefaultSearchLimit by default
+}
+
+// buildSearchIndex check SearchIndex and resolve the JSON names of its Fields.
+func (obj *WebObject) buildSearchIndex() error {
+	idx := obj.SearchIndex
+	if idx == nil {
+		return nil
+	}
+	if idx.Indexer == nil {
+		return fmt.Errorf("%s search index without indexer", obj.Name)
+	}
+	if idx.Type == "" {
+		idx.Type = obj.Name
+	}
+	fields := idx.Fields
+	if len(fields) == 0 {
+		fields = obj.Searchables
+	}
+	if len(fields) == 0 {
+		return fmt.Errorf("%s search index without fields", obj.Name)
+	}
+
+	obj.searchIndexFields = nil
+	for _, field := range fields {
+		jsonName := ""
+		for k, v := range obj.jsonToFields {
+			if v == field {
+				jsonName = k
+				break
+			}
+		}
+		if jsonName == "" {
+			return fmt.Errorf("invalid search index field: %s", field)
+		}
+		obj.searchIndexFields = append(obj.searchIndexFields, jsonName)
+	}
+
+	if idx.Keyword && len(obj.uniqueKeys) != 1 {
+		return fmt.Errorf("%s keyword search needs a single primary key", obj.Name)
+	}
+	return nil
+}
+
+// searchType return the document type of the records of tenant.
+func (obj *WebObject) searchType(tenant string) string {
+	if obj.TenantField == "" {
+		return obj.SearchIndex.Type
+	}
+	return tenantKey(tenant, obj.SearchIndex.Type)
+}
+
+func searchContext(c *gin.Context) context.Context {
+	if c == nil || c.Request == nil {
+		return context.Background()
+	}
+	return c.Request.Context()
+}
+
+// searchDoc return the document of the model in the index.
+func (obj *WebObject) searchDoc(val any) (SearchDoc, error) {
+	vals, err := historyValues(val)
+	if err != nil {
+		return SearchDoc{}, err
+	}
+	doc := SearchDoc{ID: obj.historyRecordKey(vals), Fields: map[string]any{}}
+	for _, k := range obj.searchIndexFields {
+		if v, ok := vals[k]; ok && v != nil {
+			doc.Fields[k] = v
+		}
+	}
+	return doc, nil
+}
+
+// indexSearch add or replace the models in the index, if there is a SearchIndex.
+func (obj *WebObject) indexSearch(ctx context.Context, vals ...any) error {
+	if obj.SearchIndex == nil || len(vals) == 0 {
+		return nil
+	}
+	var types []string
+	docs := map[string][]SearchDoc{}
+	for _, val := range vals {
+		doc, err := obj.searchDoc(val)
+		if err != nil {
+			return err
+		}
+		docType := obj.searchType(obj.tenantOf(val))
+		if _, ok := docs[docType]; !ok {
+			types = append(types, docType)
+		}
+		docs[docType] = append(docs[docType], doc)
+	}
+	for _, docType := range types {
+		if err := obj.SearchIndex.Indexer.Index(ctx, docType, docs[docType]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unindexSearch remove the model from the index, if there is a SearchIndex.
+func (obj *WebObject) unindexSearch(ctx context.Context, val any) error {
+	if obj.SearchIndex == nil {
+		return nil
+	}
+	vals, err := historyValues(val)
+	if err != nil {
+		return err
+	}
+	return obj.SearchIndex.Indexer.Delete(ctx, obj.searchType(obj.tenantOf(val)), []string{obj.historyRecordKey(vals)})
+}
+
+// pendingIndex is an update of the search index waiting for the commit of the request.
+type pendingIndex struct {
+	obj    *WebObject
+	val    any
+	delete bool
+}
+
+// stashIndex keep the update of the index in the request until afterCommit
+// applies it, without a request the index is updated at once.
+func (obj *WebObject) stashIndex(c *gin.Context, val any, delete bool) error {
+	if obj.SearchIndex == nil {
+		return nil
+	}
+	if c == nil {
+		if delete {
+			return obj.unindexSearch(context.Background(), val)
+		}
+		return obj.indexSearch(context.Background(), val)
+	}
+	pending, _ := c.Get(constants.SearchField)
+	updates, _ := pending.([]pendingIndex)
+	c.Set(constants.SearchField, append(updates, pendingIndex{obj: obj, val: val, delete: delete}))
+	return nil
+}
+
+// applyIndex update the index with the changes committed by the request.
+// It's the best effort, the index is fixed by the next change or Reindex anyway.
+func applyIndex(c *gin.Context) {
+	pending, _ := c.Get(constants.SearchField)
+	updates, _ := pending.([]pendingIndex)
+	if len(updates) == 0 {
+		return
+	}
+	c.Set(constants.SearchField, nil)
+	ctx := searchContext(c)
+	for _, u := range updates {
+		if u.delete {
+			u.obj.unindexSearch(ctx, u.val)
+		} else {
+			u.obj.indexSearch(ctx, u.val)
+		}
+	}
+}
+
+// primaryValues return the primary values of the model, as getPrimaryValues.
+func (obj *WebObject) primaryValues(val any) ([]string, error) {
+	vals, err := historyValues(val)
+	if err != nil {
+		return nil, err
+	}
+	var result []string
+	for _, field := range obj.uniqueKeys {
+		result = append(result, formatPrimaryValue(vals[field.JSONName]))
+	}
+	return result, nil
+}
+
+// searchKeyword return the primary values of the records matching keyword,
+// typed as the primary field.
+func (obj *WebObject) searchKeyword(c *gin.Context, keyword string) ([]any, error) {
+	limit := obj.SearchIndex.Limit
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	}
+	ids, err := obj.SearchIndex.Indexer.Search(searchContext(c), obj.searchType(CurrentTenant(c)), keyword, obj.searchIndexFields, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]any, 0, len(ids))
+	for _, id := range ids {
+		switch obj.uniqueKeys[0].Kind {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			v, err := strconv.ParseInt(id, 10, 64)
+			if err != nil {
+				continue // not a record of this object
+			}
+			keys = append(keys, v)
+		default:
+			keys = append(keys, id)
+		}
+	}
+	return keys, nil
+}
+
+// Reindex add all the records of db to the search index, batchSize rows at a time.
+// The records deleted bypassing the WebObject are not removed from the index.
+func (obj *WebObject) Reindex(ctx context.Context, db *gorm.DB, batchSize int) (int, error) {
+	if obj.SearchIndex == nil {
+		return 0, fmt.Errorf("%s without search index", obj.Name)
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultReindexBatch
+	}
+
+	count := 0
+	vals := reflect.New(reflect.SliceOf(obj.modelElem))
+	result := db.Model(obj.Model).FindInBatches(vals.Interface(), batchSize, func(tx *gorm.DB, batch int) error {
+		rows := vals.Elem()
+		items := make([]any, 0, rows.Len())
+		for i := 0; i < rows.Len(); i++ {
+			items = append(items, rows.Index(i).Addr().Interface())
+		}
+		if err := obj.indexSearch(ctx, items...); err != nil {
+			return err
+		}
+		count += len(items)
+		return nil
+	})
+	return count, result.Error
+}
@@ -0,0 +1,170 @@
+package LingEcho
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type testArticle struct {
+	ID     uint   `json:"id" gorm:"primaryKey"`
+	Title  string `json:"title"`
+	Body   string `json:"body"`
+	Status string `json:"status"`
+}
+
+// memIndexer matches the keyword as a substring of the fields
+type memIndexer struct {
+	docs map[string]map[string]any
+	fail bool
+}
+
+func newMemIndexer() *memIndexer {
+	return &memIndexer{docs: map[string]map[string]any{}}
+}
+
+func (m *memIndexer) Index(ctx context.Context, docType string, docs []SearchDoc) error {
+	if m.fail {
+		return errors.New("index failed")
+	}
+	for _, doc := range docs {
+		m.docs[docType+":"+doc.ID] = doc.Fields
+	}
+	return nil
+}
+
+func (m *memIndexer) Delete(ctx context.Context, docType string, ids []string) error {
+	if m.fail {
+		return errors.New("index failed")
+	}
+	for _, id := range ids {
+		delete(m.docs, docType+":"+id)
+	}
+	return nil
+}
+
+func (m *memIndexer) Search(ctx context.Context, docType, keyword string, fields []string, limit int) ([]string, error) {
+	var ids []string
+	for id, doc := range m.docs {
+		if !strings.HasPrefix(id, docType+":") {
+			continue
+		}
+		for _, f := range fields {
+			if v, ok := doc[f].(string); ok && strings.Contains(v, keyword) {
+				ids = append(ids, strings.TrimPrefix(id, docType+":"))
+				break
+			}
+		}
+	}
+	sort.Strings(ids)
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+	return ids, nil
+}
+
+func TestWebObjectSearchIndex(t *testing.T) {
+	idx := newMemIndexer()
+	r, db := newTestObject(t, &WebObject{
+		Model:        testArticle{},
+		Editables:    []string{"Title", "Body", "Status"},
+		Searchables:  []string{"Title"},
+		AllowMethods: GET | CREATE | EDIT | DELETE | QUERY | BATCH,
+		SearchIndex:  &SearchIndex{Indexer: idx, Fields: []string{"Title", "Body"}, Keyword: true},
+	})
+
+	res := doTestRequest(r, http.MethodPut, "/api/testarticle", map[string]any{"title": "hello", "body": "gopher news", "status": "draft"})
+	require.Equal(t, float64(200), res["code"], res["msg"])
+	doTestRequest(r, http.MethodPut, "/api/testarticle", map[string]any{"title": "world", "body": "nothing"})
+	assert.Equal(t, map[string]any{"title": "hello", "body": "gopher news"}, idx.docs["testarticle:1"])
+
+	res = doTestRequest(r, http.MethodPatch, "/api/testarticle/2", map[string]any{"body": "more gopher"})
+	require.Equal(t, float64(200), res["code"], res["msg"])
+	assert.Equal(t, "more gopher", idx.docs["testarticle:2"]["body"])
+
+	// the keyword matches the body, which is not Searchables
+	res = doTestRequest(r, http.MethodPost, "/api/testarticle", map[string]any{"keyword": "gopher"})
+	data := res["data"].(map[string]any)
+	assert.Equal(t, float64(2), data["total"])
+	res = doTestRequest(r, http.MethodPost, "/api/testarticle", map[string]any{"keyword": "news"})
+	data = res["data"].(map[string]any)
+	assert.Equal(t, float64(1), data["total"])
+	assert.Equal(t, "hello", data["items"].([]any)[0].(map[string]any)["title"])
+	res = doTestRequest(r, http.MethodPost, "/api/testarticle", map[string]any{"keyword": "nope"})
+	assert.Nil(t, res["data"].(map[string]any)["total"])
+
+	res = doTestRequest(r, http.MethodDelete, "/api/testarticle/1", nil)
+	require.Equal(t, float64(200), res["code"], res["msg"])
+	assert.NotContains(t, idx.docs, "testarticle:1")
+
+	// a failed index doesn't roll back the change, the next change fixes it
+	idx.fail = true
+	res = doTestRequest(r, http.MethodPatch, "/api/testarticle/2", map[string]any{"title": "changed"})
+	require.Equal(t, float64(200), res["code"], res["msg"])
+	var article testArticle
+	require.NoError(t, db.First(&article, 2).Error)
+	assert.Equal(t, "changed", article.Title)
+	assert.Equal(t, "world", idx.docs["testarticle:2"]["title"])
+	idx.fail = false
+	res = doTestRequest(r, http.MethodPatch, "/api/testarticle/2", map[string]any{"title": "world"})
+	require.Equal(t, float64(200), res["code"], res["msg"])
+	assert.Equal(t, "world", idx.docs["testarticle:2"]["title"])
+
+	// the changes rolled back never reach the index
+	res = doTestRequest(r, http.MethodPost, "/api/testarticle/batch", map[string]any{
+		"creates": []any{map[string]any{"title": "ghost"}},
+		"edits":   []any{map[string]any{"id": 2, "title": "batched"}},
+		"deletes": []any{99},
+	})
+	assert.NotEqual(t, float64(200), res["code"])
+	assert.Equal(t, "world", idx.docs["testarticle:2"]["title"])
+	assert.Len(t, idx.docs, 1)
+
+	AddObjectListener(func(db *gorm.DB, c *gin.Context, e *ObjectEvent) error {
+		return errors.New("listener failed")
+	})
+	t.Cleanup(func() { ObjectListeners = nil })
+	res = doTestRequest(r, http.MethodPatch, "/api/testarticle/2", map[string]any{"title": "rolled"})
+	assert.Equal(t, "listener failed", res["msg"])
+	res = doTestRequest(r, http.MethodDelete, "/api/testarticle/2", nil)
+	assert.Equal(t, "listener failed", res["msg"])
+	assert.Equal(t, "world", idx.docs["testarticle:2"]["title"])
+}
+
+func TestWebObjectReindex(t *testing.T) {
+	idx := newMemIndexer()
+	obj := &WebObject{
+		Model:       testArticle{},
+		Searchables: []string{"Title"},
+		SearchIndex: &SearchIndex{Indexer: idx, Type: "article"},
+	}
+	_, db := newTestObject(t, obj)
+	for _, title := range []string{"a", "b", "c"} {
+		require.NoError(t, db.Create(&testArticle{Title: title, Body: "ignored"}).Error)
+	}
+
+	n, err := obj.Reindex(context.Background(), db, 2)
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+	assert.Len(t, idx.docs, 3)
+	assert.Equal(t, map[string]any{"title": "c"}, idx.docs["article:3"])
+}
+
+func TestWebObjectSearchIndexBuild(t *testing.T) {
+	obj := &WebObject{Model: testArticle{}, SearchIndex: &SearchIndex{Indexer: newMemIndexer()}}
+	assert.EqualError(t, obj.Build(), "testarticle search index without fields")
+
+	obj = &WebObject{Model: testArticle{}, SearchIndex: &SearchIndex{Indexer: newMemIndexer(), Fields: []string{"Missing"}}}
+	assert.EqualError(t, obj.Build(), "invalid search index field: Missing")
+
+	obj = &WebObject{Model: testArticle{}, SearchIndex: &SearchIndex{Fields: []string{"Title"}}}
+	assert.EqualError(t, obj.Build(), "testarticle search index without indexer")
+}
@@ -0,0 +1,219 @@
+package LingEcho
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/code-100-precent/LingFramework/pkg/constants"
+	"github.com/code-100-precent/LingFramework/pkg/utils/response"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrTenantRequired  = errors.New("tenant required")
+	ErrInvalidTenant   = errors.New("invalid tenant")
+	ErrTenantForbidden = errors.New("tenant forbidden")
+)
+
+// TenantResolver return the tenant of the request, "" if the request doesn't carry one.
+type TenantResolver func(c *gin.Context) (string, error)
+
+// TenantMemberFunc return true if the user of the request belongs to tenant.
+type TenantMemberFunc func(c *gin.Context, tenant string) bool
+
+// TenantMiddleware resolve the tenant of the request with the first resolver returning one,
+// and store it for CurrentTenant. The request is rejected with 403 if the user doesn't
+// belong to the tenant, with 400 if a resolver fails otherwise.
+func TenantMiddleware(resolvers ...TenantResolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, resolve := range resolvers {
+			tenant, err := resolve(c)
+			if errors.Is(err, ErrTenantForbidden) {
+				response.AbortWithStatusJSON(c, http.StatusForbidden, err)
+				return
+			}
+			if err != nil {
+				response.AbortWithStatusJSON(c, http.StatusBadRequest, err)
+				return
+			}
+			if tenant != "" {
+				c.Set(constants.TenantField, tenant)
+				break
+			}
+		}
+		c.Next()
+	}
+}
+
+// CurrentTenant return the tenant resolved by TenantMiddleware, "" if none.
+func CurrentTenant(c *gin.Context) string {
+	if c == nil {
+		return ""
+	}
+	return c.GetString(constants.TenantField)
+}
+
+// TenantKey namespace key with the tenant of the request, such as cache keys.
+// key is returned as it is without a tenant.
+func TenantKey(c *gin.Context, key string) string {
+	return tenantKey(CurrentTenant(c), key)
+}
+
+func tenantKey(tenant, key string) string {
+	if tenant == "" {
+		return key
+	}
+	return tenant + ":" + key
+}
+
+// TenantFromHeader resolve the tenant from the request header, such as "X-Tenant-ID".
+// The header is chosen by the client, the tenant is accepted only if member returns
+// true for the user of the request; a nil member accepts none.
+func TenantFromHeader(name string, member TenantMemberFunc) TenantResolver {
+	return func(c *gin.Context) (string, error) {
+		return checkTenantMember(c, strings.TrimSpace(c.GetHeader(name)), member)
+	}
+}
+
+// TenantFromSubdomain resolve the tenant from the subdomain of domain,
+// "acme.example.com" is the tenant "acme" of "example.com".
+// Hosts outside of domain and nested subdomains carry no tenant.
+// As TenantFromHeader, the tenant is accepted only if member returns true.
+func TenantFromSubdomain(domain string, member TenantMemberFunc) TenantResolver {
+	suffix := "." + strings.ToLower(strings.Trim(domain, "."))
+	return func(c *gin.Context) (string, error) {
+		host := c.Request.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.ToLower(host)
+		if !strings.HasSuffix(host, suffix) {
+			return "", nil
+		}
+		sub := strings.TrimSuffix(host, suffix)
+		if sub == "" || strings.Contains(sub, ".") {
+			return "", nil
+		}
+		return checkTenantMember(c, sub, member)
+	}
+}
+
+func checkTenantMember(c *gin.Context, tenant string, member TenantMemberFunc) (string, error) {
+	if tenant == "" {
+		return "", nil
+	}
+	if member == nil || !member(c, tenant) {
+		return "", ErrTenantForbidden
+	}
+	return tenant, nil
+}
+
+// buildTenantField check the TenantField of obj.
+func (obj *WebObject) buildTenantField() error {
+	if obj.TenantField == "" {
+		return nil
+	}
+	f, ok := obj.modelElem.FieldByName(obj.TenantField)
+	if !ok {
+		return fmt.Errorf("%s: invalid tenant field %s", obj.Name, obj.TenantField)
+	}
+	switch f.Type.Kind() {
+	case reflect.String, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return nil
+	}
+	return fmt.Errorf("%s: tenant field %s must be string or integer", obj.Name, obj.TenantField)
+}
+
+// tenantValue return the tenant of the request typed as TenantField.
+func (obj *WebObject) tenantValue(c *gin.Context) (any, error) {
+	tenant := CurrentTenant(c)
+	if tenant == "" {
+		return nil, ErrTenantRequired
+	}
+	f, _ := obj.modelElem.FieldByName(obj.TenantField)
+	switch f.Type.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v, err := strconv.ParseInt(tenant, 10, f.Type.Bits())
+		if err != nil {
+			return nil, ErrInvalidTenant
+		}
+		return v, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v, err := strconv.ParseUint(tenant, 10, f.Type.Bits())
+		if err != nil {
+			return nil, ErrInvalidTenant
+		}
+		return v, nil
+	}
+	return tenant, nil
+}
+
+// requireTenant reject the requests to obj without a valid tenant.
+func (obj *WebObject) requireTenant(c *gin.Context) {
+	if _, err := obj.tenantValue(c); err != nil {
+		response.Fail(c, err.Error(), nil)
+		c.Abort()
+		return
+	}
+	c.Next()
+}
+
+// getDB return the connection of the request, scoped to its tenant if obj has TenantField.
+// The connection for create is not scoped, the new rows are stamped by createObject instead.
+func (obj *WebObject) getDB(c *gin.Context, isCreate bool) *gorm.DB {
+	db := GetDbConnection(c, obj.GetDB, isCreate)
+	if obj.TenantField == "" || isCreate {
+		return db
+	}
+	v, err := obj.tenantValue(c)
+	if err != nil {
+		db.AddError(err)
+		return db
+	}
+	return db.Where(clause.Eq{
+		Column: clause.Column{Table: obj.dbTable(db), Name: obj.tenantColumn(db)},
+		Value:  v,
+	}).Session(&gorm.Session{})
+}
+
+func (obj *WebObject) tenantColumn(db *gorm.DB) string {
+	return db.NamingStrategy.ColumnName(obj.tableName, obj.TenantField)
+}
+
+// stampTenant set TenantField of the model to the tenant of the request.
+func (obj *WebObject) stampTenant(c *gin.Context, vptr any) error {
+	if obj.TenantField == "" {
+		return nil
+	}
+	v, err := obj.tenantValue(c)
+	if err != nil {
+		return err
+	}
+	rv := reflect.Indirect(reflect.ValueOf(vptr))
+	f := rv.FieldByName(obj.TenantField)
+	if !f.CanSet() {
+		return fmt.Errorf("%s: can't set tenant field %s", obj.Name, obj.TenantField)
+	}
+	f.Set(reflect.ValueOf(v).Convert(f.Type()))
+	return nil
+}
+
+// tenantOf return the tenant of the model, "" if obj has no TenantField.
+func (obj *WebObject) tenantOf(val any) string {
+	if obj.TenantField == "" || val == nil {
+		return ""
+	}
+	rv := reflect.Indirect(reflect.ValueOf(val))
+	if rv.Kind() != reflect.Struct {
+		return ""
+	}
+	return fmt.Sprint(rv.FieldByName(obj.TenantField).Interface())
+}
@@ -0,0 +1,191 @@
+package LingEcho
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/code-100-precent/LingFramework/pkg/constants"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type testNote struct {
+	ID       uint   `json:"id" gorm:"primaryKey"`
+	TenantID string `json:"tenantId" gorm:"size:64;index"`
+	Title    string `json:"title"`
+}
+
+// testTenantMember accepts all the tenants but "initech"
+func testTenantMember(c *gin.Context, tenant string) bool {
+	return tenant != "initech"
+}
+
+func newTestTenantObject(t *testing.T, obj *WebObject) (*gin.Engine, *gorm.DB) {
+	gin.SetMode(gin.TestMode)
+
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(obj.Model, &ObjectHistory{}))
+
+	r := gin.New()
+	g := r.Group("/api")
+	g.Use(func(c *gin.Context) {
+		c.Set(constants.DbField, db)
+		c.Next()
+	}, TenantMiddleware(TenantFromHeader("X-Tenant", testTenantMember), TenantFromSubdomain("example.org", testTenantMember)))
+	require.NoError(t, obj.RegisterObject(g))
+	return r, db
+}
+
+func doTenantRequest(r *gin.Engine, tenant, method, path string, body any) map[string]any {
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	if tenant != "" {
+		req.Header.Set("X-Tenant", tenant)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var result map[string]any
+	json.Unmarshal(w.Body.Bytes(), &result)
+	return result
+}
+
+func TestWebObjectTenant(t *testing.T) {
+	idx := newMemIndexer()
+	r, db := newTestTenantObject(t, &WebObject{
+		Model:        testNote{},
+		TenantField:  "TenantID",
+		Editables:    []string{"Title", "TenantID"},
+		Searchables:  []string{"Title"},
+		Filterables:  []string{"Title"},
+		AllowMethods: GET | CREATE | EDIT | DELETE | QUERY | BATCH | HISTORY,
+		SearchIndex:  &SearchIndex{Indexer: idx, Keyword: true},
+	})
+
+	res := doTenantRequest(r, "", http.MethodPut, "/api/testnote", map[string]any{"title": "x"})
+	assert.Equal(t, ErrTenantRequired.Error(), res["msg"])
+
+	// stamped with the tenant of the request, not of the body
+	res = doTenantRequest(r, "acme", http.MethodPut, "/api/testnote", map[string]any{"title": "acme note", "tenantId": "globex"})
+	require.Equal(t, float64(200), res["code"], res["msg"])
+	assert.Equal(t, "acme", res["data"].(map[string]any)["tenantId"])
+	res = doTenantRequest(r, "globex", http.MethodPut, "/api/testnote", map[string]any{"title": "globex note"})
+	require.Equal(t, float64(200), res["code"], res["msg"])
+
+	res = doTenantRequest(r, "acme", http.MethodPost, "/api/testnote", map[string]any{})
+	assert.Equal(t, float64(1), res["data"].(map[string]any)["total"])
+	res = doTenantRequest(r, "acme", http.MethodPost, "/api/testnote", map[string]any{"keyword": "note"})
+	items := res["data"].(map[string]any)["items"].([]any)
+	require.Len(t, items, 1)
+	assert.Equal(t, "acme note", items[0].(map[string]any)["title"])
+	assert.Contains(t, idx.docs, "acme:testnote:1")
+	assert.Contains(t, idx.docs, "globex:testnote:2")
+
+	// cross-tenant
+	res = doTenantRequest(r, "acme", http.MethodGet, "/api/testnote/2", nil)
+	assert.Equal(t, "not found", res["msg"])
+	res = doTenantRequest(r, "acme", http.MethodPatch, "/api/testnote/2", map[string]any{"title": "stolen"})
+	assert.NotEqual(t, float64(200), res["code"])
+	res = doTenantRequest(r, "acme", http.MethodDelete, "/api/testnote/2", nil)
+	assert.NotEqual(t, float64(200), res["code"])
+	res = doTenantRequest(r, "acme", http.MethodPost, "/api/testnote/batch", map[string]any{
+		"edits": []any{map[string]any{"id": 2, "title": "stolen"}},
+	})
+	assert.NotEqual(t, float64(200), res["code"])
+	res = doTenantRequest(r, "acme", http.MethodGet, "/api/testnote/2/history", nil)
+	assert.Empty(t, res["data"].(map[string]any)["items"])
+
+	var note testNote
+	require.NoError(t, db.First(&note, 2).Error)
+	assert.Equal(t, "globex note", note.Title)
+	assert.Equal(t, "globex", note.TenantID)
+
+	// the tenant can't be moved
+	res = doTenantRequest(r, "acme", http.MethodPatch, "/api/testnote/1", map[string]any{"title": "moved", "tenantId": "globex"})
+	require.Equal(t, float64(200), res["code"], res["msg"])
+	note = testNote{}
+	require.NoError(t, db.First(&note, 1).Error)
+	assert.Equal(t, "moved", note.Title)
+	assert.Equal(t, "acme", note.TenantID)
+
+	res = doTenantRequest(r, "acme", http.MethodGet, "/api/testnote/1/history", nil)
+	assert.Equal(t, float64(2), res["data"].(map[string]any)["total"])
+
+	res = doTenantRequest(r, "globex", http.MethodDelete, "/api/testnote/2", nil)
+	require.Equal(t, float64(200), res["code"], res["msg"])
+	assert.NotContains(t, idx.docs, "globex:testnote:2")
+}
+
+func TestTenantResolvers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(TenantMiddleware(TenantFromHeader("X-Tenant", testTenantMember), TenantFromSubdomain("example.org", testTenantMember)))
+	r.GET("/", func(c *gin.Context) {
+		c.String(http.StatusOK, "%s|%s", CurrentTenant(c), TenantKey(c, "k"))
+	})
+
+	cases := []struct {
+		host, header, want string
+	}{
+		{"example.org", "", "|k"},
+		{"acme.example.org:8080", "", "acme|acme:k"},
+		{"Acme.Example.org", "", "acme|acme:k"},
+		{"a.b.example.org", "", "|k"},
+		{"acme.other.org", "", "|k"},
+		{"acme.example.org", "globex", "globex|globex:k"},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Host = tc.host
+		if tc.header != "" {
+			req.Header.Set("X-Tenant", tc.header)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, tc.want, w.Body.String(), tc.host)
+	}
+
+	// the tenants the user doesn't belong to are forbidden
+	for _, tc := range []struct{ host, header string }{
+		{"example.org", "initech"},
+		{"initech.example.org", ""},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Host = tc.host
+		if tc.header != "" {
+			req.Header.Set("X-Tenant", tc.header)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code, tc.host)
+	}
+
+	// no member check accepts no tenant
+	r = gin.New()
+	r.Use(TenantMiddleware(TenantFromHeader("X-Tenant", nil)))
+	r.GET("/", func(c *gin.Context) { c.String(http.StatusOK, CurrentTenant(c)) })
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Tenant", "acme")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestWebObjectTenantBuild(t *testing.T) {
+	obj := &WebObject{Model: testNote{}, TenantField: "Missing"}
+	assert.Error(t, obj.Build())
+	obj = &WebObject{Model: testNote{}, TenantField: "TenantID"}
+	assert.NoError(t, obj.Build())
+}
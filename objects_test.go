@@ -0,0 +1,85 @@
+package LingEcho
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/code-100-precent/LingFramework/pkg/constants"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type testItem struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Name      string    `json:"name" gorm:"size:64"`
+	Status    string    `json:"status" gorm:"size:32"`
+	Score     int       `json:"score"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func newTestObject(t *testing.T, obj *WebObject) (*gin.Engine, *gorm.DB) {
+	gin.SetMode(gin.TestMode)
+
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(obj.Model))
+
+	r := gin.New()
+	g := r.Group("/api")
+	g.Use(func(c *gin.Context) {
+		c.Set(constants.DbField, db)
+		c.Next()
+	})
+	require.NoError(t, obj.RegisterObject(g))
+	return r, db
+}
+
+func doTestRequest(r *gin.Engine, method, path string, body any) map[string]any {
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var result map[string]any
+	json.Unmarshal(w.Body.Bytes(), &result)
+	return result
+}
+
+func TestWebObjectCRUD(t *testing.T) {
+	r, db := newTestObject(t, &WebObject{
+		Model:     testItem{},
+		Editables: []string{"Name", "Score"},
+	})
+
+	res := doTestRequest(r, http.MethodPut, "/api/testitem", map[string]any{"name": "alice", "score": 1})
+	assert.Equal(t, float64(200), res["code"])
+
+	res = doTestRequest(r, http.MethodPatch, "/api/testitem/1", map[string]any{"score": 5, "status": "x"})
+	assert.Equal(t, float64(200), res["code"])
+
+	var item testItem
+	require.NoError(t, db.First(&item, 1).Error)
+	assert.Equal(t, 5, item.Score)
+	assert.Equal(t, "", item.Status)
+
+	res = doTestRequest(r, http.MethodPatch, "/api/testitem/1", map[string]any{"status": "x"})
+	assert.Equal(t, "not changed", res["msg"])
+
+	res = doTestRequest(r, http.MethodDelete, "/api/testitem/1", nil)
+	assert.Equal(t, float64(200), res["code"])
+
+	res = doTestRequest(r, http.MethodDelete, "/api/testitem/1", nil)
+	assert.Equal(t, "not found", res["msg"])
+}
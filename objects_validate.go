@@ -0,0 +1,131 @@
+package LingEcho
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/code-100-precent/LingFramework/pkg/i18n"
+	"github.com/code-100-precent/LingFramework/pkg/utils/response"
+	"github.com/code-100-precent/LingFramework/pkg/validator"
+	"github.com/gin-gonic/gin"
+)
+
+// defaultValidator validates the requests without validator.Middleware,
+// with the default English messages.
+var defaultValidator = validator.NewValidator(nil)
+
+// buildRules merge the Rules into the rules of the `validate` tags.
+func (obj *WebObject) buildRules() error {
+	for fieldName, rules := range obj.Rules {
+		jsonName := ""
+		for k, v := range obj.jsonToFields {
+			if v == fieldName {
+				jsonName = k
+				break
+			}
+		}
+		if jsonName == "" {
+			return fmt.Errorf("%s rules of unknown field %s", obj.Name, fieldName)
+		}
+		obj.rules[jsonName] = mergeRules(obj.rules[jsonName], rules)
+	}
+	return nil
+}
+
+// mergeRules join the rules in the tag syntax, such as "required" and
+// "maxlen:64" => "required,maxlen:64".
+func mergeRules(rules ...string) string {
+	var parts []string
+	for _, r := range rules {
+		if r = strings.Trim(strings.TrimSpace(r), ","); r != "" {
+			parts = append(parts, r)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// hasRule reports whether the rules in the tag syntax contain the rule name.
+func hasRule(rules, name string) bool {
+	for _, part := range strings.Split(rules, ",") {
+		if i := strings.IndexAny(part, ":="); i >= 0 {
+			part = part[:i]
+		}
+		if strings.TrimSpace(part) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// validateCreate validate all the fields of the new record vptr.
+func (obj *WebObject) validateCreate(c *gin.Context, vptr any) error {
+	if len(obj.rules) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(vptr)
+	if err != nil {
+		return err
+	}
+	var values map[string]any
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+	return obj.validate(c, values, false)
+}
+
+// validateEdit validate the fields of inputVals the edit changes, the others
+// are kept as stored.
+func (obj *WebObject) validateEdit(c *gin.Context, inputVals map[string]any) error {
+	if len(obj.rules) == 0 {
+		return nil
+	}
+	values := map[string]any{}
+	for k, v := range inputVals {
+		if v != nil && obj.isEditable(k) {
+			values[k] = v
+		}
+	}
+	return obj.validate(c, values, true)
+}
+
+// validate returns the validator.ValidationErrors of values. The validator
+// of validator.Middleware localizes the messages by its i18n.Manager to the
+// locale of i18n.Middleware.
+func (obj *WebObject) validate(c *gin.Context, values map[string]any, partial bool) error {
+	v, locale := defaultValidator, i18n.DefaultLocale
+	if c != nil {
+		if cv, ok := c.Get("validator"); ok {
+			if cv, ok := cv.(*validator.Validator); ok {
+				v = cv
+			}
+		}
+		locale = i18n.GetLocaleFromGin(c)
+	}
+	if errs := v.ValidateMap(values, obj.rules, partial, locale); len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// failValidation writes the field errors of the validation errors, returns
+// false for the others.
+func failValidation(c *gin.Context, err error) bool {
+	errs := fieldErrors(err)
+	if errs == nil {
+		return false
+	}
+	response.Result(c, http.StatusUnprocessableEntity, http.StatusUnprocessableEntity, "validation failed", gin.H{"errors": errs})
+	return true
+}
+
+// fieldErrors returns the field errors of the validation errors, nil for the others.
+func fieldErrors(err error) validator.ValidationErrors {
+	var errs validator.ValidationErrors
+	if errors.As(err, &errs) {
+		return errs
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package LingEcho
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/code-100-precent/LingFramework/pkg/constants"
+	"github.com/code-100-precent/LingFramework/pkg/i18n"
+	"github.com/code-100-precent/LingFramework/pkg/validator"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type testProfile struct {
+	ID    uint   `json:"id" gorm:"primaryKey"`
+	Name  string `json:"name" validate:"required,maxlen:8"`
+	Email string `json:"email,omitempty" validate:"email"`
+	Age   int    `json:"age"`
+}
+
+func testProfileObject() *WebObject {
+	return &WebObject{
+		Model:        testProfile{},
+		Editables:    []string{"Name", "Email", "Age"},
+		Rules:        map[string]string{"Age": "min:18"},
+		AllowMethods: GET | CREATE | EDIT | BATCH,
+	}
+}
+
+func TestWebObjectValidate(t *testing.T) {
+	r, db := newTestObject(t, testProfileObject())
+
+	fieldErrors := func(res map[string]any) []any {
+		require.Equal(t, float64(http.StatusUnprocessableEntity), res["code"], res["msg"])
+		assert.Equal(t, "validation failed", res["msg"])
+		return res["data"].(map[string]any)["errors"].([]any)
+	}
+
+	res := doTestRequest(r, http.MethodPut, "/api/testprofile", map[string]any{"name": "toolongname", "email": "bad", "age": 16})
+	assert.Equal(t, []any{
+		map[string]any{"field": "age", "rule": "min", "param": "18", "message": "validation failed for field 'age' with rule 'min'"},
+		map[string]any{"field": "email", "rule": "email", "message": "validation failed for field 'email' with rule 'email'"},
+		map[string]any{"field": "name", "rule": "maxlen", "param": "8", "message": "validation failed for field 'name' with rule 'maxlen'"},
+	}, fieldErrors(res))
+
+	// the empty optional fields are not checked
+	res = doTestRequest(r, http.MethodPut, "/api/testprofile", map[string]any{"age": 20})
+	errs := fieldErrors(res)
+	require.Len(t, errs, 1)
+	assert.Equal(t, "required", errs[0].(map[string]any)["rule"])
+
+	res = doTestRequest(r, http.MethodPut, "/api/testprofile", map[string]any{"name": "alice", "age": 20})
+	require.Equal(t, float64(200), res["code"], res["msg"])
+
+	// the edits only check the changed fields
+	res = doTestRequest(r, http.MethodPatch, "/api/testprofile/1", map[string]any{"email": "alice@example.org"})
+	require.Equal(t, float64(200), res["code"], res["msg"])
+	res = doTestRequest(r, http.MethodPatch, "/api/testprofile/1", map[string]any{"name": ""})
+	assert.Equal(t, "name", fieldErrors(res)[0].(map[string]any)["field"])
+	w := doVersionRequest(r, http.MethodPatch, "/api/testprofile/1", map[string]any{"age": 3}, map[string]string{"Content-Type": ContentTypeMergePatch})
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	var profile testProfile
+	require.NoError(t, db.First(&profile, 1).Error)
+	assert.Equal(t, testProfile{ID: 1, Name: "alice", Email: "alice@example.org", Age: 20}, profile)
+
+	// the failed batch item carries the field errors
+	res = doTestRequest(r, http.MethodPost, "/api/testprofile/batch", map[string]any{
+		"creates": []map[string]any{{"name": "bob", "age": 30}, {"name": "carol", "age": 1}},
+	})
+	assert.Equal(t, float64(500), res["code"])
+	creates := res["data"].(map[string]any)["creates"].([]any)
+	require.Len(t, creates, 2)
+	assert.Equal(t, "age", creates[1].(map[string]any)["errors"].([]any)[0].(map[string]any)["field"])
+	var count int64
+	db.Model(&testProfile{}).Count(&count)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestWebObjectValidateLocale(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(testProfile{}))
+
+	translations := i18n.NewManager(&i18n.Config{SupportedLocales: []i18n.Locale{"en", "zh-CN"}})
+	translations.SetTranslation("en", "validation.rule.required", "Field '%s' is required")
+	translations.SetTranslation("zh-CN", "validation.rule.maxlen", "字段 '%s' 长度必须最多为 %v 个字符")
+	r := gin.New()
+	g := r.Group("/api")
+	g.Use(func(c *gin.Context) {
+		c.Set(constants.DbField, db)
+		c.Next()
+	}, i18n.Middleware(translations), validator.Middleware(validator.NewValidator(translations)))
+	require.NoError(t, testProfileObject().RegisterObject(g))
+
+	w := doVersionRequest(r, http.MethodPut, "/api/testprofile?locale=zh-CN", map[string]any{"name": "toolongname"}, nil)
+	assert.Contains(t, w.Body.String(), "字段 'name' 长度必须最多为 8 个字符")
+	w = doVersionRequest(r, http.MethodPut, "/api/testprofile", map[string]any{"name": ""}, nil)
+	assert.Contains(t, w.Body.String(), "Field 'name' is required")
+}
+
+func TestWebObjectRules(t *testing.T) {
+	obj := testProfileObject()
+	require.NoError(t, obj.Build())
+	assert.Equal(t, map[string]string{"name": "required,maxlen:8", "email": "email", "age": "min:18"}, obj.rules)
+
+	doc := GetWebObjectDocDefine("/api", *obj)
+	for _, f := range doc.Fields {
+		switch f.Name {
+		case "name":
+			assert.Equal(t, "required,maxlen:8", f.Rules)
+			assert.True(t, f.Required)
+		case "age":
+			assert.Equal(t, "min:18", f.Rules)
+			assert.False(t, f.Required)
+		}
+	}
+
+	obj = testProfileObject()
+	obj.Rules = map[string]string{"Missing": "required"}
+	assert.EqualError(t, obj.Build(), "testprofile rules of unknown field Missing")
+}
+
+type testSecretProfile struct {
+	ID    uint   `json:"id" gorm:"primaryKey"`
+	Name  string `json:"name" validate:"required"`
+	Token string `json:"-" validate:"required"`
+}
+
+func TestWebObjectValidateHiddenField(t *testing.T) {
+	obj := &WebObject{
+		Model:     testSecretProfile{},
+		Editables: []string{"Name"},
+		BeforeCreate: func(db *gorm.DB, ctx *gin.Context, vptr any) error {
+			vptr.(*testSecretProfile).Token = "generated"
+			return nil
+		},
+	}
+	r, db := newTestObject(t, obj)
+	assert.Equal(t, map[string]string{"name": "required"}, obj.rules)
+
+	res := doTestRequest(r, http.MethodPut, "/api/testsecretprofile", map[string]any{"name": "alice"})
+	require.Equal(t, float64(200), res["code"], res["msg"])
+	var profile testSecretProfile
+	require.NoError(t, db.First(&profile).Error)
+	assert.Equal(t, "generated", profile.Token)
+}
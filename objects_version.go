@@ -0,0 +1,137 @@
+package LingEcho
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/code-100-precent/LingFramework/pkg/utils/response"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrPreconditionFailed   = errors.New("version mismatch")
+	ErrPreconditionRequired = errors.New("If-Match required")
+)
+
+// buildVersionField check VersionField, it must be an integer or time field visible in JSON.
+func (obj *WebObject) buildVersionField() error {
+	if obj.VersionField == "" {
+		return nil
+	}
+	f, ok := obj.modelElem.FieldByName(obj.VersionField)
+	if !ok {
+		return fmt.Errorf("%s: invalid version field %s", obj.Name, obj.VersionField)
+	}
+	if jsonFieldName(f.Tag, f.Name) == "-" {
+		return fmt.Errorf("%s: version field %s must be visible in json", obj.Name, obj.VersionField)
+	}
+
+	ft := f.Type
+	if ft.Kind() == reflect.Ptr {
+		ft = ft.Elem()
+	}
+	switch ft.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		obj.versionIsTime = false
+	default:
+		if ft != reflect.TypeOf(time.Time{}) {
+			return fmt.Errorf("%s: version field %s must be integer or time.Time", obj.Name, obj.VersionField)
+		}
+		obj.versionIsTime = true
+	}
+	return nil
+}
+
+// versionValue return the value of VersionField, nil if not set.
+func (obj *WebObject) versionValue(val any) any {
+	f := reflect.Indirect(reflect.ValueOf(val)).FieldByName(obj.VersionField)
+	if f.Kind() == reflect.Ptr {
+		if f.IsNil() {
+			return nil
+		}
+		f = f.Elem()
+	}
+	return f.Interface()
+}
+
+// formatVersion return the text of the version, the same as it's rendered in JSON.
+func formatVersion(v any) string {
+	switch vv := v.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		return vv.UTC().Format(time.RFC3339Nano)
+	}
+	return fmt.Sprint(v)
+}
+
+// getETag return the ETag of the model, empty if VersionField is not set.
+func (obj *WebObject) getETag(val any) string {
+	if obj.VersionField == "" {
+		return ""
+	}
+	return strconv.Quote(formatVersion(obj.versionValue(val)))
+}
+
+// matchVersion check the If-Match (or If-None-Match) header against the version,
+// the header is a list of ETags, or the version as rendered in JSON.
+func (obj *WebObject) matchVersion(val any, header string) bool {
+	current := obj.versionValue(val)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" {
+			return true
+		}
+		if s, err := strconv.Unquote(tag); err == nil {
+			tag = s
+		}
+		if obj.versionIsTime {
+			t, ok := castTime(tag).(time.Time)
+			cur, isTime := current.(time.Time)
+			if ok && isTime && t.Equal(cur) {
+				return true
+			}
+			continue
+		}
+		if tag == formatVersion(current) {
+			return true
+		}
+	}
+	return false
+}
+
+// versionCondition return the compare-and-set condition and the new version of an update.
+func (obj *WebObject) versionCondition(db *gorm.DB, val any) (clause.Expression, string, any) {
+	column := db.NamingStrategy.ColumnName(obj.tableName, obj.VersionField)
+	col := clause.Column{Table: obj.dbTable(db), Name: column}
+
+	var cond clause.Expression = clause.Eq{Column: col, Value: obj.versionValue(val)}
+	if obj.versionValue(val) == nil {
+		cond = clause.Expr{SQL: "? IS NULL", Vars: []any{col}}
+	}
+	if obj.versionIsTime {
+		return cond, column, time.Now()
+	}
+	return cond, column, gorm.Expr("COALESCE(?, 0) + 1", clause.Column{Name: column})
+}
+
+// failVersion write the response of a failed edit, it returns false if err is not about the version.
+func failVersion(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, ErrPreconditionFailed):
+		response.Result(c, http.StatusPreconditionFailed, http.StatusPreconditionFailed, err.Error(), nil)
+	case errors.Is(err, ErrPreconditionRequired):
+		response.Result(c, http.StatusPreconditionRequired, http.StatusPreconditionRequired, err.Error(), nil)
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,144 @@
+package LingEcho
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type testVersionedItem struct {
+	ID      uint   `json:"id" gorm:"primaryKey"`
+	Name    string `json:"name"`
+	Version int    `json:"version"`
+}
+
+type testTimedItem struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Name      string    `json:"name"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func doVersionRequest(r http.Handler, method, path string, body any, headers map[string]string) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestWebObjectVersion(t *testing.T) {
+	r, db := newTestObject(t, &WebObject{
+		Model:        testVersionedItem{},
+		Editables:    []string{"Name", "Version"},
+		VersionField: "Version",
+		AllowMethods: GET | EDIT | QUERY | BATCH,
+	})
+	require.NoError(t, db.Create(&testVersionedItem{Name: "alice", Version: 1}).Error)
+
+	w := doVersionRequest(r, http.MethodGet, "/api/testversioneditem/1", nil, nil)
+	assert.Equal(t, `"1"`, w.Header().Get("ETag"))
+
+	w = doVersionRequest(r, http.MethodGet, "/api/testversioneditem/1", nil, map[string]string{"If-None-Match": `"1"`})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+
+	w = doVersionRequest(r, http.MethodPatch, "/api/testversioneditem/1", map[string]any{"name": "bob"}, nil)
+	assert.Equal(t, http.StatusPreconditionRequired, w.Code)
+
+	w = doVersionRequest(r, http.MethodPatch, "/api/testversioneditem/1", map[string]any{"name": "bob"}, map[string]string{"If-Match": `"1"`})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+
+	// stale version
+	w = doVersionRequest(r, http.MethodPatch, "/api/testversioneditem/1", map[string]any{"name": "carol", "version": 100}, map[string]string{"If-Match": `"1"`})
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+	var item testVersionedItem
+	require.NoError(t, db.First(&item, 1).Error)
+	assert.Equal(t, "bob", item.Name)
+	assert.Equal(t, 2, item.Version)
+
+	// query items carry the version, batch edits use it as If-Match
+	res := doTestRequest(r, http.MethodPost, "/api/testversioneditem", map[string]any{})
+	version := res["data"].(map[string]any)["items"].([]any)[0].(map[string]any)["version"]
+	assert.Equal(t, float64(2), version)
+
+	res = doTestRequest(r, http.MethodPost, "/api/testversioneditem/batch", map[string]any{
+		"edits": []map[string]any{{"id": 1, "name": "dave", "version": 1}},
+	})
+	assert.Contains(t, res["msg"], ErrPreconditionFailed.Error())
+	res = doTestRequest(r, http.MethodPost, "/api/testversioneditem/batch", map[string]any{
+		"edits": []map[string]any{{"id": 1, "name": "dave", "version": version}},
+	})
+	assert.Equal(t, float64(200), res["code"])
+	require.NoError(t, db.First(&item, 1).Error)
+	assert.Equal(t, 3, item.Version)
+}
+
+func TestWebObjectVersionTime(t *testing.T) {
+	r, db := newTestObject(t, &WebObject{
+		Model:        testTimedItem{},
+		Editables:    []string{"Name"},
+		VersionField: "UpdatedAt",
+	})
+	require.NoError(t, db.Create(&testTimedItem{Name: "alice"}).Error)
+
+	w := doVersionRequest(r, http.MethodGet, "/api/testtimeditem/1", nil, nil)
+	etag := w.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	var res map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	updatedAt := res["data"].(map[string]any)["updatedAt"].(string)
+
+	// the JSON value is accepted as well
+	w = doVersionRequest(r, http.MethodPatch, "/api/testtimeditem/1", map[string]any{"name": "bob"}, map[string]string{"If-Match": updatedAt})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
+
+	w = doVersionRequest(r, http.MethodPatch, "/api/testtimeditem/1", map[string]any{"name": "carol"}, map[string]string{"If-Match": etag})
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+	assert.Error(t, (&WebObject{Model: testTimedItem{}, VersionField: "Name"}).Build())
+	assert.Error(t, (&WebObject{Model: testTimedItem{}, VersionField: "Missing"}).Build())
+}
+
+func TestWebObjectNotModifiedAfterRender(t *testing.T) {
+	r, db := newTestObject(t, &WebObject{
+		Model:        testVersionedItem{},
+		VersionField: "Version",
+		AllowMethods: GET,
+		BeforeRender: func(db *gorm.DB, c *gin.Context, obj any) (any, error) {
+			if c.GetHeader("X-User") != "alice" {
+				return nil, errors.New("forbidden")
+			}
+			return obj, nil
+		},
+	})
+	require.NoError(t, db.Create(&testVersionedItem{Name: "alice", Version: 1}).Error)
+
+	// the version isn't confirmed to the requests BeforeRender denies
+	w := doVersionRequest(r, http.MethodGet, "/api/testversioneditem/1", nil, map[string]string{"If-None-Match": `"1"`})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("ETag"))
+	assert.Contains(t, w.Body.String(), "forbidden")
+
+	w = doVersionRequest(r, http.MethodGet, "/api/testversioneditem/1", nil, map[string]string{"If-None-Match": `"1"`, "X-User": "alice"})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Equal(t, `"1"`, w.Header().Get("ETag"))
+}
@@ -3,8 +3,10 @@ package auth
 import (
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
 
@@ -135,3 +137,24 @@ func (m *JWTManager) ExtractClaims(tokenString string) (*JWTClaims, error) {
 
 	return nil, errors.New("invalid token format")
 }
+
+// TenantFromClaim returns a tenant resolver for LingEcho.TenantMiddleware, reading the
+// claim from the Extra of the bearer token. Requests without a token carry no tenant,
+// an invalid token is an error.
+func (m *JWTManager) TenantFromClaim(claim string) func(c *gin.Context) (string, error) {
+	return func(c *gin.Context) (string, error) {
+		token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if token == "" {
+			return "", nil
+		}
+		claims, err := m.ValidateToken(token)
+		if err != nil {
+			return "", errors.New("invalid token")
+		}
+		v, ok := claims.Extra[claim]
+		if !ok || v == nil {
+			return "", nil
+		}
+		return fmt.Sprint(v), nil
+	}
+}
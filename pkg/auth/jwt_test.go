@@ -1,9 +1,12 @@
 package auth
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
+	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -96,3 +99,37 @@ func TestJWTManager_DifferentSecret(t *testing.T) {
 	_, err = manager2.ValidateToken(token)
 	assert.Error(t, err)
 }
+
+func TestJWTManager_TenantFromClaim(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	manager := NewJWTManager(DefaultJWTConfig("test-secret-key"))
+	resolve := manager.TenantFromClaim("tenant")
+
+	resolveToken := func(token string) (string, error) {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+		if token != "" {
+			c.Request.Header.Set("Authorization", "Bearer "+token)
+		}
+		return resolve(c)
+	}
+
+	tenant, err := resolveToken("")
+	assert.NoError(t, err)
+	assert.Empty(t, tenant)
+
+	token, err := manager.GenerateAccessToken(1, "testuser", nil, map[string]interface{}{"tenant": "acme"})
+	assert.NoError(t, err)
+	tenant, err = resolveToken(token)
+	assert.NoError(t, err)
+	assert.Equal(t, "acme", tenant)
+
+	token, err = manager.GenerateAccessToken(1, "testuser", nil, nil)
+	assert.NoError(t, err)
+	tenant, err = resolveToken(token)
+	assert.NoError(t, err)
+	assert.Empty(t, tenant)
+
+	_, err = resolveToken("invalid-token")
+	assert.Error(t, err)
+}
@@ -13,6 +13,9 @@ type Cache interface {
 	// Set 设置缓存值
 	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error
 
+	// SetNX 键不存在时设置缓存值，返回是否设置
+	SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error)
+
 	// Delete 删除缓存
 	Delete(ctx context.Context, key string) error
 
@@ -44,6 +47,18 @@ type Cache interface {
 	Close() error
 }
 
+// InvalidateChannel 分层缓存广播本地缓存失效的频道
+const InvalidateChannel = "lingecho:cache:invalidate"
+
+// PubSub 由多节点共享的缓存实现（如 Redis），用于跨节点广播消息
+type PubSub interface {
+	// Publish 发布消息
+	Publish(ctx context.Context, channel, message string) error
+
+	// Subscribe 订阅频道，handler 在后台调用，直到 ctx 结束
+	Subscribe(ctx context.Context, channel string, handler func(message string)) error
+}
+
 // Config 缓存配置
 type Config struct {
 	// 缓存类型: "local" 或 "redis"
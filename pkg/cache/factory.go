@@ -2,6 +2,7 @@ package cache
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
@@ -72,11 +73,40 @@ func NewLayeredCache(config Config, options *Options) (Cache, error) {
 		return nil, fmt.Errorf("unsupported distributed cache type: %s", config.Type)
 	}
 
-	return &layeredCache{
-		local:       localCache,
-		distributed: distributedCache,
+	lc, err := newLayeredCache(localCache, distributedCache, options)
+	if err != nil {
+		distributedCache.Close()
+		return nil, err
+	}
+	return lc, nil
+}
+
+// NewLayeredCacheWith 组合给定的本地缓存和分布式缓存，如自定义的分布式缓存
+func NewLayeredCacheWith(local, distributed Cache, options *Options) (Cache, error) {
+	if options == nil {
+		options = DefaultOptions()
+	}
+	return newLayeredCache(local, distributed, options)
+}
+
+// newLayeredCache 组合本地缓存和分布式缓存，分布式缓存支持 PubSub 时，
+// 删除、清空和自增自减会广播到其他节点，使其本地缓存同时失效
+func newLayeredCache(local, distributed Cache, options *Options) (*layeredCache, error) {
+	lc := &layeredCache{
+		local:       local,
+		distributed: distributed,
 		options:     options,
-	}, nil
+	}
+	if ps, ok := distributed.(PubSub); ok {
+		ctx, cancel := context.WithCancel(context.Background())
+		if err := ps.Subscribe(ctx, InvalidateChannel, lc.handleInvalidate); err != nil {
+			cancel()
+			return nil, err
+		}
+		lc.pubsub = ps
+		lc.cancel = cancel
+	}
+	return lc, nil
 }
 
 // layeredCache 分层缓存实现
@@ -84,6 +114,40 @@ type layeredCache struct {
 	local       Cache
 	distributed Cache
 	options     *Options
+	pubsub      PubSub
+	cancel      context.CancelFunc
+}
+
+// invalidation 广播的本地缓存失效消息
+type invalidation struct {
+	Keys  []string `json:"keys,omitempty"`
+	Clear bool     `json:"clear,omitempty"`
+}
+
+// publishInvalidate 通知其他节点删除本地缓存
+func (lc *layeredCache) publishInvalidate(ctx context.Context, msg invalidation) error {
+	if lc.pubsub == nil {
+		return nil
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return lc.pubsub.Publish(ctx, InvalidateChannel, string(data))
+}
+
+// handleInvalidate 删除其他节点通知失效的本地缓存
+func (lc *layeredCache) handleInvalidate(message string) {
+	var msg invalidation
+	if err := json.Unmarshal([]byte(message), &msg); err != nil {
+		return
+	}
+	ctx := context.Background()
+	if msg.Clear {
+		lc.local.Clear(ctx)
+		return
+	}
+	lc.local.DeleteMulti(ctx, msg.Keys...)
 }
 
 // Get 从本地缓存获取，如果没有则从分布式缓存获取并回填本地缓存
@@ -114,6 +178,17 @@ func (lc *layeredCache) Set(ctx context.Context, key string, value interface{},
 	return lc.local.Set(ctx, key, value, lc.options.LocalExpiration)
 }
 
+// SetNX 由分布式缓存判断键是否存在
+func (lc *layeredCache) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	ok, err := lc.distributed.SetNX(ctx, key, value, expiration)
+	if err != nil || !ok {
+		return ok, err
+	}
+
+	// 设置到本地缓存
+	return true, lc.local.Set(ctx, key, value, lc.options.LocalExpiration)
+}
+
 // Delete 从两个缓存层删除
 func (lc *layeredCache) Delete(ctx context.Context, key string) error {
 	// 删除本地缓存
@@ -122,7 +197,10 @@ func (lc *layeredCache) Delete(ctx context.Context, key string) error {
 	}
 
 	// 删除分布式缓存
-	return lc.distributed.Delete(ctx, key)
+	if err := lc.distributed.Delete(ctx, key); err != nil {
+		return err
+	}
+	return lc.publishInvalidate(ctx, invalidation{Keys: []string{key}})
 }
 
 // Exists 检查键是否存在
@@ -138,7 +216,10 @@ func (lc *layeredCache) Clear(ctx context.Context) error {
 	}
 
 	// 清空分布式缓存
-	return lc.distributed.Clear(ctx)
+	if err := lc.distributed.Clear(ctx); err != nil {
+		return err
+	}
+	return lc.publishInvalidate(ctx, invalidation{Clear: true})
 }
 
 // GetMulti 批量获取
@@ -191,7 +272,10 @@ func (lc *layeredCache) DeleteMulti(ctx context.Context, keys ...string) error {
 	}
 
 	// 删除分布式缓存
-	return lc.distributed.DeleteMulti(ctx, keys...)
+	if err := lc.distributed.DeleteMulti(ctx, keys...); err != nil {
+		return err
+	}
+	return lc.publishInvalidate(ctx, invalidation{Keys: keys})
 }
 
 // Increment 自增
@@ -202,9 +286,11 @@ func (lc *layeredCache) Increment(ctx context.Context, key string, value int64)
 		return 0, err
 	}
 
-	// 更新本地缓存
-	lc.local.Set(ctx, key, result, lc.options.LocalExpiration)
-	return result, nil
+	// 本地缓存的旧值失效，其他节点也从分布式缓存重新读取
+	if err := lc.local.Delete(ctx, key); err != nil {
+		return 0, err
+	}
+	return result, lc.publishInvalidate(ctx, invalidation{Keys: []string{key}})
 }
 
 // Decrement 自减
@@ -215,9 +301,11 @@ func (lc *layeredCache) Decrement(ctx context.Context, key string, value int64)
 		return 0, err
 	}
 
-	// 更新本地缓存
-	lc.local.Set(ctx, key, result, lc.options.LocalExpiration)
-	return result, nil
+	// 本地缓存的旧值失效，其他节点也从分布式缓存重新读取
+	if err := lc.local.Delete(ctx, key); err != nil {
+		return 0, err
+	}
+	return result, lc.publishInvalidate(ctx, invalidation{Keys: []string{key}})
 }
 
 // GetWithTTL 获取值和TTL
@@ -239,6 +327,9 @@ func (lc *layeredCache) GetWithTTL(ctx context.Context, key string) (interface{}
 
 // Close 关闭缓存连接
 func (lc *layeredCache) Close() error {
+	if lc.cancel != nil {
+		lc.cancel()
+	}
 	// 关闭本地缓存
 	if err := lc.local.Close(); err != nil {
 		return err
@@ -2,6 +2,7 @@ package cache
 
 import (
 	"context"
+	"sync"
 	"testing"
 	"time"
 
@@ -230,7 +231,7 @@ func TestLayeredCache_IncDec_Clear_Exists_Close(t *testing.T) {
 	ctx := context.Background()
 	key := "layered:cnt"
 
-	// Increment on distributed, write-through to local
+	// Increment on distributed, the local value invalidated
 	nv, err := lc.Increment(ctx, key, 5)
 	assert.NoError(t, err)
 	assert.EqualValues(t, 5, nv)
@@ -257,3 +258,72 @@ func TestLayeredCache_IncDec_Clear_Exists_Close(t *testing.T) {
 
 	_ = lc.Close()
 }
+
+// memPubSub is a distributed cache shared by the nodes, with in-process pub/sub
+type memPubSub struct {
+	Cache
+	mu       sync.Mutex
+	handlers []func(string)
+}
+
+func (m *memPubSub) Publish(ctx context.Context, channel, message string) error {
+	m.mu.Lock()
+	handlers := append([]func(string){}, m.handlers...)
+	m.mu.Unlock()
+	for _, h := range handlers {
+		h(message)
+	}
+	return nil
+}
+
+func (m *memPubSub) Subscribe(ctx context.Context, channel string, handler func(message string)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.handlers = append(m.handlers, handler)
+	return nil
+}
+
+func TestLayeredCache_InvalidateAcrossNodes(t *testing.T) {
+	ctx := context.Background()
+	shared := &memPubSub{Cache: NewLocalCache(mustLocalConfig())}
+	opts := &Options{LocalExpiration: time.Minute}
+
+	node1, err := newLayeredCache(NewLocalCache(mustLocalConfig()), shared, opts)
+	assert.NoError(t, err)
+	node2, err := newLayeredCache(NewLocalCache(mustLocalConfig()), shared, opts)
+	assert.NoError(t, err)
+
+	assert.NoError(t, node1.Set(ctx, "k1", "v1", time.Minute))
+	assert.NoError(t, node1.Set(ctx, "k2", "v2", time.Minute))
+	// backfill the local cache of node2
+	v, ok := node2.Get(ctx, "k1")
+	assert.True(t, ok)
+	assert.Equal(t, "v1", v)
+	node2.Get(ctx, "k2")
+
+	assert.NoError(t, node1.Delete(ctx, "k1"))
+	_, ok = node2.local.Get(ctx, "k1")
+	assert.False(t, ok, "local cache of node2 must be invalidated")
+	_, ok = node2.Get(ctx, "k1")
+	assert.False(t, ok)
+
+	// the counters read by node2 follow the increments of node1
+	_, err = node1.Increment(ctx, "n", 1)
+	assert.NoError(t, err)
+	v, _ = node2.Get(ctx, "n")
+	assert.EqualValues(t, 1, v)
+	_, err = node1.Increment(ctx, "n", 1)
+	assert.NoError(t, err)
+	v, _ = node2.Get(ctx, "n")
+	assert.EqualValues(t, 2, v)
+	_, err = node1.Decrement(ctx, "n", 2)
+	assert.NoError(t, err)
+	v, _ = node2.Get(ctx, "n")
+	assert.EqualValues(t, 0, v)
+
+	assert.NoError(t, node1.Clear(ctx))
+	_, ok = node2.local.Get(ctx, "k2")
+	assert.False(t, ok)
+
+	assert.NoError(t, node1.Close())
+}
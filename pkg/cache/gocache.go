@@ -42,6 +42,12 @@ func (gc *goCacheWrapper) Set(ctx context.Context, key string, value interface{}
 	return nil
 }
 
+// SetNX 键不存在时设置缓存值
+func (gc *goCacheWrapper) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	// go-cache的Add在键存在时返回错误
+	return gc.cache.Add(key, value, expiration) == nil, nil
+}
+
 // Delete 删除缓存
 func (gc *goCacheWrapper) Delete(ctx context.Context, key string) error {
 	gc.cache.Delete(key)
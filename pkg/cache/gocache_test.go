@@ -105,6 +105,22 @@ func TestGoCacheIncrementDecrement(t *testing.T) {
 	assert.Equal(t, int64(12), nv)
 }
 
+func TestGoCacheSetNX(t *testing.T) {
+	c := newTestGoCache()
+	ctx := context.Background()
+
+	ok, err := c.SetNX(ctx, "lock", "a", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = c.SetNX(ctx, "lock", "b", time.Minute)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	val, _ := c.Get(ctx, "lock")
+	assert.Equal(t, "a", val)
+}
+
 func TestGoCacheGetMulti(t *testing.T) {
 	c := newTestGoCache()
 	ctx := context.Background()
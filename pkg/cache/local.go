@@ -86,6 +86,27 @@ func (lc *localCache) Set(ctx context.Context, key string, value interface{}, ex
 	return nil
 }
 
+// SetNX 键不存在时设置缓存值
+func (lc *localCache) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	if item, exists := lc.cache.get(key); exists && (item.expiration.IsZero() || time.Now().Before(item.expiration)) {
+		return false, nil
+	}
+
+	var exp time.Time
+	if expiration > 0 {
+		exp = time.Now().Add(expiration)
+	}
+	lc.cache.set(key, &cacheItem{
+		value:      value,
+		expiration: exp,
+		lastAccess: time.Now(),
+	})
+	return true, nil
+}
+
 // Delete 删除缓存
 func (lc *localCache) Delete(ctx context.Context, key string) error {
 	lc.mu.Lock()
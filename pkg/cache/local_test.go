@@ -58,6 +58,28 @@ func TestExpirationOnGetAndExists(t *testing.T) {
 	assert.False(t, c.Exists(ctx, "exp"))
 }
 
+func TestSetNX(t *testing.T) {
+	c := newTestCache(10, time.Minute, time.Hour)
+	ctx := context.Background()
+
+	ok, err := c.SetNX(ctx, "lock", 1, 50*time.Millisecond)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	// 已存在时不覆盖
+	ok, err = c.SetNX(ctx, "lock", 2, time.Minute)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	v, _ := c.Get(ctx, "lock")
+	assert.Equal(t, 1, v)
+
+	// 过期后可再次设置
+	time.Sleep(70 * time.Millisecond)
+	ok, err = c.SetNX(ctx, "lock", 3, time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
+
 func TestGetWithTTL(t *testing.T) {
 	c := newTestCache(10, time.Minute, time.Hour)
 	ctx := context.Background()
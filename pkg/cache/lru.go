@@ -94,6 +94,28 @@ func (lc *lruCacheImpl) Set(ctx context.Context, key string, value interface{},
 	return nil
 }
 
+// SetNX 键不存在时设置缓存值
+func (lc *lruCacheImpl) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	if item, ok := lc.cache.Get(key); ok && (item.expiration.IsZero() || time.Now().Before(item.expiration)) {
+		return false, nil
+	}
+
+	var exp time.Time
+	if expiration > 0 {
+		exp = time.Now().Add(expiration)
+	} else if lc.config.DefaultExpiration > 0 {
+		exp = time.Now().Add(lc.config.DefaultExpiration)
+	}
+	lc.cache.Add(key, &lruCacheItem{
+		value:      value,
+		expiration: exp,
+	})
+	return true, nil
+}
+
 // Delete 删除缓存
 func (lc *lruCacheImpl) Delete(ctx context.Context, key string) error {
 	lc.mu.Lock()
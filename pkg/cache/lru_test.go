@@ -240,6 +240,35 @@ func TestLRUCache_Increment(t *testing.T) {
 	assert.Equal(t, int64(15), counterVal)
 }
 
+func TestLRUCache_SetNX(t *testing.T) {
+	config := LRUCacheConfig{
+		MaxSize:           100,
+		DefaultExpiration: 5 * time.Minute,
+		CleanupInterval:   10 * time.Minute,
+	}
+	cache := NewLRUCache(config)
+	defer cache.Close()
+	ctx := context.Background()
+
+	// Set non-existing key
+	ok, err := cache.SetNX(ctx, "lock", "a", 50*time.Millisecond)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	// Existing key is kept
+	ok, err = cache.SetNX(ctx, "lock", "b", 0)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	val, _ := cache.Get(ctx, "lock")
+	assert.Equal(t, "a", val)
+
+	// Expired key is replaced
+	time.Sleep(70 * time.Millisecond)
+	ok, err = cache.SetNX(ctx, "lock", "c", 0)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
+
 func TestLRUCache_Decrement(t *testing.T) {
 	config := LRUCacheConfig{
 		MaxSize:           100,
@@ -71,6 +71,16 @@ func (rc *redisCache) Set(ctx context.Context, key string, value interface{}, ex
 	return rc.client.Set(ctx, key, data, expiration).Err()
 }
 
+// SetNX 键不存在时设置缓存值
+func (rc *redisCache) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal value: %w", err)
+	}
+
+	return rc.client.SetNX(ctx, key, data, expiration).Result()
+}
+
 // Delete 删除缓存
 func (rc *redisCache) Delete(ctx context.Context, key string) error {
 	return rc.client.Del(ctx, key).Err()
@@ -184,3 +194,33 @@ func (rc *redisCache) GetWithTTL(ctx context.Context, key string) (interface{},
 func (rc *redisCache) Close() error {
 	return rc.client.Close()
 }
+
+// Publish 发布消息
+func (rc *redisCache) Publish(ctx context.Context, channel, message string) error {
+	return rc.client.Publish(ctx, channel, message).Err()
+}
+
+// Subscribe 订阅频道，handler 在后台调用，直到 ctx 结束
+func (rc *redisCache) Subscribe(ctx context.Context, channel string, handler func(message string)) error {
+	ps := rc.client.Subscribe(ctx, channel)
+	if _, err := ps.Receive(ctx); err != nil {
+		ps.Close()
+		return fmt.Errorf("failed to subscribe %s: %w", channel, err)
+	}
+	go func() {
+		defer ps.Close()
+		ch := ps.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+				handler(msg.Payload)
+			}
+		}
+	}()
+	return nil
+}
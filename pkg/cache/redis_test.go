@@ -174,6 +174,26 @@ func TestRedisIncrementDecrement(t *testing.T) {
 	assert.Equal(t, int64(12), nv)
 }
 
+func TestRedisSetNX(t *testing.T) {
+	c := newTestRedisCache(t)
+	skipIfRedisNotAvailable(t, c)
+	ctx := context.Background()
+	c.Delete(ctx, "lock")
+
+	ok, err := c.SetNX(ctx, "lock", "a", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = c.SetNX(ctx, "lock", "b", time.Minute)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	// the lock expires
+	_, ttl, _ := c.GetWithTTL(ctx, "lock")
+	assert.True(t, ttl > 0 && ttl <= time.Minute)
+	c.Delete(ctx, "lock")
+}
+
 func TestRedisBatchOps(t *testing.T) {
 	c := newTestRedisCache(t)
 	skipIfRedisNotAvailable(t, c)
@@ -27,6 +27,7 @@ type Config struct {
 	APIPrefix        string `env:"API_PREFIX"`
 	AdminPrefix      string `env:"ADMIN_PREFIX"`
 	AuthPrefix       string `env:"AUTH_PREFIX"`
+	GraphQLPrefix    string `env:"GRAPHQL_PREFIX"` // under APIPrefix, disabled if empty
 	SessionSecret    string `env:"SESSION_SECRET"`
 	SecretExpireDays string `env:"SESSION_EXPIRE_DAYS"`
 	LLMApiKey        string `env:"LLM_API_KEY"`
@@ -45,6 +46,9 @@ type Config struct {
 	SSLEnabled       bool   `env:"SSL_ENABLED"`
 	SSLCertFile      string `env:"SSL_CERT_FILE"`
 	SSLKeyFile       string `env:"SSL_KEY_FILE"`
+	TenantHeader     string `env:"TENANT_HEADER"` // such as X-Tenant-ID, checked against TenantClaim
+	TenantDomain     string `env:"TENANT_DOMAIN"` // the tenant is the subdomain of it, checked against TenantClaim
+	TenantClaim      string `env:"TENANT_CLAIM"`  // extra claim of the JWT bearer token
 }
 
 // GlobalConfig is the global configuration instance
@@ -76,6 +80,7 @@ func Load() error {
 		APIPrefix:        getStringOrDefault("API_PREFIX", "/api"),
 		AdminPrefix:      getStringOrDefault("ADMIN_PREFIX", "/admin"),
 		AuthPrefix:       getStringOrDefault("AUTH_PREFIX", "/auth"),
+		GraphQLPrefix:    getStringOrDefault("GRAPHQL_PREFIX", ""),
 		SecretExpireDays: getStringOrDefault("SESSION_EXPIRE_DAYS", "7"),
 		SessionSecret:    getStringOrDefault("SESSION_SECRET", generateDefaultSessionSecret()),
 		Log: logger.LogConfig{
@@ -102,6 +107,9 @@ func Load() error {
 		SSLEnabled:      getBoolOrDefault("SSL_ENABLED", false),
 		SSLCertFile:     getStringOrDefault("SSL_CERT_FILE", ""),
 		SSLKeyFile:      getStringOrDefault("SSL_KEY_FILE", ""),
+		TenantHeader:    getStringOrDefault("TENANT_HEADER", ""),
+		TenantDomain:    getStringOrDefault("TENANT_DOMAIN", ""),
+		TenantClaim:     getStringOrDefault("TENANT_CLAIM", ""),
 	}
 	return nil
 }
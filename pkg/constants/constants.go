@@ -20,6 +20,9 @@ const DbField = "_lingecho_db"
 const UserField = "_lingecho_uid"
 const GroupField = "_lingecho_gid"
 const TzField = "_lingecho_tz"
+const TenantField = "_lingecho_tenant"
+const ChangesField = "_lingecho_changes"
+const SearchField = "_lingecho_search"
 const AssetsField = "_lingecho_assets"
 const TemplatesField = "_lingecho_templates"
 
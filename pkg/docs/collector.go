@@ -0,0 +1,188 @@
+package docs
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RouteInfo is a route registered on the gin engine
+type RouteInfo struct {
+	Method  string
+	Path    string
+	Handler string // full name of the handler function
+}
+
+// RouteCollector collects the routes of a gin engine, skipping the ignored ones
+type RouteCollector struct {
+	ignoreMethods  []string
+	ignorePrefixes []string
+	ignoreHandlers []string
+}
+
+// NewRouteCollector creates a collector which skips HEAD/OPTIONS routes, static files
+// and the WebObject routes (they are documented by WebObjectDoc)
+func NewRouteCollector() *RouteCollector {
+	return &RouteCollector{
+		ignoreMethods: []string{http.MethodHead, http.MethodOptions},
+		ignoreHandlers: []string{
+			".(*WebObject).",
+			".(*RouterGroup).createStaticHandler",
+		},
+	}
+}
+
+// IgnorePrefix skips the routes with the path prefixes
+func (rc *RouteCollector) IgnorePrefix(prefixes ...string) *RouteCollector {
+	rc.ignorePrefixes = append(rc.ignorePrefixes, prefixes...)
+	return rc
+}
+
+// IgnoreHandler skips the routes whose handler name contains any of names
+func (rc *RouteCollector) IgnoreHandler(names ...string) *RouteCollector {
+	rc.ignoreHandlers = append(rc.ignoreHandlers, names...)
+	return rc
+}
+
+// Collect returns the routes of r sorted by path and method
+func (rc *RouteCollector) Collect(r *gin.Engine) []RouteInfo {
+	var routes []RouteInfo
+	for _, v := range r.Routes() {
+		if rc.ignored(v) {
+			continue
+		}
+		routes = append(routes, RouteInfo{Method: v.Method, Path: v.Path, Handler: v.Handler})
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
+
+func (rc *RouteCollector) ignored(v gin.RouteInfo) bool {
+	for _, m := range rc.ignoreMethods {
+		if v.Method == m {
+			return true
+		}
+	}
+	for _, p := range rc.ignorePrefixes {
+		if strings.HasPrefix(v.Path, p) {
+			return true
+		}
+	}
+	for _, h := range rc.ignoreHandlers {
+		if strings.Contains(v.Handler, h) {
+			return true
+		}
+	}
+	return false
+}
+
+// AutoDocGenerator builds UriDoc from the collected routes
+type AutoDocGenerator struct {
+	collector *RouteCollector
+}
+
+func NewAutoDocGenerator(collector *RouteCollector) *AutoDocGenerator {
+	if collector == nil {
+		collector = NewRouteCollector()
+	}
+	return &AutoDocGenerator{collector: collector}
+}
+
+// GenerateUriDocs returns a UriDoc of every route, the summary is made from the handler name,
+// such as handleUserSignupPage => "User signup page"
+func (g *AutoDocGenerator) GenerateUriDocs(r *gin.Engine) []UriDoc {
+	var result []UriDoc
+	for _, route := range g.collector.Collect(r) {
+		summary := handlerSummary(route.Handler)
+		if summary == "" {
+			summary = route.Method + " " + route.Path
+		}
+		result = append(result, UriDoc{
+			Group:   routeGroup(route.Path),
+			Path:    route.Path,
+			Method:  route.Method,
+			Summary: summary,
+			Desc:    summary,
+		})
+	}
+	return result
+}
+
+var versionSegment = regexp.MustCompile(`^v[0-9]+$`)
+
+// routeGroup return the first static segment of path after "api" and the version
+func routeGroup(path string) string {
+	for _, seg := range strings.Split(path, "/") {
+		if seg == "" || seg == "api" || versionSegment.MatchString(seg) {
+			continue
+		}
+		if strings.HasPrefix(seg, ":") || strings.HasPrefix(seg, "*") {
+			break
+		}
+		return seg
+	}
+	return "default"
+}
+
+// handlerSummary convert the handler name to words,
+// such as "pkg.(*Handlers).handleUserSignupPage-fm" => "User signup page"
+func handlerSummary(handler string) string {
+	name := handler[strings.LastIndex(handler, "/")+1:]
+	name = name[strings.LastIndex(name, ".")+1:]
+	name = strings.TrimSuffix(name, "-fm")
+	if name == "" || strings.HasPrefix(name, "func") {
+		// anonymous function
+		return ""
+	}
+	for _, p := range []string{"handle", "Handle"} {
+		if strings.HasPrefix(name, p) && len(name) > len(p) {
+			name = name[len(p):]
+			break
+		}
+	}
+
+	words := splitWords(name)
+	for i, w := range words {
+		if i == 0 {
+			words[i] = strings.ToUpper(w[:1]) + w[1:]
+		} else if strings.ToUpper(w) != w {
+			words[i] = strings.ToLower(w)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// splitWords splits the identifier by the underscores and the case changes,
+// such as "getAPIKeys_v2" => ["get", "API", "Keys", "v2"]
+func splitWords(name string) []string {
+	var words []string
+	var word []rune
+	runes := []rune(name)
+	for i, c := range runes {
+		newWord := unicode.IsUpper(c) && i > 0 &&
+			(unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1])))
+		if c == '_' || newWord {
+			if len(word) > 0 {
+				words = append(words, string(word))
+			}
+			word = nil
+			if c == '_' {
+				continue
+			}
+		}
+		word = append(word, c)
+	}
+	if len(word) > 0 {
+		words = append(words, string(word))
+	}
+	return words
+}
@@ -0,0 +1,46 @@
+package docs
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+type testHandlers struct{}
+
+func (h *testHandlers) handleUserSignupPage(c *gin.Context) {}
+func (h *testHandlers) GetAPIKeys(c *gin.Context)           {}
+
+func TestRouteCollector_Collect(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := &testHandlers{}
+	r := gin.New()
+	r.GET("/api/auth/register", h.handleUserSignupPage)
+	r.POST("/api/v1/keys/:id", h.GetAPIKeys)
+	r.HEAD("/api/auth/register", h.handleUserSignupPage)
+	r.GET("/internal/ping", func(c *gin.Context) {})
+	r.Static("/static", ".")
+
+	routes := NewRouteCollector().IgnorePrefix("/internal").Collect(r)
+	assert.Len(t, routes, 2)
+	assert.Equal(t, "/api/auth/register", routes[0].Path)
+	assert.Equal(t, http.MethodPost, routes[1].Method)
+
+	docs := NewAutoDocGenerator(nil).GenerateUriDocs(r)
+	assert.Len(t, docs, 3)
+	assert.Equal(t, "auth", docs[0].Group)
+	assert.Equal(t, "User signup page", docs[0].Summary)
+	assert.Equal(t, "keys", docs[1].Group)
+	assert.Equal(t, "Get API keys", docs[1].Summary)
+	assert.Equal(t, "internal", docs[2].Group)
+	assert.Equal(t, "GET /internal/ping", docs[2].Summary)
+}
+
+func TestHandlerSummary(t *testing.T) {
+	assert.Equal(t, "User signup page", handlerSummary("github.com/x/handlers.(*Handlers).handleUserSignupPage-fm"))
+	assert.Equal(t, "List users", handlerSummary("main.ListUsers"))
+	assert.Equal(t, "Get by ID", handlerSummary("main.get_by_ID"))
+	assert.Equal(t, "", handlerSummary("main.main.func1"))
+}
@@ -0,0 +1,81 @@
+package docs
+
+// Field types of DocField, the same as the root package
+const (
+	TypeDate    = "date"
+	TypeString  = "string"
+	TypeInt     = "int"
+	TypeFloat   = "float"
+	TypeBoolean = "boolean"
+	TypeObject  = "object"
+	TypeMap     = "map"
+)
+
+// DocField describes a field of a request or response body
+type DocField struct {
+	FieldName string     `json:"-"`
+	Name      string     `json:"name"`
+	Desc      string     `json:"desc,omitempty"`
+	Type      string     `json:"type,omitempty"`
+	Default   any        `json:"default,omitempty"`
+	Required  bool       `json:"required,omitempty"`
+	CanNull   bool       `json:"canNull,omitempty"`
+	IsArray   bool       `json:"isArray,omitempty"`
+	IsPrimary bool       `json:"isPrimary,omitempty"`
+	Rules     string     `json:"rules,omitempty"` // validation rules, such as "required,maxlen:64"
+	Fields    []DocField `json:"fields,omitempty"`
+}
+
+// UriDoc describes a single route
+type UriDoc struct {
+	Group        string    `json:"group"`
+	Path         string    `json:"path"`
+	Summary      string    `json:"summary"`
+	Desc         string    `json:"desc,omitempty"`
+	AuthRequired bool      `json:"authRequired,omitempty"`
+	Method       string    `json:"method"`
+	Request      *DocField `json:"request"`
+	Response     *DocField `json:"response"`
+}
+
+// WebObjectDoc describes the routes of a WebObject.
+// Filters, Orders, Searches, Groups and Aggregates are the struct field names,
+// Editables and Version are the json names.
+type WebObjectDoc struct {
+	Group        string     `json:"group"`
+	Path         string     `json:"path"`
+	Desc         string     `json:"desc,omitempty"`
+	AuthRequired bool       `json:"authRequired,omitempty"`
+	AllowMethods []string   `json:"allowMethods,omitempty"`
+	Fields       []DocField `json:"fields,omitempty"`
+	Filters      []string   `json:"filters,omitempty"`
+	Orders       []string   `json:"orders,omitempty"`
+	Searches     []string   `json:"searches,omitempty"`
+	Groups       []string   `json:"groups,omitempty"`
+	Aggregates   []string   `json:"aggregates,omitempty"`
+	Version      string     `json:"version,omitempty"`
+	CacheTTL     int        `json:"cacheTtl,omitempty"`   // seconds
+	Idempotent   bool       `json:"idempotent,omitempty"` // CREATE and BATCH honour the Idempotency-Key header
+	Editables    []string   `json:"editables,omitempty"`
+	Expands      []DocField `json:"expands,omitempty"`
+	Views        []UriDoc   `json:"views,omitempty"`
+}
+
+// jsonName return the json name of the struct field name, or name itself if not found
+func (doc *WebObjectDoc) jsonName(name string) string {
+	for _, f := range doc.Fields {
+		if f.FieldName == name {
+			return f.Name
+		}
+	}
+	return name
+}
+
+// jsonNames convert the struct field names to json names
+func (doc *WebObjectDoc) jsonNames(names []string) []string {
+	var result []string
+	for _, v := range names {
+		result = append(result, doc.jsonName(v))
+	}
+	return result
+}
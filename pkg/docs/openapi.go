@@ -0,0 +1,923 @@
+package docs
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+const OpenAPIVersion = "3.1.0"
+
+// Security scheme names of the spec
+const (
+	SecuritySession = "sessionAuth"
+	SecurityBearer  = "bearerAuth"
+)
+
+var (
+	filterOps = []any{"=", "<>", "is not", "in", "not_in", ">", ">=", "<", "<=", "like", "not_like",
+		"starts_with", "ends_with", "between", "is_null", "not_null", "and", "or", "not"}
+	orderOps       = []any{"asc", "desc"}
+	aggregateOps   = []any{"count", "sum", "avg", "min", "max"}
+	bucketNames    = []any{"hour", "day", "week", "month", "year"}
+	exportFormats  = []any{"csv", "xlsx", "ndjson"}
+	exportContents = []string{"text/csv", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", "application/x-ndjson"}
+)
+
+// Spec is an OpenAPI 3.1 document
+type Spec struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Servers    []Server            `json:"servers,omitempty"`
+	Tags       []Tag               `json:"tags,omitempty"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type Server struct {
+	URL         string `json:"url"`
+	Description string `json:"description,omitempty"`
+}
+
+type Tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem maps the lower case http method to the operation
+type PathItem map[string]*Operation
+
+type Operation struct {
+	Tags        []string              `json:"tags,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	OperationID string                `json:"operationId"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+	CacheTTL    int                   `json:"x-cache-ttl,omitempty"` // seconds the response is cached
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"` // path, query, header
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Description string               `json:"description,omitempty"`
+	Required    bool                 `json:"required,omitempty"`
+	Content     map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Headers     map[string]Header    `json:"headers,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas,omitempty"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Description  string `json:"description,omitempty"`
+	Name         string `json:"name,omitempty"`
+	In           string `json:"in,omitempty"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+// Schema is a JSON Schema (draft 2020-12), Type is a string or a list of strings
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 any                `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Default              any                `json:"default,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	AdditionalProperties any                `json:"additionalProperties,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+}
+
+// ToJSON returns the indented JSON of the spec
+func (s *Spec) ToJSON() ([]byte, error) {
+	return json.MarshalIndent(s, "", "  ")
+}
+
+// ToYAML returns the YAML of the spec, the keys are sorted as in JSON
+func (s *Spec) ToYAML() ([]byte, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(v)
+}
+
+// OpenAPIGenerator converts UriDoc and WebObjectDoc to an OpenAPI document
+type OpenAPIGenerator struct {
+	BaseURL       string
+	Version       string
+	Title         string
+	SessionCookie string // name of the session cookie, "lingecho" by default
+
+	spec             *Spec
+	schemaNames      map[string]bool
+	operations       map[string]bool
+	jsonPatch        *Schema
+	validationErrors *Schema
+}
+
+func NewOpenAPIGenerator(baseURL, version, title string) *OpenAPIGenerator {
+	return &OpenAPIGenerator{
+		BaseURL:       baseURL,
+		Version:       version,
+		Title:         title,
+		SessionCookie: "lingecho",
+	}
+}
+
+// Generate builds the spec, the output is the same for the same input
+func (g *OpenAPIGenerator) Generate(uriDocs []UriDoc, objDocs []WebObjectDoc) *Spec {
+	g.spec = &Spec{
+		OpenAPI: OpenAPIVersion,
+		Info:    Info{Title: g.Title, Version: g.Version},
+		Paths:   map[string]PathItem{},
+		Components: Components{
+			Schemas: map[string]*Schema{},
+			SecuritySchemes: map[string]*SecurityScheme{
+				SecuritySession: {Type: "apiKey", In: "cookie", Name: g.SessionCookie, Description: "Session cookie of the signed in user"},
+				SecurityBearer:  {Type: "http", Scheme: "bearer", Description: "Authorization: Bearer {token}"},
+			},
+		},
+	}
+	if g.BaseURL != "" {
+		g.spec.Servers = []Server{{URL: g.BaseURL}}
+	}
+	g.schemaNames = map[string]bool{}
+	g.operations = map[string]bool{}
+
+	tags := map[string]bool{}
+	addTag := func(name string) {
+		if name != "" && !tags[name] {
+			tags[name] = true
+			g.spec.Tags = append(g.spec.Tags, Tag{Name: name})
+		}
+	}
+
+	for _, doc := range objDocs {
+		addTag(objectTag(&doc))
+		g.addWebObject(&doc)
+	}
+	for _, doc := range uriDocs {
+		addTag(doc.Group)
+		g.addUriDoc(&doc)
+	}
+	return g.spec
+}
+
+func objectTag(doc *WebObjectDoc) string {
+	if doc.Group != "" {
+		return doc.Group
+	}
+	return path.Base(doc.Path)
+}
+
+// addOperation adds op at the gin path, such as "/user/:id", the path parameters are added
+// if they are not in op.Parameters yet
+func (g *OpenAPIGenerator) addOperation(method, ginPath string, authRequired bool, op *Operation) {
+	apiPath, params := convertPath(ginPath)
+	for _, name := range params {
+		exists := false
+		for _, p := range op.Parameters {
+			if p.In == "path" && p.Name == name {
+				exists = true
+			}
+		}
+		if !exists {
+			op.Parameters = append(op.Parameters, Parameter{Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"}})
+		}
+	}
+
+	if op.OperationID == "" {
+		op.OperationID = operationID(strings.ToLower(method), apiPath)
+	}
+	id := op.OperationID
+	for i := 2; g.operations[op.OperationID]; i++ {
+		op.OperationID = fmt.Sprintf("%s%d", id, i)
+	}
+	g.operations[op.OperationID] = true
+
+	if authRequired {
+		op.Security = []map[string][]string{{SecuritySession: {}}, {SecurityBearer: {}}}
+		op.Responses["401"] = &Response{Description: "Unauthorized"}
+	}
+
+	item, ok := g.spec.Paths[apiPath]
+	if !ok {
+		item = PathItem{}
+		g.spec.Paths[apiPath] = item
+	}
+	item[strings.ToLower(method)] = op
+}
+
+// addSchema adds the schema to components with a unique name, it returns the reference
+func (g *OpenAPIGenerator) addSchema(name string, schema *Schema) *Schema {
+	base := name
+	for i := 2; g.schemaNames[name]; i++ {
+		name = fmt.Sprintf("%s%d", base, i)
+	}
+	g.schemaNames[name] = true
+	g.spec.Components.Schemas[name] = schema
+	return schemaRef(name)
+}
+
+func (g *OpenAPIGenerator) addUriDoc(doc *UriDoc) {
+	method := strings.ToUpper(doc.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+	op := &Operation{
+		Summary:     doc.Summary,
+		Description: doc.Desc,
+		Responses:   map[string]*Response{"200": {Description: "OK"}},
+	}
+	if doc.Group != "" {
+		op.Tags = []string{doc.Group}
+	}
+	if op.Summary == "" {
+		op.Summary = doc.Desc
+	}
+
+	if doc.Request != nil {
+		if method == http.MethodGet || method == http.MethodDelete {
+			for _, f := range doc.Request.Fields {
+				op.Parameters = append(op.Parameters, Parameter{
+					Name:        f.Name,
+					In:          "query",
+					Description: f.Desc,
+					Required:    f.Required,
+					Schema:      fieldSchema(&f),
+				})
+			}
+		} else {
+			op.RequestBody = jsonBody(fieldSchema(doc.Request))
+		}
+	}
+	if doc.Response != nil {
+		op.Responses["200"].Content = jsonContent(fieldSchema(doc.Response))
+	}
+	g.addOperation(method, doc.Path, doc.AuthRequired, op)
+}
+
+func (g *OpenAPIGenerator) addWebObject(doc *WebObjectDoc) {
+	name := schemaName(path.Base(doc.Path))
+	tags := []string{objectTag(doc)}
+
+	model := &Schema{Type: "object", Description: doc.Desc, Properties: map[string]*Schema{}}
+	for _, f := range doc.Fields {
+		model.Properties[f.Name] = fieldSchema(&f)
+		if f.Required {
+			model.Required = append(model.Required, f.Name)
+		}
+	}
+	modelRef := g.addSchema(name, model)
+
+	// the path of a record, such as /api/user/:id
+	var keys []DocField
+	for _, f := range doc.Fields {
+		if f.IsPrimary {
+			keys = append(keys, f)
+		}
+	}
+	if len(keys) == 0 {
+		keys = []DocField{{Name: "id", Type: TypeString}}
+	}
+	keyPath := doc.Path
+	var keyParams []Parameter
+	for _, f := range keys {
+		keyPath += "/:" + f.Name
+		schema := fieldSchema(&f)
+		schema.Type = jsonType(f.Type)
+		keyParams = append(keyParams, Parameter{Name: f.Name, In: "path", Required: true, Schema: schema})
+	}
+	withKeys := func(params ...Parameter) []Parameter {
+		return append(append([]Parameter{}, keyParams...), params...)
+	}
+
+	var queryForm, queryResult *Schema
+	queryRefs := func() (*Schema, *Schema) {
+		if queryForm == nil {
+			queryForm = g.addQueryForm(name, doc)
+			queryResult = g.addSchema(name+"QueryResult", &Schema{
+				Type: "object",
+				Properties: map[string]*Schema{
+					"total":      {Type: "integer"},
+					"pos":        {Type: "integer"},
+					"limit":      {Type: "integer"},
+					"keyword":    {Type: "string"},
+					"items":      {Type: "array", Items: modelRef},
+					"nextCursor": {Type: "string"},
+					"prevCursor": {Type: "string"},
+				},
+				Required: []string{"items"},
+			})
+		}
+		return queryForm, queryResult
+	}
+
+	var versionHeader *Schema
+	if doc.Version != "" {
+		versionHeader = &Schema{Type: "string", Description: "ETag of the " + doc.Version + " field"}
+	}
+
+	for _, method := range doc.AllowMethods {
+		op := &Operation{Tags: tags, Responses: map[string]*Response{}}
+		switch strings.ToUpper(method) {
+		case "GET":
+			op.OperationID = "get" + name
+			op.Summary = "Get " + name
+			op.Parameters = withKeys()
+			if len(doc.Expands) > 0 {
+				op.Parameters = append(op.Parameters, Parameter{
+					Name:        "expand",
+					In:          "query",
+					Description: "associations to preload, the fields can be selected, such as author(id,name)",
+					Schema:      &Schema{Type: "array", Items: &Schema{Type: "string"}},
+				})
+			}
+			res := &Response{Description: "OK", Content: jsonContent(envelope(modelRef))}
+			if versionHeader != nil {
+				op.Parameters = append(op.Parameters, Parameter{Name: "If-None-Match", In: "header", Schema: &Schema{Type: "string"}})
+				res.Headers = map[string]Header{"ETag": {Schema: versionHeader}}
+				op.Responses["304"] = &Response{Description: "Not Modified"}
+			}
+			op.Responses["200"] = res
+			doc.markCached(op)
+			g.addOperation(http.MethodGet, keyPath, doc.AuthRequired, op)
+		case "CREATE":
+			op.OperationID = "create" + name
+			op.Summary = "Create " + name
+			op.RequestBody = jsonBody(modelRef)
+			op.Responses["200"] = &Response{Description: "OK", Content: jsonContent(envelope(modelRef))}
+			op.Responses["422"] = g.validationResponse()
+			doc.markIdempotent(op)
+			g.addOperation(http.MethodPut, doc.Path, doc.AuthRequired, op)
+		case "EDIT":
+			op.OperationID = "edit" + name
+			op.Summary = "Edit " + name
+			op.Parameters = withKeys()
+			edit := &Schema{Type: "object", Properties: map[string]*Schema{}}
+			for _, v := range doc.Editables {
+				if p, ok := model.Properties[v]; ok && v != doc.Version {
+					edit.Properties[v] = p
+				}
+			}
+			op.RequestBody = jsonBody(edit)
+			// the patches of the stored record, by RFC 7396 and RFC 6902
+			op.RequestBody.Content["application/merge-patch+json"] = MediaType{Schema: edit}
+			op.RequestBody.Content["application/json-patch+json"] = MediaType{Schema: g.jsonPatchSchema()}
+			op.Responses["200"] = &Response{Description: "OK", Content: jsonContent(envelope(&Schema{Type: "boolean"}))}
+			op.Responses["409"] = &Response{Description: "JSON Patch test failed"}
+			op.Responses["422"] = g.validationResponse()
+			if versionHeader != nil {
+				op.Parameters = append(op.Parameters, Parameter{
+					Name: "If-Match", In: "header", Required: true,
+					Description: "ETag or " + doc.Version + " of the record", Schema: &Schema{Type: "string"},
+				})
+				op.Responses["200"].Headers = map[string]Header{"ETag": {Schema: versionHeader}}
+				op.Responses["412"] = &Response{Description: "Version mismatch"}
+				op.Responses["428"] = &Response{Description: "If-Match required"}
+			}
+			g.addOperation(http.MethodPatch, keyPath, doc.AuthRequired, op)
+		case "DELETE":
+			op.OperationID = "delete" + name
+			op.Summary = "Delete " + name
+			op.Parameters = withKeys()
+			op.Responses["200"] = &Response{Description: "OK", Content: jsonContent(envelope(&Schema{Type: "boolean"}))}
+			g.addOperation(http.MethodDelete, keyPath, doc.AuthRequired, op)
+		case "QUERY":
+			form, result := queryRefs()
+			op.OperationID = "query" + name
+			op.Summary = "Query " + name
+			op.RequestBody = jsonBody(form)
+			op.Responses["200"] = &Response{Description: "OK", Content: jsonContent(envelope(result))}
+			doc.markCached(op)
+			g.addOperation(http.MethodPost, doc.Path, doc.AuthRequired, op)
+		case "BATCH":
+			op.OperationID = "batch" + name
+			op.Summary = "Create, edit and delete " + name + " in one transaction"
+			op.RequestBody = jsonBody(&Schema{
+				Type: "object",
+				Properties: map[string]*Schema{
+					"creates": {Type: "array", Items: modelRef},
+					"edits":   {Type: "array", Items: &Schema{Type: "object", Description: "primary fields and the fields to change", AdditionalProperties: true}},
+					"deletes": {Type: "array", Items: &Schema{Description: "primary fields, or the primary value"}},
+				},
+			})
+			op.Responses["200"] = &Response{Description: "OK", Content: jsonContent(envelope(&Schema{Type: "object"}))}
+			doc.markIdempotent(op)
+			g.addOperation(http.MethodPost, doc.Path+"/batch", doc.AuthRequired, op)
+		case "EXPORT":
+			form, _ := queryRefs()
+			op.OperationID = "export" + name
+			op.Summary = "Export " + name
+			op.Parameters = []Parameter{{Name: "format", In: "query", Schema: &Schema{Type: "string", Enum: exportFormats, Default: "csv"}}}
+			op.RequestBody = jsonBody(form)
+			op.RequestBody.Required = false
+			content := map[string]MediaType{}
+			for _, v := range exportContents {
+				content[v] = MediaType{Schema: &Schema{Type: "string", Format: "binary"}}
+			}
+			op.Responses["200"] = &Response{Description: "OK", Content: content}
+			g.addOperation(http.MethodPost, doc.Path+"/export", doc.AuthRequired, op)
+		case "IMPORT":
+			op.OperationID = "import" + name
+			op.Summary = "Import " + name
+			op.Parameters = []Parameter{
+				{Name: "format", In: "query", Description: "taken from the file extension if empty", Schema: &Schema{Type: "string", Enum: exportFormats}},
+				{Name: "dryRun", In: "query", Schema: &Schema{Type: "boolean"}},
+			}
+			op.RequestBody = &RequestBody{
+				Required: true,
+				Content: map[string]MediaType{
+					"multipart/form-data": {Schema: &Schema{
+						Type:       "object",
+						Properties: map[string]*Schema{"file": {Type: "string", Format: "binary"}},
+						Required:   []string{"file"},
+					}},
+					"application/octet-stream": {Schema: &Schema{Type: "string", Format: "binary"}},
+				},
+			}
+			op.Responses["200"] = &Response{Description: "OK", Content: jsonContent(envelope(&Schema{
+				Type: "object",
+				Properties: map[string]*Schema{
+					"total":   {Type: "integer"},
+					"created": {Type: "integer"},
+					"dryRun":  {Type: "boolean"},
+				},
+			}))}
+			g.addOperation(http.MethodPost, doc.Path+"/import", doc.AuthRequired, op)
+		case "AGGREGATE":
+			form, _ := queryRefs()
+			op.OperationID = "aggregate" + name
+			op.Summary = "Aggregate " + name
+			op.RequestBody = jsonBody(g.addAggregateForm(name, doc, form))
+			op.Responses["200"] = &Response{Description: "OK", Content: jsonContent(envelope(&Schema{
+				Type: "object",
+				Properties: map[string]*Schema{
+					"columns": {Type: "array", Items: &Schema{Type: "string"}},
+					"rows":    {Type: "array", Items: &Schema{Type: "array", Items: &Schema{}}},
+				},
+			}))}
+			g.addOperation(http.MethodPost, doc.Path+"/aggregate", doc.AuthRequired, op)
+		case "HISTORY":
+			op.OperationID = "history" + name
+			op.Summary = "List the changes of " + name
+			op.Parameters = withKeys(
+				Parameter{Name: "pos", In: "query", Schema: &Schema{Type: "integer"}},
+				Parameter{Name: "limit", In: "query", Schema: &Schema{Type: "integer"}},
+			)
+			op.Responses["200"] = &Response{Description: "OK", Content: jsonContent(envelope(&Schema{Type: "object"}))}
+			g.addOperation(http.MethodGet, keyPath+"/history", doc.AuthRequired, op)
+
+			revert := &Operation{
+				Tags:        tags,
+				OperationID: "revert" + name,
+				Summary:     "Revert " + name + " to the version of a change",
+				Parameters:  withKeys(Parameter{Name: "historyId", In: "path", Required: true, Schema: &Schema{Type: "integer"}}),
+				Responses:   map[string]*Response{"200": {Description: "OK", Content: jsonContent(envelope(&Schema{Type: "boolean"}))}},
+			}
+			g.addOperation(http.MethodPost, keyPath+"/history/:historyId/revert", doc.AuthRequired, revert)
+		}
+	}
+
+	for _, v := range doc.Views {
+		form, result := queryRefs()
+		method := strings.ToUpper(v.Method)
+		if method == "" {
+			method = http.MethodPost
+		}
+		op := &Operation{
+			Tags:        tags,
+			Summary:     v.Desc,
+			OperationID: operationID("query"+name, strings.TrimPrefix(v.Path, doc.Path)),
+			Responses:   map[string]*Response{"200": {Description: "OK", Content: jsonContent(envelope(result))}},
+		}
+		if method != http.MethodGet {
+			op.RequestBody = jsonBody(form)
+			op.RequestBody.Required = false
+		}
+		doc.markCached(op)
+		g.addOperation(method, v.Path, doc.AuthRequired, op)
+	}
+}
+
+// markCached notes the cache of the object on the read operation
+func (doc *WebObjectDoc) markCached(op *Operation) {
+	if doc.CacheTTL <= 0 {
+		return
+	}
+	op.CacheTTL = doc.CacheTTL
+	op.Description = strings.TrimSpace(op.Description + fmt.Sprintf("\n\nCached for %ds, invalidated by the writes of the object. The X-Cache header tells HIT or MISS.", doc.CacheTTL))
+}
+
+// markIdempotent adds the Idempotency-Key header to the operation creating records
+func (doc *WebObjectDoc) markIdempotent(op *Operation) {
+	if !doc.Idempotent {
+		return
+	}
+	maxLength := 255
+	op.Parameters = append(op.Parameters, Parameter{
+		Name: "Idempotency-Key", In: "header",
+		Description: "the retries with the same key replay the first response, with the Idempotent-Replayed header",
+		Schema:      &Schema{Type: "string", MaxLength: &maxLength},
+	})
+	op.Responses["409"] = &Response{Description: "Request with the Idempotency-Key in progress"}
+	if _, ok := op.Responses["422"]; !ok {
+		op.Responses["422"] = &Response{Description: "Idempotency-Key reused with a different request"}
+	}
+}
+
+// addQueryForm adds the QueryForm schema of the object, the filters and orders are limited to the whitelists
+func (g *OpenAPIGenerator) addQueryForm(name string, doc *WebObjectDoc) *Schema {
+	properties := map[string]*Schema{
+		"pos":        {Type: "integer"},
+		"limit":      {Type: "integer"},
+		"cursor":     {Type: "string"},
+		"cursorMode": {Type: "boolean"},
+		"skipCount":  {Type: "boolean"},
+		"foreign":    {Type: "boolean"},
+	}
+
+	if len(doc.Filters) > 0 {
+		filterName := name + "Filter"
+		filter := &Schema{
+			Type: "object",
+			Properties: map[string]*Schema{
+				"name":    {Type: "string", Enum: stringsToAny(doc.jsonNames(doc.Filters))},
+				"op":      {Type: "string", Enum: filterOps},
+				"value":   {},
+				"filters": {Type: "array", Items: schemaRef(filterName), Description: "child filters of and/or/not"},
+			},
+			Required: []string{"op"},
+		}
+		properties["filters"] = &Schema{Type: "array", Items: g.addSchema(filterName, filter)}
+	}
+
+	if len(doc.Orders) > 0 {
+		properties["orders"] = &Schema{
+			Type: "array",
+			Items: &Schema{
+				Type: "object",
+				Properties: map[string]*Schema{
+					"name": {Type: "string", Enum: stringsToAny(doc.jsonNames(doc.Orders))},
+					"op":   {Type: "string", Enum: orderOps},
+				},
+				Required: []string{"name"},
+			},
+		}
+	}
+
+	if len(doc.Searches) > 0 {
+		properties["keyword"] = &Schema{
+			Type:        "string",
+			Description: "searched in " + strings.Join(doc.jsonNames(doc.Searches), ", "),
+		}
+	}
+
+	if len(doc.Expands) > 0 {
+		var names []string
+		for _, v := range doc.Expands {
+			names = append(names, v.Name)
+		}
+		properties["expand"] = &Schema{
+			Type:        "array",
+			Description: "one of " + strings.Join(names, ", ") + ", the fields can be selected, such as author(id,name)",
+			Items:       &Schema{Type: "string"},
+		}
+	}
+	return g.addSchema(name+"QueryForm", &Schema{Type: "object", Properties: properties})
+}
+
+// addAggregateForm adds the AggregateForm schema of the object, with the filters of the QueryForm form
+func (g *OpenAPIGenerator) addAggregateForm(name string, doc *WebObjectDoc, form *Schema) *Schema {
+	properties := map[string]*Schema{
+		"groupBy": {
+			Type: "array",
+			Items: &Schema{
+				Type: "object",
+				Properties: map[string]*Schema{
+					"name":   {Type: "string", Enum: stringsToAny(doc.jsonNames(doc.Groups))},
+					"bucket": {Type: "string", Enum: bucketNames, Description: "only for time fields"},
+					"as":     {Type: "string"},
+				},
+				Required: []string{"name"},
+			},
+		},
+		"metrics": {
+			Type: "array",
+			Items: &Schema{
+				Type: "object",
+				Properties: map[string]*Schema{
+					"op":   {Type: "string", Enum: aggregateOps},
+					"name": {Type: "string", Enum: stringsToAny(doc.jsonNames(doc.Aggregates)), Description: "empty to count the rows"},
+					"as":   {Type: "string"},
+				},
+				Required: []string{"op"},
+			},
+		},
+		"sort": {
+			Type: "array",
+			Items: &Schema{
+				Type: "object",
+				Properties: map[string]*Schema{
+					"name": {Type: "string", Description: "column of the result"},
+					"op":   {Type: "string", Enum: orderOps},
+				},
+			},
+		},
+		"limit": {Type: "integer"},
+	}
+	if len(doc.Groups) == 0 {
+		delete(properties, "groupBy")
+	}
+	if ref, ok := g.spec.Components.Schemas[strings.TrimPrefix(form.Ref, "#/components/schemas/")]; ok {
+		for _, k := range []string{"filters", "keyword"} {
+			if p, ok := ref.Properties[k]; ok {
+				properties[k] = p
+			}
+		}
+	}
+	return g.addSchema(name+"AggregateForm", &Schema{Type: "object", Properties: properties, Required: []string{"metrics"}})
+}
+
+// fieldSchema converts the DocField to a schema
+func fieldSchema(f *DocField) *Schema {
+	s := &Schema{Description: f.Desc, Default: f.Default}
+	if f.Type == TypeObject || len(f.Fields) > 0 {
+		s.Type = "object"
+		for _, child := range f.Fields {
+			if s.Properties == nil {
+				s.Properties = map[string]*Schema{}
+			}
+			s.Properties[child.Name] = fieldSchema(&child)
+			if child.Required {
+				s.Required = append(s.Required, child.Name)
+			}
+		}
+	} else {
+		s.Type = jsonType(f.Type)
+		switch f.Type {
+		case TypeDate:
+			s.Format = "date-time"
+		case TypeMap:
+			s.AdditionalProperties = true
+		}
+	}
+
+	if f.IsArray {
+		item := *s
+		item.Description = ""
+		item.Default = nil
+		s = &Schema{Type: "array", Items: &item, Description: f.Desc}
+		if f.Type == "uint8" {
+			// []byte is base64 in JSON
+			s = &Schema{Type: "string", Format: "byte", Description: f.Desc}
+		}
+	}
+	if !f.IsArray {
+		applyRules(s, f.Rules)
+	}
+	if f.CanNull {
+		if t, ok := s.Type.(string); ok {
+			s.Type = []string{t, "null"}
+		}
+	}
+	return s
+}
+
+// applyRules adds the constraints of the validation rules to the schema,
+// such as "maxlen:64" => {"maxLength": 64}
+func applyRules(s *Schema, rules string) {
+	for _, part := range strings.Split(rules, ",") {
+		name, param := strings.TrimSpace(part), ""
+		if i := strings.IndexAny(name, ":="); i >= 0 {
+			name, param = strings.TrimSpace(name[:i]), strings.TrimSpace(name[i+1:])
+		}
+		switch name {
+		case "email":
+			s.Format = "email"
+		case "url":
+			s.Format = "uri"
+		case "alpha":
+			s.Pattern = "^[a-zA-Z]+$"
+		case "alphanum":
+			s.Pattern = "^[a-zA-Z0-9]+$"
+		case "phone":
+			s.Pattern = `^\+?[1-9]\d{1,14}$`
+		case "minlen", "maxlen":
+			n, err := strconv.Atoi(param)
+			if err != nil {
+				continue
+			}
+			if name == "minlen" {
+				s.MinLength = &n
+			} else {
+				s.MaxLength = &n
+			}
+		case "min", "max":
+			n, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				continue
+			}
+			if name == "min" {
+				s.Minimum = &n
+			} else {
+				s.Maximum = &n
+			}
+		}
+	}
+}
+
+// jsonPatchSchema returns the shared schema of the JSON Patch documents
+func (g *OpenAPIGenerator) jsonPatchSchema() *Schema {
+	if g.jsonPatch != nil {
+		return g.jsonPatch
+	}
+	g.jsonPatch = g.addSchema("JSONPatch", &Schema{
+		Type: "array",
+		Items: &Schema{
+			Type: "object",
+			Properties: map[string]*Schema{
+				"op":    {Type: "string", Enum: []any{"add", "remove", "replace", "move", "copy", "test"}},
+				"path":  {Type: "string", Description: "JSON Pointer of the target"},
+				"from":  {Type: "string", Description: "JSON Pointer of the source of move and copy"},
+				"value": {Description: "value of add, replace and test"},
+			},
+			Required: []string{"op", "path"},
+		},
+	})
+	return g.jsonPatch
+}
+
+// validationResponse returns the response of the failed validations, with the shared field errors schema
+func (g *OpenAPIGenerator) validationResponse() *Response {
+	if g.validationErrors == nil {
+		g.validationErrors = g.addSchema("ValidationErrors", &Schema{
+			Type: "object",
+			Properties: map[string]*Schema{
+				"errors": {
+					Type: "array",
+					Items: &Schema{
+						Type: "object",
+						Properties: map[string]*Schema{
+							"field":   {Type: "string", Description: "JSON name of the field"},
+							"rule":    {Type: "string", Description: "the failed rule, such as maxlen"},
+							"param":   {Type: "string", Description: "parameter of the rule, such as 64 of maxlen:64"},
+							"message": {Type: "string", Description: "localized message"},
+						},
+						Required: []string{"field", "rule", "message"},
+					},
+				},
+			},
+			Required: []string{"errors"},
+		})
+	}
+	return &Response{Description: "Validation failed", Content: jsonContent(envelope(g.validationErrors))}
+}
+
+// jsonType converts the DocField type, or the kind of a slice element, to the JSON Schema type
+func jsonType(t string) any {
+	switch t {
+	case TypeDate, TypeString:
+		return "string"
+	case TypeInt, "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64", "uintptr":
+		return "integer"
+	case TypeFloat, "float32", "float64":
+		return "number"
+	case TypeBoolean, "bool":
+		return "boolean"
+	case TypeObject, TypeMap:
+		return "object"
+	}
+	return nil
+}
+
+func schemaRef(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// envelope wraps data in the response of pkg/utils/response
+func envelope(data *Schema) *Schema {
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"code": {Type: "integer"},
+			"msg":  {Type: "string"},
+			"data": data,
+		},
+		Required: []string{"code", "msg"},
+	}
+}
+
+func jsonContent(schema *Schema) map[string]MediaType {
+	return map[string]MediaType{"application/json": {Schema: schema}}
+}
+
+func jsonBody(schema *Schema) *RequestBody {
+	return &RequestBody{Required: true, Content: jsonContent(schema)}
+}
+
+func stringsToAny(vals []string) []any {
+	var result []any
+	for _, v := range vals {
+		result = append(result, v)
+	}
+	return result
+}
+
+// convertPath converts the gin path to the OpenAPI path, such as "/user/:id" => "/user/{id}"
+func convertPath(ginPath string) (string, []string) {
+	var params []string
+	segs := strings.Split(ginPath, "/")
+	for i, seg := range segs {
+		if strings.HasPrefix(seg, ":") || strings.HasPrefix(seg, "*") {
+			params = append(params, seg[1:])
+			segs[i] = "{" + seg[1:] + "}"
+		}
+	}
+	return strings.Join(segs, "/"), params
+}
+
+// schemaName converts the object name to a schema name, such as "user_group" => "UserGroup"
+func schemaName(name string) string {
+	var sb strings.Builder
+	upper := true
+	for _, c := range name {
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+			upper = true
+			continue
+		}
+		if upper {
+			c = unicode.ToUpper(c)
+			upper = false
+		}
+		sb.WriteRune(c)
+	}
+	if sb.Len() == 0 {
+		return "Object"
+	}
+	return sb.String()
+}
+
+// operationID builds the id from the method and path, such as GET /api/auth/info => getApiAuthInfo
+func operationID(prefix, apiPath string) string {
+	id := strings.ToLower(prefix[:1]) + prefix[1:]
+	for _, seg := range strings.Split(apiPath, "/") {
+		seg = strings.Trim(seg, "{}")
+		if seg == "" {
+			continue
+		}
+		id += schemaName(seg)
+	}
+	return id
+}
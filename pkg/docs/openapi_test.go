@@ -0,0 +1,153 @@
+package docs
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func testObjectDoc() WebObjectDoc {
+	return WebObjectDoc{
+		Group:        "lingEcho",
+		Path:         "/api/user",
+		AuthRequired: true,
+		AllowMethods: []string{"GET", "CREATE", "EDIT", "DELETE", "QUERY", "AGGREGATE", "HISTORY"},
+		Idempotent:   true,
+		Fields: []DocField{
+			{FieldName: "ID", Name: "id", Type: TypeInt, IsPrimary: true},
+			{FieldName: "Email", Name: "email", Type: TypeString, Required: true, Rules: "required,email,maxlen:64"},
+			{FieldName: "Tags", Name: "tags", Type: TypeString, IsArray: true},
+			{FieldName: "LastLogin", Name: "lastLogin", Type: TypeDate, CanNull: true},
+			{FieldName: "Version", Name: "version", Type: TypeInt},
+		},
+		Filters:    []string{"Email", "LastLogin"},
+		Orders:     []string{"LastLogin"},
+		Searches:   []string{"Email"},
+		Groups:     []string{"Email"},
+		Aggregates: []string{"Version"},
+		Version:    "version",
+		Editables:  []string{"email", "version"},
+		Views:      []UriDoc{{Path: "/api/user/active", Method: http.MethodPost, Desc: "Active users"}},
+	}
+}
+
+func TestOpenAPIGenerator_WebObject(t *testing.T) {
+	spec := NewOpenAPIGenerator("http://localhost", "1.0.0", "test").Generate(nil, []WebObjectDoc{testObjectDoc()})
+	assert.Equal(t, OpenAPIVersion, spec.OpenAPI)
+
+	get := spec.Paths["/api/user/{id}"]["get"]
+	require.NotNil(t, get)
+	assert.Equal(t, "getUser", get.OperationID)
+	assert.Equal(t, "id", get.Parameters[0].Name)
+	assert.Equal(t, "integer", get.Parameters[0].Schema.Type)
+	assert.Len(t, get.Security, 2)
+	assert.Contains(t, get.Responses, "304")
+
+	edit := spec.Paths["/api/user/{id}"]["patch"]
+	editBody := edit.RequestBody.Content["application/json"].Schema
+	assert.Contains(t, editBody.Properties, "email")
+	assert.NotContains(t, editBody.Properties, "version")
+	assert.Equal(t, editBody, edit.RequestBody.Content["application/merge-patch+json"].Schema)
+	assert.Equal(t, "#/components/schemas/JSONPatch", edit.RequestBody.Content["application/json-patch+json"].Schema.Ref)
+	assert.Equal(t, "array", spec.Components.Schemas["JSONPatch"].Type)
+	assert.Equal(t, "If-Match", edit.Parameters[1].Name)
+	assert.Contains(t, edit.Responses, "412")
+	assert.Equal(t, "#/components/schemas/ValidationErrors", edit.Responses["422"].Content["application/json"].Schema.Properties["data"].Ref)
+
+	create := spec.Paths["/api/user"]["put"]
+	require.NotNil(t, create)
+	assert.Equal(t, "Idempotency-Key", create.Parameters[0].Name)
+	assert.Contains(t, create.Responses, "409")
+	assert.NotNil(t, spec.Paths["/api/user/{id}/history"]["get"])
+	revert := spec.Paths["/api/user/{id}/history/{historyId}/revert"]["post"]
+	assert.Equal(t, "revertUser", revert.OperationID)
+	assert.Equal(t, "queryUserActive", spec.Paths["/api/user/active"]["post"].OperationID)
+	assert.Zero(t, get.CacheTTL)
+
+	user := spec.Components.Schemas["User"]
+	assert.Equal(t, []string{"email"}, user.Required)
+	assert.Equal(t, "array", user.Properties["tags"].Type)
+	assert.Equal(t, []string{"string", "null"}, user.Properties["lastLogin"].Type)
+	assert.Equal(t, "date-time", user.Properties["lastLogin"].Format)
+	assert.Equal(t, "email", user.Properties["email"].Format)
+	assert.Equal(t, 64, *user.Properties["email"].MaxLength)
+
+	// the whitelists are json names
+	form := spec.Components.Schemas["UserQueryForm"]
+	assert.Equal(t, "#/components/schemas/UserFilter", form.Properties["filters"].Items.Ref)
+	assert.Equal(t, []any{"email", "lastLogin"}, spec.Components.Schemas["UserFilter"].Properties["name"].Enum)
+	assert.Equal(t, []any{"lastLogin"}, form.Properties["orders"].Items.Properties["name"].Enum)
+	assert.Contains(t, form.Properties, "keyword")
+
+	aggregate := spec.Components.Schemas["UserAggregateForm"]
+	assert.Equal(t, []any{"version"}, aggregate.Properties["metrics"].Items.Properties["name"].Enum)
+	assert.Contains(t, aggregate.Properties, "filters")
+}
+
+func TestOpenAPIGenerator_Cached(t *testing.T) {
+	doc := testObjectDoc()
+	doc.CacheTTL = 60
+	spec := NewOpenAPIGenerator("http://localhost", "1.0.0", "test").Generate(nil, []WebObjectDoc{doc})
+
+	assert.Equal(t, 60, spec.Paths["/api/user/{id}"]["get"].CacheTTL)
+	assert.Equal(t, 60, spec.Paths["/api/user"]["post"].CacheTTL)
+	assert.Equal(t, 60, spec.Paths["/api/user/active"]["post"].CacheTTL)
+	assert.Contains(t, spec.Paths["/api/user"]["post"].Description, "Cached for 60s")
+	assert.Zero(t, spec.Paths["/api/user/{id}"]["patch"].CacheTTL)
+}
+
+func TestOpenAPIGenerator_UriDoc(t *testing.T) {
+	uriDocs := []UriDoc{
+		{Group: "auth", Path: "/api/auth/info", Method: http.MethodGet, Summary: "Info", AuthRequired: true,
+			Request: &DocField{Type: TypeObject, Fields: []DocField{{Name: "lang", Type: TypeString}}}},
+		{Group: "auth", Path: "/api/auth/login", Method: http.MethodPost, Desc: "Login",
+			Request:  &DocField{Type: TypeObject, Fields: []DocField{{Name: "email", Type: TypeString, Required: true}}},
+			Response: &DocField{Type: TypeObject, Fields: []DocField{{Name: "token", Type: TypeString}}}},
+		{Path: "/api/files/*path", Method: http.MethodGet},
+	}
+	spec := NewOpenAPIGenerator("", "1.0.0", "test").Generate(uriDocs, nil)
+	assert.Empty(t, spec.Servers)
+
+	info := spec.Paths["/api/auth/info"]["get"]
+	assert.Equal(t, "getApiAuthInfo", info.OperationID)
+	assert.Equal(t, "query", info.Parameters[0].In)
+	assert.Contains(t, info.Responses, "401")
+
+	login := spec.Paths["/api/auth/login"]["post"]
+	assert.Equal(t, "Login", login.Summary)
+	assert.Empty(t, login.Security)
+	assert.Equal(t, []string{"email"}, login.RequestBody.Content["application/json"].Schema.Required)
+	assert.Contains(t, login.Responses["200"].Content["application/json"].Schema.Properties, "token")
+
+	files := spec.Paths["/api/files/{path}"]["get"]
+	assert.Equal(t, "path", files.Parameters[0].Name)
+}
+
+func TestSpec_Output(t *testing.T) {
+	gen := func() *Spec {
+		return NewOpenAPIGenerator("http://localhost", "1.0.0", "test").Generate(
+			[]UriDoc{{Path: "/api/ping", Method: http.MethodGet}}, []WebObjectDoc{testObjectDoc(), testObjectDoc()})
+	}
+	data, err := gen().ToJSON()
+	require.NoError(t, err)
+	again, _ := gen().ToJSON()
+	assert.Equal(t, string(data), string(again))
+
+	var v map[string]any
+	require.NoError(t, json.Unmarshal(data, &v))
+	assert.Equal(t, "3.1.0", v["openapi"])
+	// the same object twice gets unique names
+	schemas := v["components"].(map[string]any)["schemas"].(map[string]any)
+	assert.Contains(t, schemas, "User2")
+
+	data, err = gen().ToYAML()
+	require.NoError(t, err)
+	var y map[string]any
+	require.NoError(t, yaml.Unmarshal(data, &y))
+	assert.Equal(t, "3.1.0", y["openapi"])
+	assert.Contains(t, y["paths"], "/api/ping")
+}
@@ -0,0 +1,211 @@
+package docs
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Kinds of the SDK operations
+const (
+	sdkGet      = "get"
+	sdkCreate   = "create"
+	sdkEdit     = "edit"
+	sdkDelete   = "delete"
+	sdkQuery    = "query"
+	sdkEndpoint = "endpoint"
+)
+
+// SDKGenerator generates the client SDKs of the WebObjects and the UriDoc endpoints,
+// the output is the same for the same docs, whatever their order is
+type SDKGenerator struct {
+	PackageName string // package of the Go client, "client" by default
+	Generator   string // name of the generator command, written in the header
+}
+
+func NewSDKGenerator() *SDKGenerator {
+	return &SDKGenerator{PackageName: "client", Generator: "sdkgen"}
+}
+
+type sdkObject struct {
+	Name      string // type name, such as User
+	Doc       *WebObjectDoc
+	Keys      []DocField
+	Filters   []string // json names
+	Orders    []string // json names
+	Editables []DocField
+}
+
+func (obj *sdkObject) hasMethod(method string) bool {
+	for _, v := range obj.Doc.AllowMethods {
+		if strings.EqualFold(v, method) {
+			return true
+		}
+	}
+	return false
+}
+
+type sdkOperation struct {
+	Name         string // camel case, such as getUser
+	Kind         string
+	Method       string
+	Path         string // gin path
+	Desc         string
+	AuthRequired bool
+	Object       *sdkObject
+	PathParams   []DocField
+	Request      *DocField
+	Response     *DocField
+}
+
+type sdkModel struct {
+	Objects    []*sdkObject
+	Operations []*sdkOperation
+}
+
+// buildSDKModel sorts the docs and names the operations
+func buildSDKModel(uriDocs []UriDoc, objDocs []WebObjectDoc) *sdkModel {
+	objDocs = append([]WebObjectDoc{}, objDocs...)
+	sort.SliceStable(objDocs, func(i, j int) bool { return objDocs[i].Path < objDocs[j].Path })
+	uriDocs = append([]UriDoc{}, uriDocs...)
+	sort.SliceStable(uriDocs, func(i, j int) bool {
+		if uriDocs[i].Path != uriDocs[j].Path {
+			return uriDocs[i].Path < uriDocs[j].Path
+		}
+		return uriDocs[i].Method < uriDocs[j].Method
+	})
+
+	m := &sdkModel{}
+	names := map[string]bool{}
+	uniqueName := func(name string) string {
+		base := name
+		for i := 2; names[strings.ToLower(name)]; i++ {
+			name = fmt.Sprintf("%s%d", base, i)
+		}
+		names[strings.ToLower(name)] = true
+		return name
+	}
+
+	for i := range objDocs {
+		doc := &objDocs[i]
+		obj := &sdkObject{Name: uniqueName(schemaName(path.Base(doc.Path))), Doc: doc}
+		for _, f := range doc.Fields {
+			if f.IsPrimary {
+				obj.Keys = append(obj.Keys, f)
+			}
+		}
+		if len(obj.Keys) == 0 {
+			obj.Keys = []DocField{{Name: "id", Type: TypeString}}
+		}
+		obj.Filters = doc.jsonNames(doc.Filters)
+		obj.Orders = doc.jsonNames(doc.Orders)
+		for _, f := range doc.Fields {
+			for _, v := range doc.Editables {
+				if f.Name == v && v != doc.Version {
+					obj.Editables = append(obj.Editables, f)
+				}
+			}
+		}
+		m.Objects = append(m.Objects, obj)
+
+		keyPath := doc.Path
+		for _, f := range obj.Keys {
+			keyPath += "/:" + f.Name
+		}
+		for _, method := range doc.AllowMethods {
+			op := &sdkOperation{Object: obj, AuthRequired: doc.AuthRequired}
+			switch strings.ToUpper(method) {
+			case "GET":
+				op.Kind, op.Method, op.Path, op.Desc = sdkGet, http.MethodGet, keyPath, "Get "+obj.Name
+			case "CREATE":
+				op.Kind, op.Method, op.Path, op.Desc = sdkCreate, http.MethodPut, doc.Path, "Create "+obj.Name
+			case "EDIT":
+				op.Kind, op.Method, op.Path, op.Desc = sdkEdit, http.MethodPatch, keyPath, "Edit "+obj.Name
+			case "DELETE":
+				op.Kind, op.Method, op.Path, op.Desc = sdkDelete, http.MethodDelete, keyPath, "Delete "+obj.Name
+			case "QUERY":
+				op.Kind, op.Method, op.Path, op.Desc = sdkQuery, http.MethodPost, doc.Path, "Query "+obj.Name
+			default:
+				continue
+			}
+			if op.Kind == sdkGet || op.Kind == sdkEdit || op.Kind == sdkDelete {
+				op.PathParams = obj.Keys
+			}
+			op.Name = uniqueName(op.Kind + obj.Name)
+			m.Operations = append(m.Operations, op)
+		}
+		for _, v := range doc.Views {
+			method := strings.ToUpper(v.Method)
+			if method == "" {
+				method = http.MethodPost
+			}
+			op := &sdkOperation{
+				Kind:         sdkQuery,
+				Method:       method,
+				Path:         v.Path,
+				Desc:         v.Desc,
+				AuthRequired: doc.AuthRequired,
+				Object:       obj,
+				Name:         uniqueName(operationID(sdkQuery+obj.Name, strings.TrimPrefix(v.Path, doc.Path))),
+			}
+			m.Operations = append(m.Operations, op)
+		}
+	}
+
+	for i := range uriDocs {
+		doc := &uriDocs[i]
+		method := strings.ToUpper(doc.Method)
+		if method == "" {
+			method = http.MethodGet
+		}
+		apiPath, params := convertPath(doc.Path)
+		op := &sdkOperation{
+			Name:         uniqueName(operationID(strings.ToLower(method), apiPath)),
+			Kind:         sdkEndpoint,
+			Method:       method,
+			Path:         doc.Path,
+			Desc:         doc.Desc,
+			AuthRequired: doc.AuthRequired,
+			Request:      doc.Request,
+			Response:     doc.Response,
+		}
+		if op.Desc == "" {
+			op.Desc = doc.Summary
+		}
+		for _, p := range params {
+			op.PathParams = append(op.PathParams, DocField{Name: p, Type: TypeString})
+		}
+		m.Operations = append(m.Operations, op)
+	}
+	return m
+}
+
+// pathTemplate replaces the params of the gin path, such as "/user/:id" => "/user/" + f("id")
+func pathTemplate(ginPath string, f func(param string) string) string {
+	segs := strings.Split(ginPath, "/")
+	for i, seg := range segs {
+		if strings.HasPrefix(seg, ":") || strings.HasPrefix(seg, "*") {
+			segs[i] = f(seg[1:])
+		}
+	}
+	return strings.Join(segs, "/")
+}
+
+// identifier converts the json name to a lower camel identifier, such as "user_id" => "userId"
+func identifier(name string) string {
+	s := schemaName(name)
+	return strings.ToLower(s[:1]) + s[1:]
+}
+
+// commentLines returns the lines of the comment, prefixed with prefix
+func commentLines(text, prefix string) string {
+	var sb strings.Builder
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		sb.WriteString(prefix)
+		sb.WriteString(strings.TrimRight(line, " \t\r"))
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,412 @@
+package docs
+
+import (
+	"fmt"
+	"go/format"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const goRuntime = `
+// Filter ops of Filter
+const (
+	FilterOpEqual          = "="
+	FilterOpNotEqual       = "<>"
+	FilterOpIsNot          = "is not"
+	FilterOpIn             = "in"
+	FilterOpNotIn          = "not_in"
+	FilterOpGreater        = ">"
+	FilterOpGreaterOrEqual = ">="
+	FilterOpLess           = "<"
+	FilterOpLessOrEqual    = "<="
+	FilterOpLike           = "like"
+	FilterOpNotLike        = "not_like"
+	FilterOpStartsWith     = "starts_with"
+	FilterOpEndsWith       = "ends_with"
+	FilterOpBetween        = "between"
+	FilterOpIsNull         = "is_null"
+	FilterOpNotNull        = "not_null"
+	FilterOpAnd            = "and"
+	FilterOpOr             = "or"
+	FilterOpNot            = "not"
+
+	OrderOpAsc  = "asc"
+	OrderOpDesc = "desc"
+)
+
+// Filter is a condition on the field Name, or a group of child Filters
+// when Op is "and", "or" or "not"
+type Filter struct {
+	Name    string   ` + "`json:\"name,omitempty\"`" + `
+	Op      string   ` + "`json:\"op\"`" + `
+	Value   any      ` + "`json:\"value,omitempty\"`" + `
+	Filters []Filter ` + "`json:\"filters,omitempty\"`" + `
+}
+
+type Order struct {
+	Name string ` + "`json:\"name\"`" + `
+	Op   string ` + "`json:\"op,omitempty\"`" + `
+}
+
+type QueryForm struct {
+	Pos        int      ` + "`json:\"pos,omitempty\"`" + `
+	Limit      int      ` + "`json:\"limit,omitempty\"`" + `
+	Keyword    string   ` + "`json:\"keyword,omitempty\"`" + `
+	Filters    []Filter ` + "`json:\"filters,omitempty\"`" + `
+	Orders     []Order  ` + "`json:\"orders,omitempty\"`" + `
+	Cursor     string   ` + "`json:\"cursor,omitempty\"`" + `
+	CursorMode bool     ` + "`json:\"cursorMode,omitempty\"`" + `
+	SkipCount  bool     ` + "`json:\"skipCount,omitempty\"`" + `
+	Expand     []string ` + "`json:\"expand,omitempty\"`" + `
+}
+
+type QueryResult[T any] struct {
+	Total      int    ` + "`json:\"total,omitempty\"`" + `
+	Pos        int    ` + "`json:\"pos,omitempty\"`" + `
+	Limit      int    ` + "`json:\"limit,omitempty\"`" + `
+	Keyword    string ` + "`json:\"keyword,omitempty\"`" + `
+	Items      []T    ` + "`json:\"items\"`" + `
+	NextCursor string ` + "`json:\"nextCursor,omitempty\"`" + `
+	PrevCursor string ` + "`json:\"prevCursor,omitempty\"`" + `
+}
+
+// Error is a failed response, Code is the code of the response or the http status
+type Error struct {
+	Code int
+	Msg  string
+	Data json.RawMessage
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d: %s", e.Code, e.Msg)
+}
+
+type Client struct {
+	BaseURL    string
+	Token      string // sent as "Authorization: Bearer {Token}"
+	Header     http.Header
+	HTTPClient *http.Client
+}
+
+func NewClient(baseURL string) *Client {
+	return &Client{BaseURL: strings.TrimSuffix(baseURL, "/"), HTTPClient: http.DefaultClient}
+}
+
+// Do send the request, the data of the {code, msg, data} response is decoded into out
+func (c *Client) Do(ctx context.Context, method, path string, query url.Values, header http.Header, body, out any) error {
+	u := c.BaseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return err
+	}
+	for k, v := range c.Header {
+		req.Header[k] = v
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	var result struct {
+		Code *int            ` + "`json:\"code\"`" + `
+		Msg  string          ` + "`json:\"msg\"`" + `
+		Data json.RawMessage ` + "`json:\"data\"`" + `
+	}
+	if json.Unmarshal(data, &result) == nil && result.Code != nil {
+		if *result.Code != http.StatusOK {
+			return &Error{Code: *result.Code, Msg: result.Msg, Data: result.Data}
+		}
+		data = result.Data
+	} else if resp.StatusCode >= http.StatusBadRequest {
+		return &Error{Code: resp.StatusCode, Msg: resp.Status, Data: data}
+	}
+	if out == nil || len(data) == 0 || string(data) == "null" {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// queryValues encode the fields of v as the url query
+func queryValues(v any) url.Values {
+	values := url.Values{}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return values
+	}
+	var fields map[string]any
+	if json.Unmarshal(data, &fields) != nil {
+		return values
+	}
+	for k, field := range fields {
+		items, ok := field.([]any)
+		if !ok {
+			items = []any{field}
+		}
+		for _, item := range items {
+			if item != nil {
+				values.Add(k, fmt.Sprint(item))
+			}
+		}
+	}
+	return values
+}
+`
+
+// GenerateGo returns the Go client, a single file of the package PackageName
+func (g *SDKGenerator) GenerateGo(uriDocs []UriDoc, objDocs []WebObjectDoc) ([]byte, error) {
+	m := buildSDKModel(uriDocs, objDocs)
+	gen := &goTypes{declared: map[string]bool{}}
+	for _, v := range []string{"Filter", "Order", "QueryForm", "QueryResult", "Error", "Client"} {
+		gen.declared[v] = true
+	}
+	for _, obj := range m.Objects {
+		gen.declared[obj.Name] = true
+		gen.declared[obj.Name+"Edit"] = true
+	}
+
+	var types, methods strings.Builder
+	for _, obj := range m.Objects {
+		types.WriteString("\n")
+		if obj.Doc.Desc != "" {
+			types.WriteString("// " + obj.Name + " " + obj.Doc.Desc + "\n")
+		}
+		fmt.Fprintf(&types, "type %s struct {\n%s}\n", obj.Name, gen.fields(obj.Name, obj.Doc.Fields, false))
+
+		if obj.hasMethod("EDIT") {
+			fmt.Fprintf(&types, "\n// %sEdit is the fields of %s can be edited, nil fields are not changed\n", obj.Name, obj.Name)
+			fmt.Fprintf(&types, "type %sEdit struct {\n%s}\n", obj.Name, gen.fields(obj.Name+"Edit", obj.Editables, true))
+		}
+
+		if len(obj.Filters) > 0 || len(obj.Orders) > 0 {
+			fmt.Fprintf(&types, "\n// Fields of %s can be filtered or ordered by\nconst (\n", obj.Name)
+			for _, v := range obj.Filters {
+				fmt.Fprintf(&types, "\t%sFilter%s = %q\n", obj.Name, goName(v), v)
+			}
+			for _, v := range obj.Orders {
+				fmt.Fprintf(&types, "\t%sOrder%s = %q\n", obj.Name, goName(v), v)
+			}
+			types.WriteString(")\n")
+		}
+	}
+
+	for _, op := range m.Operations {
+		name := goName(op.Name)
+		params := []string{"ctx context.Context"}
+		for _, p := range op.PathParams {
+			t := "string"
+			if jsonType(p.Type) == "integer" {
+				t = "int64"
+			}
+			params = append(params, identifier(p.Name)+" "+t)
+		}
+		path := strconv.Quote(op.Path)
+		if len(op.PathParams) > 0 {
+			path = `"` + pathTemplate(op.Path, func(param string) string {
+				return `" + url.PathEscape(fmt.Sprint(` + identifier(param) + `)) + "`
+			}) + `"`
+			path = strings.TrimSuffix(strings.TrimPrefix(path, `"" + `), ` + ""`)
+		}
+
+		var result, query, header, body string = "", "nil", "nil", "nil"
+		switch op.Kind {
+		case sdkGet:
+			result = "*" + op.Object.Name
+			if len(op.Object.Doc.Expands) > 0 {
+				params = append(params, "expand ...string")
+				query = `url.Values{"expand": expand}`
+			}
+		case sdkCreate:
+			params = append(params, "val *"+op.Object.Name)
+			result, body = "*"+op.Object.Name, "val"
+		case sdkEdit:
+			params = append(params, "vals *"+op.Object.Name+"Edit")
+			body = "vals"
+			if op.Object.Doc.Version != "" {
+				params = append(params, "ifMatch string")
+				header = `http.Header{"If-Match": {ifMatch}}`
+			}
+		case sdkDelete:
+		case sdkQuery:
+			params = append(params, "form *QueryForm")
+			result, body = "*QueryResult["+op.Object.Name+"]", "form"
+			if op.Method == http.MethodGet {
+				query, body = "queryValues(form)", "nil"
+			}
+		case sdkEndpoint:
+			if op.Response != nil {
+				result = gen.typeOf(name+"Response", op.Response, false)
+				if len(op.Response.Fields) > 0 && !op.Response.IsArray {
+					result = "*" + result
+				}
+			}
+			if op.Request != nil {
+				params = append(params, "req "+gen.typeOf(name+"Request", op.Request, false))
+				if op.Method == http.MethodGet || op.Method == http.MethodDelete {
+					query = "queryValues(req)"
+				} else {
+					body = "req"
+				}
+			}
+		}
+
+		methods.WriteString("\n")
+		if op.Desc != "" {
+			methods.WriteString(commentLines(name+" "+lowerFirst(op.Desc), "// "))
+		}
+		if result == "" {
+			fmt.Fprintf(&methods, "func (c *Client) %s(%s) error {\n", name, strings.Join(params, ", "))
+			fmt.Fprintf(&methods, "\treturn c.Do(ctx, %q, %s, %s, %s, %s, nil)\n}\n", op.Method, path, query, header, body)
+			continue
+		}
+		fmt.Fprintf(&methods, "func (c *Client) %s(%s) (%s, error) {\n", name, strings.Join(params, ", "), result)
+		if strings.HasPrefix(result, "*") {
+			fmt.Fprintf(&methods, "\tvar out %s\n", result[1:])
+			fmt.Fprintf(&methods, "\tif err := c.Do(ctx, %q, %s, %s, %s, %s, &out); err != nil {\n\t\treturn nil, err\n\t}\n\treturn &out, nil\n}\n",
+				op.Method, path, query, header, body)
+		} else {
+			fmt.Fprintf(&methods, "\tvar out %s\n", result)
+			fmt.Fprintf(&methods, "\terr := c.Do(ctx, %q, %s, %s, %s, %s, &out)\n\treturn out, err\n}\n",
+				op.Method, path, query, header, body)
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "// Code generated by %s. DO NOT EDIT.\n\n", g.Generator)
+	fmt.Fprintf(&sb, "package %s\n\n", g.PackageName)
+	sb.WriteString("import (\n\t\"bytes\"\n\t\"context\"\n\t\"encoding/json\"\n\t\"fmt\"\n\t\"io\"\n\t\"net/http\"\n\t\"net/url\"\n\t\"strings\"\n")
+	if gen.usesTime {
+		sb.WriteString("\t\"time\"\n")
+	}
+	sb.WriteString(")\n")
+	sb.WriteString(goRuntime)
+	sb.WriteString(types.String())
+	sb.WriteString(gen.nested.String())
+	sb.WriteString(methods.String())
+	return format.Source([]byte(sb.String()))
+}
+
+// goTypes converts DocField to Go types, nested objects are declared as named structs
+type goTypes struct {
+	nested   strings.Builder
+	declared map[string]bool
+	usesTime bool
+}
+
+func (g *goTypes) fields(parent string, fields []DocField, optional bool) string {
+	var sb strings.Builder
+	for _, f := range fields {
+		name := goName(f.Name)
+		t := g.typeOf(parent+name, &f, optional)
+		tag := f.Name
+		if f.CanNull || optional {
+			tag += ",omitempty"
+		}
+		if f.Desc != "" {
+			sb.WriteString("\t// " + f.Desc + "\n")
+		}
+		fmt.Fprintf(&sb, "\t%s %s `json:%q`\n", name, t, tag)
+	}
+	return sb.String()
+}
+
+// typeOf returns the Go type of the field, optional fields are pointers
+func (g *goTypes) typeOf(name string, f *DocField, optional bool) string {
+	var t string
+	if len(f.Fields) > 0 {
+		t = name
+		for i := 2; g.declared[t]; i++ {
+			t = fmt.Sprintf("%s%d", name, i)
+		}
+		g.declared[t] = true
+		fmt.Fprintf(&g.nested, "\ntype %s struct {\n%s}\n", t, g.fields(t, f.Fields, false))
+	} else {
+		switch jsonType(f.Type) {
+		case "string":
+			t = "string"
+			if f.Type == TypeDate {
+				g.usesTime = true
+				t = "time.Time"
+			}
+		case "integer":
+			t = "int64"
+		case "number":
+			t = "float64"
+		case "boolean":
+			t = "bool"
+		case "object":
+			t = "map[string]any"
+		default:
+			t = "any"
+		}
+	}
+	if f.IsArray {
+		if f.Type == "uint8" {
+			return "[]byte"
+		}
+		return "[]" + t
+	}
+	if t == "any" || strings.HasPrefix(t, "map[") {
+		return t
+	}
+	if optional || (f.CanNull && t == "time.Time") {
+		return "*" + t
+	}
+	return t
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
+
+var goInitialisms = map[string]bool{
+	"API": true, "HTML": true, "HTTP": true, "ID": true, "IP": true, "JSON": true,
+	"SQL": true, "UID": true, "URI": true, "URL": true, "UUID": true,
+}
+
+// goName converts the json name to an exported Go name, such as "userId" => "UserID"
+func goName(name string) string {
+	var sb strings.Builder
+	for _, w := range splitWords(schemaName(name)) {
+		if goInitialisms[strings.ToUpper(w)] {
+			sb.WriteString(strings.ToUpper(w))
+		} else {
+			sb.WriteString(w)
+		}
+	}
+	return sb.String()
+}
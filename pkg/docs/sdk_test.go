@@ -0,0 +1,113 @@
+package docs
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testSDKDocs() ([]UriDoc, []WebObjectDoc) {
+	group := testObjectDoc()
+	group.Path = "/api/group"
+	group.AllowMethods = []string{"GET", "QUERY"}
+	group.Fields = []DocField{
+		{Name: "name", Type: TypeString, IsPrimary: true},
+		{Name: "profile", Type: TypeObject, Fields: []DocField{{Name: "bio", Type: TypeString}}},
+		{Name: "settings", Type: TypeMap},
+		{Name: "avatar", Type: "uint8", IsArray: true},
+	}
+	group.Expands = []DocField{{Name: "owner"}}
+	group.Views = nil
+
+	uriDocs := []UriDoc{
+		{Path: "/api/auth/logout", Method: http.MethodGet, Desc: "User logout"},
+		{Path: "/api/search", Method: http.MethodPost, Desc: "Execute a search query",
+			Request: &DocField{Type: TypeObject, Fields: []DocField{{Name: "keyword", Type: TypeString}}},
+			Response: &DocField{Type: TypeObject, Fields: []DocField{
+				{Name: "total", Type: TypeInt},
+				{Name: "hits", Type: TypeObject, IsArray: true, Fields: []DocField{{Name: "id", Type: TypeString}}},
+			}}},
+		{Path: "/api/files/:name", Method: http.MethodDelete,
+			Request:  &DocField{Type: TypeObject, Fields: []DocField{{Name: "force", Type: TypeBoolean}}},
+			Response: &DocField{Type: TypeBoolean}},
+	}
+	return uriDocs, []WebObjectDoc{testObjectDoc(), group}
+}
+
+func TestSDKGenerator_TypeScript(t *testing.T) {
+	uriDocs, objDocs := testSDKDocs()
+	code := string(NewSDKGenerator().GenerateTypeScript(uriDocs, objDocs))
+
+	assert.Contains(t, code, "// Code generated by sdkgen. DO NOT EDIT.")
+	assert.Contains(t, code, "export interface User {\n  id: number;\n  email: string;\n  tags: string[];\n  lastLogin?: string;")
+	assert.Contains(t, code, "export interface UserEdit {\n  email?: string;\n}")
+	assert.Contains(t, code, "export type UserFilterField = 'email' | 'lastLogin';")
+	assert.Contains(t, code, "export type UserQueryForm = QueryForm<UserFilterField, UserOrderField>;")
+	assert.Contains(t, code, "  getUser(id: number | string): Promise<User> {\n    return this.request('GET', `/api/user/${encodeURIComponent(String(id))}`);")
+	assert.Contains(t, code, "editUser(id: number | string, body: UserEdit, ifMatch?: string): Promise<boolean>")
+	assert.Contains(t, code, "queryUser(form: UserQueryForm = {}): Promise<QueryResult<User>>")
+	assert.Contains(t, code, "queryUserActive(form: UserQueryForm = {})")
+	assert.Contains(t, code, "getGroup(name: string, expand?: string[]): Promise<Group>")
+	assert.Contains(t, code, "  profile: {\n    bio: string;\n  };")
+	assert.Contains(t, code, "  avatar: string;")
+	assert.Contains(t, code, "postApiSearch(body: PostApiSearchRequest): Promise<PostApiSearchResponse>")
+	assert.Contains(t, code, "  hits: Array<{\n    id: string;\n  }>;")
+	assert.Contains(t, code, "deleteApiFilesName(name: string, query?: DeleteApiFilesNameRequest): Promise<boolean>")
+	assert.Contains(t, code, "getApiAuthLogout(): Promise<unknown>")
+}
+
+func TestSDKGenerator_Go(t *testing.T) {
+	uriDocs, objDocs := testSDKDocs()
+	code, err := NewSDKGenerator().GenerateGo(uriDocs, objDocs)
+	require.NoError(t, err)
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "client.go", code, parser.ParseComments)
+	require.NoError(t, err, string(code))
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	pkg, err := conf.Check("client", fset, []*ast.File{file}, nil)
+	require.NoError(t, err, string(code))
+
+	for _, name := range []string{"User", "UserEdit", "Group", "GroupProfile", "PostAPISearchRequest", "PostAPISearchResponseHits", "Client"} {
+		assert.NotNil(t, pkg.Scope().Lookup(name), name)
+	}
+	client := pkg.Scope().Lookup("Client").Type()
+	methods := map[string]string{
+		"GetUser":            "func(ctx context.Context, id int64) (*client.User, error)",
+		"EditUser":           "func(ctx context.Context, id int64, vals *client.UserEdit, ifMatch string) error",
+		"QueryUser":          "func(ctx context.Context, form *client.QueryForm) (*client.QueryResult[client.User], error)",
+		"GetGroup":           "func(ctx context.Context, name string, expand ...string) (*client.Group, error)",
+		"DeleteAPIFilesName": "func(ctx context.Context, name string, req client.DeleteAPIFilesNameRequest) (bool, error)",
+		"GetAPIAuthLogout":   "func(ctx context.Context) error",
+	}
+	for name, sig := range methods {
+		obj, _, _ := types.LookupFieldOrMethod(types.NewPointer(client), true, pkg, name)
+		require.NotNil(t, obj, name)
+		assert.Equal(t, sig, obj.Type().String())
+	}
+	assert.Contains(t, string(code), "\tLastLogin *time.Time `json:\"lastLogin,omitempty\"`")
+	assert.Contains(t, string(code), "\tUserFilterLastLogin = \"lastLogin\"")
+}
+
+func TestSDKGenerator_Deterministic(t *testing.T) {
+	uriDocs, objDocs := testSDKDocs()
+	g := NewSDKGenerator()
+	ts := g.GenerateTypeScript(uriDocs, objDocs)
+	code, err := g.GenerateGo(uriDocs, objDocs)
+	require.NoError(t, err)
+
+	// the order of the docs doesn't matter
+	uriDocs[0], uriDocs[2] = uriDocs[2], uriDocs[0]
+	objDocs[0], objDocs[1] = objDocs[1], objDocs[0]
+	assert.Equal(t, string(ts), string(g.GenerateTypeScript(uriDocs, objDocs)))
+	again, err := g.GenerateGo(uriDocs, objDocs)
+	require.NoError(t, err)
+	assert.Equal(t, string(code), string(again))
+}
@@ -0,0 +1,307 @@
+package docs
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var tsIdentifier = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+const tsRuntime = `export type FilterOp =
+  | '='
+  | '<>'
+  | 'is not'
+  | 'in'
+  | 'not_in'
+  | '>'
+  | '>='
+  | '<'
+  | '<='
+  | 'like'
+  | 'not_like'
+  | 'starts_with'
+  | 'ends_with'
+  | 'between'
+  | 'is_null'
+  | 'not_null'
+  | 'and'
+  | 'or'
+  | 'not';
+
+/** A condition on the field name, or a group of child filters when op is and/or/not. */
+export interface Filter<N extends string = string> {
+  name?: N;
+  op: FilterOp;
+  value?: unknown;
+  filters?: Filter<N>[];
+}
+
+export interface Order<N extends string = string> {
+  name: N;
+  op?: 'asc' | 'desc';
+}
+
+export interface QueryForm<F extends string = string, O extends string = string> {
+  pos?: number;
+  limit?: number;
+  keyword?: string;
+  filters?: Filter<F>[];
+  orders?: Order<O>[];
+  cursor?: string;
+  cursorMode?: boolean;
+  skipCount?: boolean;
+  expand?: string[];
+}
+
+export interface QueryResult<T> {
+  total?: number;
+  pos?: number;
+  limit?: number;
+  keyword?: string;
+  items: T[] | null;
+  nextCursor?: string;
+  prevCursor?: string;
+}
+
+export class ApiError extends Error {
+  constructor(public code: number, message: string, public data?: unknown) {
+    super(message);
+    this.name = 'ApiError';
+  }
+}
+
+export interface ClientOptions {
+  baseURL?: string;
+  /** Sent as "Authorization: Bearer {token}", the session cookie is sent as well. */
+  token?: string;
+  headers?: Record<string, string>;
+  fetch?: typeof fetch;
+}
+
+export interface RequestOptions {
+  query?: object;
+  body?: unknown;
+  headers?: Record<string, string>;
+}
+
+export class BaseClient {
+  constructor(public options: ClientOptions = {}) {}
+
+  /** Send the request, the data of the {code, msg, data} response is returned. */
+  async request<T>(method: string, path: string, opts: RequestOptions = {}): Promise<T> {
+    let url = (this.options.baseURL ?? '') + path;
+    const params = new URLSearchParams();
+    for (const [k, v] of Object.entries(opts.query ?? {})) {
+      if (v === undefined || v === null) continue;
+      for (const item of Array.isArray(v) ? v : [v]) params.append(k, String(item));
+    }
+    const qs = params.toString();
+    if (qs) url += (url.includes('?') ? '&' : '?') + qs;
+
+    const headers: Record<string, string> = { ...this.options.headers, ...opts.headers };
+    if (opts.body !== undefined) headers['Content-Type'] = 'application/json';
+    if (this.options.token) headers['Authorization'] = ` + "`Bearer ${this.options.token}`" + `;
+
+    const res = await (this.options.fetch ?? fetch)(url, {
+      method,
+      headers,
+      body: opts.body === undefined ? undefined : JSON.stringify(opts.body),
+      credentials: 'include',
+    });
+    const text = await res.text();
+    let payload: any = text;
+    try {
+      payload = text ? JSON.parse(text) : undefined;
+    } catch {
+      // not json
+    }
+    if (payload && typeof payload === 'object' && typeof payload.code === 'number' && 'msg' in payload) {
+      if (payload.code !== 200) throw new ApiError(payload.code, payload.msg, payload.data);
+      return payload.data as T;
+    }
+    if (!res.ok) throw new ApiError(res.status, res.statusText, payload);
+    return payload as T;
+  }
+}
+`
+
+// GenerateTypeScript returns the TypeScript client, a single module based on fetch
+func (g *SDKGenerator) GenerateTypeScript(uriDocs []UriDoc, objDocs []WebObjectDoc) []byte {
+	m := buildSDKModel(uriDocs, objDocs)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "// Code generated by %s. DO NOT EDIT.\n\n", g.Generator)
+	sb.WriteString("/* eslint-disable */\n\n")
+	sb.WriteString(tsRuntime)
+
+	for _, obj := range m.Objects {
+		sb.WriteString("\n")
+		if obj.Doc.Desc != "" {
+			sb.WriteString("/** " + obj.Doc.Desc + " */\n")
+		}
+		fmt.Fprintf(&sb, "export interface %s {\n%s}\n\n", obj.Name, tsFields(obj.Doc.Fields, "  "))
+		if obj.hasMethod("EDIT") {
+			fmt.Fprintf(&sb, "export interface %sEdit {\n", obj.Name)
+			for _, f := range obj.Editables {
+				sb.WriteString(tsField(f, "  ", true))
+			}
+			sb.WriteString("}\n\n")
+		}
+		fmt.Fprintf(&sb, "export type %sFilterField = %s;\n\n", obj.Name, tsUnion(obj.Filters))
+		fmt.Fprintf(&sb, "export type %sOrderField = %s;\n\n", obj.Name, tsUnion(obj.Orders))
+		fmt.Fprintf(&sb, "export type %sQueryForm = QueryForm<%sFilterField, %sOrderField>;\n", obj.Name, obj.Name, obj.Name)
+	}
+
+	var methods strings.Builder
+	for _, op := range m.Operations {
+		typeName := schemaName(op.Name)
+		var params []string
+		for _, p := range op.PathParams {
+			params = append(params, fmt.Sprintf("%s: %s", identifier(p.Name), tsPathType(&p)))
+		}
+		path := "`" + pathTemplate(op.Path, func(param string) string {
+			return "${encodeURIComponent(String(" + identifier(param) + "))}"
+		}) + "`"
+		if len(op.PathParams) == 0 {
+			path = "'" + op.Path + "'"
+		}
+
+		var result, opts string
+		switch op.Kind {
+		case sdkGet:
+			result = op.Object.Name
+			if len(op.Object.Doc.Expands) > 0 {
+				params = append(params, "expand?: string[]")
+				opts = ", { query: { expand } }"
+			}
+		case sdkCreate:
+			params = append(params, "body: Partial<"+op.Object.Name+">")
+			result, opts = op.Object.Name, ", { body }"
+		case sdkEdit:
+			params = append(params, "body: "+op.Object.Name+"Edit")
+			result, opts = "boolean", ", { body }"
+			if op.Object.Doc.Version != "" {
+				params = append(params, "ifMatch?: string")
+				opts = ", { body, headers: ifMatch ? { 'If-Match': ifMatch } : undefined }"
+			}
+		case sdkDelete:
+			result = "boolean"
+		case sdkQuery:
+			params = append(params, "form: "+op.Object.Name+"QueryForm = {}")
+			result, opts = "QueryResult<"+op.Object.Name+">", ", { body: form }"
+			if op.Method == http.MethodGet {
+				opts = ", { query: form }"
+			}
+		case sdkEndpoint:
+			result = "unknown"
+			if op.Response != nil {
+				result = tsType(op.Response, "")
+				if len(op.Response.Fields) > 0 && !op.Response.IsArray {
+					fmt.Fprintf(&sb, "\nexport interface %sResponse {\n%s}\n", typeName, tsFields(op.Response.Fields, "  "))
+					result = typeName + "Response"
+				}
+			}
+			if op.Request != nil {
+				reqType := tsType(op.Request, "")
+				if len(op.Request.Fields) > 0 && !op.Request.IsArray {
+					fmt.Fprintf(&sb, "\nexport interface %sRequest {\n%s}\n", typeName, tsFields(op.Request.Fields, "  "))
+					reqType = typeName + "Request"
+				}
+				if op.Method == http.MethodGet || op.Method == http.MethodDelete {
+					params = append(params, "query?: "+reqType)
+					opts = ", { query }"
+				} else {
+					params = append(params, "body: "+reqType)
+					opts = ", { body }"
+				}
+			}
+		}
+
+		methods.WriteString("\n")
+		if op.Desc != "" {
+			methods.WriteString("  /**\n" + commentLines(op.Desc, "   * ") + "   */\n")
+		}
+		fmt.Fprintf(&methods, "  %s(%s): Promise<%s> {\n", op.Name, strings.Join(params, ", "), result)
+		fmt.Fprintf(&methods, "    return this.request('%s', %s%s);\n  }\n", op.Method, path, opts)
+	}
+
+	sb.WriteString("\nexport class Client extends BaseClient {")
+	sb.WriteString(methods.String())
+	sb.WriteString("}\n")
+	return []byte(sb.String())
+}
+
+func tsFields(fields []DocField, indent string) string {
+	var sb strings.Builder
+	for _, f := range fields {
+		sb.WriteString(tsField(f, indent, f.CanNull))
+	}
+	return sb.String()
+}
+
+func tsField(f DocField, indent string, optional bool) string {
+	var sb strings.Builder
+	if f.Desc != "" {
+		sb.WriteString(indent + "/** " + f.Desc + " */\n")
+	}
+	name := f.Name
+	if !tsIdentifier.MatchString(name) {
+		name = strconv.Quote(name)
+	}
+	if optional {
+		name += "?"
+	}
+	sb.WriteString(indent + name + ": " + tsType(&f, indent) + ";\n")
+	return sb.String()
+}
+
+// tsType returns the TypeScript type of the field, nested objects are inlined
+func tsType(f *DocField, indent string) string {
+	var t string
+	if len(f.Fields) > 0 {
+		t = "{\n" + tsFields(f.Fields, indent+"  ") + indent + "}"
+	} else {
+		switch jsonType(f.Type) {
+		case "string":
+			t = "string"
+		case "integer", "number":
+			t = "number"
+		case "boolean":
+			t = "boolean"
+		case "object":
+			t = "Record<string, unknown>"
+		default:
+			t = "unknown"
+		}
+	}
+	if f.IsArray {
+		if f.Type == "uint8" {
+			return "string" // []byte is base64 in JSON
+		}
+		if tsIdentifier.MatchString(t) {
+			return t + "[]"
+		}
+		return "Array<" + t + ">"
+	}
+	return t
+}
+
+func tsPathType(f *DocField) string {
+	if jsonType(f.Type) == "integer" {
+		return "number | string"
+	}
+	return "string"
+}
+
+func tsUnion(names []string) string {
+	if len(names) == 0 {
+		return "never"
+	}
+	var quoted []string
+	for _, v := range names {
+		quoted = append(quoted, "'"+v+"'")
+	}
+	return strings.Join(quoted, " | ")
+}
@@ -0,0 +1,113 @@
+package graphql
+
+// Document is a parsed GraphQL request document.
+type Document struct {
+	Operations []*Operation
+	Fragments  map[string]*Fragment
+}
+
+// Operation is a query or a mutation.
+type Operation struct {
+	Type         string // "query" or "mutation"
+	Name         string
+	Variables    []*VariableDefinition
+	Directives   []*Directive
+	SelectionSet []Selection
+}
+
+// VariableDefinition is a variable declared by the operation, such as "$id: Int! = 1".
+type VariableDefinition struct {
+	Name    string
+	Type    *TypeRef
+	Default *Value
+}
+
+// TypeRef is a type in the variable definitions, such as "[String!]!".
+type TypeRef struct {
+	Name    string   // the named type, empty for lists
+	Elem    *TypeRef // the element of a list
+	NonNull bool
+}
+
+// Selection is one of *Field, *FragmentSpread and *InlineFragment.
+type Selection interface {
+	isSelection()
+}
+
+// Field is a field in a selection set, such as "alias: name(arg: 1) { ... }".
+type Field struct {
+	Alias        string
+	Name         string
+	Arguments    []*Argument
+	Directives   []*Directive
+	SelectionSet []Selection
+}
+
+// ResponseKey return the key of the field in the result, the alias if set.
+func (f *Field) ResponseKey() string {
+	if f.Alias != "" {
+		return f.Alias
+	}
+	return f.Name
+}
+
+// FragmentSpread is "...Name".
+type FragmentSpread struct {
+	Name       string
+	Directives []*Directive
+}
+
+// InlineFragment is "... on Type { ... }", TypeCondition is empty without "on".
+type InlineFragment struct {
+	TypeCondition string
+	Directives    []*Directive
+	SelectionSet  []Selection
+}
+
+// Fragment is a named fragment definition.
+type Fragment struct {
+	Name          string
+	TypeCondition string
+	Directives    []*Directive
+	SelectionSet  []Selection
+}
+
+func (*Field) isSelection()          {}
+func (*FragmentSpread) isSelection() {}
+func (*InlineFragment) isSelection() {}
+
+// Argument is a named value of fields, directives and input objects.
+type Argument struct {
+	Name  string
+	Value *Value
+}
+
+// Directive is such as "@include(if: $flag)".
+type Directive struct {
+	Name      string
+	Arguments []*Argument
+}
+
+// ValueKind is the kind of a literal value.
+type ValueKind int
+
+const (
+	ValueVariable ValueKind = iota
+	ValueInt
+	ValueFloat
+	ValueString
+	ValueBoolean
+	ValueNull
+	ValueEnum
+	ValueList
+	ValueObject
+)
+
+// Value is a literal value or a variable reference.
+// Raw is the variable name, the number, the string, "true"/"false" or the enum name.
+type Value struct {
+	Kind   ValueKind
+	Raw    string
+	List   []*Value
+	Fields []*Argument
+}
@@ -0,0 +1,660 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// Request is the GraphQL request, in the body of POST.
+type Request struct {
+	Query         string         `json:"query" form:"query"`
+	OperationName string         `json:"operationName,omitempty" form:"operationName"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
+
+// Error is an error in the result, Path is the response keys to the field failed.
+type Error struct {
+	Message string `json:"message"`
+	Path    []any  `json:"path,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Result is the response of a request, Data is nil if the request failed before the execution.
+type Result struct {
+	Data   any      `json:"data,omitempty"`
+	Errors []*Error `json:"errors,omitempty"`
+}
+
+// Object is an object in Result.Data, the fields are in the order selected.
+type Object struct {
+	keys   []string
+	values map[string]any
+}
+
+// Get return the value of the field by its response key.
+func (o *Object) Get(key string) any {
+	return o.values[key]
+}
+
+// Keys return the response keys in the order selected.
+func (o *Object) Keys() []string {
+	return o.keys
+}
+
+func (o *Object) set(key string, v any) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = v
+}
+
+func (o *Object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, _ := json.Marshal(key)
+		buf.Write(k)
+		buf.WriteByte(':')
+		v, err := json.Marshal(o.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Operation return the operation to execute, by its name if there are many.
+func (d *Document) Operation(name string) (*Operation, error) {
+	if name == "" {
+		if len(d.Operations) != 1 {
+			return nil, fmt.Errorf("operation name required")
+		}
+		return d.Operations[0], nil
+	}
+	for _, op := range d.Operations {
+		if op.Name == name {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown operation %s", name)
+}
+
+// Execute parse and execute the request.
+func (s *Schema) Execute(ctx context.Context, req Request) *Result {
+	doc, err := Parse(req.Query)
+	if err != nil {
+		return &Result{Errors: []*Error{{Message: err.Error()}}}
+	}
+	return s.ExecuteDocument(ctx, doc, req.OperationName, req.Variables)
+}
+
+// ExecuteDocument execute an operation of the parsed document.
+// The fields are resolved one by one, the errors are reported in the result
+// with the fields nulled.
+func (s *Schema) ExecuteDocument(ctx context.Context, doc *Document, operationName string, variables map[string]any) *Result {
+	fail := func(err error) *Result {
+		return &Result{Errors: []*Error{{Message: err.Error()}}}
+	}
+	op, err := doc.Operation(operationName)
+	if err != nil {
+		return fail(err)
+	}
+
+	root := s.Query
+	switch op.Type {
+	case "mutation":
+		if s.Mutation == nil {
+			return fail(fmt.Errorf("mutations are not supported"))
+		}
+		root = s.Mutation
+	case "subscription":
+		return fail(fmt.Errorf("subscriptions are not supported"))
+	}
+
+	if err := s.checkLimits(doc, op); err != nil {
+		return fail(err)
+	}
+	ex := &executor{ctx: ctx, schema: s, doc: doc}
+	if ex.vars, err = ex.coerceVariables(op.Variables, variables); err != nil {
+		return fail(err)
+	}
+	data, _ := ex.selectionSet(root, nil, op.SelectionSet, nil)
+	result := &Result{Errors: ex.errors}
+	if data != nil {
+		result.Data = data
+	}
+	return result
+}
+
+type executor struct {
+	ctx    context.Context
+	schema *Schema
+	doc    *Document
+	vars   map[string]any
+	errors []*Error
+}
+
+func (ex *executor) addError(path []any, format string, args ...any) {
+	ex.errors = append(ex.errors, &Error{Message: fmt.Sprintf(format, args...), Path: path})
+}
+
+func appendPath(path []any, key any) []any {
+	result := make([]any, len(path), len(path)+1)
+	copy(result, path)
+	return append(result, key)
+}
+
+// fieldGroup is the fields by the response keys, in the order selected.
+type fieldGroup struct {
+	keys  []string
+	nodes map[string][]*Field
+}
+
+func (ex *executor) collectFields(t *Type, sels []Selection, visited map[string]bool, g *fieldGroup) {
+	for _, sel := range sels {
+		switch s := sel.(type) {
+		case *Field:
+			if !ex.included(s.Directives) {
+				continue
+			}
+			key := s.ResponseKey()
+			if _, ok := g.nodes[key]; !ok {
+				g.keys = append(g.keys, key)
+			}
+			g.nodes[key] = append(g.nodes[key], s)
+		case *FragmentSpread:
+			if !ex.included(s.Directives) || visited[s.Name] {
+				continue
+			}
+			visited[s.Name] = true
+			frag, ok := ex.doc.Fragments[s.Name]
+			if !ok {
+				ex.addError(nil, "unknown fragment %s", s.Name)
+				continue
+			}
+			if frag.TypeCondition == t.Name {
+				ex.collectFields(t, frag.SelectionSet, visited, g)
+			}
+		case *InlineFragment:
+			if !ex.included(s.Directives) {
+				continue
+			}
+			if s.TypeCondition == "" || s.TypeCondition == t.Name {
+				ex.collectFields(t, s.SelectionSet, visited, g)
+			}
+		}
+	}
+}
+
+// included apply @skip and @include.
+func (ex *executor) included(directives []*Directive) bool {
+	for _, d := range directives {
+		if d.Name != "skip" && d.Name != "include" {
+			continue
+		}
+		args, err := ex.coerceArgs(directiveArgs, d.Arguments)
+		if err != nil {
+			ex.addError(nil, "@%s: %v", d.Name, err)
+			return false
+		}
+		if args["if"] == (d.Name == "skip") {
+			return false
+		}
+	}
+	return true
+}
+
+func (ex *executor) subSelections(nodes []*Field) []Selection {
+	var sels []Selection
+	for _, node := range nodes {
+		sels = append(sels, node.SelectionSet...)
+	}
+	return sels
+}
+
+// selected return the fields selected of the object type t.
+func (ex *executor) selected(t *Type, sels []Selection) []*SelectedField {
+	if t.Kind != KindObject || len(sels) == 0 {
+		return nil
+	}
+	g := &fieldGroup{nodes: map[string][]*Field{}}
+	ex.collectFields(t, sels, map[string]bool{}, g)
+
+	var result []*SelectedField
+	seen := map[string]bool{}
+	for _, key := range g.keys {
+		nodes := g.nodes[key]
+		name := nodes[0].Name
+		def := t.field(name)
+		if def == nil || seen[name] {
+			continue
+		}
+		seen[name] = true
+		result = append(result, &SelectedField{Name: name, Selected: ex.selected(def.Type.named(), ex.subSelections(nodes))})
+	}
+	return result
+}
+
+func (ex *executor) selectionSet(t *Type, source any, sels []Selection, path []any) (*Object, bool) {
+	g := &fieldGroup{nodes: map[string][]*Field{}}
+	ex.collectFields(t, sels, map[string]bool{}, g)
+
+	obj := &Object{values: map[string]any{}}
+	for _, key := range g.keys {
+		v, ok := ex.field(t, source, g.nodes[key], appendPath(path, key))
+		if !ok {
+			return nil, false
+		}
+		obj.set(key, v)
+	}
+	return obj, true
+}
+
+func (ex *executor) field(parent *Type, source any, nodes []*Field, path []any) (any, bool) {
+	node := nodes[0]
+	var def *FieldDef
+	switch {
+	case node.Name == "__typename":
+		return parent.Name, true
+	case parent == ex.schema.Query && node.Name == "__schema":
+		def, source = schemaMetaField, ex.schema
+	case parent == ex.schema.Query && node.Name == "__type":
+		def, source = typeMetaField, ex.schema
+	default:
+		def = parent.field(node.Name)
+	}
+	if def == nil {
+		ex.addError(path, "unknown field %s on type %s", node.Name, parent.Name)
+		return nil, true
+	}
+
+	args, err := ex.coerceArgs(def.Args, node.Arguments)
+	if err != nil {
+		ex.addError(path, "%v", err)
+		return nil, def.Type.Kind != KindNonNull
+	}
+	resolve := def.Resolve
+	if resolve == nil {
+		resolve = defaultResolve
+	}
+	sels := ex.subSelections(nodes)
+	v, err := resolve(ResolveParams{
+		Context: ex.ctx,
+		Source:  source,
+		Args:    args,
+		Info: ResolveInfo{
+			FieldName:  node.Name,
+			ParentType: parent,
+			Path:       path,
+			Selected:   ex.selected(def.Type.named(), sels),
+		},
+	})
+	if err != nil {
+		ex.addError(path, "%v", err)
+		return nil, def.Type.Kind != KindNonNull
+	}
+	return ex.completeValue(def.Type, sels, v, path)
+}
+
+// defaultResolve read the field of the map source.
+func defaultResolve(p ResolveParams) (any, error) {
+	if m, ok := p.Source.(map[string]any); ok {
+		return m[p.Info.FieldName], nil
+	}
+	return nil, nil
+}
+
+// completeValue is complete, with the failures of the nullable types nulled.
+func (ex *executor) completeValue(t *Type, sels []Selection, v any, path []any) (any, bool) {
+	r, ok := ex.complete(t, sels, v, path)
+	if !ok && t.Kind != KindNonNull {
+		return nil, true
+	}
+	return r, ok
+}
+
+func isNil(v any) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		return rv.IsNil()
+	}
+	return false
+}
+
+// complete convert the resolved value to the type, false if it must be nulled
+// because of an error.
+func (ex *executor) complete(t *Type, sels []Selection, v any, path []any) (any, bool) {
+	if t.Kind == KindNonNull {
+		r, ok := ex.complete(t.OfType, sels, v, path)
+		if !ok {
+			return nil, false
+		}
+		if r == nil {
+			ex.addError(path, "cannot return null for non-nullable %s", t)
+			return nil, false
+		}
+		return r, true
+	}
+	if isNil(v) {
+		return nil, true
+	}
+
+	switch t.Kind {
+	case KindList:
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			ex.addError(path, "expected a list for %s", t)
+			return nil, false
+		}
+		items := make([]any, rv.Len())
+		for i := range items {
+			item, ok := ex.completeValue(t.OfType, sels, rv.Index(i).Interface(), appendPath(path, i))
+			if !ok {
+				return nil, false
+			}
+			items[i] = item
+		}
+		return items, true
+	case KindScalar:
+		r, err := t.Serialize(v)
+		if err != nil {
+			ex.addError(path, "%v", err)
+			return nil, false
+		}
+		return r, true
+	case KindEnum:
+		for _, ev := range t.EnumValues {
+			if reflect.DeepEqual(ev.Value, v) {
+				return ev.Name, true
+			}
+		}
+		ex.addError(path, "%s cannot represent %v", t.Name, v)
+		return nil, false
+	case KindObject:
+		obj, ok := ex.selectionSet(t, v, sels, path)
+		if !ok {
+			return nil, false
+		}
+		return obj, true
+	}
+	ex.addError(path, "invalid output type %s", t)
+	return nil, false
+}
+
+// coerceVariables check and convert the values of the variables, the missing
+// ones without defaults are left out.
+func (ex *executor) coerceVariables(defs []*VariableDefinition, values map[string]any) (map[string]any, error) {
+	result := map[string]any{}
+	for _, def := range defs {
+		t, err := ex.typeOf(def.Type)
+		if err != nil {
+			return nil, fmt.Errorf("variable $%s: %v", def.Name, err)
+		}
+		if !isInputType(t) {
+			return nil, fmt.Errorf("variable $%s: %s is not an input type", def.Name, t)
+		}
+		v, ok := values[def.Name]
+		if !ok {
+			if def.Default != nil {
+				if result[def.Name], err = ex.coerceLiteral(t, def.Default); err != nil {
+					return nil, fmt.Errorf("variable $%s: %v", def.Name, err)
+				}
+			} else if t.Kind == KindNonNull {
+				return nil, fmt.Errorf("variable $%s of type %s is required", def.Name, t)
+			}
+			continue
+		}
+		if result[def.Name], err = coerceValue(t, v); err != nil {
+			return nil, fmt.Errorf("variable $%s: %v", def.Name, err)
+		}
+	}
+	return result, nil
+}
+
+func (ex *executor) typeOf(ref *TypeRef) (*Type, error) {
+	var t *Type
+	if ref.Elem != nil {
+		elem, err := ex.typeOf(ref.Elem)
+		if err != nil {
+			return nil, err
+		}
+		t = NewList(elem)
+	} else if t = ex.schema.Type(ref.Name); t == nil {
+		return nil, fmt.Errorf("unknown type %s", ref.Name)
+	}
+	if ref.NonNull {
+		t = NewNonNull(t)
+	}
+	return t, nil
+}
+
+func findArgument(args []*Argument, name string) *Argument {
+	for _, arg := range args {
+		if arg.Name == name {
+			return arg
+		}
+	}
+	return nil
+}
+
+func findInputValue(defs []*InputValue, name string) *InputValue {
+	for _, def := range defs {
+		if def.Name == name {
+			return def
+		}
+	}
+	return nil
+}
+
+// coerceArgs convert the arguments by the definitions, with the defaults.
+// It's also used for the fields of the input objects.
+func (ex *executor) coerceArgs(defs []*InputValue, args []*Argument) (map[string]any, error) {
+	for _, arg := range args {
+		if findInputValue(defs, arg.Name) == nil {
+			return nil, fmt.Errorf("unknown argument %s", arg.Name)
+		}
+	}
+	result := map[string]any{}
+	for _, def := range defs {
+		arg := findArgument(args, def.Name)
+		if arg != nil && arg.Value.Kind == ValueVariable {
+			if _, ok := ex.vars[arg.Value.Raw]; !ok {
+				arg = nil
+			}
+		}
+		if arg == nil {
+			if def.DefaultValue != nil {
+				result[def.Name] = def.DefaultValue
+			} else if def.Type.Kind == KindNonNull {
+				return nil, fmt.Errorf("argument %s of type %s is required", def.Name, def.Type)
+			}
+			continue
+		}
+		v, err := ex.coerceLiteral(def.Type, arg.Value)
+		if err != nil {
+			return nil, fmt.Errorf("argument %s: %v", def.Name, err)
+		}
+		result[def.Name] = v
+	}
+	return result, nil
+}
+
+func (ex *executor) coerceLiteral(t *Type, v *Value) (any, error) {
+	if v.Kind == ValueVariable {
+		val := ex.vars[v.Raw]
+		if val == nil && t.Kind == KindNonNull {
+			return nil, fmt.Errorf("expected %s, found null", t)
+		}
+		return val, nil
+	}
+	if t.Kind == KindNonNull {
+		if v.Kind == ValueNull {
+			return nil, fmt.Errorf("expected %s, found null", t)
+		}
+		return ex.coerceLiteral(t.OfType, v)
+	}
+	if v.Kind == ValueNull {
+		return nil, nil
+	}
+
+	switch t.Kind {
+	case KindList:
+		if v.Kind != ValueList {
+			item, err := ex.coerceLiteral(t.OfType, v)
+			if err != nil {
+				return nil, err
+			}
+			return []any{item}, nil
+		}
+		items := make([]any, len(v.List))
+		for i, item := range v.List {
+			var err error
+			if items[i], err = ex.coerceLiteral(t.OfType, item); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	case KindInputObject:
+		if v.Kind != ValueObject {
+			return nil, fmt.Errorf("expected %s", t)
+		}
+		return ex.coerceArgs(t.InputFields, v.Fields)
+	case KindEnum:
+		if v.Kind != ValueEnum {
+			return nil, fmt.Errorf("expected %s", t)
+		}
+		ev := t.enumValue(v.Raw)
+		if ev == nil {
+			return nil, fmt.Errorf("%s has no value %s", t.Name, v.Raw)
+		}
+		return ev.Value, nil
+	}
+
+	if v.Kind == ValueEnum && t != JSON {
+		return nil, fmt.Errorf("expected %s, found %s", t, v.Raw)
+	}
+	lit, err := ex.literalValue(v)
+	if err != nil {
+		return nil, err
+	}
+	return t.ParseValue(lit)
+}
+
+// literalValue convert the literal to a Go value, the variables are replaced.
+func (ex *executor) literalValue(v *Value) (any, error) {
+	switch v.Kind {
+	case ValueVariable:
+		return ex.vars[v.Raw], nil
+	case ValueInt:
+		return strconv.ParseInt(v.Raw, 10, 64)
+	case ValueFloat:
+		return strconv.ParseFloat(v.Raw, 64)
+	case ValueString, ValueEnum:
+		return v.Raw, nil
+	case ValueBoolean:
+		return v.Raw == "true", nil
+	case ValueList:
+		items := make([]any, len(v.List))
+		for i, item := range v.List {
+			var err error
+			if items[i], err = ex.literalValue(item); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	case ValueObject:
+		m := make(map[string]any, len(v.Fields))
+		for _, f := range v.Fields {
+			var err error
+			if m[f.Name], err = ex.literalValue(f.Value); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	}
+	return nil, nil
+}
+
+// coerceValue convert the value of a variable, decoded from JSON.
+func coerceValue(t *Type, v any) (any, error) {
+	if t.Kind == KindNonNull {
+		if v == nil {
+			return nil, fmt.Errorf("expected %s, found null", t)
+		}
+		return coerceValue(t.OfType, v)
+	}
+	if v == nil {
+		return nil, nil
+	}
+
+	switch t.Kind {
+	case KindList:
+		items, ok := v.([]any)
+		if !ok {
+			item, err := coerceValue(t.OfType, v)
+			if err != nil {
+				return nil, err
+			}
+			return []any{item}, nil
+		}
+		result := make([]any, len(items))
+		for i, item := range items {
+			var err error
+			if result[i], err = coerceValue(t.OfType, item); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	case KindInputObject:
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("expected %s", t)
+		}
+		for k := range m {
+			if findInputValue(t.InputFields, k) == nil {
+				return nil, fmt.Errorf("unknown field %s of %s", k, t.Name)
+			}
+		}
+		result := map[string]any{}
+		for _, def := range t.InputFields {
+			fv, ok := m[def.Name]
+			if !ok {
+				if def.DefaultValue != nil {
+					result[def.Name] = def.DefaultValue
+				} else if def.Type.Kind == KindNonNull {
+					return nil, fmt.Errorf("field %s of type %s is required", def.Name, def.Type)
+				}
+				continue
+			}
+			var err error
+			if result[def.Name], err = coerceValue(def.Type, fv); err != nil {
+				return nil, fmt.Errorf("field %s: %v", def.Name, err)
+			}
+		}
+		return result, nil
+	case KindEnum:
+		name, _ := v.(string)
+		ev := t.enumValue(name)
+		if ev == nil {
+			return nil, fmt.Errorf("%s has no value %v", t.Name, v)
+		}
+		return ev.Value, nil
+	}
+	return t.ParseValue(v)
+}
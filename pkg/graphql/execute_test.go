@@ -0,0 +1,269 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSchema(t *testing.T) *Schema {
+	users := map[string]map[string]any{
+		"1": {"id": 1, "name": "alice", "friends": []any{"2"}},
+		"2": {"id": 2, "name": "bob", "friends": []any{}},
+	}
+	role := &Type{Kind: KindEnum, Name: "Role", EnumValues: []*EnumValue{
+		{Name: "ADMIN", Value: "admin"},
+		{Name: "MEMBER", Value: "member"},
+	}}
+	user := &Type{Kind: KindObject, Name: "User", Description: "A user"}
+	user.Fields = []*FieldDef{
+		{Name: "id", Type: NewNonNull(ID)},
+		{Name: "name", Type: String, Description: "The display name"},
+		{Name: "role", Type: role, Resolve: func(p ResolveParams) (any, error) { return "admin", nil }},
+		{Name: "friends", Type: NewList(NewNonNull(user)), Resolve: func(p ResolveParams) (any, error) {
+			var result []any
+			for _, id := range p.Source.(map[string]any)["friends"].([]any) {
+				result = append(result, users[id.(string)])
+			}
+			return result, nil
+		}},
+		{Name: "secret", Type: NewNonNull(String), Resolve: func(p ResolveParams) (any, error) {
+			return nil, errors.New("forbidden")
+		}},
+	}
+
+	query := &Type{Kind: KindObject, Name: "Query", Fields: []*FieldDef{
+		{Name: "user", Type: user, Args: []*InputValue{{Name: "id", Type: NewNonNull(ID)}},
+			Resolve: func(p ResolveParams) (any, error) {
+				if u, ok := users[p.Args["id"].(string)]; ok {
+					return u, nil
+				}
+				return nil, nil
+			}},
+		{Name: "echo", Type: JSON, Args: []*InputValue{
+			{Name: "value", Type: JSON},
+			{Name: "limit", Type: Int, DefaultValue: int64(10)},
+			{Name: "roles", Type: NewList(role)},
+		}, Resolve: func(p ResolveParams) (any, error) { return p.Args, nil }},
+	}}
+	mutation := &Type{Kind: KindObject, Name: "Mutation", Fields: []*FieldDef{
+		{Name: "rename", Type: user, Args: []*InputValue{{Name: "id", Type: NewNonNull(ID)}, {Name: "name", Type: NewNonNull(String)}},
+			Resolve: func(p ResolveParams) (any, error) {
+				u := users[p.Args["id"].(string)]
+				u["name"] = p.Args["name"]
+				return u, nil
+			}},
+	}}
+	s, err := NewSchema(query, mutation)
+	require.NoError(t, err)
+	return s
+}
+
+func toJSON(t *testing.T, r *Result) map[string]any {
+	data, err := json.Marshal(r)
+	require.NoError(t, err)
+	var result map[string]any
+	require.NoError(t, json.Unmarshal(data, &result))
+	return result
+}
+
+func TestExecute(t *testing.T) {
+	s := newTestSchema(t)
+	ctx := context.Background()
+
+	r := s.Execute(ctx, Request{Query: `query ($id: ID!, $more: Boolean = false) {
+		me: user(id: $id) { ...F friends { name role } }
+		other: user(id: 2) { id @skip(if: $more) name @include(if: $more) __typename }
+		none: user(id: "3") { id }
+	}
+	fragment F on User { id name }`, Variables: map[string]any{"id": 1}})
+	require.Empty(t, r.Errors)
+	data, _ := json.Marshal(r.Data)
+	assert.JSONEq(t, `{
+		"me": {"id": "1", "name": "alice", "friends": [{"name": "bob", "role": "ADMIN"}]},
+		"other": {"id": "2", "__typename": "User"},
+		"none": null
+	}`, string(data))
+	assert.Equal(t, []string{"me", "other", "none"}, r.Data.(*Object).Keys())
+
+	// the arguments are coerced, with the defaults
+	res := toJSON(t, s.Execute(ctx, Request{Query: `{ echo(value: {a: [1, "x", B]}, roles: MEMBER) }`}))
+	assert.Equal(t, map[string]any{"value": map[string]any{"a": []any{float64(1), "x", "B"}}, "limit": float64(10), "roles": []any{"member"}},
+		res["data"].(map[string]any)["echo"])
+
+	// the errors null the nearest nullable field
+	res = toJSON(t, s.Execute(ctx, Request{Query: `{ user(id: 1) { name secret } echo }`}))
+	assert.Equal(t, map[string]any{"user": nil, "echo": map[string]any{"limit": float64(10)}}, res["data"])
+	assert.Equal(t, []any{map[string]any{"message": "forbidden", "path": []any{"user", "secret"}}}, res["errors"])
+	res = toJSON(t, s.Execute(ctx, Request{Query: `{ user(id: 1) { friends { secret } } }`}))
+	assert.Equal(t, map[string]any{"user": map[string]any{"friends": nil}}, res["data"])
+	assert.Equal(t, []any{"user", "friends", float64(0), "secret"}, res["errors"].([]any)[0].(map[string]any)["path"])
+
+	res = toJSON(t, s.Execute(ctx, Request{Query: `mutation M { rename(id: 2, name: "carol") { name } }`, OperationName: "M"}))
+	assert.Equal(t, "carol", res["data"].(map[string]any)["rename"].(map[string]any)["name"])
+
+	for query, msg := range map[string]string{
+		`query ($id: ID!) { user(id: $id) { id } }`: "variable $id of type ID! is required",
+		`{ user { id } }`:                   "argument id of type ID! is required",
+		`{ user(id: 1) { missing } }`:       "unknown field missing on type User",
+		`{ echo(limit: "1") }`:              `argument limit: Int cannot represent 1`,
+		`{ echo(roles: [OWNER]) }`:          "argument roles: Role has no value OWNER",
+		`query A { echo } query B { echo }`: "operation name required",
+		`subscription { echo }`:             "subscriptions are not supported",
+	} {
+		r := s.Execute(ctx, Request{Query: query})
+		require.NotEmpty(t, r.Errors, query)
+		assert.Equal(t, msg, r.Errors[0].Message, query)
+	}
+}
+
+const introspectionQuery = `
+query IntrospectionQuery {
+  __schema {
+    queryType { name }
+    mutationType { name }
+    subscriptionType { name }
+    types { ...FullType }
+    directives { name description locations args { ...InputValue } }
+  }
+}
+fragment FullType on __Type {
+  kind name description
+  fields(includeDeprecated: true) {
+    name description
+    args { ...InputValue }
+    type { ...TypeRef }
+    isDeprecated deprecationReason
+  }
+  inputFields { ...InputValue }
+  interfaces { ...TypeRef }
+  enumValues(includeDeprecated: true) { name description isDeprecated deprecationReason }
+  possibleTypes { ...TypeRef }
+}
+fragment InputValue on __InputValue { name description type { ...TypeRef } defaultValue }
+fragment TypeRef on __Type { kind name ofType { kind name ofType { kind name ofType { kind name } } } }
+`
+
+func TestIntrospection(t *testing.T) {
+	s := newTestSchema(t)
+	res := toJSON(t, s.Execute(context.Background(), Request{Query: introspectionQuery}))
+	require.Nil(t, res["errors"])
+
+	schema := res["data"].(map[string]any)["__schema"].(map[string]any)
+	assert.Equal(t, "Mutation", schema["mutationType"].(map[string]any)["name"])
+	types := map[string]map[string]any{}
+	for _, v := range schema["types"].([]any) {
+		types[v.(map[string]any)["name"].(string)] = v.(map[string]any)
+	}
+	for _, name := range []string{"Query", "Mutation", "User", "Role", "JSON", "String", "__Schema", "__TypeKind"} {
+		assert.Contains(t, types, name)
+	}
+	user := types["User"]
+	assert.Equal(t, "A user", user["description"])
+	name := user["fields"].([]any)[1].(map[string]any)
+	assert.Equal(t, "The display name", name["description"])
+	assert.Equal(t, map[string]any{"kind": "SCALAR", "name": "String", "ofType": nil}, name["type"])
+	echo := types["Query"]["fields"].([]any)[1].(map[string]any)
+	assert.Equal(t, "10", echo["args"].([]any)[1].(map[string]any)["defaultValue"])
+
+	res = toJSON(t, s.Execute(context.Background(), Request{Query: `{ __type(name: "Role") { kind enumValues { name } } missing: __type(name: "Missing") { name } }`}))
+	assert.Equal(t, map[string]any{
+		"__type":  map[string]any{"kind": "ENUM", "enumValues": []any{map[string]any{"name": "ADMIN"}, map[string]any{"name": "MEMBER"}}},
+		"missing": nil,
+	}, res["data"])
+}
+
+func TestExecuteLimits(t *testing.T) {
+	s := newTestSchema(t)
+	s.MaxDepth, s.MaxFields = 3, 10
+	execute := func(query string) *Result {
+		return s.Execute(context.Background(), Request{Query: query})
+	}
+
+	res := execute(`{ user(id: 1) { friends { name } } }`)
+	assert.Nil(t, res.Errors)
+	res = execute(`{ user(id: 1) { friends { friends { name } } } }`)
+	require.Len(t, res.Errors, 1)
+	assert.Equal(t, "name is nested too deep, the max depth is 3", res.Errors[0].Message)
+	assert.Nil(t, res.Data)
+
+	// the aliases and the fragments are counted once expanded
+	res = execute(`{ a: user(id: 1) { ...F } b: user(id: 2) { ...F } } fragment F on User { id name role }`)
+	assert.Nil(t, res.Errors)
+	res = execute(`{ a: user(id: 1) { ...F } b: user(id: 2) { ...F } c: user(id: 2) { id } } fragment F on User { id name role }`)
+	require.Len(t, res.Errors, 1)
+	assert.Equal(t, "too many fields selected, the max is 10", res.Errors[0].Message)
+
+	s.MaxFields = 0
+	res = execute(`{ user(id: 1) { ...A } } fragment A on User { friends { ...B } } fragment B on User { ...A }`)
+	require.Len(t, res.Errors, 1)
+	assert.Equal(t, "fragment A spreads itself", res.Errors[0].Message)
+	// the fragments spreading the others twice are expanded up to the limit
+	query := `{ user(id: 1) { ...F0 } }`
+	for i := 0; i < 40; i++ {
+		query += fmt.Sprintf(" fragment F%d on User { ...F%d ...F%d }", i, i+1, i+1)
+	}
+	res = execute(query + " fragment F40 on User { id }")
+	require.Len(t, res.Errors, 1)
+	assert.Equal(t, "too many fields selected, the max is 300", res.Errors[0].Message)
+}
+
+func TestNewSchema(t *testing.T) {
+	query := &Type{Kind: KindObject, Name: "Query", Fields: []*FieldDef{{Name: "a", Type: String}}}
+	_, err := NewSchema(query, nil)
+	assert.NoError(t, err)
+
+	other := &Type{Kind: KindObject, Name: "String", Fields: []*FieldDef{{Name: "a", Type: String}}}
+	_, err = NewSchema(&Type{Kind: KindObject, Name: "Query", Fields: []*FieldDef{{Name: "a", Type: other}}}, nil)
+	assert.EqualError(t, err, "duplicate type String")
+	_, err = NewSchema(&Type{Kind: KindObject, Name: "Query", Fields: []*FieldDef{{Name: "a-b", Type: String}}}, nil)
+	assert.Error(t, err)
+	_, err = NewSchema(&Type{Kind: KindObject, Name: "Query", Fields: []*FieldDef{{Name: "a", Type: String, Args: []*InputValue{{Name: "u", Type: query}}}}}, nil)
+	assert.EqualError(t, err, "Query.a.u must be an input type")
+}
+
+func TestHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	h := Handler(newTestSchema(t))
+	r.GET("/graphql", h)
+	r.POST("/graphql", h)
+
+	do := func(req *http.Request) (int, map[string]any) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var result map[string]any
+		json.Unmarshal(w.Body.Bytes(), &result)
+		return w.Code, result
+	}
+
+	q := url.Values{"query": {`query ($id: ID!) { user(id: $id) { name } }`}, "variables": {`{"id": "1"}`}}
+	code, res := do(httptest.NewRequest(http.MethodGet, "/graphql?"+q.Encode(), nil))
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, map[string]any{"user": map[string]any{"name": "alice"}}, res["data"])
+
+	q = url.Values{"query": {`mutation { rename(id: 1, name: "x") { name } }`}}
+	code, _ = do(httptest.NewRequest(http.MethodGet, "/graphql?"+q.Encode(), nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, code)
+
+	code, res = do(httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(`{"query": "mutation { rename(id: 1, name: \"x\") { name } }"}`)))
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "x", res["data"].(map[string]any)["rename"].(map[string]any)["name"])
+
+	code, _ = do(httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(`{"query": "{ user(id: 1) { "}`)))
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = do(httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(`not json`)))
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = do(httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(`{"query": "`+strings.Repeat(" ", int(MaxRequestSize))+`{ a }"}`)))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, code)
+}
@@ -0,0 +1,49 @@
+package graphql
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Handler serve the schema over HTTP. The request is read from the JSON body
+// of POST, up to MaxRequestSize, or the query parameters of GET with the
+// variables encoded in JSON.
+// The mutations are only allowed by POST. The request is passed to the
+// resolvers as the context, use p.Context.(*gin.Context) to get it.
+func Handler(s *Schema) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req Request
+		if c.Request.Method == http.MethodGet {
+			req.Query = c.Query("query")
+			req.OperationName = c.Query("operationName")
+			if vars := c.Query("variables"); vars != "" {
+				if err := json.Unmarshal([]byte(vars), &req.Variables); err != nil {
+					c.JSON(http.StatusBadRequest, &Result{Errors: []*Error{{Message: "invalid variables"}}})
+					return
+				}
+			}
+		} else if err := json.NewDecoder(http.MaxBytesReader(c.Writer, c.Request.Body, MaxRequestSize)).Decode(&req); err != nil {
+			if _, ok := err.(*http.MaxBytesError); ok {
+				c.JSON(http.StatusRequestEntityTooLarge, &Result{Errors: []*Error{{Message: "request body too large"}}})
+				return
+			}
+			c.JSON(http.StatusBadRequest, &Result{Errors: []*Error{{Message: "invalid request body"}}})
+			return
+		}
+
+		doc, err := Parse(req.Query)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, &Result{Errors: []*Error{{Message: err.Error()}}})
+			return
+		}
+		if c.Request.Method == http.MethodGet {
+			if op, err := doc.Operation(req.OperationName); err == nil && op.Type != "query" {
+				c.JSON(http.StatusMethodNotAllowed, &Result{Errors: []*Error{{Message: "only queries are allowed by GET"}}})
+				return
+			}
+		}
+		c.JSON(http.StatusOK, s.ExecuteDocument(c, doc, req.OperationName, req.Variables))
+	}
+}
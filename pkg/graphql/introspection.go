@@ -0,0 +1,279 @@
+package graphql
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// directive is a directive supported by the executor.
+type directive struct {
+	Name        string
+	Description string
+	Locations   []string
+	Args        []*InputValue
+}
+
+var directiveArgs = []*InputValue{{Name: "if", Type: NewNonNull(Boolean)}}
+
+var directives = []*directive{
+	{
+		Name:        "include",
+		Description: "Directs the executor to include this field or fragment only when the `if` argument is true.",
+		Locations:   []string{"FIELD", "FRAGMENT_SPREAD", "INLINE_FRAGMENT"},
+		Args:        []*InputValue{{Name: "if", Description: "Included when true.", Type: NewNonNull(Boolean)}},
+	},
+	{
+		Name:        "skip",
+		Description: "Directs the executor to skip this field or fragment when the `if` argument is true.",
+		Locations:   []string{"FIELD", "FRAGMENT_SPREAD", "INLINE_FRAGMENT"},
+		Args:        []*InputValue{{Name: "if", Description: "Skipped when true.", Type: NewNonNull(Boolean)}},
+	},
+}
+
+// resolver adapt fn to a ResolveFunc reading the source of type T.
+func resolver[T any](fn func(src T, args map[string]any) any) ResolveFunc {
+	return func(p ResolveParams) (any, error) {
+		src, ok := p.Source.(T)
+		if !ok {
+			return nil, fmt.Errorf("invalid source of %s", p.Info.FieldName)
+		}
+		return fn(src, p.Args), nil
+	}
+}
+
+func enumOf(name string, values ...string) *Type {
+	t := &Type{Kind: KindEnum, Name: name}
+	for _, v := range values {
+		t.EnumValues = append(t.EnumValues, &EnumValue{Name: v, Value: v})
+	}
+	return t
+}
+
+var (
+	typeKindEnum = enumOf("__TypeKind",
+		"SCALAR", "OBJECT", "INTERFACE", "UNION", "ENUM", "INPUT_OBJECT", "LIST", "NON_NULL")
+	directiveLocationEnum = enumOf("__DirectiveLocation",
+		"QUERY", "MUTATION", "SUBSCRIPTION", "FIELD", "FRAGMENT_DEFINITION", "FRAGMENT_SPREAD", "INLINE_FRAGMENT",
+		"VARIABLE_DEFINITION", "SCHEMA", "SCALAR", "OBJECT", "FIELD_DEFINITION", "ARGUMENT_DEFINITION", "INTERFACE",
+		"UNION", "ENUM", "ENUM_VALUE", "INPUT_OBJECT", "INPUT_FIELD_DEFINITION")
+
+	schemaType     = &Type{Kind: KindObject, Name: "__Schema"}
+	typeType       = &Type{Kind: KindObject, Name: "__Type"}
+	fieldType      = &Type{Kind: KindObject, Name: "__Field"}
+	inputValueType = &Type{Kind: KindObject, Name: "__InputValue"}
+	enumValueType  = &Type{Kind: KindObject, Name: "__EnumValue"}
+	directiveType  = &Type{Kind: KindObject, Name: "__Directive"}
+
+	introspectionTypes = []*Type{
+		schemaType, typeType, fieldType, inputValueType, enumValueType, directiveType,
+		typeKindEnum, directiveLocationEnum,
+	}
+
+	schemaMetaField = &FieldDef{
+		Name: "__schema",
+		Type: NewNonNull(schemaType),
+	}
+	typeMetaField = &FieldDef{
+		Name: "__type",
+		Args: []*InputValue{{Name: "name", Type: NewNonNull(String)}},
+		Type: typeType,
+	}
+)
+
+var includeDeprecatedArgs = []*InputValue{{Name: "includeDeprecated", Type: Boolean, DefaultValue: false}}
+
+func init() {
+	// the source of the meta fields is the schema executing
+	schemaMetaField.Resolve = resolver(func(s *Schema, _ map[string]any) any { return s })
+	typeMetaField.Resolve = resolver(func(s *Schema, args map[string]any) any { return s.Type(args["name"].(string)) })
+
+	nonNullString := NewNonNull(String)
+	typeList := NewNonNull(NewList(NewNonNull(typeType)))
+	inputValueList := NewNonNull(NewList(NewNonNull(inputValueType)))
+
+	schemaType.Fields = []*FieldDef{
+		{Name: "description", Type: String, Resolve: resolver(func(s *Schema, _ map[string]any) any { return nil })},
+		{Name: "types", Type: typeList, Resolve: resolver(func(s *Schema, _ map[string]any) any {
+			types := make([]*Type, 0, len(s.names))
+			for _, name := range s.names {
+				types = append(types, s.types[name])
+			}
+			return types
+		})},
+		{Name: "queryType", Type: NewNonNull(typeType), Resolve: resolver(func(s *Schema, _ map[string]any) any { return s.Query })},
+		{Name: "mutationType", Type: typeType, Resolve: resolver(func(s *Schema, _ map[string]any) any { return s.Mutation })},
+		{Name: "subscriptionType", Type: typeType, Resolve: resolver(func(s *Schema, _ map[string]any) any { return nil })},
+		{Name: "directives", Type: NewNonNull(NewList(NewNonNull(directiveType))), Resolve: resolver(func(s *Schema, _ map[string]any) any { return directives })},
+	}
+
+	typeType.Fields = []*FieldDef{
+		{Name: "kind", Type: NewNonNull(typeKindEnum), Resolve: resolver(func(t *Type, _ map[string]any) any { return string(t.Kind) })},
+		{Name: "name", Type: String, Resolve: resolver(func(t *Type, _ map[string]any) any { return optional(t.Name) })},
+		{Name: "description", Type: String, Resolve: resolver(func(t *Type, _ map[string]any) any { return optional(t.Description) })},
+		{Name: "specifiedByURL", Type: String, Resolve: resolver(func(t *Type, _ map[string]any) any { return nil })},
+		{Name: "fields", Type: NewList(NewNonNull(fieldType)), Args: includeDeprecatedArgs, Resolve: resolver(func(t *Type, args map[string]any) any {
+			if t.Kind != KindObject {
+				return nil
+			}
+			fields := []*FieldDef{}
+			for _, f := range t.Fields {
+				if f.DeprecationReason == "" || args["includeDeprecated"] == true {
+					fields = append(fields, f)
+				}
+			}
+			return fields
+		})},
+		{Name: "interfaces", Type: NewList(NewNonNull(typeType)), Resolve: resolver(func(t *Type, _ map[string]any) any {
+			if t.Kind != KindObject {
+				return nil
+			}
+			return []*Type{}
+		})},
+		{Name: "possibleTypes", Type: NewList(NewNonNull(typeType)), Resolve: resolver(func(t *Type, _ map[string]any) any { return nil })},
+		{Name: "enumValues", Type: NewList(NewNonNull(enumValueType)), Args: includeDeprecatedArgs, Resolve: resolver(func(t *Type, args map[string]any) any {
+			if t.Kind != KindEnum {
+				return nil
+			}
+			values := []*EnumValue{}
+			for _, v := range t.EnumValues {
+				if v.DeprecationReason == "" || args["includeDeprecated"] == true {
+					values = append(values, v)
+				}
+			}
+			return values
+		})},
+		{Name: "inputFields", Type: NewList(NewNonNull(inputValueType)), Args: includeDeprecatedArgs, Resolve: resolver(func(t *Type, _ map[string]any) any {
+			if t.Kind != KindInputObject {
+				return nil
+			}
+			return t.InputFields
+		})},
+		{Name: "ofType", Type: typeType, Resolve: resolver(func(t *Type, _ map[string]any) any { return t.OfType })},
+		{Name: "isOneOf", Type: Boolean, Resolve: resolver(func(t *Type, _ map[string]any) any {
+			if t.Kind != KindInputObject {
+				return nil
+			}
+			return false
+		})},
+	}
+
+	fieldType.Fields = []*FieldDef{
+		{Name: "name", Type: nonNullString, Resolve: resolver(func(f *FieldDef, _ map[string]any) any { return f.Name })},
+		{Name: "description", Type: String, Resolve: resolver(func(f *FieldDef, _ map[string]any) any { return optional(f.Description) })},
+		{Name: "args", Type: inputValueList, Args: includeDeprecatedArgs, Resolve: resolver(func(f *FieldDef, _ map[string]any) any {
+			if f.Args == nil {
+				return []*InputValue{}
+			}
+			return f.Args
+		})},
+		{Name: "type", Type: NewNonNull(typeType), Resolve: resolver(func(f *FieldDef, _ map[string]any) any { return f.Type })},
+		{Name: "isDeprecated", Type: NewNonNull(Boolean), Resolve: resolver(func(f *FieldDef, _ map[string]any) any { return f.DeprecationReason != "" })},
+		{Name: "deprecationReason", Type: String, Resolve: resolver(func(f *FieldDef, _ map[string]any) any { return optional(f.DeprecationReason) })},
+	}
+
+	inputValueType.Fields = []*FieldDef{
+		{Name: "name", Type: nonNullString, Resolve: resolver(func(v *InputValue, _ map[string]any) any { return v.Name })},
+		{Name: "description", Type: String, Resolve: resolver(func(v *InputValue, _ map[string]any) any { return optional(v.Description) })},
+		{Name: "type", Type: NewNonNull(typeType), Resolve: resolver(func(v *InputValue, _ map[string]any) any { return v.Type })},
+		{Name: "defaultValue", Type: String, Resolve: resolver(func(v *InputValue, _ map[string]any) any {
+			if v.DefaultValue == nil {
+				return nil
+			}
+			return printValue(v.Type, v.DefaultValue)
+		})},
+		{Name: "isDeprecated", Type: NewNonNull(Boolean), Resolve: resolver(func(v *InputValue, _ map[string]any) any { return false })},
+		{Name: "deprecationReason", Type: String, Resolve: resolver(func(v *InputValue, _ map[string]any) any { return nil })},
+	}
+
+	enumValueType.Fields = []*FieldDef{
+		{Name: "name", Type: nonNullString, Resolve: resolver(func(v *EnumValue, _ map[string]any) any { return v.Name })},
+		{Name: "description", Type: String, Resolve: resolver(func(v *EnumValue, _ map[string]any) any { return optional(v.Description) })},
+		{Name: "isDeprecated", Type: NewNonNull(Boolean), Resolve: resolver(func(v *EnumValue, _ map[string]any) any { return v.DeprecationReason != "" })},
+		{Name: "deprecationReason", Type: String, Resolve: resolver(func(v *EnumValue, _ map[string]any) any { return optional(v.DeprecationReason) })},
+	}
+
+	directiveType.Fields = []*FieldDef{
+		{Name: "name", Type: nonNullString, Resolve: resolver(func(d *directive, _ map[string]any) any { return d.Name })},
+		{Name: "description", Type: String, Resolve: resolver(func(d *directive, _ map[string]any) any { return optional(d.Description) })},
+		{Name: "isRepeatable", Type: NewNonNull(Boolean), Resolve: resolver(func(d *directive, _ map[string]any) any { return false })},
+		{Name: "locations", Type: NewNonNull(NewList(NewNonNull(directiveLocationEnum))), Resolve: resolver(func(d *directive, _ map[string]any) any { return d.Locations })},
+		{Name: "args", Type: inputValueList, Args: includeDeprecatedArgs, Resolve: resolver(func(d *directive, _ map[string]any) any { return d.Args })},
+	}
+}
+
+// optional return nil for the empty strings.
+func optional(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// printValue print the input value of t in the GraphQL notation, for the defaults.
+func printValue(t *Type, v any) string {
+	if v == nil {
+		return "null"
+	}
+	t = unwrapNonNull(t)
+	switch t.Kind {
+	case KindEnum:
+		for _, ev := range t.EnumValues {
+			if reflect.DeepEqual(ev.Value, v) {
+				return ev.Name
+			}
+		}
+	case KindList:
+		if items, ok := v.([]any); ok {
+			parts := make([]string, len(items))
+			for i, item := range items {
+				parts[i] = printValue(t.OfType, item)
+			}
+			return "[" + strings.Join(parts, ", ") + "]"
+		}
+	case KindInputObject:
+		if m, ok := v.(map[string]any); ok {
+			var parts []string
+			for _, f := range t.InputFields {
+				if fv, ok := m[f.Name]; ok {
+					parts = append(parts, f.Name+": "+printValue(f.Type, fv))
+				}
+			}
+			return "{" + strings.Join(parts, ", ") + "}"
+		}
+	}
+
+	switch val := v.(type) {
+	case string:
+		return strconv.Quote(val)
+	case bool:
+		return strconv.FormatBool(val)
+	case []any:
+		parts := make([]string, len(val))
+		for i, item := range val {
+			parts[i] = printValue(JSON, item)
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	case map[string]any:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		parts := make([]string, len(keys))
+		for i, k := range keys {
+			parts[i] = k + ": " + printValue(JSON, val[k])
+		}
+		return "{" + strings.Join(parts, ", ") + "}"
+	}
+	return fmt.Sprint(v)
+}
+
+func unwrapNonNull(t *Type) *Type {
+	if t.Kind == KindNonNull {
+		return t.OfType
+	}
+	return t
+}
@@ -0,0 +1,80 @@
+package graphql
+
+import "fmt"
+
+const (
+	DefaultMaxDepth  = 15
+	DefaultMaxFields = 300
+)
+
+// MaxRequestSize is the size of the request bodies read by Handler.
+var MaxRequestSize int64 = 1 << 20
+
+func (s *Schema) maxDepth() int {
+	if s.MaxDepth > 0 {
+		return s.MaxDepth
+	}
+	return DefaultMaxDepth
+}
+
+func (s *Schema) maxFields() int {
+	if s.MaxFields > 0 {
+		return s.MaxFields
+	}
+	return DefaultMaxFields
+}
+
+// limiter checks the selections of an operation before it's executed, the
+// fragments are expanded where they are spread.
+type limiter struct {
+	doc       *Document
+	maxDepth  int
+	maxFields int
+	fields    int
+	spreading map[string]bool // the fragments being expanded
+}
+
+// checkLimits reject the operations selecting the fields deeper than
+// MaxDepth, or more than MaxFields, and the fragments spreading themselves.
+func (s *Schema) checkLimits(doc *Document, op *Operation) error {
+	l := &limiter{doc: doc, maxDepth: s.maxDepth(), maxFields: s.maxFields(), spreading: map[string]bool{}}
+	return l.check(op.SelectionSet, 1)
+}
+
+func (l *limiter) check(sels []Selection, depth int) error {
+	for _, sel := range sels {
+		// the fragments are counted as the fields, the ones without fields
+		// can't be expanded endlessly
+		if l.fields++; l.fields > l.maxFields {
+			return fmt.Errorf("too many fields selected, the max is %d", l.maxFields)
+		}
+		switch s := sel.(type) {
+		case *Field:
+			if depth > l.maxDepth {
+				return fmt.Errorf("%s is nested too deep, the max depth is %d", s.Name, l.maxDepth)
+			}
+			if err := l.check(s.SelectionSet, depth+1); err != nil {
+				return err
+			}
+		case *InlineFragment:
+			if err := l.check(s.SelectionSet, depth); err != nil {
+				return err
+			}
+		case *FragmentSpread:
+			frag, ok := l.doc.Fragments[s.Name]
+			if !ok {
+				continue // reported by the execution
+			}
+			if l.spreading[s.Name] {
+				return fmt.Errorf("fragment %s spreads itself", s.Name)
+			}
+			l.spreading[s.Name] = true
+			err := l.check(frag.SelectionSet, depth)
+			delete(l.spreading, s.Name)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
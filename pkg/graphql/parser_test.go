@@ -0,0 +1,81 @@
+package graphql
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	doc, err := Parse(`
+		# the comments and commas are ignored
+		query Users($id: Int! = 1, $tags: [String!]) @cached {
+			u: user(id: $id, filter: {name: "a\"bA", tags: [x, 1.5e3, -2, null, true]}) {
+				...UserFields
+				... on User @include(if: true) { email }
+			}
+		}
+		fragment UserFields on User { id, name }
+	`)
+	require.NoError(t, err)
+	require.Len(t, doc.Operations, 1)
+	op := doc.Operations[0]
+	assert.Equal(t, "query", op.Type)
+	assert.Equal(t, "Users", op.Name)
+	require.Len(t, op.Variables, 2)
+	assert.Equal(t, &TypeRef{Name: "Int", NonNull: true}, op.Variables[0].Type)
+	assert.Equal(t, "1", op.Variables[0].Default.Raw)
+	assert.Equal(t, &TypeRef{Elem: &TypeRef{Name: "String", NonNull: true}}, op.Variables[1].Type)
+
+	f := op.SelectionSet[0].(*Field)
+	assert.Equal(t, "u", f.ResponseKey())
+	assert.Equal(t, "user", f.Name)
+	assert.Equal(t, ValueVariable, f.Arguments[0].Value.Kind)
+	filter := f.Arguments[1].Value
+	assert.Equal(t, `a"bA`, filter.Fields[0].Value.Raw)
+	kinds := []ValueKind{}
+	for _, v := range filter.Fields[1].Value.List {
+		kinds = append(kinds, v.Kind)
+	}
+	assert.Equal(t, []ValueKind{ValueEnum, ValueFloat, ValueInt, ValueNull, ValueBoolean}, kinds)
+	assert.Equal(t, "UserFields", f.SelectionSet[0].(*FragmentSpread).Name)
+	assert.Equal(t, "User", f.SelectionSet[1].(*InlineFragment).TypeCondition)
+	assert.Equal(t, "User", doc.Fragments["UserFields"].TypeCondition)
+
+	doc, err = Parse(`{ a(text: """
+		  first
+		    second
+		""") }`)
+	require.NoError(t, err)
+	assert.Equal(t, "first\n  second", doc.Operations[0].SelectionSet[0].(*Field).Arguments[0].Value.Raw)
+
+	for _, query := range []string{
+		``,
+		`{ }`,
+		`{ a(`,
+		`{ a(x: "unterminated) }`,
+		`{ a(x: 1.) }`,
+		`{ a(x: 01x) }`,
+		`query ($id: Int = $other) { a }`,
+		`fragment F on T { a } fragment F on T { b } { a }`,
+		`{ a } extra`,
+	} {
+		_, err := Parse(query)
+		assert.Error(t, err, query)
+	}
+
+	// the deep nesting is rejected before it exhausts the stack
+	for _, query := range []string{
+		strings.Repeat("{a", 1<<20),
+		"{ a(x: " + strings.Repeat("[", 1<<20) + ") }",
+		"{ a(x: " + strings.Repeat("{b: ", 1<<20) + ") }",
+		"query ($x: " + strings.Repeat("[", 1<<20) + ") { a }",
+	} {
+		_, err := Parse(query)
+		assert.ErrorContains(t, err, "nested too deep")
+	}
+	_, err = Parse(strings.Repeat("{a", maxParseDepth-1) + "{a}" + strings.Repeat("}", maxParseDepth-1))
+	assert.NoError(t, err)
+}
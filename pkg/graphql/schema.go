@@ -0,0 +1,406 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+)
+
+// TypeKind is the kind of a type, as reported by the introspection.
+type TypeKind string
+
+const (
+	KindScalar      TypeKind = "SCALAR"
+	KindObject      TypeKind = "OBJECT"
+	KindEnum        TypeKind = "ENUM"
+	KindInputObject TypeKind = "INPUT_OBJECT"
+	KindList        TypeKind = "LIST"
+	KindNonNull     TypeKind = "NON_NULL"
+)
+
+// Type is a named type, or a list or non-null wrapper of OfType.
+type Type struct {
+	Kind        TypeKind
+	Name        string
+	Description string
+	Fields      []*FieldDef   // OBJECT
+	InputFields []*InputValue // INPUT_OBJECT
+	EnumValues  []*EnumValue  // ENUM
+	OfType      *Type         // LIST, NON_NULL
+
+	// Serialize convert the resolved value for the result, ParseValue convert
+	// the input value, the literals are passed as int64, float64, string, bool,
+	// []any or map[string]any. SCALAR only.
+	Serialize  func(v any) (any, error)
+	ParseValue func(v any) (any, error)
+}
+
+// ResolveFunc return the value of the field.
+type ResolveFunc func(p ResolveParams) (any, error)
+
+// FieldDef is a field of an object type.
+type FieldDef struct {
+	Name              string
+	Description       string
+	Args              []*InputValue
+	Type              *Type
+	Resolve           ResolveFunc // read the map source by Name if nil
+	DeprecationReason string
+}
+
+// InputValue is an argument or a field of an input object type.
+type InputValue struct {
+	Name         string
+	Description  string
+	Type         *Type
+	DefaultValue any // nil for none
+}
+
+// EnumValue is a value of an enum type, Value is passed to the resolvers.
+type EnumValue struct {
+	Name              string
+	Description       string
+	Value             any
+	DeprecationReason string
+}
+
+// ResolveParams is passed to ResolveFunc.
+type ResolveParams struct {
+	Context context.Context
+	Source  any
+	Args    map[string]any
+	Info    ResolveInfo
+}
+
+// ResolveInfo describe the field being resolved.
+type ResolveInfo struct {
+	FieldName  string
+	ParentType *Type
+	Path       []any
+	// Selected is the selection set of the field, with the fragments
+	// merged and the @skip/@include directives applied.
+	Selected []*SelectedField
+}
+
+// SelectedField is a field selected in the query, by its name.
+type SelectedField struct {
+	Name     string
+	Selected []*SelectedField
+}
+
+var nameRegexp = regexp.MustCompile(`^[_A-Za-z][_0-9A-Za-z]*$`)
+
+// IsValidName report whether name can be a GraphQL name.
+func IsValidName(name string) bool {
+	return nameRegexp.MatchString(name)
+}
+
+// NewList return the list type of t.
+func NewList(t *Type) *Type {
+	return &Type{Kind: KindList, OfType: t}
+}
+
+// NewNonNull return the non-null type of t.
+func NewNonNull(t *Type) *Type {
+	if t.Kind == KindNonNull {
+		return t
+	}
+	return &Type{Kind: KindNonNull, OfType: t}
+}
+
+// String return the type in the GraphQL notation, such as "[String!]!".
+func (t *Type) String() string {
+	switch t.Kind {
+	case KindList:
+		return "[" + t.OfType.String() + "]"
+	case KindNonNull:
+		return t.OfType.String() + "!"
+	}
+	return t.Name
+}
+
+// named return the named type wrapped by t.
+func (t *Type) named() *Type {
+	for t.OfType != nil {
+		t = t.OfType
+	}
+	return t
+}
+
+func (t *Type) field(name string) *FieldDef {
+	for _, f := range t.Fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+func (t *Type) enumValue(name string) *EnumValue {
+	for _, v := range t.EnumValues {
+		if v.Name == name {
+			return v
+		}
+	}
+	return nil
+}
+
+func isInputType(t *Type) bool {
+	switch t.named().Kind {
+	case KindScalar, KindEnum, KindInputObject:
+		return true
+	}
+	return false
+}
+
+// The built-in scalars, and JSON for the free-form values.
+var (
+	Int = &Type{
+		Kind:        KindScalar,
+		Name:        "Int",
+		Description: "The `Int` scalar type represents non-fractional signed whole numeric values.",
+		Serialize:   serializeInt,
+		ParseValue:  parseInt,
+	}
+	Float = &Type{
+		Kind:        KindScalar,
+		Name:        "Float",
+		Description: "The `Float` scalar type represents signed double-precision fractional values.",
+		Serialize:   serializeFloat,
+		ParseValue:  serializeFloat,
+	}
+	String = &Type{
+		Kind:        KindScalar,
+		Name:        "String",
+		Description: "The `String` scalar type represents textual data as UTF-8 character sequences.",
+		Serialize:   serializeString,
+		ParseValue:  parseString,
+	}
+	Boolean = &Type{
+		Kind:        KindScalar,
+		Name:        "Boolean",
+		Description: "The `Boolean` scalar type represents `true` or `false`.",
+		Serialize:   parseBoolean,
+		ParseValue:  parseBoolean,
+	}
+	ID = &Type{
+		Kind:        KindScalar,
+		Name:        "ID",
+		Description: "The `ID` scalar type represents a unique identifier, serialized as a string.",
+		Serialize:   serializeID,
+		ParseValue:  serializeID,
+	}
+	JSON = &Type{
+		Kind:        KindScalar,
+		Name:        "JSON",
+		Description: "The `JSON` scalar type represents any JSON value.",
+		Serialize:   func(v any) (any, error) { return v, nil },
+		ParseValue:  func(v any) (any, error) { return v, nil },
+	}
+)
+
+func serializeInt(v any) (any, error) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if rv.Uint() > math.MaxInt64 {
+			break
+		}
+		return int64(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		if f := rv.Float(); f == math.Trunc(f) && math.Abs(f) < 1<<63 {
+			return int64(f), nil
+		}
+	case reflect.Bool:
+		if rv.Bool() {
+			return int64(1), nil
+		}
+		return int64(0), nil
+	}
+	if n, ok := v.(json.Number); ok {
+		return n.Int64()
+	}
+	return nil, fmt.Errorf("Int cannot represent %v", v)
+}
+
+func parseInt(v any) (any, error) {
+	switch v.(type) {
+	case bool, string:
+		return nil, fmt.Errorf("Int cannot represent %v", v)
+	}
+	return serializeInt(v)
+}
+
+func serializeFloat(v any) (any, error) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	}
+	if n, ok := v.(json.Number); ok {
+		return n.Float64()
+	}
+	return nil, fmt.Errorf("Float cannot represent %v", v)
+}
+
+func serializeString(v any) (any, error) {
+	switch s := v.(type) {
+	case string:
+		return s, nil
+	case fmt.Stringer:
+		return s.String(), nil
+	case bool:
+		return strconv.FormatBool(s), nil
+	}
+	if f, err := serializeFloat(v); err == nil {
+		return strconv.FormatFloat(f.(float64), 'f', -1, 64), nil
+	}
+	return nil, fmt.Errorf("String cannot represent %v", v)
+}
+
+func parseString(v any) (any, error) {
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	return nil, fmt.Errorf("String cannot represent %v", v)
+}
+
+func parseBoolean(v any) (any, error) {
+	if b, ok := v.(bool); ok {
+		return b, nil
+	}
+	return nil, fmt.Errorf("Boolean cannot represent %v", v)
+}
+
+func serializeID(v any) (any, error) {
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	n, err := serializeInt(v)
+	if err != nil {
+		return nil, fmt.Errorf("ID cannot represent %v", v)
+	}
+	return strconv.FormatInt(n.(int64), 10), nil
+}
+
+// Schema is the types with the query and the mutation roots.
+type Schema struct {
+	Query    *Type
+	Mutation *Type // nil without mutations
+	// MaxDepth of the fields selected, DefaultMaxDepth by default.
+	MaxDepth int
+	// MaxFields selected by an operation, the aliases and the fragments
+	// expanded included, DefaultMaxFields by default.
+	MaxFields int
+	types     map[string]*Type
+	names     []string // in the order collected
+}
+
+// NewSchema collect the types reachable from the roots, and check the names.
+func NewSchema(query, mutation *Type) (*Schema, error) {
+	if query == nil || query.Kind != KindObject {
+		return nil, fmt.Errorf("invalid query type")
+	}
+	s := &Schema{Query: query, Mutation: mutation, types: map[string]*Type{}}
+	for _, t := range []*Type{Int, Float, String, Boolean, ID} {
+		if err := s.collect(t); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.collect(query); err != nil {
+		return nil, err
+	}
+	if mutation != nil {
+		if mutation.Kind != KindObject {
+			return nil, fmt.Errorf("invalid mutation type")
+		}
+		if err := s.collect(mutation); err != nil {
+			return nil, err
+		}
+	}
+	for _, t := range introspectionTypes {
+		if err := s.collect(t); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Type return the named type, nil if it's not in the schema.
+func (s *Schema) Type(name string) *Type {
+	return s.types[name]
+}
+
+func (s *Schema) collect(t *Type) error {
+	t = t.named()
+	if exist, ok := s.types[t.Name]; ok {
+		if exist != t {
+			return fmt.Errorf("duplicate type %s", t.Name)
+		}
+		return nil
+	}
+	if !IsValidName(t.Name) {
+		return fmt.Errorf("invalid type name %q", t.Name)
+	}
+	s.types[t.Name] = t
+	s.names = append(s.names, t.Name)
+
+	switch t.Kind {
+	case KindScalar:
+		if t.Serialize == nil || t.ParseValue == nil {
+			return fmt.Errorf("scalar %s without Serialize or ParseValue", t.Name)
+		}
+	case KindObject:
+		if len(t.Fields) == 0 {
+			return fmt.Errorf("type %s without fields", t.Name)
+		}
+		for _, f := range t.Fields {
+			if !IsValidName(f.Name) {
+				return fmt.Errorf("invalid field name %s.%s", t.Name, f.Name)
+			}
+			if err := s.collect(f.Type); err != nil {
+				return err
+			}
+			for _, arg := range f.Args {
+				if err := s.collectInput(t.Name+"."+f.Name, arg); err != nil {
+					return err
+				}
+			}
+		}
+	case KindInputObject:
+		for _, f := range t.InputFields {
+			if err := s.collectInput(t.Name, f); err != nil {
+				return err
+			}
+		}
+	case KindEnum:
+		for _, v := range t.EnumValues {
+			if !IsValidName(v.Name) {
+				return fmt.Errorf("invalid enum value %s.%s", t.Name, v.Name)
+			}
+		}
+	default:
+		return fmt.Errorf("invalid type %s", t.Name)
+	}
+	return nil
+}
+
+func (s *Schema) collectInput(owner string, v *InputValue) error {
+	if !IsValidName(v.Name) {
+		return fmt.Errorf("invalid argument name %s.%s", owner, v.Name)
+	}
+	if !isInputType(v.Type) {
+		return fmt.Errorf("%s.%s must be an input type", owner, v.Name)
+	}
+	return s.collect(v.Type)
+}
@@ -0,0 +1,325 @@
+package media
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/code-100-precent/LingFramework/pkg/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultConferenceSampleRate    = 16000
+	DefaultConferenceFrameDuration = 20 * time.Millisecond
+	DefaultConferenceMaxDelay      = 200 * time.Millisecond
+)
+
+var (
+	ErrParticipantExists   = errors.New("participant already joined")
+	ErrParticipantNotFound = errors.New("participant not found")
+	ErrConferenceClosed    = errors.New("conference closed")
+)
+
+// ConferenceOptions configures a Conference
+type ConferenceOptions struct {
+	// SampleRate of the mix, DefaultConferenceSampleRate by default.
+	SampleRate int
+	// FrameDuration of the frames mixed, DefaultConferenceFrameDuration by default.
+	FrameDuration time.Duration
+	// MaxDelay of the samples received waiting to be mixed, and of the
+	// frames waiting to be sent, the oldest samples and the newest frames
+	// are dropped beyond it. DefaultConferenceMaxDelay by default.
+	MaxDelay time.Duration
+}
+
+type participant struct {
+	id         string
+	transport  MediaTransport
+	sampleRate int
+	in         SampleRateConverter // to the rate of the mix
+	out        SampleRateConverter // to the rate of the participant
+	cancel     context.CancelFunc
+	outbound   chan []byte // the frames to send, at the rate of the participant
+
+	pending []int16 // received at the rate of the mix
+	muted   bool
+	gain    float64
+}
+
+// Conference mixes the audio of the participants, MediaTransports of
+// 16 bits mono PCM at any sample rate, such as the transports of the
+// calls decoded. The samples received are resampled to the rate of the
+// mix, and every FrameDuration each participant is sent the mix of the
+// others, its mix-minus, resampled to its rate. The participants can be
+// muted and their gain set; the mix is clamped to the 16 bits range.
+// The transports are read until they end, then leave, but they are not
+// closed by the conference. The frames are sent to each participant by its
+// own goroutine, a transport slow to send drops its frames beyond MaxDelay
+// without holding up the others.
+type Conference struct {
+	sampleRate    int
+	frameDuration time.Duration
+	frameSize     int // samples per frame
+	maxPending    int // samples per participant
+	maxOutbound   int // frames per participant
+	ctx           context.Context
+	cancel        context.CancelFunc
+	done          chan struct{}
+
+	mu           sync.Mutex
+	participants []*participant // in joining order
+}
+
+// NewConference starts mixing until Close.
+func NewConference(opts ConferenceOptions) *Conference {
+	c := newConference(opts)
+	go c.run()
+	return c
+}
+
+func newConference(opts ConferenceOptions) *Conference {
+	if opts.SampleRate <= 0 {
+		opts.SampleRate = DefaultConferenceSampleRate
+	}
+	if opts.FrameDuration <= 0 {
+		opts.FrameDuration = DefaultConferenceFrameDuration
+	}
+	if opts.MaxDelay < opts.FrameDuration {
+		opts.MaxDelay = max(DefaultConferenceMaxDelay, opts.FrameDuration)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &Conference{
+		sampleRate:    opts.SampleRate,
+		frameDuration: opts.FrameDuration,
+		frameSize:     max(int(int64(opts.SampleRate)*int64(opts.FrameDuration)/int64(time.Second)), 1),
+		maxPending:    int(int64(opts.SampleRate) * int64(opts.MaxDelay) / int64(time.Second)),
+		maxOutbound:   int(opts.MaxDelay / opts.FrameDuration),
+		ctx:           ctx,
+		cancel:        cancel,
+		done:          make(chan struct{}),
+	}
+	return c
+}
+
+func (c *Conference) String() string {
+	return fmt.Sprintf("Conference{SampleRate: %d, Participants: %v}", c.sampleRate, c.Participants())
+}
+
+// Join adds the transport as the participant id, unmuted at gain 1.
+func (c *Conference) Join(id string, transport MediaTransport) error {
+	codec := transport.Codec()
+	if name := strings.ToLower(codec.Codec); (name != "pcm" && name != "") || (codec.BitDepth != 0 && codec.BitDepth != 16) || codec.Channels > 1 {
+		return fmt.Errorf("%w: %s in conference", ErrCodecNotSupported, codec)
+	}
+	sampleRate := codec.SampleRate
+	if sampleRate <= 0 {
+		sampleRate = c.sampleRate
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ctx.Err() != nil {
+		return ErrConferenceClosed
+	}
+	if c.find(id) != nil {
+		return fmt.Errorf("%w: %s", ErrParticipantExists, id)
+	}
+	ctx, cancel := context.WithCancel(c.ctx)
+	p := &participant{
+		id:         id,
+		transport:  transport,
+		sampleRate: sampleRate,
+		in:         DefaultResampler(sampleRate, c.sampleRate),
+		out:        DefaultResampler(c.sampleRate, sampleRate),
+		cancel:     cancel,
+		outbound:   make(chan []byte, c.maxOutbound),
+		gain:       1,
+	}
+	c.participants = append(c.participants, p)
+	go c.receive(ctx, p)
+	go c.send(ctx, p)
+	logger.Info("conference participant joined", zap.String("participant", id), zap.Int("sampleRate", sampleRate))
+	return nil
+}
+
+// Leave removes the participant id, its transport is not closed.
+func (c *Conference) Leave(id string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, p := range c.participants {
+		if p.id == id {
+			p.cancel()
+			c.participants = append(c.participants[:i], c.participants[i+1:]...)
+			logger.Info("conference participant left", zap.String("participant", id))
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrParticipantNotFound, id)
+}
+
+// Mute mutes or unmutes the participant id for the others
+func (c *Conference) Mute(id string, muted bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p := c.find(id)
+	if p == nil {
+		return fmt.Errorf("%w: %s", ErrParticipantNotFound, id)
+	}
+	p.muted = muted
+	return nil
+}
+
+// SetGain sets the gain of the participant id in the mix, 1 keeps it as is
+func (c *Conference) SetGain(id string, gain float64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p := c.find(id)
+	if p == nil {
+		return fmt.Errorf("%w: %s", ErrParticipantNotFound, id)
+	}
+	p.gain = max(gain, 0)
+	return nil
+}
+
+// Participants returns the ids of the participants in joining order
+func (c *Conference) Participants() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ids := make([]string, len(c.participants))
+	for i, p := range c.participants {
+		ids[i] = p.id
+	}
+	return ids
+}
+
+// Close stops the mixing, the participants leave.
+func (c *Conference) Close() error {
+	c.cancel()
+	<-c.done
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.participants = nil
+	return nil
+}
+
+func (c *Conference) find(id string) *participant {
+	for _, p := range c.participants {
+		if p.id == id {
+			return p
+		}
+	}
+	return nil
+}
+
+// receive reads the transport of p until it ends
+func (c *Conference) receive(ctx context.Context, p *participant) {
+	for {
+		packet, err := p.transport.Next(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				if err != io.EOF {
+					logger.Warn("conference participant failed", zap.String("participant", p.id), zap.Error(err))
+				}
+				_ = c.Leave(p.id)
+			}
+			return
+		}
+		audio, ok := packet.(*AudioPacket)
+		if !ok || len(audio.Payload) < 2 {
+			continue
+		}
+		if _, err := p.in.Write(audio.Payload[:len(audio.Payload)&^1]); err != nil {
+			continue
+		}
+		samples := p.in.Samples()
+
+		c.mu.Lock()
+		for i := 0; i+1 < len(samples); i += 2 {
+			p.pending = append(p.pending, int16(binary.LittleEndian.Uint16(samples[i:])))
+		}
+		if drop := len(p.pending) - c.maxPending; drop > 0 {
+			p.pending = p.pending[drop:]
+		}
+		c.mu.Unlock()
+	}
+}
+
+// send sends the frames of p until it leaves
+func (c *Conference) send(ctx context.Context, p *participant) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case frame := <-p.outbound:
+			if _, err := p.transport.Send(ctx, &AudioPacket{Payload: frame}); err != nil && ctx.Err() == nil {
+				logger.Warn("conference send failed", zap.String("participant", p.id), zap.Error(err))
+			}
+		}
+	}
+}
+
+func (c *Conference) run() {
+	defer close(c.done)
+	ticker := time.NewTicker(c.frameDuration)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			for p, frame := range c.mix() {
+				select {
+				case p.outbound <- frame:
+				default: // the transport is behind, the frame is dropped
+				}
+			}
+		}
+	}
+}
+
+// mix consumes a frame of each participant and returns the mix-minus of
+// each, at its sample rate
+func (c *Conference) mix() map[*participant][]byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	total := make([]float64, c.frameSize)
+	contributions := make([][]float64, len(c.participants))
+	for i, p := range c.participants {
+		n := min(len(p.pending), c.frameSize)
+		if !p.muted && p.gain > 0 {
+			contributions[i] = make([]float64, c.frameSize)
+			for j, s := range p.pending[:n] {
+				contributions[i][j] = float64(s) * p.gain
+				total[j] += contributions[i][j]
+			}
+		}
+		p.pending = p.pending[n:]
+	}
+
+	frames := make(map[*participant][]byte, len(c.participants))
+	for i, p := range c.participants {
+		frame := make([]byte, 2*c.frameSize)
+		for j, s := range total {
+			if contributions[i] != nil {
+				s -= contributions[i][j]
+			}
+			binary.LittleEndian.PutUint16(frame[2*j:], uint16(clampInt16(s)))
+		}
+		if _, err := p.out.Write(frame); err == nil {
+			frames[p] = p.out.Samples()
+		}
+	}
+	return frames
+}
+
+func clampInt16(s float64) int16 {
+	return int16(math.Round(min(max(s, math.MinInt16), math.MaxInt16)))
+}
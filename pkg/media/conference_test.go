@@ -0,0 +1,213 @@
+package media
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+// pipeTransport is a PCM transport of the packets pushed to rx and the
+// packets sent to tx
+type pipeTransport struct {
+	codec CodecConfig
+	rx    chan MediaPacket
+	tx    chan []byte
+}
+
+func newPipeTransport(sampleRate int) *pipeTransport {
+	return &pipeTransport{
+		codec: CodecConfig{Codec: "pcm", SampleRate: sampleRate, Channels: 1, BitDepth: 16},
+		rx:    make(chan MediaPacket, 16),
+		tx:    make(chan []byte, 1024),
+	}
+}
+
+func (t *pipeTransport) String() string         { return "pipeTransport" }
+func (t *pipeTransport) Close() error           { return nil }
+func (t *pipeTransport) Attach(s *MediaSession) {}
+func (t *pipeTransport) Codec() CodecConfig     { return t.codec }
+
+func (t *pipeTransport) Next(ctx context.Context) (MediaPacket, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case packet, ok := <-t.rx:
+		if !ok {
+			return nil, io.EOF
+		}
+		return packet, nil
+	}
+}
+
+func (t *pipeTransport) Send(ctx context.Context, packet MediaPacket) (int, error) {
+	payload := packet.(*AudioPacket).Payload
+	t.tx <- payload
+	return len(payload), nil
+}
+
+// constant returns n samples of value
+func constant(n int, value int16) *AudioPacket {
+	pcm := make([]byte, 2*n)
+	for i := 0; i < n; i++ {
+		binary.LittleEndian.PutUint16(pcm[2*i:], uint16(value))
+	}
+	return &AudioPacket{Payload: pcm}
+}
+
+// waitPending waits until each participant has a frame to mix
+func waitPending(t *testing.T, c *Conference) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		c.mu.Lock()
+		ready := true
+		for _, p := range c.participants {
+			ready = ready && len(p.pending) >= c.frameSize
+		}
+		c.mu.Unlock()
+		if ready {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("expected the packets received")
+}
+
+// heard returns the first sample heard by each participant of a mix
+func heard(c *Conference) map[string]int16 {
+	result := map[string]int16{}
+	for p, frame := range c.mix() {
+		result[p.id] = int16(binary.LittleEndian.Uint16(frame))
+	}
+	return result
+}
+
+func TestConferenceMixMinus(t *testing.T) {
+	// not running, the frames are mixed by the test
+	c := newConference(ConferenceOptions{SampleRate: 16000})
+	defer c.cancel()
+
+	a, b, d := newPipeTransport(16000), newPipeTransport(8000), newPipeTransport(16000)
+	for id, tr := range map[string]*pipeTransport{"a": a, "b": b, "d": d} {
+		if err := c.Join(id, tr); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := c.Join("a", a); !errors.Is(err, ErrParticipantExists) {
+		t.Errorf("expected ErrParticipantExists, got %v", err)
+	}
+	if err := c.Join("e", &pipeTransport{codec: CodecConfig{Codec: "pcma"}}); !errors.Is(err, ErrCodecNotSupported) {
+		t.Errorf("expected ErrCodecNotSupported, got %v", err)
+	}
+
+	push := func(av, bv, dv int16) {
+		a.rx <- constant(320, av)
+		b.rx <- constant(160, bv) // 20ms at 8kHz
+		d.rx <- constant(320, dv)
+		waitPending(t, c)
+	}
+	expect := func(want map[string]int16) {
+		t.Helper()
+		got := heard(c)
+		for id, value := range want {
+			if got[id] != value {
+				t.Errorf("%s: expected %d, got %d", id, value, got[id])
+			}
+		}
+	}
+
+	push(1000, 2000, 0)
+	expect(map[string]int16{"a": 2000, "b": 1000, "d": 3000})
+
+	c.Mute("a", true)
+	c.SetGain("b", 0.5)
+	push(1000, 2000, 0)
+	expect(map[string]int16{"a": 1000, "b": 0, "d": 1000})
+
+	c.Mute("a", false)
+	c.SetGain("b", 1)
+	push(30000, 30000, -100)
+	expect(map[string]int16{"a": 29900, "b": 29900, "d": 32767})
+
+	// nothing received is silence
+	expect(map[string]int16{"a": 0, "b": 0, "d": 0})
+	frames := c.mix()
+	for p, frame := range frames {
+		if want := 2 * p.sampleRate / 50; len(frame) != want {
+			t.Errorf("%s: expected %d bytes, got %d", p.id, want, len(frame))
+		}
+	}
+
+	if err := c.Mute("x", true); !errors.Is(err, ErrParticipantNotFound) {
+		t.Errorf("expected ErrParticipantNotFound, got %v", err)
+	}
+}
+
+func TestConferenceRun(t *testing.T) {
+	c := NewConference(ConferenceOptions{SampleRate: 8000})
+	a, b := newPipeTransport(8000), newPipeTransport(8000)
+	c.Join("a", a)
+	c.Join("b", b)
+	a.rx <- constant(160, 1234)
+
+	deadline := time.After(5 * time.Second)
+	for found := false; !found; {
+		select {
+		case frame := <-b.tx:
+			found = int16(binary.LittleEndian.Uint16(frame)) == 1234
+		case <-deadline:
+			t.Fatalf("expected the mix of a sent to b")
+		}
+	}
+
+	// the participants leave at the end of their transport
+	close(a.rx)
+	for deadline := time.Now().Add(5 * time.Second); len(c.Participants()) != 1; {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected a to leave, got %v", c.Participants())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := c.Leave("b"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	c.Close()
+	if err := c.Join("a", a); !errors.Is(err, ErrConferenceClosed) {
+		t.Errorf("expected ErrConferenceClosed, got %v", err)
+	}
+}
+
+// stalledTransport never completes a Send until its context ends
+type stalledTransport struct {
+	*pipeTransport
+}
+
+func (t *stalledTransport) Send(ctx context.Context, packet MediaPacket) (int, error) {
+	<-ctx.Done()
+	return 0, ctx.Err()
+}
+
+func TestConferenceStalledParticipant(t *testing.T) {
+	c := NewConference(ConferenceOptions{SampleRate: 8000})
+	defer c.Close()
+	a, b := newPipeTransport(8000), newPipeTransport(8000)
+	c.Join("stalled", &stalledTransport{newPipeTransport(8000)})
+	c.Join("a", a)
+	c.Join("b", b)
+
+	// b keeps hearing the frames while the stalled participant sends none
+	deadline := time.After(5 * time.Second)
+	for frames := 0; frames < 10; frames++ {
+		select {
+		case <-b.tx:
+		case <-deadline:
+			t.Fatalf("expected the frames sent to b, got %d", frames)
+		}
+	}
+	if err := c.Leave("stalled"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
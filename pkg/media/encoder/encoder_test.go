@@ -1,7 +1,9 @@
 package encoder
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/code-100-precent/LingFramework/pkg/media"
 	"github.com/stretchr/testify/assert"
@@ -391,3 +393,41 @@ func TestEncodeDecodeRoundTrip(t *testing.T) {
 		assert.LessOrEqual(t, decodedPCM, 32767)
 	}
 }
+
+func TestCodecsOverRTP(t *testing.T) {
+	pcm := media.CodecConfig{Codec: CodecPCM, SampleRate: 8000, Channels: 1, BitDepth: 16}
+	samples := make([]byte, 320) // 20ms
+	for i := 0; i < len(samples); i += 2 {
+		samples[i+1] = byte(i)
+	}
+	for _, name := range []string{CodecPCMU, CodecPCMA, CodecG722} {
+		src := media.CodecConfig{Codec: name, SampleRate: 8000, Channels: 1, FrameDuration: "20ms"}
+		encode, err := CreateEncode(src, pcm)
+		assert.NoError(t, err)
+		decode, err := CreateDecode(src, pcm)
+		assert.NoError(t, err)
+
+		receiver, err := media.ListenRTP("127.0.0.1:0", media.RTPTransportOptions{Codec: src})
+		assert.NoError(t, err)
+		sender, err := media.ListenRTP("127.0.0.1:0", media.RTPTransportOptions{Codec: src, Remote: receiver.LocalAddr().String()})
+		assert.NoError(t, err)
+
+		frames, err := encode(&media.AudioPacket{Payload: samples})
+		assert.NoError(t, err)
+		assert.NotEmpty(t, frames, name)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		for _, frame := range frames {
+			_, err := sender.Send(ctx, frame)
+			assert.NoError(t, err)
+			packet, err := receiver.Next(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, frame.(*media.AudioPacket).Payload, packet.(*media.AudioPacket).Payload, name)
+			decoded, err := decode(packet)
+			assert.NoError(t, err)
+			assert.NotEmpty(t, decoded, name)
+		}
+		cancel()
+		sender.Close()
+		receiver.Close()
+	}
+}
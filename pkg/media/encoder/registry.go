@@ -1,6 +1,7 @@
 package encoder
 
 import (
+	"bytes"
 	"strings"
 	"time"
 
@@ -70,16 +71,21 @@ func HasCodec(name string) bool {
 	return exists
 }
 
-// RemoveWavHeader removes WAV file header if present
+// StripWavHeader removes WAV file header if present
+//
+// Deprecated: use media.ReadWavHeader, which also returns the codec of the samples.
 func StripWavHeader(data []byte) []byte {
-	const wavHeaderSize = 44
-	const riffSignature = "RIFF"
-	if len(data) > wavHeaderSize &&
-		data[0] == riffSignature[0] &&
-		data[1] == riffSignature[1] &&
-		data[2] == riffSignature[2] &&
-		data[3] == riffSignature[3] {
-		return data[wavHeaderSize:]
+	r := bytes.NewReader(data)
+	if _, size, err := media.ReadWavHeader(r); err == nil {
+		samples := data[len(data)-r.Len():]
+		if size > 0 && size <= int64(len(samples)) {
+			samples = samples[:size]
+		}
+		return samples
+	}
+	// the 44 bytes header of the files not parsed
+	if len(data) > media.WavHeaderSize && string(data[:4]) == "RIFF" {
+		return data[media.WavHeaderSize:]
 	}
 	return data
 }
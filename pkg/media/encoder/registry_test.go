@@ -1,6 +1,7 @@
 package encoder
 
 import (
+	"bytes"
 	"testing"
 
 	"github.com/code-100-precent/LingFramework/pkg/media"
@@ -130,6 +131,20 @@ func TestStripWavHeader(t *testing.T) {
 		t.Errorf("expected result length 56, got %d", len(result))
 	}
 
+	// Test with a header parsed, longer than 44 bytes
+	header, err := media.WavHeader(media.CodecConfig{Codec: "pcm", SampleRate: 8000, Channels: 1, BitDepth: 16}, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed := append([]byte{}, header[:36]...)
+	parsed = append(parsed, "LIST\x04\x00\x00\x00INFO"...)
+	parsed = append(parsed, header[36:]...)
+	parsed = append(parsed, 1, 2, 3, 4, 5, 6)
+	result = StripWavHeader(parsed)
+	if !bytes.Equal(result, []byte{1, 2, 3, 4}) {
+		t.Errorf("expected the samples of the data chunk, got %v", result)
+	}
+
 	// Test without WAV header
 	normalData := []byte{1, 2, 3, 4, 5}
 	result = StripWavHeader(normalData)
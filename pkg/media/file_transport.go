@@ -0,0 +1,329 @@
+package media
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DefaultFileFrameDuration = 20 * time.Millisecond
+
+var ErrTransportClosed = errors.New("transport closed")
+
+// FileTransportOptions configures a FileInputTransport
+type FileTransportOptions struct {
+	// Codec of the raw files, the WAV files carry their own.
+	Codec CodecConfig
+	// FrameDuration of the packets, DefaultFileFrameDuration by default.
+	FrameDuration time.Duration
+	// Paced delivers the packets in real time, as a live transport does,
+	// otherwise as fast as they are read.
+	Paced bool
+}
+
+// FileInputTransport reads the samples of a WAV file, or a raw PCM, PCMA or
+// PCMU file, as AudioPackets of FrameDuration, for replaying the recorded
+// calls through a MediaSession and for the golden-file tests. It's input
+// only, Next returns io.EOF at the end of the file.
+type FileInputTransport struct {
+	name          string
+	codec         CodecConfig
+	reader        io.Reader
+	closer        io.Closer
+	frameDuration time.Duration
+	frameSize     int
+	paced         bool
+
+	mu       sync.Mutex
+	closed   bool
+	done     chan struct{} // closed by Close
+	sequence int
+	startAt  time.Time
+}
+
+// OpenFileInputTransport opens the file at path, see NewFileInputTransport.
+func OpenFileInputTransport(path string, opts FileTransportOptions) (*FileInputTransport, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	t, err := NewFileInputTransport(f, opts)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	t.name = path
+	return t, nil
+}
+
+// NewFileInputTransport reads r as a WAV file if it starts with a RIFF
+// header, otherwise as raw samples of opts.Codec. r is closed by Close if
+// it's an io.Closer.
+func NewFileInputTransport(r io.Reader, opts FileTransportOptions) (*FileInputTransport, error) {
+	br := bufio.NewReader(r)
+	codec, reader := opts.Codec, io.Reader(br)
+	if magic, _ := br.Peek(4); string(magic) == "RIFF" {
+		var size int64
+		var err error
+		if codec, size, err = ReadWavHeader(br); err != nil {
+			return nil, err
+		}
+		// the size is left 0 or the maximum by the writers not fixing it up,
+		// otherwise the chunks after the samples are not read
+		if size > 0 && size < 0xFFFFFFFF {
+			reader = io.LimitReader(br, size)
+		}
+	} else {
+		switch strings.ToLower(codec.Codec) {
+		case "pcm":
+			if codec.BitDepth == 0 {
+				codec.BitDepth = 16
+			}
+		case "pcma", "pcmu":
+			codec.BitDepth = 8
+		default:
+			return nil, fmt.Errorf("%w: %q in raw file", ErrCodecNotSupported, codec.Codec)
+		}
+		if codec.SampleRate <= 0 || codec.BitDepth <= 0 || codec.BitDepth%8 != 0 {
+			return nil, fmt.Errorf("%w: %s", ErrCodecNotSupported, codec)
+		}
+		if codec.Channels <= 0 {
+			codec.Channels = 1
+		}
+	}
+
+	frameDuration := opts.FrameDuration
+	if frameDuration <= 0 {
+		frameDuration = DefaultFileFrameDuration
+	}
+	codec.FrameDuration = frameDuration.String()
+	samples := max(int64(codec.SampleRate)*int64(frameDuration)/int64(time.Second), 1)
+
+	t := &FileInputTransport{
+		name:          "reader",
+		codec:         codec,
+		reader:        reader,
+		frameDuration: frameDuration,
+		frameSize:     int(samples) * codec.Channels * codec.BitDepth / 8,
+		paced:         opts.Paced,
+		done:          make(chan struct{}),
+	}
+	if closer, ok := r.(io.Closer); ok {
+		t.closer = closer
+	}
+	return t, nil
+}
+
+func (t *FileInputTransport) String() string {
+	return fmt.Sprintf("FileInputTransport{Name: %s, Codec: %s, Paced: %t}", t.name, t.codec, t.paced)
+}
+
+func (t *FileInputTransport) Attach(s *MediaSession) {}
+
+func (t *FileInputTransport) Codec() CodecConfig {
+	return t.codec
+}
+
+// Next returns the next frame of the file, the last may be shorter.
+// The paced transport waits until the time of the frame since the first.
+// Close interrupts the wait and the read, Next returns io.EOF then.
+func (t *FileInputTransport) Next(ctx context.Context) (MediaPacket, error) {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil, io.EOF
+	}
+	if t.paced && t.startAt.IsZero() {
+		t.startAt = time.Now()
+	}
+	sequence, startAt := t.sequence, t.startAt
+	t.mu.Unlock()
+
+	// the lock isn't held while waiting, Next is called by a single reader
+	if t.paced {
+		if wait := time.Until(startAt.Add(time.Duration(sequence) * t.frameDuration)); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, ctx.Err()
+			case <-t.done:
+				timer.Stop()
+				return nil, io.EOF
+			case <-timer.C:
+			}
+		}
+	}
+
+	payload := make([]byte, t.frameSize)
+	n, err := io.ReadFull(t.reader, payload)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return nil, io.EOF
+	}
+	if n == 0 {
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		return nil, err
+	}
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+
+	packet := &AudioPacket{
+		Sequence:      sequence,
+		Payload:       payload[:n],
+		IsFirstPacket: sequence == 0,
+	}
+	t.sequence++
+	return packet, nil
+}
+
+func (t *FileInputTransport) Send(ctx context.Context, packet MediaPacket) (int, error) {
+	return 0, ErrNotOutputTransport
+}
+
+func (t *FileInputTransport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return nil
+	}
+	t.closed = true
+	close(t.done)
+	if t.closer != nil {
+		return t.closer.Close()
+	}
+	return nil
+}
+
+// WavOutputTransport writes the AudioPackets sent to a WAV file of its
+// codec, the other packets are ignored. The sizes of the header are fixed
+// up on Close, it's output only.
+type WavOutputTransport struct {
+	name   string
+	codec  CodecConfig
+	writer io.WriteSeeker
+	closer io.Closer
+
+	mu     sync.Mutex
+	closed bool
+	size   int64
+}
+
+// CreateWavOutputTransport creates or truncates the file at path, see NewWavOutputTransport.
+func CreateWavOutputTransport(path string, codec CodecConfig) (*WavOutputTransport, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	t, err := NewWavOutputTransport(f, codec)
+	if err != nil {
+		f.Close()
+		os.Remove(path)
+		return nil, err
+	}
+	t.name = path
+	return t, nil
+}
+
+// NewWavOutputTransport writes the header of codec, PCM, PCMA or PCMU,
+// to w. w is closed by Close if it's an io.Closer.
+func NewWavOutputTransport(w io.WriteSeeker, codec CodecConfig) (*WavOutputTransport, error) {
+	header, err := WavHeader(codec, 0)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	t := &WavOutputTransport{name: "writer", codec: codec, writer: w}
+	if closer, ok := w.(io.Closer); ok {
+		t.closer = closer
+	}
+	return t, nil
+}
+
+func (t *WavOutputTransport) String() string {
+	return fmt.Sprintf("WavOutputTransport{Name: %s, Codec: %s}", t.name, t.codec)
+}
+
+func (t *WavOutputTransport) Attach(s *MediaSession) {}
+
+func (t *WavOutputTransport) Codec() CodecConfig {
+	return t.codec
+}
+
+func (t *WavOutputTransport) Next(ctx context.Context) (MediaPacket, error) {
+	return nil, ErrNotInputTransport
+}
+
+func (t *WavOutputTransport) Send(ctx context.Context, packet MediaPacket) (int, error) {
+	audio, ok := packet.(*AudioPacket)
+	if !ok {
+		return 0, nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return 0, ErrTransportClosed
+	}
+	n, err := t.writer.Write(audio.Payload)
+	t.size += int64(n)
+	return n, err
+}
+
+// Size returns the bytes of the samples written.
+func (t *WavOutputTransport) Size() int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.size
+}
+
+// Close pads the samples to an even size as RIFF requires, rewrites the
+// header with the sizes written and closes the writer.
+func (t *WavOutputTransport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return nil
+	}
+	t.closed = true
+
+	err := t.finish()
+	if t.closer != nil {
+		if cerr := t.closer.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+func (t *WavOutputTransport) finish() error {
+	if t.size%2 == 1 {
+		if _, err := t.writer.Write([]byte{0}); err != nil {
+			return err
+		}
+	}
+	header, err := WavHeader(t.codec, t.size)
+	if err != nil {
+		return err
+	}
+	if _, err := t.writer.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := t.writer.Write(header); err != nil {
+		return err
+	}
+	_, err = t.writer.Seek(0, io.SeekEnd)
+	return err
+}
@@ -0,0 +1,203 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// readAll returns the payloads of the packets of t until io.EOF
+func readAll(t *testing.T, tr MediaTransport) [][]byte {
+	t.Helper()
+	var payloads [][]byte
+	for {
+		packet, err := tr.Next(context.Background())
+		if err == io.EOF {
+			return payloads
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		audio := packet.(*AudioPacket)
+		if audio.Sequence != len(payloads) || audio.IsFirstPacket != (len(payloads) == 0) {
+			t.Errorf("unexpected packet %d: %s", len(payloads), audio)
+		}
+		payloads = append(payloads, audio.Payload)
+	}
+}
+
+func TestWavFileTransports(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "call.wav")
+	codec := CodecConfig{Codec: "pcm", SampleRate: 8000, Channels: 2, BitDepth: 16}
+	out, err := CreateWavOutputTransport(path, codec)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	samples := make([]byte, 1000) // 62.5ms of 8kHz stereo
+	for i := range samples {
+		samples[i] = byte(i)
+	}
+	out.Send(context.Background(), &AudioPacket{Payload: samples[:600]})
+	out.Send(context.Background(), &TextPacket{Text: "ignored"})
+	out.Send(context.Background(), &AudioPacket{Payload: samples[600:]})
+	if err := out.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := out.Send(context.Background(), &AudioPacket{Payload: samples}); !errors.Is(err, ErrTransportClosed) {
+		t.Errorf("expected ErrTransportClosed, got %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if len(data) != WavHeaderSize+len(samples) || !bytes.Equal(data[WavHeaderSize:], samples) {
+		t.Fatalf("unexpected file of %d bytes", len(data))
+	}
+
+	in, err := OpenFileInputTransport(path, FileTransportOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer in.Close()
+	expected := codec
+	expected.FrameDuration = "20ms"
+	if in.Codec() != expected {
+		t.Errorf("expected %s, got %s", expected, in.Codec())
+	}
+	payloads := readAll(t, in)
+	// 20ms of 8kHz stereo 16-bit is 640 bytes
+	if len(payloads) != 2 || len(payloads[0]) != 640 || len(payloads[1]) != 360 {
+		t.Fatalf("unexpected frames of %d", len(payloads))
+	}
+	if !bytes.Equal(bytes.Join(payloads, nil), samples) {
+		t.Error("expected the samples written")
+	}
+	if _, err := in.Send(context.Background(), &AudioPacket{}); !errors.Is(err, ErrNotOutputTransport) {
+		t.Errorf("expected ErrNotOutputTransport, got %v", err)
+	}
+}
+
+func TestWavOutputTransportOddSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "odd.wav")
+	out, err := CreateWavOutputTransport(path, CodecConfig{Codec: "pcmu", SampleRate: 8000, Channels: 1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out.Send(context.Background(), &AudioPacket{Payload: []byte{1, 2, 3}})
+	out.Close()
+
+	// the pad byte is not a sample
+	in, err := OpenFileInputTransport(path, FileTransportOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer in.Close()
+	if in.Codec().Codec != "pcmu" {
+		t.Errorf("expected pcmu, got %s", in.Codec())
+	}
+	payloads := readAll(t, in)
+	if len(payloads) != 1 || !bytes.Equal(payloads[0], []byte{1, 2, 3}) {
+		t.Errorf("unexpected payloads %v", payloads)
+	}
+
+	if _, err := CreateWavOutputTransport(path, CodecConfig{Codec: "opus", SampleRate: 48000, Channels: 1}); !errors.Is(err, ErrCodecNotSupported) {
+		t.Errorf("expected ErrCodecNotSupported, got %v", err)
+	}
+}
+
+func TestRawFileInputTransport(t *testing.T) {
+	raw := make([]byte, 400)
+	in, err := NewFileInputTransport(bytes.NewReader(raw), FileTransportOptions{
+		Codec:         CodecConfig{Codec: "pcma", SampleRate: 8000},
+		FrameDuration: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if codec := in.Codec(); codec.BitDepth != 8 || codec.Channels != 1 {
+		t.Errorf("unexpected codec %s", codec)
+	}
+	if payloads := readAll(t, in); len(payloads) != 5 || len(payloads[4]) != 80 {
+		t.Errorf("expected 5 frames of 80 bytes, got %d", len(payloads))
+	}
+
+	if _, err := NewFileInputTransport(bytes.NewReader(raw), FileTransportOptions{}); !errors.Is(err, ErrCodecNotSupported) {
+		t.Errorf("expected ErrCodecNotSupported, got %v", err)
+	}
+}
+
+func TestFileInputTransportPaced(t *testing.T) {
+	raw := make([]byte, 4*320) // 4 frames of 16kHz 16-bit
+	opts := FileTransportOptions{Codec: DefaultCodecConfig(), FrameDuration: 10 * time.Millisecond, Paced: true}
+	in, _ := NewFileInputTransport(bytes.NewReader(raw), opts)
+	start := time.Now()
+	if payloads := readAll(t, in); len(payloads) != 4 {
+		t.Fatalf("expected 4 frames, got %d", len(payloads))
+	}
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("expected paced frames, took %s", elapsed)
+	}
+
+	in, _ = NewFileInputTransport(bytes.NewReader(raw), opts)
+	in.Next(context.Background())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := in.Next(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	in.Close()
+	if _, err := in.Next(context.Background()); err != io.EOF {
+		t.Errorf("expected io.EOF after Close, got %v", err)
+	}
+}
+
+func TestFileInputTransportCloseWhileWaiting(t *testing.T) {
+	// a paced frame far away
+	opts := FileTransportOptions{Codec: DefaultCodecConfig(), FrameDuration: time.Hour, Paced: true}
+	in, _ := NewFileInputTransport(bytes.NewReader(make([]byte, 1<<20)), opts)
+	in.Next(context.Background())
+	result := make(chan error, 1)
+	go func() {
+		_, err := in.Next(context.Background())
+		result <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	in.Close()
+	select {
+	case err := <-result:
+		if err != io.EOF {
+			t.Errorf("expected io.EOF after Close, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Next still waiting after Close")
+	}
+
+	// a read blocked on a live stream
+	r, w := io.Pipe()
+	go w.Write([]byte{1, 2, 3, 4})
+	in, err := NewFileInputTransport(r, FileTransportOptions{Codec: DefaultCodecConfig()})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	go func() {
+		_, err := in.Next(context.Background())
+		result <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	closed := make(chan struct{})
+	go func() {
+		in.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close waits for the blocked read")
+	}
+	if err := <-result; err != io.EOF {
+		t.Errorf("expected io.EOF after Close, got %v", err)
+	}
+}
@@ -0,0 +1,263 @@
+package recorder
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/code-100-precent/LingFramework/pkg/logger"
+	"github.com/code-100-precent/LingFramework/pkg/media"
+	stores "github.com/code-100-precent/LingFramework/pkg/storage"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultKeyPrefix = "recordings/"
+	DefaultTolerance = 100 * time.Millisecond
+
+	caller = 0 // the left channel, the packets received
+	agent  = 1 // the right channel, the packets sent
+)
+
+// The session values set by the recorder once uploaded, for the PostHooks
+const (
+	ValueRecordingKey      = "_recording_key"      // string
+	ValueRecordingDuration = "_recording_duration" // time.Duration
+)
+
+// Options configures a Recorder
+type Options struct {
+	// Store to upload to, stores.Default() by default.
+	Store stores.Store
+	// Key of the recording, DefaultKeyPrefix + session ID + ".wav" by default.
+	Key string
+	// SampleRate of the PCM of the session, its SampleRate by default.
+	SampleRate int
+	// TempDir of the recording being written, os.TempDir() by default.
+	TempDir string
+	// Tolerance is the jitter of the arrivals of the packets absorbed
+	// before a gap is filled with silence, DefaultTolerance by default.
+	Tolerance time.Duration
+}
+
+// track is a channel of the recording, its samples not written yet
+type track struct {
+	cursor  int64  // samples of the channel, written and pending
+	pending []byte // 16 bits PCM
+}
+
+func (t *track) fill(samples int64) {
+	if samples > 0 {
+		t.pending = append(t.pending, make([]byte, 2*samples)...)
+		t.cursor += samples
+	}
+}
+
+// Recorder records the calls of a MediaSession as a stereo WAV, the
+// caller on the left channel and the agent on the right. The PCM packets
+// received and sent are placed on the wall-clock timeline since the first
+// packet: a packet arrived after a gap is preceded by silence, the
+// packets sent faster than real time follow each other, and a channel
+// without packets is filled with silence as the time goes. The recording
+// is written incrementally to a temporary file and uploaded to the Store
+// when the session stops.
+type Recorder struct {
+	store      stores.Store
+	key        string
+	sampleRate int
+	tolerance  int64 // in samples
+	now        func() time.Time
+
+	mu       sync.Mutex
+	path     string
+	out      *media.WavOutputTransport
+	startAt  time.Time
+	tracks   [2]track
+	written  int64 // samples per channel
+	finished bool
+	err      error
+}
+
+// Attach records session from now on, it taps its transports and uploads
+// the recording in a PostHook. Attach it before the PostHooks reading the
+// ValueRecordingKey and ValueRecordingDuration values.
+func Attach(session *media.MediaSession, opts Options) (*Recorder, error) {
+	if opts.Store == nil {
+		opts.Store = stores.Default()
+	}
+	if opts.Key == "" {
+		opts.Key = DefaultKeyPrefix + session.ID + ".wav"
+	}
+	if opts.SampleRate <= 0 {
+		opts.SampleRate = session.SampleRate
+	}
+	r, err := New(opts)
+	if err != nil {
+		return nil, err
+	}
+	session.Tap(media.DirectionInput, r.CallerFilter).
+		Tap(media.DirectionOutput, r.AgentFilter).
+		PostHook(func(s *media.MediaSession) {
+			duration, err := r.Finish()
+			if err != nil {
+				logger.Error("failed to upload recording", zap.String("sessionID", s.ID), zap.String("key", r.key), zap.Error(err))
+				return
+			}
+			s.Set(ValueRecordingKey, r.key)
+			s.Set(ValueRecordingDuration, duration)
+			logger.Info("recording uploaded", zap.String("sessionID", s.ID), zap.String("key", r.key), zap.Duration("duration", duration))
+		})
+	return r, nil
+}
+
+// New creates the temporary file of the recording, opts.Store and
+// opts.Key are required. The packets are recorded by CallerFilter and
+// AgentFilter, Finish uploads the recording.
+func New(opts Options) (*Recorder, error) {
+	if opts.Store == nil || opts.Key == "" {
+		return nil, fmt.Errorf("recorder: store and key are required")
+	}
+	sampleRate := opts.SampleRate
+	if sampleRate <= 0 {
+		sampleRate = 16000
+	}
+	tolerance := opts.Tolerance
+	if tolerance <= 0 {
+		tolerance = DefaultTolerance
+	}
+
+	f, err := os.CreateTemp(opts.TempDir, "recording-*.wav")
+	if err != nil {
+		return nil, err
+	}
+	out, err := media.NewWavOutputTransport(f, media.CodecConfig{Codec: "pcm", SampleRate: sampleRate, Channels: 2, BitDepth: 16})
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return &Recorder{
+		store:      opts.Store,
+		key:        opts.Key,
+		sampleRate: sampleRate,
+		tolerance:  int64(tolerance) * int64(sampleRate) / int64(time.Second),
+		now:        time.Now,
+		path:       f.Name(),
+		out:        out,
+	}, nil
+}
+
+// Key returns the key of the recording
+func (r *Recorder) Key() string {
+	return r.key
+}
+
+// CallerFilter records the packets received, as a PacketFilter
+func (r *Recorder) CallerFilter(packet media.MediaPacket) (bool, error) {
+	r.record(caller, packet)
+	return false, nil
+}
+
+// AgentFilter records the packets sent, as a PacketFilter
+func (r *Recorder) AgentFilter(packet media.MediaPacket) (bool, error) {
+	r.record(agent, packet)
+	return false, nil
+}
+
+func (r *Recorder) record(channel int, packet media.MediaPacket) {
+	audio, ok := packet.(*media.AudioPacket)
+	if !ok || len(audio.Payload) < 2 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.finished || r.err != nil {
+		return
+	}
+
+	now := r.now()
+	if r.startAt.IsZero() {
+		r.startAt = now
+	}
+	samples := int64(len(audio.Payload) / 2)
+	arrival := int64(now.Sub(r.startAt)) * int64(r.sampleRate) / int64(time.Second)
+
+	// the packets received end when they arrive, the ones sent start
+	start := arrival
+	if channel == caller {
+		start -= samples
+	}
+	t := &r.tracks[channel]
+	if start > t.cursor+r.tolerance {
+		t.fill(start - t.cursor)
+	}
+	t.pending = append(t.pending, audio.Payload[:2*samples]...)
+	t.cursor += samples
+	for i := range r.tracks {
+		r.tracks[i].fill(arrival - r.tolerance - r.tracks[i].cursor)
+	}
+
+	if err := r.flush(); err != nil {
+		r.err = err
+		logger.Error("failed to write recording", zap.String("key", r.key), zap.Error(err))
+	}
+}
+
+// flush writes the samples of both channels
+func (r *Recorder) flush() error {
+	left, right := r.tracks[caller].pending, r.tracks[agent].pending
+	n := min(len(left), len(right)) / 2
+	if n == 0 {
+		return nil
+	}
+	frames := make([]byte, 4*n)
+	for i := 0; i < n; i++ {
+		copy(frames[4*i:4*i+2], left[2*i:2*i+2])
+		copy(frames[4*i+2:4*i+4], right[2*i:2*i+2])
+	}
+	r.tracks[caller].pending = left[2*n:]
+	r.tracks[agent].pending = right[2*n:]
+	r.written += int64(n)
+	_, err := r.out.Send(context.Background(), &media.AudioPacket{Payload: frames})
+	return err
+}
+
+// Finish completes the recording, uploads it to the Store and removes the
+// temporary file. It returns the duration recorded, the packets recorded
+// after are ignored.
+func (r *Recorder) Finish() (time.Duration, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.finished {
+		return 0, fmt.Errorf("recorder: %s already finished", r.key)
+	}
+	r.finished = true
+	defer os.Remove(r.path)
+
+	err := r.err
+	if err == nil {
+		end := max(r.tracks[caller].cursor, r.tracks[agent].cursor)
+		for i := range r.tracks {
+			r.tracks[i].fill(end - r.tracks[i].cursor)
+		}
+		err = r.flush()
+	}
+	if cerr := r.out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	f, err := os.Open(r.path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	if err := r.store.Write(r.key, f); err != nil {
+		return 0, err
+	}
+	return time.Duration(r.written) * time.Second / time.Duration(r.sampleRate), nil
+}
@@ -0,0 +1,276 @@
+package recorder
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/code-100-precent/LingFramework/pkg/logger"
+	"github.com/code-100-precent/LingFramework/pkg/media"
+	"go.uber.org/zap"
+)
+
+func init() {
+	// Initialize logger for tests
+	if logger.Lg == nil {
+		logger.Lg = zap.NewNop() // Use no-op logger for tests
+	}
+}
+
+// memoryStore is a stores.Store in memory
+type memoryStore map[string][]byte
+
+func (m memoryStore) Read(key string) (io.ReadCloser, int64, error) {
+	data, ok := m[key]
+	if !ok {
+		return nil, 0, os.ErrNotExist
+	}
+	return io.NopCloser(bytes.NewReader(data)), int64(len(data)), nil
+}
+
+func (m memoryStore) Write(key string, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	m[key] = data
+	return err
+}
+
+func (m memoryStore) Delete(key string) error {
+	delete(m, key)
+	return nil
+}
+
+func (m memoryStore) Exists(key string) (bool, error) {
+	_, ok := m[key]
+	return ok, nil
+}
+
+func (m memoryStore) PublicURL(key string) string {
+	return "/" + key
+}
+
+// samples returns n samples of value as 16 bits PCM
+func samples(n int, value int16) *media.AudioPacket {
+	pcm := make([]byte, 2*n)
+	for i := 0; i < n; i++ {
+		binary.LittleEndian.PutUint16(pcm[2*i:], uint16(value))
+	}
+	return &media.AudioPacket{Payload: pcm}
+}
+
+// channels reads the stereo recording of key
+func channels(t *testing.T, store memoryStore, key string) ([]int16, []int16) {
+	t.Helper()
+	r := bytes.NewReader(store[key])
+	codec, size, err := media.ReadWavHeader(r)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if codec.Channels != 2 || codec.SampleRate != 1000 || codec.BitDepth != 16 {
+		t.Fatalf("unexpected codec: %s", codec)
+	}
+	var left, right []int16
+	for i := int64(0); i < size/4; i++ {
+		var frame [2]int16
+		if err := binary.Read(r, binary.LittleEndian, &frame); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		left, right = append(left, frame[0]), append(right, frame[1])
+	}
+	return left, right
+}
+
+func TestRecorderTimeline(t *testing.T) {
+	store := memoryStore{}
+	r, err := New(Options{Store: store, Key: "calls/1.wav", SampleRate: 1000, TempDir: t.TempDir(), Tolerance: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	start := time.Now()
+	at := func(ms int) { r.now = func() time.Time { return start.Add(time.Duration(ms) * time.Millisecond) } }
+
+	// the caller speaks 100ms, pauses 100ms and speaks 100ms
+	at(0)
+	r.CallerFilter(samples(100, 1))
+	at(100)
+	r.CallerFilter(samples(100, 1))
+	at(400)
+	r.CallerFilter(samples(100, 2))
+	at(405) // the agent answers 300ms at once
+	r.AgentFilter(samples(300, 3))
+	r.AgentFilter(&media.TextPacket{Text: "ignored"})
+	duration, err := r.Finish()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if duration != 705*time.Millisecond {
+		t.Errorf("expected 705ms, got %v", duration)
+	}
+
+	left, right := channels(t, store, "calls/1.wav")
+	expect := func(name string, channel []int16, from, to int, value int16) {
+		for i := from; i < to; i++ {
+			if channel[i] != value {
+				t.Fatalf("%s: expected %d at %dms, got %d", name, value, i, channel[i])
+			}
+		}
+	}
+	if len(left) != 705 || len(right) != 705 {
+		t.Fatalf("expected 705ms, got %d and %d", len(left), len(right))
+	}
+	expect("caller", left, 0, 200, 1)
+	expect("caller", left, 200, 300, 0)
+	expect("caller", left, 300, 400, 2)
+	expect("caller", left, 400, 705, 0)
+	expect("agent", right, 0, 405, 0)
+	expect("agent", right, 405, 705, 3)
+
+	if _, err := r.Finish(); err == nil {
+		t.Errorf("expected finished")
+	}
+	if files, _ := filepath.Glob(filepath.Join(filepath.Dir(r.path), "*")); len(files) != 0 {
+		t.Errorf("expected the temporary file removed: %v", files)
+	}
+}
+
+// failingStore fails the uploads
+type failingStore struct{ memoryStore }
+
+func (failingStore) Write(key string, r io.Reader) error {
+	return errors.New("unavailable")
+}
+
+func TestAttach(t *testing.T) {
+	session := media.NewDefaultSession()
+	session.SampleRate = 1000
+	store := memoryStore{}
+	r, err := Attach(session, Options{Store: store, TempDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r.Key() != DefaultKeyPrefix+session.ID+".wav" {
+		t.Errorf("unexpected key: %s", r.Key())
+	}
+
+	codec := media.CodecConfig{Codec: "pcm", SampleRate: 1000, Channels: 1, BitDepth: 16}
+	input, err := media.NewFileInputTransport(bytes.NewReader(samples(100, 1).Payload), media.FileTransportOptions{Codec: codec})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	output, err := media.CreateWavOutputTransport(filepath.Join(t.TempDir(), "out.wav"), codec)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	session.Input(input).Output(output).On(media.Hangup, func(event media.StateChange) {
+		session.Close()
+	})
+	done := make(chan error, 1)
+	go func() { done <- session.Serve() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(30 * time.Second): // the cleanup of the transports waits for their loops
+		t.Fatalf("expected the session to stop")
+	}
+
+	if session.GetString(ValueRecordingKey) != r.Key() {
+		t.Errorf("expected the key in the session, got %q", session.GetString(ValueRecordingKey))
+	}
+	if duration, _ := session.Get(ValueRecordingDuration); duration != 100*time.Millisecond {
+		t.Errorf("expected 100ms in the session, got %v", duration)
+	}
+	if left, _ := channels(t, store, r.Key()); len(left) != 100 || left[0] != 1 {
+		t.Errorf("unexpected recording of %d samples", len(left))
+	}
+
+	failing, err := New(Options{Store: failingStore{}, Key: "k", TempDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := failing.Finish(); err == nil || err.Error() != "unavailable" {
+		t.Errorf("expected the upload error, got %v", err)
+	}
+}
+
+// sinkTransport is a PCM output transport of the packets sent to tx
+type sinkTransport struct {
+	tx chan media.MediaPacket
+}
+
+func (t *sinkTransport) String() string               { return "sinkTransport" }
+func (t *sinkTransport) Close() error                 { return nil }
+func (t *sinkTransport) Attach(s *media.MediaSession) {}
+func (t *sinkTransport) Codec() media.CodecConfig {
+	return media.CodecConfig{Codec: "pcm", SampleRate: 1000, Channels: 1, BitDepth: 16}
+}
+
+func (t *sinkTransport) Next(ctx context.Context) (media.MediaPacket, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (t *sinkTransport) Send(ctx context.Context, packet media.MediaPacket) (int, error) {
+	t.tx <- packet
+	return 0, nil
+}
+
+func TestAttachOutputs(t *testing.T) {
+	session := media.NewDefaultSession()
+	session.SampleRate = 1000
+	store := memoryStore{}
+	r, err := Attach(session, Options{Store: store, TempDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the agent echoes the caller to two outputs
+	codec := media.CodecConfig{Codec: "pcm", SampleRate: 1000, Channels: 1, BitDepth: 16}
+	input, err := media.NewFileInputTransport(bytes.NewReader(samples(100, 1).Payload), media.FileTransportOptions{Codec: codec})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	a, b := &sinkTransport{tx: make(chan media.MediaPacket, 16)}, &sinkTransport{tx: make(chan media.MediaPacket, 16)}
+	echo := func(packet media.MediaPacket) (bool, error) {
+		if audio, ok := packet.(*media.AudioPacket); ok {
+			session.SendToOutput("echo", &media.AudioPacket{Payload: bytes.Clone(audio.Payload)})
+		}
+		return false, nil
+	}
+	session.Input(input, echo).Output(a).Output(b)
+	done := make(chan error, 1)
+	go func() { done <- session.Serve() }()
+	for _, out := range []*sinkTransport{a, b} {
+		for sent := 0; sent < 200; {
+			select {
+			case packet := <-out.tx:
+				sent += len(packet.(*media.AudioPacket).Payload)
+			case <-time.After(5 * time.Second):
+				t.Fatalf("expected the packets sent to each output")
+			}
+		}
+	}
+	session.Close()
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		t.Fatalf("expected the session to stop")
+	}
+
+	// the packet sent to both outputs is recorded once
+	left, right := channels(t, store, r.Key())
+	if len(left) != 100 || len(right) != 100 {
+		t.Fatalf("expected 100ms, got %d and %d", len(left), len(right))
+	}
+	for i := range right {
+		if right[i] != 1 {
+			t.Fatalf("agent: expected 1 at %dms, got %d", i, right[i])
+		}
+	}
+}
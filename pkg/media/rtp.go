@@ -0,0 +1,168 @@
+package media
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	RTPVersion       = 2
+	rtpHeaderSize    = 12
+	DefaultOpusRTPPT = 111 // the dynamic payload type commonly negotiated for Opus
+)
+
+var ErrInvalidRTP = errors.New("invalid rtp packet")
+
+// RTPHeader is the fixed header of RFC 3550, with the CSRC list. The header
+// extension is skipped by ParseRTPPacket and not written by Marshal.
+type RTPHeader struct {
+	Version        uint8
+	Padding        bool
+	Extension      bool
+	Marker         bool
+	PayloadType    uint8
+	SequenceNumber uint16
+	Timestamp      uint32
+	SSRC           uint32
+	CSRC           []uint32
+}
+
+type RTPPacket struct {
+	RTPHeader
+	Payload []byte
+}
+
+func (p *RTPPacket) String() string {
+	return fmt.Sprintf("RTPPacket{PT: %d, Seq: %d, TS: %d, SSRC: %08x, Marker: %t, Payload: %d bytes}",
+		p.PayloadType, p.SequenceNumber, p.Timestamp, p.SSRC, p.Marker, len(p.Payload))
+}
+
+// ParseRTPPacket parses the RTP packet of data, the payload refers to data.
+func ParseRTPPacket(data []byte) (*RTPPacket, error) {
+	if len(data) < rtpHeaderSize {
+		return nil, fmt.Errorf("%w: %d bytes", ErrInvalidRTP, len(data))
+	}
+	p := &RTPPacket{RTPHeader: RTPHeader{
+		Version:        data[0] >> 6,
+		Padding:        data[0]&0x20 != 0,
+		Extension:      data[0]&0x10 != 0,
+		Marker:         data[1]&0x80 != 0,
+		PayloadType:    data[1] & 0x7F,
+		SequenceNumber: binary.BigEndian.Uint16(data[2:4]),
+		Timestamp:      binary.BigEndian.Uint32(data[4:8]),
+		SSRC:           binary.BigEndian.Uint32(data[8:12]),
+	}}
+	if p.Version != RTPVersion {
+		return nil, fmt.Errorf("%w: version %d", ErrInvalidRTP, p.Version)
+	}
+
+	offset := rtpHeaderSize
+	csrcCount := int(data[0] & 0x0F)
+	if len(data) < offset+4*csrcCount {
+		return nil, fmt.Errorf("%w: short csrc list", ErrInvalidRTP)
+	}
+	for i := 0; i < csrcCount; i++ {
+		p.CSRC = append(p.CSRC, binary.BigEndian.Uint32(data[offset:offset+4]))
+		offset += 4
+	}
+	if p.Extension {
+		if len(data) < offset+4 {
+			return nil, fmt.Errorf("%w: short extension", ErrInvalidRTP)
+		}
+		offset += 4 + 4*int(binary.BigEndian.Uint16(data[offset+2:offset+4]))
+		if len(data) < offset {
+			return nil, fmt.Errorf("%w: short extension", ErrInvalidRTP)
+		}
+	}
+
+	end := len(data)
+	if p.Padding {
+		padding := int(data[end-1])
+		if padding == 0 || end-padding < offset {
+			return nil, fmt.Errorf("%w: invalid padding", ErrInvalidRTP)
+		}
+		end -= padding
+	}
+	p.Payload = data[offset:end]
+	return p, nil
+}
+
+// Marshal returns the packet on the wire, without padding and extension.
+func (p *RTPPacket) Marshal() []byte {
+	data := make([]byte, rtpHeaderSize+4*len(p.CSRC)+len(p.Payload))
+	data[0] = RTPVersion<<6 | byte(len(p.CSRC)&0x0F)
+	data[1] = p.PayloadType & 0x7F
+	if p.Marker {
+		data[1] |= 0x80
+	}
+	binary.BigEndian.PutUint16(data[2:4], p.SequenceNumber)
+	binary.BigEndian.PutUint32(data[4:8], p.Timestamp)
+	binary.BigEndian.PutUint32(data[8:12], p.SSRC)
+	offset := rtpHeaderSize
+	for _, csrc := range p.CSRC {
+		binary.BigEndian.PutUint32(data[offset:offset+4], csrc)
+		offset += 4
+	}
+	copy(data[offset:], p.Payload)
+	return data
+}
+
+// RTPPayloadType returns the payload type of codec, PayloadType if set,
+// otherwise the static type of RFC 3551, or DefaultOpusRTPPT for Opus.
+func RTPPayloadType(codec CodecConfig) (uint8, error) {
+	if codec.PayloadType != 0 {
+		return codec.PayloadType, nil
+	}
+	switch strings.ToLower(codec.Codec) {
+	case "pcmu":
+		return 0, nil
+	case "pcma":
+		return 8, nil
+	case "g722":
+		return 9, nil
+	case "opus":
+		return DefaultOpusRTPPT, nil
+	}
+	return 0, fmt.Errorf("%w: no payload type of %s", ErrCodecNotSupported, codec.Codec)
+}
+
+// RTPClockRate returns the RTP timestamp rate of codec. G.722 keeps the
+// 8000Hz clock of RFC 3551 for its 16000Hz samples, Opus always uses 48000Hz.
+func RTPClockRate(codec CodecConfig) int {
+	switch strings.ToLower(codec.Codec) {
+	case "pcmu", "pcma", "g722":
+		return 8000
+	case "opus":
+		return 48000
+	}
+	if codec.SampleRate > 0 {
+		return codec.SampleRate
+	}
+	return 8000
+}
+
+// rtpTimestampStep returns the timestamp increment of the payload of codec.
+// The frames of Opus can't be sized by bytes, they last FrameDuration.
+func rtpTimestampStep(codec CodecConfig, payloadSize int) uint32 {
+	channels := max(codec.Channels, 1)
+	switch strings.ToLower(codec.Codec) {
+	case "pcmu", "pcma":
+		return uint32(payloadSize / channels)
+	case "g722":
+		return uint32(payloadSize / channels) // two 16kHz samples per byte, on the 8kHz clock
+	case "opus":
+		duration, err := time.ParseDuration(codec.FrameDuration)
+		if err != nil || duration <= 0 {
+			duration = 20 * time.Millisecond
+		}
+		return uint32(48000 * duration / time.Second)
+	}
+	sampleSize := codec.BitDepth / 8
+	if sampleSize <= 0 {
+		sampleSize = 2
+	}
+	return uint32(payloadSize / sampleSize / channels)
+}
@@ -0,0 +1,183 @@
+package media
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	DefaultJitterMinDelay = 20 * time.Millisecond
+	DefaultJitterMaxDelay = 200 * time.Millisecond
+	maxJitterPackets      = 512 // the oldest are released beyond it
+	maxSequenceJump       = 3000
+)
+
+// RTPStats are the counters of an RTP stream, as RFC 3550 reports them.
+type RTPStats struct {
+	PacketsReceived   uint64        `json:"packetsReceived"`   // all the packets of the stream received
+	PacketsLost       uint64        `json:"packetsLost"`       // expected but never played
+	PacketsLate       uint64        `json:"packetsLate"`       // received after their turn, dropped
+	PacketsDuplicated uint64        `json:"packetsDuplicated"` // received again, dropped
+	PacketsSent       uint64        `json:"packetsSent"`
+	Jitter            time.Duration `json:"jitter"` // interarrival jitter
+	Delay             time.Duration `json:"delay"`  // current delay of the jitter buffer
+}
+
+// LossRate returns the fraction of the expected packets lost.
+func (s RTPStats) LossRate() float64 {
+	expected := s.PacketsReceived - s.PacketsDuplicated - s.PacketsLate + s.PacketsLost
+	if expected == 0 {
+		return 0
+	}
+	return float64(s.PacketsLost) / float64(expected)
+}
+
+type jitterEntry struct {
+	seq     int64 // extended sequence number
+	packet  *RTPPacket
+	arrival time.Time
+}
+
+// JitterBuffer reorders the packets of an RTP stream by sequence number.
+// The packets in order are released at once; a missing packet is waited
+// for until the packet after the gap has been buffered for Delay, then it's
+// counted as lost. Delay adapts to three times the interarrival jitter,
+// between the minimum and maximum delay. It's not safe for concurrent use.
+type JitterBuffer struct {
+	clockRate int
+	minDelay  time.Duration
+	maxDelay  time.Duration
+
+	entries []jitterEntry // sorted by seq, all >= next
+	started bool
+	next    int64 // seq of the next packet released
+	highest int64
+
+	hasTransit bool
+	base       time.Time // arrival of the first packet, for the arrival timestamps
+	transit    uint32
+	jitter     float64 // in timestamp units
+	stats      RTPStats
+}
+
+// NewJitterBuffer creates the buffer of the stream with the RTP clockRate,
+// the zero delays are DefaultJitterMinDelay and DefaultJitterMaxDelay.
+func NewJitterBuffer(clockRate int, minDelay, maxDelay time.Duration) *JitterBuffer {
+	if minDelay <= 0 {
+		minDelay = DefaultJitterMinDelay
+	}
+	if maxDelay < minDelay {
+		maxDelay = max(DefaultJitterMaxDelay, minDelay)
+	}
+	return &JitterBuffer{clockRate: max(clockRate, 1), minDelay: minDelay, maxDelay: maxDelay}
+}
+
+// Reset forgets the packets and the position of the stream, for a new
+// stream. The counters are kept.
+func (jb *JitterBuffer) Reset() {
+	jb.entries = nil
+	jb.started = false
+	jb.hasTransit = false
+}
+
+// Push buffers the packet arrived at arrival.
+func (jb *JitterBuffer) Push(p *RTPPacket, arrival time.Time) {
+	jb.stats.PacketsReceived++
+	jb.updateJitter(p, arrival)
+
+	if !jb.started {
+		jb.started = true
+		jb.next = int64(p.SequenceNumber)
+		jb.highest = jb.next
+	}
+	// the sequence number closest to the highest
+	seq := jb.highest + int64(int16(p.SequenceNumber-uint16(jb.highest)))
+	if seq-jb.highest > maxSequenceJump || jb.highest-seq > maxSequenceJump {
+		// the stream restarted, such as after a hold
+		jb.stats.PacketsLost += uint64(jb.highest+1-jb.next) - uint64(len(jb.entries))
+		jb.entries = nil
+		jb.next, jb.highest = seq, seq
+	}
+	if seq < jb.next {
+		jb.stats.PacketsLate++
+		return
+	}
+	jb.highest = max(jb.highest, seq)
+
+	i := sort.Search(len(jb.entries), func(i int) bool { return jb.entries[i].seq >= seq })
+	if i < len(jb.entries) && jb.entries[i].seq == seq {
+		jb.stats.PacketsDuplicated++
+		return
+	}
+	jb.entries = append(jb.entries, jitterEntry{})
+	copy(jb.entries[i+1:], jb.entries[i:])
+	jb.entries[i] = jitterEntry{seq: seq, packet: p, arrival: arrival}
+}
+
+// Pop returns the next packet to play at now, or nil and the time to try
+// again, zero if the buffer is empty.
+func (jb *JitterBuffer) Pop(now time.Time) (*RTPPacket, time.Time) {
+	if len(jb.entries) == 0 {
+		return nil, time.Time{}
+	}
+	head := jb.entries[0]
+	if head.seq != jb.next {
+		deadline := head.arrival.Add(jb.Delay())
+		if now.Before(deadline) && len(jb.entries) < maxJitterPackets {
+			return nil, deadline
+		}
+		// give up the gap
+		jb.lostUntil(head.seq)
+	}
+	jb.entries = jb.entries[1:]
+	jb.next = head.seq + 1
+	return head.packet, time.Time{}
+}
+
+// Len returns the packets buffered.
+func (jb *JitterBuffer) Len() int {
+	return len(jb.entries)
+}
+
+// Delay returns the current delay waiting for the missing packets.
+func (jb *JitterBuffer) Delay() time.Duration {
+	delay := 3 * jb.jitterDuration()
+	return min(max(delay, jb.minDelay), jb.maxDelay)
+}
+
+// Stats returns the counters of the stream.
+func (jb *JitterBuffer) Stats() RTPStats {
+	stats := jb.stats
+	stats.Jitter = jb.jitterDuration()
+	stats.Delay = jb.Delay()
+	return stats
+}
+
+func (jb *JitterBuffer) lostUntil(seq int64) {
+	if seq > jb.next {
+		jb.stats.PacketsLost += uint64(seq - jb.next)
+		jb.next = seq
+	}
+}
+
+// updateJitter estimates the interarrival jitter of RFC 3550 6.4.1.
+// The differences are taken modulo 2^32 as the timestamps wrap around.
+func (jb *JitterBuffer) updateJitter(p *RTPPacket, arrival time.Time) {
+	if !jb.hasTransit {
+		jb.base = arrival
+	}
+	arrivalTS := uint32(int64(arrival.Sub(jb.base)) * int64(jb.clockRate) / int64(time.Second))
+	transit := arrivalTS - p.Timestamp
+	if jb.hasTransit {
+		d := int64(int32(transit - jb.transit))
+		if d < 0 {
+			d = -d
+		}
+		jb.jitter += (float64(d) - jb.jitter) / 16
+	}
+	jb.transit, jb.hasTransit = transit, true
+}
+
+func (jb *JitterBuffer) jitterDuration() time.Duration {
+	return time.Duration(jb.jitter * float64(time.Second) / float64(jb.clockRate))
+}
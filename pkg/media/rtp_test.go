@@ -0,0 +1,188 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRTPPacketRoundTrip(t *testing.T) {
+	packet := &RTPPacket{
+		RTPHeader: RTPHeader{
+			Version:        RTPVersion,
+			Marker:         true,
+			PayloadType:    8,
+			SequenceNumber: 65535,
+			Timestamp:      0xFFFFFF00,
+			SSRC:           0x12345678,
+			CSRC:           []uint32{1, 2},
+		},
+		Payload: []byte{1, 2, 3, 4},
+	}
+	parsed, err := ParseRTPPacket(packet.Marshal())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if parsed.String() != packet.String() || !bytes.Equal(parsed.Payload, packet.Payload) {
+		t.Errorf("expected %s, got %s", packet, parsed)
+	}
+	if len(parsed.CSRC) != 2 || parsed.CSRC[1] != 2 {
+		t.Errorf("unexpected csrc: %v", parsed.CSRC)
+	}
+}
+
+func TestParseRTPPacketExtensionAndPadding(t *testing.T) {
+	data := []byte{
+		0xB0, 0x00, 0x00, 0x01, // version 2, padding, extension, pt 0, seq 1
+		0x00, 0x00, 0x00, 0xA0, // timestamp
+		0x00, 0x00, 0x00, 0x01, // ssrc
+		0xBE, 0xDE, 0x00, 0x01, // extension of one word
+		0x10, 0x20, 0x30, 0x40,
+		0xAA, 0xBB, // payload
+		0x00, 0x02, // padding
+	}
+	packet, err := ParseRTPPacket(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(packet.Payload, []byte{0xAA, 0xBB}) || packet.Timestamp != 0xA0 {
+		t.Errorf("unexpected packet %s: %x", packet, packet.Payload)
+	}
+
+	for name, data := range map[string][]byte{
+		"short":     data[:8],
+		"version":   append([]byte{0x40}, data[1:]...),
+		"extension": data[:14],
+		"padding":   append(append([]byte(nil), data[:22]...), 0xFF),
+	} {
+		if _, err := ParseRTPPacket(data); !errors.Is(err, ErrInvalidRTP) {
+			t.Errorf("%s: expected ErrInvalidRTP, got %v", name, err)
+		}
+	}
+}
+
+func TestRTPPayloadTypeAndClock(t *testing.T) {
+	tests := []struct {
+		codec     CodecConfig
+		pt        uint8
+		clockRate int
+		step      uint32 // of 160 bytes
+	}{
+		{CodecConfig{Codec: "pcmu", SampleRate: 8000}, 0, 8000, 160},
+		{CodecConfig{Codec: "PCMA", SampleRate: 8000}, 8, 8000, 160},
+		{CodecConfig{Codec: "g722", SampleRate: 16000}, 9, 8000, 160},
+		{CodecConfig{Codec: "opus", SampleRate: 48000, FrameDuration: "20ms"}, DefaultOpusRTPPT, 48000, 960},
+		{CodecConfig{Codec: "opus", PayloadType: 96}, 96, 48000, 960},
+		{CodecConfig{Codec: "pcm", SampleRate: 16000, BitDepth: 16, PayloadType: 100}, 100, 16000, 80},
+	}
+	for _, tt := range tests {
+		pt, err := RTPPayloadType(tt.codec)
+		if err != nil || pt != tt.pt {
+			t.Errorf("%s: expected pt %d, got %d, %v", tt.codec, tt.pt, pt, err)
+		}
+		if rate := RTPClockRate(tt.codec); rate != tt.clockRate {
+			t.Errorf("%s: expected clock %d, got %d", tt.codec, tt.clockRate, rate)
+		}
+		if step := rtpTimestampStep(tt.codec, 160); step != tt.step {
+			t.Errorf("%s: expected step %d, got %d", tt.codec, tt.step, step)
+		}
+	}
+	if _, err := RTPPayloadType(CodecConfig{Codec: "pcm"}); !errors.Is(err, ErrCodecNotSupported) {
+		t.Errorf("expected ErrCodecNotSupported, got %v", err)
+	}
+}
+
+func rtpAt(seq uint16) *RTPPacket {
+	return &RTPPacket{RTPHeader: RTPHeader{Version: RTPVersion, SequenceNumber: seq, Timestamp: uint32(seq) * 160}}
+}
+
+// popAll returns the sequence numbers released at now
+func popAll(jb *JitterBuffer, now time.Time) []uint16 {
+	var seqs []uint16
+	for {
+		packet, _ := jb.Pop(now)
+		if packet == nil {
+			return seqs
+		}
+		seqs = append(seqs, packet.SequenceNumber)
+	}
+}
+
+func TestJitterBufferReorders(t *testing.T) {
+	jb := NewJitterBuffer(8000, 0, 0)
+	now := time.Now()
+	for _, seq := range []uint16{65534, 0, 65535, 1, 0, 65533} {
+		jb.Push(rtpAt(seq), now)
+	}
+	got := popAll(jb, now)
+	want := []uint16{65534, 65535, 0, 1}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+	stats := jb.Stats()
+	if stats.PacketsReceived != 6 || stats.PacketsDuplicated != 1 || stats.PacketsLate != 1 || stats.PacketsLost != 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestJitterBufferGivesUpGaps(t *testing.T) {
+	jb := NewJitterBuffer(8000, 20*time.Millisecond, 100*time.Millisecond)
+	now := time.Now()
+	jb.Push(rtpAt(10), now)
+	jb.Push(rtpAt(13), now)
+	if got := popAll(jb, now); len(got) != 1 || got[0] != 10 {
+		t.Fatalf("expected [10], got %v", got)
+	}
+	packet, retryAt := jb.Pop(now)
+	if packet != nil || !retryAt.Equal(now.Add(jb.Delay())) {
+		t.Fatalf("expected to wait for the gap, got %v at %v", packet, retryAt)
+	}
+	if got := popAll(jb, retryAt); len(got) != 1 || got[0] != 13 {
+		t.Fatalf("expected [13], got %v", got)
+	}
+	jb.Push(rtpAt(11), now)
+	stats := jb.Stats()
+	if stats.PacketsLost != 2 || stats.PacketsLate != 1 || stats.LossRate() != 0.5 {
+		t.Errorf("unexpected stats: %+v, loss %v", stats, stats.LossRate())
+	}
+}
+
+func TestJitterBufferRestart(t *testing.T) {
+	jb := NewJitterBuffer(8000, 0, 0)
+	now := time.Now()
+	jb.Push(rtpAt(100), now)
+	jb.Push(rtpAt(102), now)
+	jb.Push(rtpAt(30000), now)
+	if got := popAll(jb, now); len(got) != 1 || got[0] != 30000 {
+		t.Fatalf("expected [30000], got %v", got)
+	}
+	if stats := jb.Stats(); stats.PacketsLost != 1 {
+		t.Errorf("expected 1 lost, got %+v", stats)
+	}
+}
+
+func TestJitterBufferJitter(t *testing.T) {
+	jb := NewJitterBuffer(8000, 0, time.Second)
+	start := time.Now()
+	for i := 0; i < 200; i++ {
+		arrival := start.Add(time.Duration(i) * 20 * time.Millisecond)
+		if i%2 == 1 {
+			arrival = arrival.Add(10 * time.Millisecond)
+		}
+		jb.Push(rtpAt(uint16(i)), arrival)
+		popAll(jb, arrival)
+	}
+	stats := jb.Stats()
+	if stats.Jitter < 9*time.Millisecond || stats.Jitter > 11*time.Millisecond {
+		t.Errorf("expected jitter of about 10ms, got %v", stats.Jitter)
+	}
+	if stats.Delay != 3*stats.Jitter {
+		t.Errorf("expected delay of 3 jitters, got %v", stats.Delay)
+	}
+}
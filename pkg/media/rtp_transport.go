@@ -0,0 +1,294 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/code-100-precent/LingFramework/pkg/logger"
+	"go.uber.org/zap"
+)
+
+const maxRTPPacketSize = 1500
+
+var ErrNoRemoteAddr = errors.New("no remote address")
+
+// RTPTransportOptions configures an RTPTransport
+type RTPTransportOptions struct {
+	// Codec of the payloads, such as PCMU, PCMA, G722 or Opus of the codec
+	// registry. The payload type is PayloadType, or the static type of RFC
+	// 3551. PCM is carried as is, with the PayloadType set.
+	Codec CodecConfig
+	// Remote address to send to, otherwise the source of the first packet
+	// received (symmetric RTP).
+	Remote string
+	// The delays of the jitter buffer, DefaultJitterMinDelay and
+	// DefaultJitterMaxDelay by default.
+	MinDelay time.Duration
+	MaxDelay time.Duration
+}
+
+// RTPTransport sends and receives the payloads of Codec as RTP over UDP.
+// The packets received are reordered by a JitterBuffer and delivered by
+// Next in sequence; the packets of other payload types, such as the
+// telephone events, are ignored. The stream is locked to the first SSRC,
+// another one restarts it. The packets sent get a random SSRC, sequence
+// number and timestamp base, the timestamps advancing by the samples of
+// the payloads, and the marker bit on the first packet of a talkspurt.
+// The statistics are reported to the SessionMetrics of the attached session.
+type RTPTransport struct {
+	codec       CodecConfig
+	payloadType uint8
+	clockRate   int
+	conn        *net.UDPConn
+
+	mu      sync.Mutex
+	remote  *net.UDPAddr
+	jitter  *JitterBuffer
+	ssrc    uint32 // of the stream received
+	hasSSRC bool
+	ready   chan struct{} // signaled when a packet is pushed
+	closed  chan struct{}
+	session *MediaSession
+	sent    uint64
+
+	sendMu    sync.Mutex
+	sendSSRC  uint32
+	sendSeq   uint16
+	sendTS    uint32
+	sendFirst bool
+}
+
+// ListenRTP listens on the UDP addr, such as ":40000", see RTPTransport.
+func ListenRTP(addr string, opts RTPTransportOptions) (*RTPTransport, error) {
+	payloadType, err := RTPPayloadType(opts.Codec)
+	if err != nil {
+		return nil, err
+	}
+	var remote *net.UDPAddr
+	if opts.Remote != "" {
+		if remote, err = net.ResolveUDPAddr("udp", opts.Remote); err != nil {
+			return nil, err
+		}
+	}
+	local, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", local)
+	if err != nil {
+		return nil, err
+	}
+
+	clockRate := RTPClockRate(opts.Codec)
+	t := &RTPTransport{
+		codec:       opts.Codec,
+		payloadType: payloadType,
+		clockRate:   clockRate,
+		conn:        conn,
+		remote:      remote,
+		jitter:      NewJitterBuffer(clockRate, opts.MinDelay, opts.MaxDelay),
+		ready:       make(chan struct{}, 1),
+		closed:      make(chan struct{}),
+		sendSSRC:    rand.Uint32(),
+		sendSeq:     uint16(rand.Uint32()),
+		sendTS:      rand.Uint32(),
+		sendFirst:   true,
+	}
+	t.codec.PayloadType = payloadType
+	go t.readLoop()
+	return t, nil
+}
+
+func (t *RTPTransport) String() string {
+	return fmt.Sprintf("RTPTransport{Local: %s, Remote: %s, Codec: %s, PT: %d}", t.LocalAddr(), t.RemoteAddr(), t.codec.Codec, t.payloadType)
+}
+
+// LocalAddr returns the address listened on.
+func (t *RTPTransport) LocalAddr() net.Addr {
+	return t.conn.LocalAddr()
+}
+
+// RemoteAddr returns the address sent to, nil if not known yet.
+func (t *RTPTransport) RemoteAddr() net.Addr {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.remote == nil {
+		return nil
+	}
+	return t.remote
+}
+
+func (t *RTPTransport) Attach(s *MediaSession) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.session = s
+}
+
+func (t *RTPTransport) Codec() CodecConfig {
+	return t.codec
+}
+
+// Stats returns the statistics of the streams.
+func (t *RTPTransport) Stats() RTPStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.statsLocked()
+}
+
+func (t *RTPTransport) statsLocked() RTPStats {
+	stats := t.jitter.Stats()
+	stats.PacketsSent = t.sent
+	return stats
+}
+
+// reportLocked updates the SessionMetrics of the attached session.
+func (t *RTPTransport) reportLocked() {
+	if t.session != nil && t.session.metrics != nil {
+		t.session.metrics.UpdateRTPStats(t.conn.LocalAddr().String(), t.statsLocked())
+	}
+}
+
+func (t *RTPTransport) readLoop() {
+	buf := make([]byte, maxRTPPacketSize)
+	for {
+		n, addr, err := t.conn.ReadFromUDP(buf)
+		if err != nil {
+			select {
+			case <-t.closed:
+			default:
+				logger.Warn("rtp transport read failed", zap.Stringer("local", t.conn.LocalAddr()), zap.Error(err))
+			}
+			return
+		}
+		arrival := time.Now()
+		packet, err := ParseRTPPacket(append([]byte(nil), buf[:n]...))
+		if err != nil || packet.PayloadType != t.payloadType {
+			continue
+		}
+
+		t.mu.Lock()
+		if t.remote == nil {
+			t.remote = addr
+		}
+		if !t.hasSSRC || packet.SSRC != t.ssrc {
+			if t.hasSSRC {
+				logger.Info("rtp transport stream restarted", zap.Stringer("local", t.conn.LocalAddr()), zap.Uint32("ssrc", packet.SSRC))
+			}
+			t.jitter.Reset()
+			t.ssrc, t.hasSSRC = packet.SSRC, true
+		}
+		t.jitter.Push(packet, arrival)
+		t.reportLocked()
+		t.mu.Unlock()
+
+		select {
+		case t.ready <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Next returns the payload of the next packet of the jitter buffer as an
+// AudioPacket, waiting for it until ctx is done, or io.EOF once the
+// transport is closed.
+func (t *RTPTransport) Next(ctx context.Context) (MediaPacket, error) {
+	for {
+		t.mu.Lock()
+		packet, retryAt := t.jitter.Pop(time.Now())
+		t.reportLocked()
+		t.mu.Unlock()
+		if packet != nil {
+			return &AudioPacket{
+				Sequence: int(packet.SequenceNumber),
+				Payload:  packet.Payload,
+			}, nil
+		}
+
+		var timer *time.Timer
+		var retry <-chan time.Time
+		if !retryAt.IsZero() {
+			timer = time.NewTimer(time.Until(retryAt))
+			retry = timer.C
+		}
+		var err error
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-t.closed:
+			err = io.EOF
+		case <-t.ready:
+		case <-retry:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Send sends the payload of the AudioPacket in one RTP packet, the other
+// packets are ignored. The marker bit is set on the first packet and on
+// the first of each IsFirstPacket.
+func (t *RTPTransport) Send(ctx context.Context, packet MediaPacket) (int, error) {
+	audio, ok := packet.(*AudioPacket)
+	if !ok || len(audio.Payload) == 0 {
+		return 0, nil
+	}
+	select {
+	case <-t.closed:
+		return 0, ErrTransportClosed
+	default:
+	}
+	remote, _ := t.RemoteAddr().(*net.UDPAddr)
+	if remote == nil {
+		return 0, ErrNoRemoteAddr
+	}
+
+	t.sendMu.Lock()
+	rtp := &RTPPacket{
+		RTPHeader: RTPHeader{
+			Version:        RTPVersion,
+			Marker:         t.sendFirst || audio.IsFirstPacket,
+			PayloadType:    t.payloadType,
+			SequenceNumber: t.sendSeq,
+			Timestamp:      t.sendTS,
+			SSRC:           t.sendSSRC,
+		},
+		Payload: audio.Payload,
+	}
+	t.sendFirst = false
+	t.sendSeq++
+	t.sendTS += rtpTimestampStep(t.codec, len(audio.Payload))
+	t.sendMu.Unlock()
+
+	n, err := t.conn.WriteToUDP(rtp.Marshal(), remote)
+	if err != nil {
+		return n, err
+	}
+	t.mu.Lock()
+	t.sent++
+	t.reportLocked()
+	t.mu.Unlock()
+	return len(audio.Payload), nil
+}
+
+func (t *RTPTransport) Close() error {
+	t.mu.Lock()
+	select {
+	case <-t.closed:
+		t.mu.Unlock()
+		return nil
+	default:
+	}
+	close(t.closed)
+	t.mu.Unlock()
+	return t.conn.Close()
+}
@@ -0,0 +1,116 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRTPTransports(t *testing.T) {
+	codec := CodecConfig{Codec: "pcma", SampleRate: 8000, Channels: 1, BitDepth: 8}
+	receiver, err := ListenRTP("127.0.0.1:0", RTPTransportOptions{Codec: codec})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer receiver.Close()
+	sender, err := ListenRTP("127.0.0.1:0", RTPTransportOptions{Codec: codec, Remote: receiver.LocalAddr().String()})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer sender.Close()
+	if receiver.Codec().PayloadType != 8 {
+		t.Errorf("expected payload type 8, got %d", receiver.Codec().PayloadType)
+	}
+
+	session := NewDefaultSession()
+	receiver.Attach(session)
+	if _, err := receiver.Send(context.Background(), &AudioPacket{Payload: []byte{1}}); !errors.Is(err, ErrNoRemoteAddr) {
+		t.Errorf("expected ErrNoRemoteAddr, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for i := 0; i < 3; i++ {
+		payload := bytes.Repeat([]byte{byte(i)}, 160)
+		if n, err := sender.Send(ctx, &AudioPacket{Payload: payload}); err != nil || n != 160 {
+			t.Fatalf("unexpected send: %d, %v", n, err)
+		}
+		packet, err := receiver.Next(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if audio := packet.(*AudioPacket); !bytes.Equal(audio.Payload, payload) {
+			t.Errorf("packet %d: unexpected payload %x", i, audio.Payload[:4])
+		}
+	}
+
+	// the remote of the receiver is learnt from the packets
+	if n, err := receiver.Send(ctx, &AudioPacket{Payload: []byte{9, 9}}); err != nil || n != 2 {
+		t.Fatalf("unexpected send: %d, %v", n, err)
+	}
+	if packet, err := sender.Next(ctx); err != nil || !bytes.Equal(packet.(*AudioPacket).Payload, []byte{9, 9}) {
+		t.Errorf("unexpected reply: %v, %v", packet, err)
+	}
+
+	stats := session.GetRTPStats()
+	if stats.PacketsReceived != 3 || stats.PacketsSent != 1 || stats.PacketsLost != 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	if metrics := session.GetAllMetrics(); metrics["rtp_packets_received"] != uint64(3) {
+		t.Errorf("unexpected metrics: %v", metrics["rtp_packets_received"])
+	}
+
+	receiver.Close()
+	if _, err := receiver.Next(ctx); err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+}
+
+func TestRTPTransportReordersAndDropsOtherPayloads(t *testing.T) {
+	codec := CodecConfig{Codec: "pcmu", SampleRate: 8000}
+	receiver, err := ListenRTP("127.0.0.1:0", RTPTransportOptions{Codec: codec, MinDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer receiver.Close()
+	conn, err := net.Dial("udp", receiver.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer conn.Close()
+
+	send := func(seq uint16, pt uint8) {
+		packet := &RTPPacket{
+			RTPHeader: RTPHeader{Version: RTPVersion, PayloadType: pt, SequenceNumber: seq, Timestamp: uint32(seq) * 160, SSRC: 42},
+			Payload:   []byte{byte(seq)},
+		}
+		if _, err := conn.Write(packet.Marshal()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	send(1, 0)
+	send(101, 101) // a telephone event
+	send(3, 0)
+	send(2, 0)
+	send(5, 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, want := range []int{1, 2, 3, 5} {
+		packet, err := receiver.Next(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if audio := packet.(*AudioPacket); audio.Sequence != want {
+			t.Errorf("expected packet %d, got %d", want, audio.Sequence)
+		}
+	}
+	stats := receiver.Stats()
+	if stats.PacketsReceived != 4 || stats.PacketsLost != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"os"
 	"reflect"
 	"runtime/debug"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -273,6 +274,9 @@ type SessionMetrics struct {
 	inputTransportCount  int // Number of input transports
 	outputTransportCount int // Number of output transports
 	activeOutputCount    int // Number of active output transports
+
+	// RTP statistics
+	rtpStats map[string]RTPStats // RTP stream statistics by transport
 }
 
 // GetPacketCount returns the total number of packets processed
@@ -305,6 +309,7 @@ func (sm *SessionMetrics) GetAllMetrics() map[string]interface{} {
 	if sm.packetCount > 0 {
 		avgPacketSize = sm.totalPacketSize / sm.packetCount
 	}
+	rtp := sm.rtpStatsLocked()
 
 	return map[string]interface{}{
 		"packet_count":           sm.packetCount,
@@ -333,6 +338,13 @@ func (sm *SessionMetrics) GetAllMetrics() map[string]interface{} {
 		"input_transport_count":  sm.inputTransportCount,
 		"output_transport_count": sm.outputTransportCount,
 		"active_output_count":    sm.activeOutputCount,
+		"rtp_packets_received":   rtp.PacketsReceived,
+		"rtp_packets_lost":       rtp.PacketsLost,
+		"rtp_packets_late":       rtp.PacketsLate,
+		"rtp_packets_duplicated": rtp.PacketsDuplicated,
+		"rtp_packets_sent":       rtp.PacketsSent,
+		"rtp_loss_rate":          rtp.LossRate(),
+		"rtp_jitter":             rtp.Jitter.String(),
 	}
 }
 
@@ -401,6 +413,38 @@ func (sm *SessionMetrics) GetSessionDuration() time.Duration {
 	return sm.lastPacketTime.Sub(sm.firstPacketTime)
 }
 
+// UpdateRTPStats records the statistics of the RTP transport id
+func (sm *SessionMetrics) UpdateRTPStats(id string, stats RTPStats) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if sm.rtpStats == nil {
+		sm.rtpStats = make(map[string]RTPStats)
+	}
+	sm.rtpStats[id] = stats
+}
+
+// GetRTPStats returns the RTP statistics summed over the transports, the
+// highest jitter and delay
+func (sm *SessionMetrics) GetRTPStats() RTPStats {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return sm.rtpStatsLocked()
+}
+
+func (sm *SessionMetrics) rtpStatsLocked() RTPStats {
+	var total RTPStats
+	for _, stats := range sm.rtpStats {
+		total.PacketsReceived += stats.PacketsReceived
+		total.PacketsLost += stats.PacketsLost
+		total.PacketsLate += stats.PacketsLate
+		total.PacketsDuplicated += stats.PacketsDuplicated
+		total.PacketsSent += stats.PacketsSent
+		total.Jitter = max(total.Jitter, stats.Jitter)
+		total.Delay = max(total.Delay, stats.Delay)
+	}
+	return total
+}
+
 type MediaSession struct {
 	ctx          context.Context
 	cancel       context.CancelFunc
@@ -413,6 +457,7 @@ type MediaSession struct {
 	outputs      []*TransportManager
 	trace        MediaHandlerFunc
 	postHoooks   []SessionHook
+	taps         map[string][]PacketFilter
 
 	// New event-driven architecture
 	eventBus          *EventBus
@@ -683,6 +728,19 @@ func (s *MediaSession) PostHook(hooks ...SessionHook) *MediaSession {
 	return s
 }
 
+// Tap adds the filters observing the PCM packets received and sent,
+// DirectionInput or DirectionOutput, such as for recording. The packets
+// received are observed after the own filters of each input transport,
+// added by Serve. The packets sent are observed once when they are put to
+// the outputs, before the own filters of the output transports.
+func (s *MediaSession) Tap(direction string, filters ...PacketFilter) *MediaSession {
+	if s.taps == nil {
+		s.taps = make(map[string][]PacketFilter)
+	}
+	s.taps[direction] = append(s.taps[direction], filters...)
+	return s
+}
+
 // Handle error caused
 func (s *MediaSession) Error(handles ...ErrorHandler) *MediaSession {
 	s.errors = append(s.errors, handles...)
@@ -993,6 +1051,7 @@ func (s *MediaSession) Serve() error {
 
 	for idx := range s.inputs {
 		tl := s.inputs[idx]
+		tl.filters = append(slices.Clip(tl.filters), s.taps[DirectionInput]...)
 		go tl.processIncoming()
 	}
 
@@ -1040,12 +1099,20 @@ func (s *MediaSession) cleanup() {
 		tl := s.outputs[idx]
 		tl.cleanup()
 	}
+	for _, tap := range s.taps[DirectionOutput] {
+		_, _ = tap(&ClosePacket{Reason: "session cleanup"})
+	}
 }
 
 func (s *MediaSession) putPacket(direction string, packet MediaPacket) {
 	tls := s.inputs
 	if direction == DirectionOutput {
 		tls = s.outputs
+		for _, tap := range s.taps[DirectionOutput] {
+			if _, err := tap(packet); err != nil {
+				s.CauseError(s, err)
+			}
+		}
 	}
 
 	for idx := range tls {
@@ -1202,6 +1269,14 @@ func (s *MediaSession) GetSessionDuration() time.Duration {
 	return s.metrics.GetSessionDuration()
 }
 
+// GetRTPStats returns the statistics of the RTP transports
+func (s *MediaSession) GetRTPStats() RTPStats {
+	if s.metrics == nil {
+		return RTPStats{}
+	}
+	return s.metrics.GetRTPStats()
+}
+
 // processData is deprecated, events are now handled by event bus
 // This method is kept for backward compatibility but does nothing
 func (s *MediaSession) processData(data *MediaData) {
@@ -668,3 +668,24 @@ func (e *errorTransport) Codec() CodecConfig {
 func (e *errorTransport) Close() error {
 	return nil
 }
+
+func TestMediaSession_TapOutput(t *testing.T) {
+	session := NewDefaultSession()
+	var tapped []MediaPacket
+	session.Tap(DirectionOutput, func(packet MediaPacket) (bool, error) {
+		tapped = append(tapped, packet)
+		return false, nil
+	})
+	// the outputs carry different streams, the tap sees the packets put once
+	session.AddOutputTransport(newMockTransport(), func(packet MediaPacket) (bool, error) {
+		return true, nil
+	})
+	session.AddOutputTransport(newMockTransport())
+
+	packet := &AudioPacket{Payload: []byte{1, 2, 3}}
+	session.putPacket(DirectionOutput, packet)
+	session.putPacket(DirectionInput, &AudioPacket{Payload: []byte{4}})
+	if len(tapped) != 1 || tapped[0] != packet {
+		t.Errorf("expected the packet tapped once, got %v", tapped)
+	}
+}
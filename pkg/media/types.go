@@ -86,10 +86,10 @@ var (
 	Begin         = "begin"
 	End           = "end"
 	Hangup        = "hangup"
-	StartSpeaking = "speaking.start"
-	StartSilence  = "silence.start"
-	Transcribing  = "transcribing" // params: sentence string
-	Synthesizing  = "synthesizing" // params: result string
+	StartSpeaking = "speaking.start" // params: VADState
+	StartSilence  = "silence.start"  // params: VADState
+	Transcribing  = "transcribing"   // params: sentence string
+	Synthesizing  = "synthesizing"   // params: result string
 	StartPlay     = "play.start"
 	StopPlay      = "play.stop"
 	Completed     = "completed"
@@ -0,0 +1,304 @@
+package media
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"math/cmplx"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultVADSensitivity   = 0.5
+	DefaultVADFrameDuration = 20 * time.Millisecond
+	DefaultVADMinSpeech     = 60 * time.Millisecond
+	DefaultVADHangover      = 300 * time.Millisecond
+
+	vadMinEnergy   = -60.0 // dBFS, the quietest speech at the lowest sensitivity
+	vadNoiseFrames = 10    // to learn the noise floor at first
+	vadMaxZCR      = 0.25  // the zero-crossing rate of voiced speech is below
+)
+
+// VADOptions configures a VADProcessor
+type VADOptions struct {
+	// Sensitivity from 0 to 1, the higher detects the quieter and noisier
+	// speech. DefaultVADSensitivity by default.
+	Sensitivity float64
+	// SampleRate of the 16 bits mono PCM analysed, the SampleRate of the
+	// session by default.
+	SampleRate int
+	// FrameDuration of the frames analysed, DefaultVADFrameDuration by default.
+	FrameDuration time.Duration
+	// MinSpeech is the speech needed to start speaking, DefaultVADMinSpeech
+	// by default.
+	MinSpeech time.Duration
+	// Hangover is the silence needed to stop speaking, DefaultVADHangover
+	// by default.
+	Hangover time.Duration
+}
+
+// VADState is the parameter of the StartSpeaking and StartSilence states.
+// The times are the offsets in the audio analysed.
+type VADState struct {
+	At       time.Duration `json:"at"`       // when the speech or silence began
+	Duration time.Duration `json:"duration"` // of the speech ended, for StartSilence
+	Energy   float64       `json:"energy"`   // of the last frame, in dBFS
+}
+
+// VADFeatures are the features of an analysed frame
+type VADFeatures struct {
+	Energy   float64 // RMS energy in dBFS
+	ZCR      float64 // zero crossings per sample
+	Flatness float64 // spectral flatness, 0 for a tone to 1 for white noise
+}
+
+// VADProcessor detects the voice activity of the audio packets received,
+// the synthesized ones are ignored, and emits StartSpeaking and
+// StartSilence with a VADState. A frame is speech when its energy is above
+// the noise floor, learnt from the silent frames, by a margin decreasing
+// with the sensitivity, and it's voiced: a low zero-crossing rate or a
+// spectrum less flat than noise. Speaking starts after MinSpeech of speech
+// and stops after Hangover of silence.
+type VADProcessor struct {
+	*BaseProcessor
+	opts VADOptions
+
+	mu         sync.Mutex
+	sampleRate int
+	buffer     []byte
+	frames     int // analysed
+	noiseFloor float64
+	speaking   bool
+	runStart   int // frame the current run of speech or silence began
+	runFrames  int
+	speechAt   int // frame speaking started
+	changes    []StateChange
+}
+
+// NewVADProcessor creates the "vad" processor of PriorityNormal.
+func NewVADProcessor(opts VADOptions) *VADProcessor {
+	if opts.Sensitivity <= 0 || opts.Sensitivity > 1 {
+		opts.Sensitivity = DefaultVADSensitivity
+	}
+	if opts.FrameDuration <= 0 {
+		opts.FrameDuration = DefaultVADFrameDuration
+	}
+	if opts.MinSpeech <= 0 {
+		opts.MinSpeech = DefaultVADMinSpeech
+	}
+	if opts.Hangover <= 0 {
+		opts.Hangover = DefaultVADHangover
+	}
+	return &VADProcessor{
+		BaseProcessor: NewBaseProcessor("vad", PriorityNormal),
+		opts:          opts,
+		noiseFloor:    vadMinEnergy,
+	}
+}
+
+// CanHandle checks if this is an audio packet received
+func (v *VADProcessor) CanHandle(ctx context.Context, event *MediaEvent) bool {
+	if !v.BaseProcessor.CanHandle(ctx, event) || event.Type != EventTypePacket {
+		return false
+	}
+	audio, ok := event.Payload.(*AudioPacket)
+	return ok && !audio.IsSynthesized
+}
+
+// Process analyses the samples of the packet and emits the state changes
+func (v *VADProcessor) Process(ctx context.Context, session *MediaSession, event *MediaEvent) error {
+	audio, ok := event.Payload.(*AudioPacket)
+	if !ok {
+		return nil
+	}
+	sampleRate := v.opts.SampleRate
+	if sampleRate <= 0 {
+		sampleRate = session.SampleRate
+	}
+	for _, change := range v.Write(audio.Payload, sampleRate) {
+		session.EmitState(v, change.State, change.Params...)
+	}
+	return nil
+}
+
+// Speaking reports whether speech is detected
+func (v *VADProcessor) Speaking() bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.speaking
+}
+
+// Reset forgets the audio analysed, the noise floor included.
+func (v *VADProcessor) Reset() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.buffer = nil
+	v.frames = 0
+	v.noiseFloor = vadMinEnergy
+	v.speaking = false
+	v.runStart, v.runFrames, v.speechAt = 0, 0, 0
+}
+
+// Write analyses the 16 bits mono PCM samples at sampleRate, the partial
+// frame is kept for the next samples, and returns the state changes.
+func (v *VADProcessor) Write(pcm []byte, sampleRate int) []StateChange {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if sampleRate <= 0 {
+		sampleRate = 16000
+	}
+	if sampleRate != v.sampleRate {
+		v.sampleRate, v.buffer = sampleRate, nil
+	}
+
+	frameSize := 2 * max(int(int64(sampleRate)*int64(v.opts.FrameDuration)/int64(time.Second)), 1)
+	v.buffer = append(v.buffer, pcm...)
+	v.changes = nil
+	for len(v.buffer) >= frameSize {
+		v.analyse(AnalyseVADFrame(v.buffer[:frameSize]))
+		v.buffer = v.buffer[frameSize:]
+	}
+	if len(v.buffer) == 0 {
+		v.buffer = nil
+	}
+	return v.changes
+}
+
+func (v *VADProcessor) analyse(features VADFeatures) {
+	frame := v.frames
+	v.frames++
+	energy := max(features.Energy, -100) // the digital silence is -Inf
+
+	speech := v.isSpeech(features)
+	if !speech && (energy < v.noiseFloor+v.margin() || v.frames <= vadNoiseFrames) {
+		// follow the noise, faster down than up
+		rate := 0.05
+		if energy < v.noiseFloor || v.frames <= vadNoiseFrames {
+			rate = 0.3
+		}
+		v.noiseFloor += (energy - v.noiseFloor) * rate
+	}
+
+	if speech != v.speaking {
+		if v.runFrames == 0 {
+			v.runStart = frame
+		}
+		v.runFrames++
+	} else {
+		v.runFrames = 0
+	}
+
+	switch {
+	case !v.speaking && v.runFrames > 0 && v.duration(v.runFrames) >= v.opts.MinSpeech:
+		v.speaking, v.speechAt, v.runFrames = true, v.runStart, 0
+		v.changes = append(v.changes, StateChange{State: StartSpeaking, Params: []any{VADState{
+			At:     v.duration(v.speechAt),
+			Energy: energy,
+		}}})
+	case v.speaking && v.runFrames > 0 && v.duration(v.runFrames) >= v.opts.Hangover:
+		v.speaking, v.runFrames = false, 0
+		v.changes = append(v.changes, StateChange{State: StartSilence, Params: []any{VADState{
+			At:       v.duration(v.runStart),
+			Duration: v.duration(v.runStart - v.speechAt),
+			Energy:   energy,
+		}}})
+	}
+}
+
+// margin returns the dB above the noise floor of speech, 6dB at the
+// highest sensitivity to 18dB at the lowest.
+func (v *VADProcessor) margin() float64 {
+	return 6 + 12*(1-v.opts.Sensitivity)
+}
+
+func (v *VADProcessor) isSpeech(f VADFeatures) bool {
+	minEnergy := vadMinEnergy + 20*(1-v.opts.Sensitivity)
+	if f.Energy < minEnergy || f.Energy < v.noiseFloor+v.margin() {
+		return false
+	}
+	return f.ZCR < vadMaxZCR || f.Flatness < 0.2+0.3*v.opts.Sensitivity
+}
+
+func (v *VADProcessor) duration(frames int) time.Duration {
+	return time.Duration(frames) * v.opts.FrameDuration
+}
+
+// AnalyseVADFrame returns the features of the frame of 16 bits mono PCM
+func AnalyseVADFrame(pcm []byte) VADFeatures {
+	n := len(pcm) / 2
+	if n == 0 {
+		return VADFeatures{Energy: math.Inf(-1)}
+	}
+	samples := make([]float64, n)
+	var sum float64
+	crossings := 0
+	for i := range samples {
+		samples[i] = float64(int16(binary.LittleEndian.Uint16(pcm[2*i:]))) / 32768
+		sum += samples[i] * samples[i]
+		if i > 0 && (samples[i] >= 0) != (samples[i-1] >= 0) {
+			crossings++
+		}
+	}
+	rms := math.Sqrt(sum / float64(n))
+	features := VADFeatures{
+		Energy:   math.Inf(-1),
+		ZCR:      float64(crossings) / float64(n),
+		Flatness: 1,
+	}
+	if rms > 0 {
+		features.Energy = 20 * math.Log10(rms)
+		features.Flatness = spectralFlatness(samples)
+	}
+	return features
+}
+
+// spectralFlatness returns the ratio of the geometric to the arithmetic
+// mean of the power spectrum of the Hann windowed samples
+func spectralFlatness(samples []float64) float64 {
+	size := 1
+	for size < len(samples) {
+		size <<= 1
+	}
+	spectrum := make([]complex128, size)
+	for i, s := range samples {
+		window := 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(len(samples)))
+		spectrum[i] = complex(s*window, 0)
+	}
+	fft(spectrum)
+
+	var logSum, sum float64
+	bins := size / 2
+	for _, c := range spectrum[1 : bins+1] {
+		power := real(c)*real(c) + imag(c)*imag(c) + 1e-12
+		logSum += math.Log(power)
+		sum += power
+	}
+	return math.Exp(logSum/float64(bins)) / (sum / float64(bins))
+}
+
+// fft transforms x in place, its length is a power of 2
+func fft(x []complex128) {
+	n := len(x)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Exp(complex(0, -2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				even, odd := x[start+k], x[start+k+size/2]*w
+				x[start+k], x[start+k+size/2] = even+odd, even-odd
+				w *= step
+			}
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package media
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// vadSignal returns duration of 16 bits PCM at 16kHz of the amplitude,
+// a 200Hz tone or white noise
+func vadSignal(duration time.Duration, amplitude float64, noise bool) []byte {
+	n := int(16000 * duration / time.Second)
+	pcm := make([]byte, 2*n)
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < n; i++ {
+		s := math.Sin(2 * math.Pi * 200 * float64(i) / 16000)
+		if noise {
+			s = rng.Float64()*2 - 1
+		}
+		binary.LittleEndian.PutUint16(pcm[2*i:], uint16(int16(s*amplitude*32767)))
+	}
+	return pcm
+}
+
+func TestAnalyseVADFrame(t *testing.T) {
+	tone := AnalyseVADFrame(vadSignal(20*time.Millisecond, 0.5, false))
+	if tone.Energy < -10 || tone.Energy > -8 || tone.ZCR > 0.05 || tone.Flatness > 0.1 {
+		t.Errorf("unexpected tone features: %+v", tone)
+	}
+	noise := AnalyseVADFrame(vadSignal(20*time.Millisecond, 0.5, true))
+	if noise.ZCR < 0.4 || noise.Flatness < 0.3 {
+		t.Errorf("unexpected noise features: %+v", noise)
+	}
+	if silence := AnalyseVADFrame(make([]byte, 640)); !math.IsInf(silence.Energy, -1) {
+		t.Errorf("unexpected silence features: %+v", silence)
+	}
+}
+
+func TestVADProcessorStates(t *testing.T) {
+	vad := NewVADProcessor(VADOptions{})
+	var changes []StateChange
+	write := func(pcm []byte) {
+		// in packets of 30ms, not aligned on the frames
+		for len(pcm) > 0 {
+			n := min(len(pcm), 960)
+			changes = append(changes, vad.Write(pcm[:n], 16000)...)
+			pcm = pcm[n:]
+		}
+	}
+	write(vadSignal(500*time.Millisecond, 0.005, true))
+	write(vadSignal(time.Second, 0.3, false))
+	if !vad.Speaking() {
+		t.Errorf("expected speaking")
+	}
+	write(vadSignal(100*time.Millisecond, 0.005, true)) // a pause shorter than the hangover
+	write(vadSignal(200*time.Millisecond, 0.3, false))
+	write(vadSignal(time.Second, 0.005, true))
+
+	if len(changes) != 2 {
+		t.Fatalf("expected 2 changes, got %+v", changes)
+	}
+	start, silence := changes[0], changes[1]
+	if start.State != StartSpeaking || start.Params[0].(VADState).At != 500*time.Millisecond {
+		t.Errorf("unexpected start: %+v", start)
+	}
+	state := silence.Params[0].(VADState)
+	if silence.State != StartSilence || state.At != 1800*time.Millisecond || state.Duration != 1300*time.Millisecond {
+		t.Errorf("unexpected silence: %+v", silence)
+	}
+}
+
+func TestVADProcessorIgnoresNoise(t *testing.T) {
+	vad := NewVADProcessor(VADOptions{Sensitivity: 0.3})
+	changes := vad.Write(vadSignal(500*time.Millisecond, 0.001, true), 16000)
+	changes = append(changes, vad.Write(vadSignal(time.Second, 0.3, true), 16000)...)
+	changes = append(changes, vad.Write(make([]byte, 16000), 16000)...)
+	if len(changes) != 0 || vad.Speaking() {
+		t.Errorf("unexpected changes: %+v", changes)
+	}
+}
+
+func TestVADProcessorSession(t *testing.T) {
+	session := NewDefaultSession()
+	defer session.Close()
+	vad := NewVADProcessor(VADOptions{MinSpeech: 20 * time.Millisecond})
+	states := make(chan StateChange, 4)
+	session.RegisterProcessor(vad).On(StartSpeaking, func(event StateChange) {
+		states <- event
+	})
+
+	if vad.CanHandle(context.Background(), &MediaEvent{Type: EventTypePacket, Payload: &AudioPacket{IsSynthesized: true}}) {
+		t.Errorf("expected the synthesized packets ignored")
+	}
+	event := &MediaEvent{Type: EventTypePacket, Payload: &AudioPacket{Payload: vadSignal(100*time.Millisecond, 0.3, false)}}
+	if !vad.CanHandle(context.Background(), event) {
+		t.Fatalf("expected the packet handled")
+	}
+	if err := vad.Process(context.Background(), session, event); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	select {
+	case state := <-states:
+		if state.Params[0].(VADState).At != 0 {
+			t.Errorf("unexpected state: %+v", state)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected StartSpeaking")
+	}
+}
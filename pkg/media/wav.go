@@ -0,0 +1,130 @@
+package media
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	WavHeaderSize = 44 // the header written by WavHeader
+
+	wavFormatPCM        = 1
+	wavFormatALaw       = 6
+	wavFormatMuLaw      = 7
+	wavFormatExtensible = 0xFFFE
+)
+
+var ErrInvalidWav = errors.New("invalid wav")
+
+// ReadWavHeader reads the RIFF header of r up to the samples of the data
+// chunk, the chunks besides "fmt " and "data" are skipped. It returns the
+// codec of the samples, PCM, PCMA or PCMU, and the size of the data chunk,
+// which may be 0 or wrong for the files still being written.
+func ReadWavHeader(r io.Reader) (CodecConfig, int64, error) {
+	var codec CodecConfig
+	var riff [12]byte
+	if _, err := io.ReadFull(r, riff[:]); err != nil {
+		return codec, 0, fmt.Errorf("%w: %v", ErrInvalidWav, err)
+	}
+	if string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return codec, 0, fmt.Errorf("%w: not RIFF WAVE", ErrInvalidWav)
+	}
+
+	hasFormat := false
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(r, chunk[:]); err != nil {
+			return codec, 0, fmt.Errorf("%w: missing data chunk", ErrInvalidWav)
+		}
+		id, size := string(chunk[0:4]), int64(binary.LittleEndian.Uint32(chunk[4:8]))
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return codec, 0, fmt.Errorf("%w: short fmt chunk", ErrInvalidWav)
+			}
+			data := make([]byte, size+size%2)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return codec, 0, fmt.Errorf("%w: %v", ErrInvalidWav, err)
+			}
+			var err error
+			if codec, err = parseWavFormat(data[:size]); err != nil {
+				return codec, 0, err
+			}
+			hasFormat = true
+		case "data":
+			if !hasFormat {
+				return codec, 0, fmt.Errorf("%w: data before fmt chunk", ErrInvalidWav)
+			}
+			return codec, size, nil
+		default:
+			if _, err := io.CopyN(io.Discard, r, size+size%2); err != nil {
+				return codec, 0, fmt.Errorf("%w: %v", ErrInvalidWav, err)
+			}
+		}
+	}
+}
+
+func parseWavFormat(data []byte) (CodecConfig, error) {
+	format := binary.LittleEndian.Uint16(data[0:2])
+	if format == wavFormatExtensible && len(data) >= 26 {
+		// the format is the first two bytes of the sub format GUID
+		format = binary.LittleEndian.Uint16(data[24:26])
+	}
+	codec := CodecConfig{
+		Channels:   int(binary.LittleEndian.Uint16(data[2:4])),
+		SampleRate: int(binary.LittleEndian.Uint32(data[4:8])),
+		BitDepth:   int(binary.LittleEndian.Uint16(data[14:16])),
+	}
+	switch {
+	case format == wavFormatPCM && codec.BitDepth%8 == 0 && codec.BitDepth > 0:
+		codec.Codec = "pcm"
+	case format == wavFormatALaw && codec.BitDepth == 8:
+		codec.Codec = "pcma"
+	case format == wavFormatMuLaw && codec.BitDepth == 8:
+		codec.Codec = "pcmu"
+	default:
+		return codec, fmt.Errorf("%w: format %d with %d bits", ErrCodecNotSupported, format, codec.BitDepth)
+	}
+	if codec.Channels <= 0 || codec.SampleRate <= 0 {
+		return codec, fmt.Errorf("%w: %d channels at %d Hz", ErrInvalidWav, codec.Channels, codec.SampleRate)
+	}
+	return codec, nil
+}
+
+// WavHeader returns the header of dataSize bytes of samples of codec, PCM,
+// PCMA or PCMU.
+func WavHeader(codec CodecConfig, dataSize int64) ([]byte, error) {
+	var format uint16
+	switch strings.ToLower(codec.Codec) {
+	case "pcm", "":
+		format = wavFormatPCM
+	case "pcma":
+		format, codec.BitDepth = wavFormatALaw, 8
+	case "pcmu":
+		format, codec.BitDepth = wavFormatMuLaw, 8
+	default:
+		return nil, fmt.Errorf("%w: %s in wav", ErrCodecNotSupported, codec.Codec)
+	}
+	if codec.BitDepth <= 0 || codec.BitDepth%8 != 0 || codec.Channels <= 0 || codec.SampleRate <= 0 {
+		return nil, fmt.Errorf("%w: %s", ErrCodecNotSupported, codec)
+	}
+
+	blockAlign := codec.Channels * codec.BitDepth / 8
+	header := make([]byte, WavHeaderSize)
+	copy(header[0:4], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:8], uint32(36+dataSize+dataSize%2))
+	copy(header[8:16], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(header[16:20], 16)
+	binary.LittleEndian.PutUint16(header[20:22], format)
+	binary.LittleEndian.PutUint16(header[22:24], uint16(codec.Channels))
+	binary.LittleEndian.PutUint32(header[24:28], uint32(codec.SampleRate))
+	binary.LittleEndian.PutUint32(header[28:32], uint32(codec.SampleRate*blockAlign))
+	binary.LittleEndian.PutUint16(header[32:34], uint16(blockAlign))
+	binary.LittleEndian.PutUint16(header[34:36], uint16(codec.BitDepth))
+	copy(header[36:40], "data")
+	binary.LittleEndian.PutUint32(header[40:44], uint32(dataSize))
+	return header, nil
+}
@@ -0,0 +1,83 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+func TestWavHeader(t *testing.T) {
+	codec := CodecConfig{Codec: "pcm", SampleRate: 44100, Channels: 2, BitDepth: 24}
+	header, err := WavHeader(codec, 1000)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(header) != WavHeaderSize {
+		t.Fatalf("expected %d bytes header, got %d", WavHeaderSize, len(header))
+	}
+	if got := binary.LittleEndian.Uint32(header[28:32]); got != 44100*6 {
+		t.Errorf("expected byte rate %d, got %d", 44100*6, got)
+	}
+
+	parsed, size, err := ReadWavHeader(bytes.NewReader(header))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if parsed != codec || size != 1000 {
+		t.Errorf("expected %s of 1000 bytes, got %s of %d bytes", codec, parsed, size)
+	}
+
+	header, _ = WavHeader(CodecConfig{Codec: "PCMU", SampleRate: 8000, Channels: 1}, 0)
+	parsed, _, err = ReadWavHeader(bytes.NewReader(header))
+	if err != nil || parsed.Codec != "pcmu" || parsed.BitDepth != 8 {
+		t.Errorf("expected pcmu with 8 bits, got %s, %v", parsed, err)
+	}
+
+	if _, err := WavHeader(CodecConfig{Codec: "opus", SampleRate: 48000, Channels: 1}, 0); !errors.Is(err, ErrCodecNotSupported) {
+		t.Errorf("expected ErrCodecNotSupported, got %v", err)
+	}
+}
+
+func TestReadWavHeaderChunks(t *testing.T) {
+	// WAVE_FORMAT_EXTENSIBLE of 16-bit PCM, with a LIST chunk of odd size
+	var buf bytes.Buffer
+	buf.WriteString("RIFF\x00\x00\x00\x00WAVE")
+	buf.WriteString("LIST")
+	binary.Write(&buf, binary.LittleEndian, uint32(3))
+	buf.WriteString("abc\x00")
+	buf.WriteString("fmt ")
+	binary.Write(&buf, binary.LittleEndian, uint32(40))
+	binary.Write(&buf, binary.LittleEndian, []uint16{wavFormatExtensible, 1})
+	binary.Write(&buf, binary.LittleEndian, []uint32{16000, 32000})
+	binary.Write(&buf, binary.LittleEndian, []uint16{2, 16, 22, 16})
+	binary.Write(&buf, binary.LittleEndian, uint32(4))
+	binary.Write(&buf, binary.LittleEndian, uint16(wavFormatPCM))
+	buf.Write(make([]byte, 14))
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, uint32(4))
+	buf.Write([]byte{1, 2, 3, 4})
+
+	r := bytes.NewReader(buf.Bytes())
+	codec, size, err := ReadWavHeader(r)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := CodecConfig{Codec: "pcm", SampleRate: 16000, Channels: 1, BitDepth: 16}
+	if codec != expected || size != 4 {
+		t.Errorf("expected %s of 4 bytes, got %s of %d bytes", expected, codec, size)
+	}
+	if r.Len() != 4 {
+		t.Errorf("expected the samples left, got %d bytes", r.Len())
+	}
+
+	for _, data := range [][]byte{
+		[]byte("RIFF"),
+		[]byte("RIFF\x00\x00\x00\x00AVI LIST"),
+		buf.Bytes()[:12],
+	} {
+		if _, _, err := ReadWavHeader(bytes.NewReader(data)); !errors.Is(err, ErrInvalidWav) {
+			t.Errorf("expected ErrInvalidWav of %q, got %v", data, err)
+		}
+	}
+}
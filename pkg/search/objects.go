@@ -0,0 +1,72 @@
+package search
+
+import (
+	"context"
+	"strings"
+
+	LingEcho "github.com/code-100-precent/LingFramework"
+)
+
+// ObjectIndexer 把 Engine 适配为 LingEcho.SearchIndexer，用于 WebObject 的自动索引
+// 文档 ID 为 "{type}:{主键}"，多个 WebObject 可以共用一个索引
+type ObjectIndexer struct {
+	engine Engine
+}
+
+var _ LingEcho.SearchIndexer = (*ObjectIndexer)(nil)
+
+func NewObjectIndexer(engine Engine) *ObjectIndexer {
+	return &ObjectIndexer{engine: engine}
+}
+
+func objectDocID(docType, id string) string {
+	return docType + ":" + id
+}
+
+// Index 索引文档，多个文档时批量写入
+func (x *ObjectIndexer) Index(ctx context.Context, docType string, docs []LingEcho.SearchDoc) error {
+	items := make([]Doc, 0, len(docs))
+	for _, d := range docs {
+		items = append(items, Doc{ID: objectDocID(docType, d.ID), Type: docType, Fields: d.Fields})
+	}
+	if len(items) == 1 {
+		return x.engine.Index(ctx, items[0])
+	}
+	return x.engine.IndexBatch(ctx, items)
+}
+
+func (x *ObjectIndexer) Delete(ctx context.Context, docType string, ids []string) error {
+	for _, id := range ids {
+		if err := x.engine.Delete(ctx, objectDocID(docType, id)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Search 在 fields 中匹配关键词，返回主键，相关度高的在前
+// 关键词按 Match 查询处理，不解析 QueryString 语法
+func (x *ObjectIndexer) Search(ctx context.Context, docType, keyword string, fields []string, limit int) ([]string, error) {
+	req := SearchRequest{
+		MustTerms:     map[string][]string{"type": {docType}},
+		MinShould:     1,
+		Size:          limit,
+		IncludeFields: []string{"type"},
+	}
+	for _, f := range fields {
+		req.Matches = append(req.Matches, ClauseMatch{Field: f, Query: keyword})
+	}
+	result, err := x.engine.Search(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := objectDocID(docType, "")
+	ids := make([]string, 0, len(result.Hits))
+	for _, hit := range result.Hits {
+		if strings.HasPrefix(hit.ID, prefix) {
+			ids = append(ids, strings.TrimPrefix(hit.ID, prefix))
+		}
+	}
+	return ids, nil
+}
@@ -0,0 +1,46 @@
+package search
+
+import (
+	"context"
+	"testing"
+
+	LingEcho "github.com/code-100-precent/LingFramework"
+)
+
+func TestObjectIndexer(t *testing.T) {
+	engine, indexPath := setupTestEngine(t)
+	defer cleanupTestEngine(t, engine, indexPath)
+
+	ctx := context.Background()
+	x := NewObjectIndexer(engine)
+	err := x.Index(ctx, "user", []LingEcho.SearchDoc{
+		{ID: "1", Fields: map[string]any{"displayName": "Alice Gopher"}},
+		{ID: "2", Fields: map[string]any{"displayName": "Bob"}},
+	})
+	if err != nil {
+		t.Fatalf("Index failed: %v", err)
+	}
+	// the same id of another type
+	if err := x.Index(ctx, "group", []LingEcho.SearchDoc{{ID: "1", Fields: map[string]any{"displayName": "Gopher club"}}}); err != nil {
+		t.Fatalf("Index failed: %v", err)
+	}
+
+	ids, err := x.Search(ctx, "user", "gopher", []string{"displayName"}, 10)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(ids) != 1 || ids[0] != "1" {
+		t.Fatalf("Expected [1], got %v", ids)
+	}
+
+	if err := x.Delete(ctx, "user", []string{"1"}); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	ids, err = x.Search(ctx, "user", "gopher", []string{"displayName"}, 10)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(ids) != 0 {
+		t.Fatalf("Expected no hits, got %v", ids)
+	}
+}
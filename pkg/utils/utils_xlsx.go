@@ -0,0 +1,317 @@
+package utils
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/></Types>`
+
+const xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`
+
+const xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`
+
+const xlsxWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets></workbook>`
+
+const xlsxSheetHeader = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+
+const xlsxSheetFooter = `</sheetData></worksheet>`
+
+// XLSXWriter writes a single sheet workbook row by row, the rows are
+// streamed to the underlying writer, so it can be used for large exports.
+type XLSXWriter struct {
+	zw    *zip.Writer
+	sheet io.Writer
+	row   int
+}
+
+// NewXLSXWriter creates a workbook with one sheet named sheetName.
+func NewXLSXWriter(w io.Writer, sheetName string) (*XLSXWriter, error) {
+	if sheetName == "" {
+		sheetName = "Sheet1"
+	}
+	var name strings.Builder
+	xml.EscapeText(&name, []byte(sheetName))
+
+	zw := zip.NewWriter(w)
+	parts := []struct{ name, body string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", fmt.Sprintf(xlsxWorkbook, name.String())},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+	}
+	for _, p := range parts {
+		f, err := zw.Create(p.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(f, p.body); err != nil {
+			return nil, err
+		}
+	}
+
+	sheet, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(sheet, xlsxSheetHeader); err != nil {
+		return nil, err
+	}
+	return &XLSXWriter{zw: zw, sheet: sheet}, nil
+}
+
+// WriteRow appends a row, numbers and bools are written as typed cells,
+// time.Time as RFC3339 text and everything else as inline strings.
+func (x *XLSXWriter) WriteRow(cells []any) error {
+	x.row++
+	var b strings.Builder
+	fmt.Fprintf(&b, `<row r="%d">`, x.row)
+	for i, cell := range cells {
+		ref := XLSXColumnName(i) + strconv.Itoa(x.row)
+		switch v := cell.(type) {
+		case nil:
+			continue
+		case bool:
+			val := 0
+			if v {
+				val = 1
+			}
+			fmt.Fprintf(&b, `<c r="%s" t="b"><v>%d</v></c>`, ref, val)
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+			fmt.Fprintf(&b, `<c r="%s"><v>%d</v></c>`, ref, v)
+		case float32:
+			fmt.Fprintf(&b, `<c r="%s"><v>%s</v></c>`, ref, strconv.FormatFloat(float64(v), 'f', -1, 32))
+		case float64:
+			fmt.Fprintf(&b, `<c r="%s"><v>%s</v></c>`, ref, strconv.FormatFloat(v, 'f', -1, 64))
+		case time.Time:
+			fmt.Fprintf(&b, `<c r="%s" t="inlineStr"><is><t>%s</t></is></c>`, ref, v.Format(time.RFC3339))
+		default:
+			var text strings.Builder
+			xml.EscapeText(&text, []byte(fmt.Sprint(v)))
+			fmt.Fprintf(&b, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, text.String())
+		}
+	}
+	b.WriteString(`</row>`)
+	_, err := io.WriteString(x.sheet, b.String())
+	return err
+}
+
+// Close finishes the sheet and the zip archive, it doesn't close the underlying writer.
+func (x *XLSXWriter) Close() error {
+	if _, err := io.WriteString(x.sheet, xlsxSheetFooter); err != nil {
+		return err
+	}
+	return x.zw.Close()
+}
+
+// XLSXColumnName convert zero based column index to the column letters, 0 => A, 27 => AB.
+func XLSXColumnName(idx int) string {
+	name := ""
+	for idx >= 0 {
+		name = string(rune('A'+idx%26)) + name
+		idx = idx/26 - 1
+	}
+	return name
+}
+
+// xlsxColumnIndex convert cell reference to zero based column index, AB3 => 27.
+func xlsxColumnIndex(ref string) int {
+	idx := 0
+	for _, ch := range ref {
+		if ch < 'A' || ch > 'Z' {
+			break
+		}
+		idx = idx*26 + int(ch-'A') + 1
+	}
+	return idx - 1
+}
+
+// XLSXReader reads the rows of the first sheet of a workbook as strings.
+type XLSXReader struct {
+	zf      *zip.Reader
+	rc      io.ReadCloser
+	decoder *xml.Decoder
+	strings []string
+}
+
+// NewXLSXReader opens the first sheet of the workbook.
+func NewXLSXReader(r io.ReaderAt, size int64) (*XLSXReader, error) {
+	zf, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	x := &XLSXReader{zf: zf}
+	var sheets []string
+	for _, f := range zf.File {
+		switch {
+		case f.Name == "xl/sharedStrings.xml":
+			if err := x.readSharedStrings(f); err != nil {
+				return nil, err
+			}
+		case path.Dir(f.Name) == "xl/worksheets" && strings.HasSuffix(f.Name, ".xml"):
+			sheets = append(sheets, f.Name)
+		}
+	}
+	if len(sheets) == 0 {
+		return nil, errors.New("xlsx: no worksheet")
+	}
+	// sheet1.xml, sheet2.xml ...
+	sort.Slice(sheets, func(i, j int) bool {
+		if len(sheets[i]) != len(sheets[j]) {
+			return len(sheets[i]) < len(sheets[j])
+		}
+		return sheets[i] < sheets[j]
+	})
+	for _, f := range zf.File {
+		if f.Name == sheets[0] {
+			x.rc, err = f.Open()
+			if err != nil {
+				return nil, err
+			}
+			x.decoder = xml.NewDecoder(x.rc)
+			break
+		}
+	}
+	return x, nil
+}
+
+func (x *XLSXReader) readSharedStrings(f *zip.File) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	decoder := xml.NewDecoder(rc)
+	var text strings.Builder
+	inText := false
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "si":
+				text.Reset()
+			case "t":
+				inText = true
+			case "rPh": // phonetic hints are not part of the value
+				decoder.Skip()
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "si":
+				x.strings = append(x.strings, text.String())
+			case "t":
+				inText = false
+			}
+		case xml.CharData:
+			if inText {
+				text.Write(t)
+			}
+		}
+	}
+}
+
+// Read returns the next row, missing cells are filled with empty strings.
+// It returns io.EOF when no more rows.
+func (x *XLSXReader) Read() ([]string, error) {
+	var row []string
+	inRow := false
+	var cellType, cellValue string
+	col := -1
+	inValue := false
+	for {
+		tok, err := x.decoder.Token()
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "row":
+				inRow = true
+			case "c":
+				col++
+				cellType, cellValue = "", ""
+				for _, attr := range t.Attr {
+					switch attr.Name.Local {
+					case "r":
+						col = xlsxColumnIndex(attr.Value)
+					case "t":
+						cellType = attr.Value
+					}
+				}
+			case "v", "t":
+				inValue = true
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "row":
+				if inRow {
+					return row, nil
+				}
+			case "c":
+				if cellType == "s" {
+					idx, err := strconv.Atoi(cellValue)
+					if err != nil || idx < 0 || idx >= len(x.strings) {
+						return nil, fmt.Errorf("xlsx: invalid shared string %q", cellValue)
+					}
+					cellValue = x.strings[idx]
+				} else if cellType == "b" {
+					cellValue = strconv.FormatBool(cellValue == "1")
+				}
+				for len(row) <= col {
+					row = append(row, "")
+				}
+				row[col] = cellValue
+			case "v", "t":
+				inValue = false
+			}
+		case xml.CharData:
+			if inValue {
+				cellValue += string(t)
+			}
+		}
+	}
+}
+
+// Close closes the opened sheet.
+func (x *XLSXReader) Close() error {
+	if x.rc != nil {
+		return x.rc.Close()
+	}
+	return nil
+}
+
+// XLSXSerialToTime convert the serial date number of Excel to time.
+func XLSXSerialToTime(serial float64) time.Time {
+	// 1899-12-30 is the day 0 of the 1900 date system (with the 1900 leap year bug)
+	base := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+	days := math.Floor(serial)
+	nanos := math.Round((serial - days) * 86400 * 1e9)
+	return base.AddDate(0, 0, int(days)).Add(time.Duration(nanos))
+}
@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestXLSXColumnName(t *testing.T) {
+	assert.Equal(t, "A", XLSXColumnName(0))
+	assert.Equal(t, "Z", XLSXColumnName(25))
+	assert.Equal(t, "AA", XLSXColumnName(26))
+	assert.Equal(t, "AB", XLSXColumnName(27))
+	assert.Equal(t, 27, xlsxColumnIndex("AB12"))
+	assert.Equal(t, 0, xlsxColumnIndex("A1"))
+}
+
+func TestXLSXWriteAndRead(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewXLSXWriter(&buf, "users")
+	require.NoError(t, err)
+	require.NoError(t, w.WriteRow([]any{"name", "age", "active", "note"}))
+	require.NoError(t, w.WriteRow([]any{"alice", 30, true, "a < b & c"}))
+	require.NoError(t, w.WriteRow([]any{"bob", 1.5, false, nil}))
+	require.NoError(t, w.Close())
+
+	r, err := NewXLSXReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	defer r.Close()
+
+	rows := [][]string{}
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		rows = append(rows, row)
+	}
+	assert.Equal(t, [][]string{
+		{"name", "age", "active", "note"},
+		{"alice", "30", "true", "a < b & c"},
+		{"bob", "1.5", "false"},
+	}, rows)
+}
+
+func TestXLSXReadSharedStrings(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	f, _ := zw.Create("xl/sharedStrings.xml")
+	io.WriteString(f, `<sst><si><t>name</t></si><si><r><t>hel</t></r><r><t>lo</t></r></si></sst>`)
+	f, _ = zw.Create("xl/worksheets/sheet1.xml")
+	io.WriteString(f, `<worksheet><sheetData><row r="1"><c r="A1" t="s"><v>0</v></c><c r="C1"><v>7</v></c></row><row r="2"><c r="B2" t="s"><v>1</v></c></row></sheetData></worksheet>`)
+	zw.Close()
+
+	r, err := NewXLSXReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	row, err := r.Read()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"name", "", "7"}, row)
+	row, err = r.Read()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"", "hello"}, row)
+	_, err = r.Read()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestXLSXSerialToTime(t *testing.T) {
+	assert.Equal(t, time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC), XLSXSerialToTime(45292.5))
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -32,10 +33,11 @@ type RuleFunc func(value interface{}, params map[string]interface{}) error
 
 // ValidationError represents a validation error
 type ValidationError struct {
-	Field   string
-	Rule    string
-	Message string
-	Value   interface{}
+	Field   string      `json:"field"`
+	Rule    string      `json:"rule"`
+	Param   string      `json:"param,omitempty"` // such as "64" of "maxlen:64"
+	Message string      `json:"message"`
+	Value   interface{} `json:"-"`
 }
 
 func (e *ValidationError) Error() string {
@@ -90,8 +92,6 @@ func (v *Validator) RegisterCustomRule(name string, ruleFunc RuleFunc) {
 
 // Validate validates a struct
 func (v *Validator) Validate(data interface{}, locale i18n.Locale) ValidationErrors {
-	var errors ValidationErrors
-
 	val := reflect.ValueOf(data)
 	if val.Kind() == reflect.Ptr {
 		val = val.Elem()
@@ -107,15 +107,27 @@ func (v *Validator) Validate(data interface{}, locale i18n.Locale) ValidationErr
 		}
 	}
 
+	return v.validateStruct(val, locale)
+}
+
+func (v *Validator) validateStruct(val reflect.Value, locale i18n.Locale) ValidationErrors {
+	var errors ValidationErrors
+
 	typ := val.Type()
 	for i := 0; i < val.NumField(); i++ {
 		field := typ.Field(i)
 		fieldValue := val.Field(i)
 
+		// Validate the fields of embedded structs as their own
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			errors = append(errors, v.validateStruct(fieldValue, locale)...)
+			continue
+		}
+
 		// Get field name from tag or use struct field name
-		fieldName := field.Tag.Get("json")
+		fieldName := strings.Split(field.Tag.Get("json"), ",")[0]
 		if fieldName == "" {
-			fieldName = field.Tag.Get("form")
+			fieldName = strings.Split(field.Tag.Get("form"), ",")[0]
 		}
 		if fieldName == "" {
 			fieldName = strings.ToLower(field.Name)
@@ -148,6 +160,39 @@ func (v *Validator) Validate(data interface{}, locale i18n.Locale) ValidationErr
 	return errors
 }
 
+// ValidateMap validates the values by their keys, such as a decoded JSON
+// body, rules maps the keys to the rules in the tag syntax:
+// {"name": "required,maxlen:64", "email": "email"}
+// Only "required" checks the missing or empty values, the other rules skip them.
+// With partial, only the keys present in values are validated, as the edits.
+func (v *Validator) ValidateMap(values map[string]interface{}, rules map[string]string, partial bool, locale i18n.Locale) ValidationErrors {
+	var errors ValidationErrors
+
+	fields := make([]string, 0, len(rules))
+	for field := range rules {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		value, ok := values[field]
+		if !ok && partial {
+			continue
+		}
+		empty := validateRequired(value, nil) != nil
+		for _, rule := range v.parseTagRules(rules[field]) {
+			if empty && rule.Name != "required" {
+				continue
+			}
+			if err := v.validateField(field, value, rule, locale); err != nil {
+				errors = append(errors, err)
+			}
+		}
+	}
+
+	return errors
+}
+
 // ValidateField validates a single field
 func (v *Validator) ValidateField(field string, value interface{}, rules []Rule, locale i18n.Locale) ValidationErrors {
 	var errors ValidationErrors
@@ -170,6 +215,7 @@ func (v *Validator) validateField(field string, value interface{}, rule Rule, lo
 			return &ValidationError{
 				Field:   field,
 				Rule:    rule.Name,
+				Param:   ruleParam(rule),
 				Message: message,
 				Value:   value,
 			}
@@ -184,6 +230,7 @@ func (v *Validator) validateField(field string, value interface{}, rule Rule, lo
 			return &ValidationError{
 				Field:   field,
 				Rule:    rule.Name,
+				Param:   ruleParam(rule),
 				Message: message,
 				Value:   value,
 			}
@@ -208,11 +255,14 @@ func (v *Validator) getErrorMessage(field string, rule Rule, locale i18n.Locale,
 			return fmt.Sprintf(message, value)
 		}
 
-		// Try generic rule message
+		// Try generic rule message, such as "Field '%s' must be at most %v characters"
 		key = fmt.Sprintf("validation.rule.%s", rule.Name)
 		message = v.i18n.GetTranslation(locale, key)
 		if message != key {
-			return fmt.Sprintf(message, field, value)
+			if param := ruleParam(rule); param != "" {
+				return fmt.Sprintf(message, field, param)
+			}
+			return fmt.Sprintf(message, field)
 		}
 	}
 
@@ -220,6 +270,14 @@ func (v *Validator) getErrorMessage(field string, rule Rule, locale i18n.Locale,
 	return fmt.Sprintf("validation failed for field '%s' with rule '%s'", field, rule.Name)
 }
 
+// ruleParam returns the parameter of the rule, such as "64" of "maxlen:64"
+func ruleParam(rule Rule) string {
+	if param, ok := rule.Params["value"]; ok {
+		return fmt.Sprint(param)
+	}
+	return ""
+}
+
 // parseTagRules parses validation tag
 func (v *Validator) parseTagRules(tag string) []Rule {
 	var rules []Rule
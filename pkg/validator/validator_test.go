@@ -290,3 +290,49 @@ func TestValidationErrors(t *testing.T) {
 		t.Error("expected error1 in message")
 	}
 }
+
+func TestValidator_ValidateMap(t *testing.T) {
+	i18nManager := i18n.NewManager(nil)
+	i18nManager.SetTranslation("en", "validation.rule.required", "Field '%s' is required")
+	i18nManager.SetTranslation("en", "validation.rule.maxlen", "Field '%s' must be at most %v characters")
+	validator := NewValidator(i18nManager)
+
+	rules := map[string]string{
+		"name":  "required,maxlen:5",
+		"email": "email",
+		"age":   "min:18",
+	}
+
+	errors := validator.ValidateMap(map[string]interface{}{"name": "too long", "email": ""}, rules, false, "en")
+	assert.Equal(t, ValidationErrors{
+		&ValidationError{Field: "name", Rule: "maxlen", Param: "5", Message: "Field 'name' must be at most 5 characters", Value: "too long"},
+	}, errors)
+
+	errors = validator.ValidateMap(map[string]interface{}{"email": "invalid", "age": float64(16)}, rules, false, "en")
+	assert.Len(t, errors, 3)
+	assert.Equal(t, []string{"age", "email", "name"}, []string{errors[0].Field, errors[1].Field, errors[2].Field})
+	assert.Equal(t, "Field 'name' is required", errors[2].Message)
+
+	// the missing keys are skipped when partial
+	errors = validator.ValidateMap(map[string]interface{}{"age": float64(20)}, rules, true, "en")
+	assert.Empty(t, errors)
+	errors = validator.ValidateMap(map[string]interface{}{"name": nil}, rules, true, "en")
+	assert.Len(t, errors, 1)
+}
+
+func TestValidator_ValidateEmbedded(t *testing.T) {
+	validator := NewValidator(nil)
+
+	type Base struct {
+		Name string `json:"name,omitempty" validate:"required"`
+	}
+	type TestStruct struct {
+		Base
+		Code string `json:"code" validate:"alphanum"`
+	}
+
+	errors := validator.Validate(TestStruct{Code: "a-b"}, "en")
+	assert.Len(t, errors, 2)
+	assert.Equal(t, "name", errors[0].Field)
+	assert.Equal(t, "code", errors[1].Field)
+}
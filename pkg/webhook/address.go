@@ -0,0 +1,67 @@
+package webhook
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrPrivateAddress is an endpoint on a loopback, link-local or private address.
+var ErrPrivateAddress = errors.New("webhook url on a private address")
+
+var (
+	errInvalidURL = errors.New("invalid webhook url")
+	sharedAddress = netip.MustParsePrefix("100.64.0.0/10") // carrier-grade NAT
+)
+
+// publicTransport return a transport connecting to the public addresses
+// only. The address is checked once resolved, when connecting, so a name
+// resolved again to another address can't reach the internal services.
+// The proxies of the environment are not used, they would connect instead.
+func publicTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = nil
+	t.DialContext = (&net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, c syscall.RawConn) error {
+			ap, err := netip.ParseAddrPort(address)
+			if err != nil || !isPublic(ap.Addr()) {
+				return ErrPrivateAddress
+			}
+			return nil
+		},
+	}).DialContext
+	return t
+}
+
+func isPublic(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !sharedAddress.Contains(addr)
+}
+
+// checkURL reject the urls other than http and https, and the endpoints
+// known to be private without AllowPrivate. The names are checked when
+// connecting.
+func (d *Dispatcher) checkURL(v string) error {
+	u, err := url.Parse(v)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errInvalidURL
+	}
+	if d.cfg.AllowPrivate {
+		return nil
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrPrivateAddress
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && !isPublic(addr) {
+		return ErrPrivateAddress
+	}
+	return nil
+}
@@ -0,0 +1,462 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	LingEcho "github.com/code-100-precent/LingFramework"
+	"github.com/code-100-precent/LingFramework/pkg/circuitbreaker"
+	"github.com/code-100-precent/LingFramework/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Config of the Dispatcher, zero values are replaced by the defaults.
+type Config struct {
+	// Client sending the requests, the default one refuses to connect to the
+	// loopback, link-local and private addresses unless AllowPrivate
+	Client *http.Client
+	// AllowPrivate accepts the endpoints on the loopback, link-local and
+	// private addresses, such as the internal services (default: false)
+	AllowPrivate bool
+	// Retry of a delivery round, the 5xx, 408, 429 and network errors are retried
+	Retry *circuitbreaker.RetryConfig
+	// Breaker return the config of the circuit breaker of an endpoint
+	Breaker func(url string) *circuitbreaker.Config
+	// MaxRounds is the number of rounds before a delivery is failed (default: 8)
+	MaxRounds int
+	// Backoff is the delay before the second round, doubled each round (default: 1m)
+	Backoff time.Duration
+	// MaxBackoff is the max delay between the rounds (default: 6h)
+	MaxBackoff time.Duration
+	// PollInterval is how often the queue is checked by Start, and how long the
+	// enabled subscriptions are cached by Wants (default: 5s)
+	PollInterval time.Duration
+	// BatchSize is the max deliveries sent by a poll (default: 100)
+	BatchSize int
+}
+
+// DefaultConfig returns a default configuration
+func DefaultConfig() *Config {
+	return &Config{
+		Client: &http.Client{Timeout: 10 * time.Second, Transport: publicTransport()},
+		Retry: circuitbreaker.DefaultRetryConfig().
+			WithInitialInterval(500 * time.Millisecond).
+			WithMaxInterval(5 * time.Second),
+		Breaker: func(url string) *circuitbreaker.Config {
+			return circuitbreaker.DefaultConfig("webhook " + url)
+		},
+		MaxRounds:    8,
+		Backoff:      time.Minute,
+		MaxBackoff:   6 * time.Hour,
+		PollInterval: 5 * time.Second,
+		BatchSize:    100,
+	}
+}
+
+// Payload is the body of the webhook requests.
+type Payload struct {
+	Event     string    `json:"event"` // {object}.{action}
+	Object    string    `json:"object"`
+	Action    string    `json:"action"`
+	Key       string    `json:"key"` // primary values joined as in the url path
+	Tenant    string    `json:"tenant,omitempty"`
+	Data      any       `json:"data,omitempty"`
+	Previous  any       `json:"previous,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// StatusError is the unexpected response status of an endpoint.
+type StatusError struct {
+	Code int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status: %d", e.Code)
+}
+
+// Temporary return true if the request can be retried at once.
+func (e *StatusError) Temporary() bool {
+	return e.Code >= 500 || e.Code == http.StatusRequestTimeout || e.Code == http.StatusTooManyRequests
+}
+
+// Dispatcher queues the WebObject events of the subscriptions in the database
+// and sends them to the endpoints.
+type Dispatcher struct {
+	db       *gorm.DB
+	cfg      *Config
+	retry    *circuitbreaker.RetryConfig
+	mu       sync.Mutex
+	breakers map[string]*circuitbreaker.CircuitBreaker // by url
+
+	subsMu     sync.Mutex
+	subs       []Subscription // enabled, cached for Wants
+	subsLoaded time.Time
+}
+
+func NewDispatcher(db *gorm.DB, cfg *Config) *Dispatcher {
+	def := DefaultConfig()
+	if cfg == nil {
+		cfg = def
+	}
+	if cfg.Client == nil && cfg.AllowPrivate {
+		cfg.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if cfg.Client == nil {
+		cfg.Client = def.Client
+	}
+	if cfg.Retry == nil {
+		cfg.Retry = def.Retry
+	}
+	if cfg.Breaker == nil {
+		cfg.Breaker = def.Breaker
+	}
+	if cfg.MaxRounds <= 0 {
+		cfg.MaxRounds = def.MaxRounds
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = def.Backoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = def.MaxBackoff
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = def.PollInterval
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = def.BatchSize
+	}
+
+	retry := *cfg.Retry
+	retry.RetryableErrors = func(err error) bool {
+		var se *StatusError
+		if errors.As(err, &se) {
+			return se.Temporary()
+		}
+		return !errors.Is(err, circuitbreaker.ErrCircuitOpen) && !errors.Is(err, ErrPrivateAddress)
+	}
+	return &Dispatcher{
+		db:       db,
+		cfg:      cfg,
+		retry:    &retry,
+		breakers: map[string]*circuitbreaker.CircuitBreaker{},
+	}
+}
+
+// Wants return true if an enabled subscription may match the events of the
+// object, the changes of the webhook tables are never delivered. The
+// subscriptions are cached for PollInterval, the changes made on the other
+// nodes are seen after it.
+func (d *Dispatcher) Wants(object, table string) bool {
+	if isWebhookTable(table) {
+		return false
+	}
+	subs, err := d.enabledSubscriptions()
+	if err != nil {
+		// Enqueue reports the error in the transaction of the change
+		return true
+	}
+	for _, sub := range subs {
+		if sub.MatchObject(object) {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Dispatcher) enabledSubscriptions() ([]Subscription, error) {
+	d.subsMu.Lock()
+	defer d.subsMu.Unlock()
+	if !d.subsLoaded.IsZero() && time.Since(d.subsLoaded) < d.cfg.PollInterval {
+		return d.subs, nil
+	}
+	var subs []Subscription
+	if err := d.db.Where("enabled", true).Find(&subs).Error; err != nil {
+		return nil, err
+	}
+	d.subs, d.subsLoaded = subs, time.Now()
+	return subs, nil
+}
+
+// resetSubscriptions drops the cache of Wants, on the changes of the subscriptions.
+func (d *Dispatcher) resetSubscriptions() {
+	d.subsMu.Lock()
+	defer d.subsMu.Unlock()
+	d.subs, d.subsLoaded = nil, time.Time{}
+}
+
+func isWebhookTable(table string) bool {
+	return table == (Subscription{}).TableName() || table == (Delivery{}).TableName() || table == (DeliveryAttempt{}).TableName()
+}
+
+// Enqueue add a delivery of e for every enabled subscription of the event, it's
+// a LingEcho.ObjectEventFunc, so the deliveries are committed with the change:
+//
+//	LingEcho.AddObjectListenerFor(dispatcher.Wants, dispatcher.Enqueue)
+//
+// The changes of the webhook tables are skipped, they would disclose the secrets.
+func (d *Dispatcher) Enqueue(db *gorm.DB, c *gin.Context, e *LingEcho.ObjectEvent) error {
+	if isWebhookTable(e.Table) {
+		return nil
+	}
+	payload := Payload{
+		Event:     e.Object + "." + e.Action,
+		Object:    e.Object,
+		Action:    e.Action,
+		Key:       e.RecordKey,
+		Tenant:    e.Tenant,
+		Data:      e.After,
+		Previous:  e.Before,
+		Timestamp: time.Now(),
+	}
+	return d.EnqueuePayload(db, &payload)
+}
+
+// EnqueuePayload add a delivery of payload for every enabled subscription of payload.Event.
+func (d *Dispatcher) EnqueuePayload(db *gorm.DB, payload *Payload) error {
+	var subs []Subscription
+	if err := db.Where("enabled", true).Find(&subs).Error; err != nil {
+		return err
+	}
+	var deliveries []Delivery
+	var body []byte
+	for _, sub := range subs {
+		if !sub.Match(payload.Event) {
+			continue
+		}
+		if body == nil {
+			var err error
+			if body, err = json.Marshal(payload); err != nil {
+				return err
+			}
+		}
+		deliveries = append(deliveries, Delivery{
+			SubscriptionID: sub.ID,
+			Event:          payload.Event,
+			Payload:        string(body),
+			Status:         StatusPending,
+			NextAttemptAt:  time.Now(),
+		})
+	}
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return db.Create(&deliveries).Error
+}
+
+// Start sends the queued deliveries every PollInterval until ctx is done.
+func (d *Dispatcher) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(d.cfg.PollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := d.DeliverPending(ctx); err != nil {
+					logger.Warn("webhook delivery failed", zap.Error(err))
+				}
+			}
+		}
+	}()
+}
+
+// DeliverPending sends the due deliveries, and return the number of them sent successfully.
+func (d *Dispatcher) DeliverPending(ctx context.Context) (int, error) {
+	var deliveries []Delivery
+	err := d.db.Where("status", StatusPending).Where("next_attempt_at <= ?", time.Now()).
+		Order("id").Limit(d.cfg.BatchSize).Find(&deliveries).Error
+	if err != nil {
+		return 0, err
+	}
+
+	subs := map[uint]*Subscription{}
+	sent := 0
+	for i := range deliveries {
+		if ctx.Err() != nil {
+			return sent, ctx.Err()
+		}
+		dl := &deliveries[i]
+		if !d.claim(dl) {
+			continue // taken by another node
+		}
+
+		sub, ok := subs[dl.SubscriptionID]
+		if !ok {
+			sub = &Subscription{}
+			if err := d.db.Take(sub, dl.SubscriptionID).Error; err != nil {
+				sub = nil
+			}
+			subs[dl.SubscriptionID] = sub
+		}
+		if sub == nil || !sub.Enabled {
+			d.finish(dl, StatusFailed, 0, "subscription not found or disabled")
+			continue
+		}
+		if d.deliver(ctx, dl, sub) {
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// claim lease the delivery for the sending, so the other nodes skip it.
+func (d *Dispatcher) claim(dl *Delivery) bool {
+	lease := time.Now().Add(d.cfg.Client.Timeout*time.Duration(d.cfg.Retry.MaxAttempts+1) + d.cfg.Retry.MaxInterval*time.Duration(d.cfg.Retry.MaxAttempts))
+	r := d.db.Model(&Delivery{}).Where("id", dl.ID).Where("status", StatusPending).
+		Where("next_attempt_at <= ?", time.Now()).Update("next_attempt_at", lease)
+	if r.Error != nil || r.RowsAffected == 0 {
+		return false
+	}
+	dl.NextAttemptAt = lease
+	return true
+}
+
+// deliver runs a round of the delivery, return true if it succeeded.
+func (d *Dispatcher) deliver(ctx context.Context, dl *Delivery, sub *Subscription) bool {
+	breaker := d.Breaker(sub.URL)
+	status, sent := 0, false
+	err := circuitbreaker.Retry(func() error {
+		return breaker.Execute(func() error {
+			var err error
+			status, err = d.send(ctx, dl, sub)
+			sent = true
+			return err
+		})
+	}, d.retry)
+
+	if err == nil {
+		d.finish(dl, StatusSuccess, status, "")
+		return true
+	}
+	if !sent {
+		// the circuit is open, not counted as a round
+		d.db.Model(dl).Updates(map[string]any{
+			"next_attempt_at": time.Now().Add(d.cfg.Backoff),
+			"last_error":      err.Error(),
+		})
+		return false
+	}
+
+	dl.Attempts++
+	if dl.Attempts >= d.cfg.MaxRounds {
+		d.finish(dl, StatusFailed, status, err.Error())
+		return false
+	}
+	d.db.Model(dl).Updates(map[string]any{
+		"attempts":        dl.Attempts,
+		"next_attempt_at": time.Now().Add(d.backoff(dl.Attempts)),
+		"response_status": status,
+		"last_error":      err.Error(),
+	})
+	return false
+}
+
+func (d *Dispatcher) finish(dl *Delivery, status string, code int, lastError string) {
+	vals := map[string]any{
+		"status":          status,
+		"response_status": code,
+		"last_error":      lastError,
+	}
+	if status == StatusSuccess {
+		vals["delivered_at"] = time.Now()
+	} else {
+		vals["attempts"] = dl.Attempts
+	}
+	if err := d.db.Model(dl).Updates(vals).Error; err != nil {
+		logger.Warn("update webhook delivery failed", zap.Uint("id", dl.ID), zap.Error(err))
+	}
+}
+
+// backoff return the delay after the round, doubled each round.
+func (d *Dispatcher) backoff(round int) time.Duration {
+	delay := d.cfg.Backoff
+	for i := 1; i < round && delay < d.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > d.cfg.MaxBackoff {
+		delay = d.cfg.MaxBackoff
+	}
+	return delay
+}
+
+// send post the payload to the endpoint and log the attempt.
+func (d *Dispatcher) send(ctx context.Context, dl *Delivery, sub *Subscription) (int, error) {
+	body := []byte(dl.Payload)
+	ts := time.Now().Unix()
+	start := time.Now()
+
+	attempt := DeliveryAttempt{DeliveryID: dl.ID}
+	err := func() error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "LingFramework-Webhook")
+		req.Header.Set(HeaderEvent, dl.Event)
+		req.Header.Set(HeaderDelivery, strconv.FormatUint(uint64(dl.ID), 10))
+		req.Header.Set(HeaderTimestamp, strconv.FormatInt(ts, 10))
+		req.Header.Set(HeaderSignature, "sha256="+Sign(sub.Secret, ts, body))
+
+		resp, err := d.cfg.Client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		attempt.StatusCode = resp.StatusCode
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return &StatusError{Code: resp.StatusCode}
+		}
+		return nil
+	}()
+
+	attempt.Duration = time.Since(start).Milliseconds()
+	if err != nil {
+		attempt.Error = err.Error()
+	}
+	if e := d.db.Create(&attempt).Error; e != nil {
+		logger.Warn("log webhook attempt failed", zap.Uint("delivery", dl.ID), zap.Error(e))
+	}
+	return attempt.StatusCode, err
+}
+
+// Breaker return the circuit breaker of the endpoint.
+func (d *Dispatcher) Breaker(url string) *circuitbreaker.CircuitBreaker {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	cb, ok := d.breakers[url]
+	if !ok {
+		cb = circuitbreaker.New(d.cfg.Breaker(url))
+		d.breakers[url] = cb
+	}
+	return cb
+}
+
+// Redeliver queue the delivery again, as a new delivery with all the rounds.
+func (d *Dispatcher) Redeliver(id uint) error {
+	r := d.db.Model(&Delivery{}).Where("id", id).Updates(map[string]any{
+		"status":          StatusPending,
+		"attempts":        0,
+		"next_attempt_at": time.Now(),
+		"last_error":      "",
+	})
+	if r.Error != nil {
+		return r.Error
+	}
+	if r.RowsAffected == 0 {
+		return errors.New("not found")
+	}
+	return nil
+}
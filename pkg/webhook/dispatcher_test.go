@@ -0,0 +1,255 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	LingEcho "github.com/code-100-precent/LingFramework"
+	"github.com/code-100-precent/LingFramework/pkg/circuitbreaker"
+	"github.com/code-100-precent/LingFramework/pkg/constants"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type testMember struct {
+	ID   uint   `json:"id" gorm:"primaryKey"`
+	Name string `json:"name"`
+}
+
+func newTestDispatcher(t *testing.T) (*gin.Engine, *gorm.DB, *Dispatcher) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(append(Models(), &testMember{})...))
+
+	d := NewDispatcher(db, &Config{
+		Retry:   circuitbreaker.DefaultRetryConfig().WithInitialInterval(time.Millisecond).WithMaxInterval(time.Millisecond),
+		Backoff: time.Millisecond,
+		Breaker: func(url string) *circuitbreaker.Config {
+			cfg := circuitbreaker.DefaultConfig(url)
+			cfg.MaxFailures = 6
+			return cfg
+		},
+		MaxRounds:    2,
+		AllowPrivate: true, // the endpoints of the tests are on the loopback
+	})
+	LingEcho.AddObjectListenerFor(d.Wants, d.Enqueue)
+	t.Cleanup(func() { LingEcho.ObjectListeners = nil })
+
+	r := gin.New()
+	g := r.Group("/api")
+	g.Use(func(c *gin.Context) {
+		c.Set(constants.DbField, db)
+		c.Next()
+	})
+	objs := append(d.WebObjects(), LingEcho.WebObject{Model: testMember{}, Editables: []string{"Name"}})
+	LingEcho.RegisterObjects(g, objs)
+	d.RegisterRoutes(g)
+	return r, db, d
+}
+
+func doRequest(r *gin.Engine, method, path string, body any) map[string]any {
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var result map[string]any
+	json.Unmarshal(w.Body.Bytes(), &result)
+	return result
+}
+
+func TestDispatcher(t *testing.T) {
+	var received []*http.Request
+	var bodies [][]byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		received = append(received, req)
+		bodies = append(bodies, body)
+	}))
+	defer srv.Close()
+
+	r, db, d := newTestDispatcher(t)
+	res := doRequest(r, http.MethodPut, "/api/webhooks", map[string]any{"name": "crm", "url": "ftp://example.org", "events": "*"})
+	assert.Equal(t, "invalid webhook url", res["msg"])
+	res = doRequest(r, http.MethodPut, "/api/webhooks", map[string]any{"name": "crm", "url": srv.URL, "events": "testmember.*", "enabled": true})
+	require.Equal(t, float64(200), res["code"], res["msg"])
+	secret := res["data"].(map[string]any)["secret"].(string)
+	assert.NotEmpty(t, secret)
+	// the secret is given by the creation only
+	res = doRequest(r, http.MethodGet, "/api/webhooks/1", nil)
+	assert.NotContains(t, res["data"], "secret")
+	res = doRequest(r, http.MethodPost, "/api/webhooks", map[string]any{})
+	assert.NotContains(t, res["data"].(map[string]any)["items"].([]any)[0], "secret")
+
+	doRequest(r, http.MethodPut, "/api/testmember", map[string]any{"name": "alice"})
+	doRequest(r, http.MethodPatch, "/api/testmember/1", map[string]any{"name": "bob"})
+	doRequest(r, http.MethodDelete, "/api/testmember/1", nil)
+
+	var count int64
+	db.Model(&Delivery{}).Count(&count)
+	assert.Equal(t, int64(3), count)
+
+	sent, err := d.DeliverPending(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 3, sent)
+	require.Len(t, received, 3)
+	assert.Equal(t, "testmember.create", received[0].Header.Get(HeaderEvent))
+	assert.Equal(t, "1", received[0].Header.Get(HeaderDelivery))
+	assert.NoError(t, Verify(secret, received[0].Header.Get(HeaderTimestamp), received[0].Header.Get(HeaderSignature), bodies[0], time.Minute))
+
+	var payload map[string]any
+	require.NoError(t, json.Unmarshal(bodies[1], &payload))
+	assert.Equal(t, "edit", payload["action"])
+	assert.Equal(t, "1", payload["key"])
+	assert.Equal(t, "bob", payload["data"].(map[string]any)["name"])
+	assert.Equal(t, "alice", payload["previous"].(map[string]any)["name"])
+
+	var dl Delivery
+	require.NoError(t, db.First(&dl, 3).Error)
+	assert.Equal(t, StatusSuccess, dl.Status)
+	assert.Equal(t, http.StatusOK, dl.ResponseStatus)
+	assert.NotNil(t, dl.DeliveredAt)
+
+	// the delivery log
+	res = doRequest(r, http.MethodPost, "/api/webhook_attempts", map[string]any{
+		"filters": []any{map[string]any{"name": "deliveryId", "op": "=", "value": 2}},
+	})
+	assert.Equal(t, float64(1), res["data"].(map[string]any)["total"])
+
+	// nothing due
+	sent, err = d.DeliverPending(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, sent)
+}
+
+func TestDispatcherRetry(t *testing.T) {
+	var calls atomic.Int32
+	status := http.StatusInternalServerError
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	r, db, d := newTestDispatcher(t)
+	require.NoError(t, db.Create(&Subscription{URL: srv.URL, Events: "*", Enabled: true, Secret: "s"}).Error)
+	doRequest(r, http.MethodPut, "/api/testmember", map[string]any{"name": "alice"})
+
+	// a round retries 3 times
+	sent, err := d.DeliverPending(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, sent)
+	assert.Equal(t, int32(3), calls.Load())
+	var dl Delivery
+	require.NoError(t, db.First(&dl, 1).Error)
+	assert.Equal(t, StatusPending, dl.Status)
+	assert.Equal(t, 1, dl.Attempts)
+	assert.Equal(t, "unexpected status: 500", dl.LastError)
+
+	// failed after MaxRounds
+	time.Sleep(5 * time.Millisecond)
+	d.DeliverPending(context.Background())
+	require.NoError(t, db.First(&dl, 1).Error)
+	assert.Equal(t, StatusFailed, dl.Status)
+	assert.Equal(t, 2, dl.Attempts)
+	assert.Equal(t, int32(6), calls.Load())
+
+	// the breaker is open after 6 failures, nothing is sent
+	assert.True(t, d.Breaker(srv.URL).IsOpen())
+	res := doRequest(r, http.MethodPost, "/api/webhook_deliveries/1/redeliver", nil)
+	require.Equal(t, float64(200), res["code"], res["msg"])
+	d.DeliverPending(context.Background())
+	assert.Equal(t, int32(6), calls.Load())
+	require.NoError(t, db.First(&dl, 1).Error)
+	assert.Equal(t, StatusPending, dl.Status)
+	assert.Equal(t, 0, dl.Attempts)
+
+	res = doRequest(r, http.MethodGet, "/api/webhooks/breakers", nil)
+	breakers := res["data"].([]any)
+	require.Len(t, breakers, 1)
+	assert.Equal(t, "OPEN", breakers[0].(map[string]any)["state"])
+
+	// client errors are not retried in the round
+	d.Breaker(srv.URL).Reset()
+	status = http.StatusBadRequest
+	time.Sleep(5 * time.Millisecond)
+	d.DeliverPending(context.Background())
+	assert.Equal(t, int32(7), calls.Load())
+}
+
+func TestDispatcherWants(t *testing.T) {
+	r, db, d := newTestDispatcher(t)
+	assert.False(t, d.Wants("testmember", "test_members"))
+
+	// the subscriptions are never delivered, they hold the secrets
+	res := doRequest(r, http.MethodPut, "/api/webhooks", map[string]any{"name": "all", "url": "https://example.org", "events": "*", "enabled": true})
+	require.Equal(t, float64(200), res["code"], res["msg"])
+	res = doRequest(r, http.MethodPatch, "/api/webhooks/1", map[string]any{"name": "everything"})
+	require.Equal(t, float64(200), res["code"], res["msg"])
+	var count int64
+	db.Model(&Delivery{}).Count(&count)
+	assert.Equal(t, int64(0), count)
+	assert.False(t, d.Wants("webhooks", "webhook_subscriptions"))
+	assert.False(t, d.Wants("webhook_deliveries", "webhook_deliveries"))
+
+	// the cache is dropped by the changes of the subscriptions
+	assert.True(t, d.Wants("testmember", "test_members"))
+	res = doRequest(r, http.MethodPatch, "/api/webhooks/1", map[string]any{"events": "user.create"})
+	require.Equal(t, float64(200), res["code"], res["msg"])
+	assert.False(t, d.Wants("testmember", "test_members"))
+	assert.True(t, d.Wants("user", "users"))
+
+	// not wanted, no transaction nor delivery
+	doRequest(r, http.MethodPut, "/api/testmember", map[string]any{"name": "alice"})
+	db.Model(&Delivery{}).Count(&count)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestDispatcherPrivateAddress(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+	defer srv.Close()
+	d := NewDispatcher(nil, nil)
+
+	for _, u := range []string{
+		"http://127.0.0.1/hook",
+		"http://localhost:8080/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://10.0.0.1/hook",
+		"https://192.168.1.1/hook",
+		"http://[::1]/hook",
+		"http://[fd00::1]/hook",
+		"http://[::ffff:127.0.0.1]/hook",
+	} {
+		assert.ErrorIs(t, d.checkURL(u), ErrPrivateAddress, u)
+	}
+	assert.NoError(t, d.checkURL("https://example.org/hook"))
+	assert.NoError(t, d.checkURL("http://93.184.216.34/hook"))
+
+	// the names are checked once resolved, when connecting
+	_, err := d.cfg.Client.Get(strings.Replace(srv.URL, "127.0.0.1", "localhost", 1))
+	assert.ErrorIs(t, err, ErrPrivateAddress)
+	_, err = d.cfg.Client.Get(srv.URL)
+	assert.ErrorIs(t, err, ErrPrivateAddress)
+
+	private := NewDispatcher(nil, &Config{AllowPrivate: true})
+	assert.NoError(t, private.checkURL(srv.URL))
+	resp, err := private.cfg.Client.Get(srv.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+}
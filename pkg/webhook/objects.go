@@ -0,0 +1,123 @@
+package webhook
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"strconv"
+
+	LingEcho "github.com/code-100-precent/LingFramework"
+	"github.com/code-100-precent/LingFramework/pkg/circuitbreaker"
+	"github.com/code-100-precent/LingFramework/pkg/utils/response"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// BreakerState is the circuit breaker of an endpoint.
+type BreakerState struct {
+	URL    string                `json:"url"`
+	State  string                `json:"state"`
+	Counts circuitbreaker.Counts `json:"counts"`
+}
+
+// WebObjects return the objects to manage the subscriptions and to read the delivery log,
+// they should be registered for the staff only.
+func (d *Dispatcher) WebObjects() []LingEcho.WebObject {
+	return []LingEcho.WebObject{
+		{
+			Group:        "webhook",
+			Desc:         "Webhook subscription",
+			Model:        Subscription{},
+			Name:         "webhooks",
+			Editables:    []string{"Name", "URL", "Secret", "Events", "Enabled"},
+			Filterables:  []string{"Enabled", "CreatedAt"},
+			Orderables:   []string{"CreatedAt"},
+			Searchables:  []string{"Name", "URL"},
+			AllowMethods: LingEcho.GET | LingEcho.CREATE | LingEcho.EDIT | LingEcho.DELETE | LingEcho.QUERY,
+			BeforeCreate: func(db *gorm.DB, ctx *gin.Context, vptr any) error {
+				sub := vptr.(*Subscription)
+				if err := d.checkURL(sub.URL); err != nil {
+					return err
+				}
+				if sub.Secret == "" {
+					sub.Secret = newSecret()
+				}
+				d.resetSubscriptions()
+				return nil
+			},
+			BeforeUpdate: func(db *gorm.DB, ctx *gin.Context, vptr any, vals map[string]any) error {
+				if v, ok := vals["url"].(string); ok {
+					if err := d.checkURL(v); err != nil {
+						return err
+					}
+				}
+				d.resetSubscriptions()
+				return nil
+			},
+			BeforeDelete: func(db *gorm.DB, ctx *gin.Context, vptr any) error {
+				d.resetSubscriptions()
+				return nil
+			},
+			// the secret is given once, by the creation
+			BeforeRender: func(db *gorm.DB, ctx *gin.Context, vptr any) (any, error) {
+				vptr.(*Subscription).Secret = ""
+				return nil, nil
+			},
+		},
+		{
+			Group:        "webhook",
+			Desc:         "Webhook delivery",
+			Model:        Delivery{},
+			Name:         "webhook_deliveries",
+			Filterables:  []string{"SubscriptionID", "Event", "Status", "CreatedAt"},
+			Orderables:   []string{"ID", "CreatedAt"},
+			AllowMethods: LingEcho.GET | LingEcho.QUERY,
+		},
+		{
+			Group:        "webhook",
+			Desc:         "Webhook delivery attempt",
+			Model:        DeliveryAttempt{},
+			Name:         "webhook_attempts",
+			Filterables:  []string{"DeliveryID", "CreatedAt"},
+			Orderables:   []string{"ID"},
+			AllowMethods: LingEcho.GET | LingEcho.QUERY,
+		},
+	}
+}
+
+// RegisterRoutes registers the redelivery and the state of the circuit breakers,
+// beside the WebObjects.
+func (d *Dispatcher) RegisterRoutes(r *gin.RouterGroup) {
+	r.POST("/webhook_deliveries/:id/redeliver", d.handleRedeliver)
+	r.GET("/webhooks/breakers", d.handleBreakers)
+}
+
+func (d *Dispatcher) handleRedeliver(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.Fail(c, "invalid id", nil)
+		return
+	}
+	if err := d.Redeliver(uint(id)); err != nil {
+		response.Fail(c, err.Error(), nil)
+		return
+	}
+	response.Success(c, "redelivery queued", true)
+}
+
+func (d *Dispatcher) handleBreakers(c *gin.Context) {
+	d.mu.Lock()
+	states := make([]BreakerState, 0, len(d.breakers))
+	for u, cb := range d.breakers {
+		states = append(states, BreakerState{URL: u, State: cb.GetState(), Counts: cb.Counts()})
+	}
+	d.mu.Unlock()
+	sort.Slice(states, func(i, j int) bool { return states[i].URL < states[j].URL })
+	response.Success(c, "success", states)
+}
+
+func newSecret() string {
+	buf := make([]byte, 24)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,138 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Status of the deliveries
+const (
+	StatusPending = "pending"
+	StatusSuccess = "success"
+	StatusFailed  = "failed"
+)
+
+// Headers of the webhook requests
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// Subscription is an endpoint receiving the events.
+// Events is a comma separated list of "{object}.{action}" patterns, either part can be "*",
+// such as "user.*,*.delete"
+type Subscription struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Name      string    `json:"name" gorm:"size:128"`
+	URL       string    `json:"url" gorm:"size:512"`
+	Secret    string    `json:"secret,omitempty" gorm:"size:128"` // rendered only when created
+	Events    string    `json:"events" gorm:"size:512"`
+	Enabled   bool      `json:"enabled" gorm:"index"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func (Subscription) TableName() string {
+	return "webhook_subscriptions"
+}
+
+// Match return true if the event, such as "user.create", is subscribed.
+func (s *Subscription) Match(event string) bool {
+	object, action, _ := strings.Cut(event, ".")
+	return s.match(object, action, false)
+}
+
+// MatchObject return true if any event of the object is subscribed.
+func (s *Subscription) MatchObject(object string) bool {
+	return s.match(object, "", true)
+}
+
+func (s *Subscription) match(object, action string, anyAction bool) bool {
+	for _, p := range strings.Split(s.Events, ",") {
+		p = strings.TrimSpace(p)
+		if p == "*" {
+			return true
+		}
+		po, pa, ok := strings.Cut(p, ".")
+		if ok && (po == "*" || po == object) && (pa == "*" || anyAction || pa == action) {
+			return true
+		}
+	}
+	return false
+}
+
+// Delivery is an event queued for a subscription, it's kept as the delivery log
+// after it succeeded or failed.
+type Delivery struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	SubscriptionID uint       `json:"subscriptionId" gorm:"index"`
+	Event          string     `json:"event" gorm:"size:128"`
+	Payload        string     `json:"payload" gorm:"type:text"`
+	Status         string     `json:"status" gorm:"size:16;index:idx_webhook_delivery_due"`
+	Attempts       int        `json:"attempts"` // rounds of delivery, each round retries a few times
+	NextAttemptAt  time.Time  `json:"nextAttemptAt" gorm:"index:idx_webhook_delivery_due"`
+	ResponseStatus int        `json:"responseStatus,omitempty"`
+	LastError      string     `json:"lastError,omitempty" gorm:"type:text"`
+	DeliveredAt    *time.Time `json:"deliveredAt,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
+}
+
+func (Delivery) TableName() string {
+	return "webhook_deliveries"
+}
+
+// DeliveryAttempt is a request sent for a delivery.
+type DeliveryAttempt struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	DeliveryID uint      `json:"deliveryId" gorm:"index"`
+	StatusCode int       `json:"statusCode,omitempty"`
+	Error      string    `json:"error,omitempty" gorm:"type:text"`
+	Duration   int64     `json:"duration"` // milliseconds
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+func (DeliveryAttempt) TableName() string {
+	return "webhook_delivery_attempts"
+}
+
+// Models return the models to migrate.
+func Models() []any {
+	return []any{&Subscription{}, &Delivery{}, &DeliveryAttempt{}}
+}
+
+// Sign return the HMAC-SHA256 of "{timestamp}.{body}" with secret, as the
+// X-Webhook-Signature header without the "sha256=" prefix.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify check the headers of a webhook request, for the receivers.
+// The timestamp must be within tolerance from now, if tolerance > 0.
+func Verify(secret, timestamp, signature string, body []byte, tolerance time.Duration) error {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp: %s", timestamp)
+	}
+	if tolerance > 0 {
+		if d := time.Since(time.Unix(ts, 0)); d > tolerance || d < -tolerance {
+			return fmt.Errorf("timestamp out of tolerance: %s", timestamp)
+		}
+	}
+	expected := Sign(secret, ts, body)
+	if !hmac.Equal([]byte(expected), []byte(strings.TrimPrefix(signature, "sha256="))) {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package webhook
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscriptionMatch(t *testing.T) {
+	sub := Subscription{Events: "user.*, *.delete"}
+	assert.True(t, sub.Match("user.create"))
+	assert.True(t, sub.Match("order.delete"))
+	assert.False(t, sub.Match("order.edit"))
+	assert.True(t, sub.MatchObject("user"))
+	assert.True(t, sub.MatchObject("order"))
+	sub.Events = "user.create"
+	assert.False(t, sub.MatchObject("order"))
+
+	sub.Events = "*"
+	assert.True(t, sub.Match("order.edit"))
+	sub.Events = ""
+	assert.False(t, sub.Match("order.edit"))
+}
+
+func TestSignAndVerify(t *testing.T) {
+	body := []byte(`{"event":"user.create"}`)
+	now := time.Now().Unix()
+	sig := "sha256=" + Sign("secret", now, body)
+	ts := strconv.FormatInt(now, 10)
+
+	assert.NoError(t, Verify("secret", ts, sig, body, time.Minute))
+	assert.EqualError(t, Verify("other", ts, sig, body, time.Minute), "signature mismatch")
+	assert.EqualError(t, Verify("secret", ts, sig, []byte(`{}`), time.Minute), "signature mismatch")
+
+	old := strconv.FormatInt(now-3600, 10)
+	assert.Error(t, Verify("secret", old, "sha256="+Sign("secret", now-3600, body), body, time.Minute))
+	assert.NoError(t, Verify("secret", old, "sha256="+Sign("secret", now-3600, body), body, 0))
+}
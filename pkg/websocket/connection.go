@@ -7,6 +7,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 	"github.com/sirupsen/logrus"
 )
@@ -27,6 +28,11 @@ func newUpgrader(cfg *Config) websocket.Upgrader {
 
 // HandleWebSocket handles WebSocket connection
 func HandleWebSocket(hub *Hub, w http.ResponseWriter, r *http.Request, userID string) {
+	serveWebSocket(hub, w, r, userID, nil)
+}
+
+// serveWebSocket upgrades the request, ctx is kept as the Context of the connection
+func serveWebSocket(hub *Hub, w http.ResponseWriter, r *http.Request, userID string, ctx *gin.Context) {
 	// Upgrade HTTP connection to WebSocket
 	upgrader := newUpgrader(hub.config)
 	conn, err := upgrader.Upgrade(w, r, nil)
@@ -55,6 +61,7 @@ func HandleWebSocket(hub *Hub, w http.ResponseWriter, r *http.Request, userID st
 		Status:   ConnectionStatusConnected,
 		Groups:   make(map[string]bool),
 		Metadata: make(map[string]interface{}),
+		Context:  ctx,
 	}
 
 	// Register connection to Hub
@@ -70,8 +70,9 @@ func (h *Handler) HandleWebSocket(c *gin.Context) {
 		return
 	}
 
-	// Handle WebSocket upgrade
-	HandleWebSocket(h.hub, c.Writer, c.Request, userIDStr)
+	// Handle WebSocket upgrade, the copy of the request scopes the published changes.
+	// It's a snapshot of the upgrade, the ChangeFeed checks the user and its tenant again.
+	serveWebSocket(h.hub, c.Writer, c.Request, userIDStr, c.Copy())
 }
 
 // HandleAnonymousWebSocket handles anonymous WebSocket connection (optional)
@@ -0,0 +1,35 @@
+package websocket
+
+import (
+	LingEcho "github.com/code-100-precent/LingFramework"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// ObjectPublisher adapts the Hub to LingEcho.ChangePublisher: the changes of
+// the WebObjects are sent to the connections which joined the group by
+// join_group, each checked against the request it was upgraded from.
+// The connections not upgraded by Handler have no request and are skipped.
+type ObjectPublisher struct {
+	hub *Hub
+}
+
+var _ LingEcho.ChangePublisher = (*ObjectPublisher)(nil)
+
+func NewObjectPublisher(hub *Hub) *ObjectPublisher {
+	return &ObjectPublisher{hub: hub}
+}
+
+func (p *ObjectPublisher) PublishChange(group string, msg *LingEcho.ChangeMessage, allow func(c *gin.Context) bool) {
+	message := &Message{
+		Type:      msg.Type,
+		Data:      msg.Data,
+		Timestamp: msg.Timestamp,
+	}
+	err := p.hub.PublishToGroup(group, message, func(conn *Connection) bool {
+		return conn.Context != nil && allow(conn.Context)
+	})
+	if err != nil {
+		logrus.Errorf("publish change to group %s failed: %v", group, err)
+	}
+}
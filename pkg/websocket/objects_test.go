@@ -0,0 +1,64 @@
+package websocket
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	LingEcho "github.com/code-100-precent/LingFramework"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObjectPublisher(t *testing.T) {
+	hub := setupTestHub()
+	defer hub.Close()
+
+	var conns []*Connection
+	for _, user := range []string{"alice", "bob", "carol"} {
+		conn, wsConn := createTestConnection(t, hub, user)
+		if conn == nil {
+			return
+		}
+		defer wsConn.Close()
+		if user != "carol" {
+			// carol is not upgraded by Handler
+			conn.Context, _ = gin.CreateTestContext(httptest.NewRecorder())
+			conn.Context.Set("user", user)
+		}
+		conn.JoinGroup("objects.user")
+		hub.register <- conn
+		conns = append(conns, conn)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	NewObjectPublisher(hub).PublishChange("objects.user", &LingEcho.ChangeMessage{
+		Type:      LingEcho.ChangeMessageType,
+		Timestamp: 1,
+		Data:      LingEcho.ChangeData{Object: "user", Action: "edit", Key: "1"},
+	}, func(c *gin.Context) bool {
+		return c.GetString("user") == "alice"
+	})
+
+	select {
+	case data := <-conns[0].Send:
+		var msg map[string]any
+		require.NoError(t, json.Unmarshal(data, &msg))
+		assert.Equal(t, LingEcho.ChangeMessageType, msg["type"])
+		assert.Equal(t, "objects.user", msg["group"])
+		assert.Equal(t, map[string]any{"object": "user", "action": "edit", "key": "1"}, msg["data"])
+	case <-time.After(time.Second):
+		t.Fatal("change not delivered")
+	}
+	assert.Len(t, conns[1].Send, 0)
+	assert.Len(t, conns[2].Send, 0)
+
+	// left the group
+	conns[0].LeaveGroup("objects.user")
+	require.NoError(t, hub.PublishToGroup("objects.user", &Message{Type: MessageTypeNotification}, nil))
+	assert.Len(t, conns[0].Send, 0)
+	assert.Len(t, conns[1].Send, 1)
+	assert.Len(t, conns[2].Send, 1)
+}
@@ -8,6 +8,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 	"github.com/sirupsen/logrus"
 )
@@ -35,6 +36,9 @@ type Connection struct {
 	mu       sync.RWMutex
 	Groups   map[string]bool
 	Metadata map[string]interface{}
+	// Context is a copy of the request the connection was upgraded from,
+	// nil if it's not upgraded by Handler
+	Context *gin.Context
 }
 
 // Hub manages all WebSocket connections
@@ -475,6 +479,45 @@ func (h *Hub) BroadcastToGroup(group string, message *Message) error {
 	return nil
 }
 
+// PublishToGroup sends a message to the connections of a group that pass allow.
+// allow is called without holding the Hub locks, it may query the database.
+func (h *Hub) PublishToGroup(group string, message *Message, allow func(conn *Connection) bool) error {
+	if message.Timestamp == 0 {
+		message.Timestamp = time.Now().Unix()
+	}
+	message.Group = group
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	h.mu.RLock()
+	var members []*Connection
+	for connID := range h.groupConnections[group] {
+		if conn, ok := h.connections[connID]; ok && conn.IsAlive {
+			members = append(members, conn)
+		}
+	}
+	h.mu.RUnlock()
+
+	var allowed []*Connection
+	for _, conn := range members {
+		if allow == nil || allow(conn) {
+			allowed = append(allowed, conn)
+		}
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, conn := range allowed {
+		// the Send of the connections unregistered meanwhile is closed
+		if h.connections[conn.ID] == conn && conn.IsAlive {
+			h.trySend(conn, data, func() { logrus.Warnf("group %s connection %s send buffer full", group, conn.ID) })
+		}
+	}
+	return nil
+}
+
 // SendToUser sends a message to a specific user
 func (h *Hub) SendToUser(userID string, message *Message) error {
 	if message.Timestamp == 0 {